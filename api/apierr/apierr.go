@@ -0,0 +1,232 @@
+// Package apierr 定义接口统一的错误码、HTTP 状态码映射以及成功和错误响应格式。
+//
+// 所有错误响应均为 {"success":"no","code":"<错误码>","error":"<错误信息>","details":{...}}，
+// 客户端应根据 code 而不是 error 文本判断错误类型。
+//
+// 所有 JSON 成功响应均为 {"success":"yes", ...}，数据放在顶层的具名字段中（如 "cluster"、"task"），
+// 不直接返回裸对象。Slurm 接口返回的 models.*Response 自带 success 字段，同样符合该格式；
+// 文件内容、下载和 known_hosts 导出等非 JSON 响应不受此约束。
+package apierr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"star-dim/internal/service"
+	"strings"
+)
+
+// Code 稳定的错误码，新增错误码时不要修改已有的值
+type Code string
+
+const (
+	BadRequest       Code = "bad_request"
+	Unauthorized     Code = "unauthorized"
+	SessionExpired   Code = "session_expired"
+	AuthFailed       Code = "auth_failed"
+	PermissionDenied Code = "permission_denied"
+	NotFound         Code = "not_found"
+	PathNotFound     Code = "path_not_found"
+	PathExists       Code = "path_exists"
+	Conflict         Code = "conflict"
+	ChecksumMismatch Code = "checksum_mismatch"
+	TooLarge         Code = "too_large"
+	SlurmRejected    Code = "slurm_rejected"
+	CommandFailed    Code = "command_failed"
+	SSHUnreachable   Code = "ssh_unreachable"
+	Timeout          Code = "timeout"
+	Canceled         Code = "canceled"
+	Internal         Code = "internal_error"
+)
+
+var statuses = map[Code]int{
+	BadRequest:       http.StatusBadRequest,
+	Unauthorized:     http.StatusUnauthorized,
+	SessionExpired:   http.StatusUnauthorized,
+	AuthFailed:       http.StatusUnauthorized,
+	PermissionDenied: http.StatusForbidden,
+	NotFound:         http.StatusNotFound,
+	PathNotFound:     http.StatusNotFound,
+	PathExists:       http.StatusConflict,
+	Conflict:         http.StatusConflict,
+	ChecksumMismatch: 460, // 沿用 tus 校验扩展的 460
+	TooLarge:         http.StatusRequestEntityTooLarge,
+	SlurmRejected:    http.StatusUnprocessableEntity,
+	CommandFailed:    http.StatusUnprocessableEntity,
+	SSHUnreachable:   http.StatusBadGateway,
+	Timeout:          http.StatusGatewayTimeout,
+	Canceled:         499, // 客户端已断开连接，沿用 nginx 的 499
+	Internal:         http.StatusInternalServerError,
+}
+
+// Error 带错误码的接口错误
+type Error struct {
+	Code    Code
+	Message string
+	// Details 附加信息，如执行的命令和输出
+	Details map[string]interface{}
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" && e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status 错误码对应的 HTTP 状态码
+func (e *Error) Status() int {
+	if status, ok := statuses[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// With 添加附加信息
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+	return e
+}
+
+// Response 错误响应内容
+type Response struct {
+	Success string                 `json:"success" example:"no"`
+	Code    Code                   `json:"code" example:"path_not_found"`
+	Error   string                 `json:"error" example:"file does not exist"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Body 错误响应内容
+func (e *Error) Body() Response {
+	return Response{Success: "no", Code: e.Code, Error: e.Error(), Details: e.Details}
+}
+
+// New 创建指定错误码的错误
+func New(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap 使用指定错误码包装底层错误，err 为 nil 时返回 nil
+func Wrap(code Code, err error) *Error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Message: err.Error(), Err: err}
+}
+
+// From 将任意错误转换为接口错误，已是 *Error 的直接返回，
+// 其余根据 SFTP、SSH、slurmrestd 和服务层错误推断错误码，无法识别的视为内部错误
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Code: classify(err), Message: err.Error(), Err: err}
+}
+
+func classify(err error) Code {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	case errors.Is(err, fs.ErrNotExist):
+		return PathNotFound
+	case errors.Is(err, fs.ErrExist):
+		return PathExists
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, service.ErrPermissionDenied):
+		return PermissionDenied
+	case errors.Is(err, service.ErrTokenInvalid), errors.Is(err, service.ErrTokenRevoked):
+		return Unauthorized
+	case errors.Is(err, service.ErrUnsupported), errors.Is(err, service.ErrInvalidChunk), errors.Is(err, service.ErrJobSelectorRequired):
+		return BadRequest
+	case errors.Is(err, service.ErrUploadNotFound), errors.Is(err, service.ErrTaskNotFound):
+		return NotFound
+	case errors.Is(err, service.ErrUploadIncomplete):
+		return Conflict
+	case errors.Is(err, service.ErrChecksumMismatch):
+		return ChecksumMismatch
+	case errors.Is(err, service.ErrHostKeyMismatch), errors.Is(err, service.ErrHostKeyUnknown),
+		errors.Is(err, service.ErrJumpHost), errors.Is(err, service.ErrConnection), errors.Is(err, sftp.ErrSSHFxConnectionLost):
+		return SSHUnreachable
+	}
+	var status *sftp.StatusError
+	if errors.As(err, &status) {
+		switch status.FxCode() {
+		case sftp.ErrSSHFxNoSuchFile:
+			return PathNotFound
+		case sftp.ErrSSHFxPermissionDenied:
+			return PermissionDenied
+		case sftp.ErrSSHFxConnectionLost, sftp.ErrSSHFxNoConnection:
+			return SSHUnreachable
+		}
+		return Internal
+	}
+	var tooLarge *service.ArchiveTooLargeError
+	if errors.As(err, &tooLarge) {
+		return TooLarge
+	}
+	var rest *service.RestError
+	if errors.As(err, &rest) {
+		switch {
+		case rest.Status == http.StatusUnauthorized || rest.Status == http.StatusForbidden:
+			return PermissionDenied
+		case len(rest.Errors) > 0 || rest.Status < http.StatusInternalServerError:
+			return CommandFailed
+		}
+		return SSHUnreachable
+	}
+	var exit *service.ExitError
+	var sshExit *ssh.ExitError
+	if errors.As(err, &exit) || errors.As(err, &sshExit) {
+		return CommandFailed
+	}
+	var missing *ssh.ExitMissingError
+	var netErr net.Error
+	if errors.As(err, &missing) || errors.As(err, &netErr) {
+		return SSHUnreachable
+	}
+	if strings.Contains(err.Error(), "unable to authenticate") {
+		return AuthFailed
+	}
+	return Internal
+}
+
+// Respond 写入统一格式的错误响应并中止后续处理，服务端错误同时记录日志
+func Respond(c *gin.Context, err error) {
+	e := From(err)
+	if e.Status() >= http.StatusInternalServerError {
+		log.Printf("%s %s: %s: %v", c.Request.Method, c.Request.URL.Path, e.Code, e)
+	}
+	c.AbortWithStatusJSON(e.Status(), e.Body())
+}
+
+// OK 写入统一格式的成功响应，fields 与 "success":"yes" 一同放在响应顶层
+func OK(c *gin.Context, status int, fields gin.H) {
+	body := gin.H{}
+	for key, value := range fields {
+		body[key] = value
+	}
+	body["success"] = "yes"
+	c.JSON(status, body)
+}
+
+// Abort 使用指定错误码和信息写入错误响应
+func Abort(c *gin.Context, code Code, format string, args ...interface{}) {
+	Respond(c, New(code, format, args...))
+}
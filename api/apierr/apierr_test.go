@@ -0,0 +1,89 @@
+package apierr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"star-dim/internal/service"
+)
+
+func TestFrom(t *testing.T) {
+	cases := []struct {
+		err    error
+		code   Code
+		status int
+	}{
+		{os.ErrNotExist, PathNotFound, http.StatusNotFound},
+		{&os.PathError{Op: "open", Path: "/a", Err: os.ErrNotExist}, PathNotFound, http.StatusNotFound},
+		{os.ErrExist, PathExists, http.StatusConflict},
+		{os.ErrPermission, PermissionDenied, http.StatusForbidden},
+		{fmt.Errorf("%w: chown", service.ErrPermissionDenied), PermissionDenied, http.StatusForbidden},
+		{service.ErrTokenRevoked, Unauthorized, http.StatusUnauthorized},
+		{fmt.Errorf("dial: %w", service.ErrHostKeyMismatch), SSHUnreachable, http.StatusBadGateway},
+		{errors.New("ssh: handshake failed: ssh: unable to authenticate"), AuthFailed, http.StatusUnauthorized},
+		{fmt.Errorf("%w: EOF", service.ErrConnection), SSHUnreachable, http.StatusBadGateway},
+		{fmt.Errorf("stat: %w", sftp.ErrSSHFxConnectionLost), SSHUnreachable, http.StatusBadGateway},
+		{fmt.Errorf("read archive: %w", io.EOF), Internal, http.StatusInternalServerError},
+		{io.ErrUnexpectedEOF, Internal, http.StatusInternalServerError},
+		{&service.TimeoutError{Command: "sacct", Err: context.DeadlineExceeded}, Timeout, http.StatusGatewayTimeout},
+		{fmt.Errorf("squeue: %w", context.Canceled), Canceled, 499},
+		{&service.RestError{Status: http.StatusInternalServerError, Errors: []string{"Invalid partition name specified"}}, CommandFailed, http.StatusUnprocessableEntity},
+		{&service.RestError{Status: http.StatusUnauthorized}, PermissionDenied, http.StatusForbidden},
+		{&service.RestError{Status: http.StatusBadGateway}, SSHUnreachable, http.StatusBadGateway},
+		{fmt.Errorf("%w: script_file", service.ErrUnsupported), BadRequest, http.StatusBadRequest},
+		{&service.UnsupportedError{Option: "--tres-bind", Since: "23.11", Cluster: "hpc", Version: "23.02.6"}, BadRequest, http.StatusBadRequest},
+		{fmt.Errorf("%w: chunk 3", service.ErrChecksumMismatch), ChecksumMismatch, 460},
+		{fmt.Errorf("%w: abc", service.ErrUploadNotFound), NotFound, http.StatusNotFound},
+		{service.ErrUploadIncomplete, Conflict, http.StatusConflict},
+		{fmt.Errorf("%w: abc", service.ErrTaskNotFound), NotFound, http.StatusNotFound},
+		{&service.ArchiveTooLargeError{Limit: 1 << 30}, TooLarge, http.StatusRequestEntityTooLarge},
+		{errors.New("boom"), Internal, http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		e := From(tc.err)
+		assert.Equal(t, tc.code, e.Code, tc.err.Error())
+		assert.Equal(t, tc.status, e.Status(), tc.err.Error())
+		assert.ErrorIs(t, e, tc.err)
+	}
+
+	// 已是接口错误的直接返回
+	e := New(BadRequest, "bad %s", "path")
+	assert.Same(t, e, From(fmt.Errorf("wrapped: %w", e)))
+	assert.Nil(t, Wrap(Internal, nil))
+}
+
+func TestRespond(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	Respond(c, Wrap(SlurmRejected, errors.New("exit status 1")).With("command", "scancel 1"))
+	assert.True(t, c.IsAborted())
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"success":"no","code":"slurm_rejected","error":"exit status 1","details":{"command":"scancel 1"}}`, w.Body.String())
+}
+
+func TestOK(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	OK(c, http.StatusCreated, gin.H{"task": map[string]string{"id": "t1"}, "success": "no"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"success":"yes","task":{"id":"t1"}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	OK(c, http.StatusOK, nil)
+	assert.JSONEq(t, `{"success":"yes"}`, w.Body.String())
+}
@@ -0,0 +1,415 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
+	"star-dim/internal/models"
+	"star-dim/internal/service"
+)
+
+type AdminHandler struct {
+	Server         *public.Server
+	ClusterService *service.ClusterService
+}
+
+func NewAdminHandler(server *public.Server) *AdminHandler {
+	return &AdminHandler{
+		Server:         server,
+		ClusterService: server.Clusters,
+	}
+}
+
+// clusterError 将集群服务错误转换为 HTTP 响应
+func clusterError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrClusterNotFound) || errors.Is(err, service.ErrLoginNodeNotFound) {
+		apierr.Respond(c, apierr.Wrap(apierr.NotFound, err))
+		return
+	}
+	apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+}
+
+// authorizeCluster 集群管理员只能管理会话所在的集群，引导令牌认证的调用者可以管理所有集群
+func authorizeCluster(c *gin.Context, name string) bool {
+	if public.CanManageCluster(c, name) {
+		return true
+	}
+	apierr.Abort(c, apierr.PermissionDenied, "cannot manage cluster %s", name)
+	return false
+}
+
+// serverSettings 返回集群配置中以服务进程身份生效的设置：读取的服务器本地文件，
+// 以及携带 SlurmUser 令牌访问的 slurmrestd 地址和证书校验方式。键为设置在配置中的位置
+func serverSettings(cluster *models.Cluster) map[string]string {
+	settings := make(map[string]string)
+	if cluster == nil {
+		return settings
+	}
+	if rest := cluster.SlurmRest; rest != nil {
+		for key, value := range map[string]string{
+			"slurmrestd.url":        rest.URL,
+			"slurmrestd.token_file": rest.TokenFile,
+			"slurmrestd.ca_file":    rest.CAFile,
+		} {
+			if value != "" {
+				settings[key] = value
+			}
+		}
+		if rest.InsecureSkipVerify {
+			settings["slurmrestd.insecure_skip_verify"] = "true"
+		}
+	}
+	for _, node := range cluster.LoginNodes {
+		if node != nil {
+			nodeServerSettings(settings, node.Name, node)
+		}
+	}
+	return settings
+}
+
+// nodeServerSettings 将登录节点跳板机的私钥文件加入 settings，name 为节点名称
+func nodeServerSettings(settings map[string]string, name string, node *models.LoginNode) {
+	if node == nil {
+		return
+	}
+	for _, jump := range node.JumpHosts {
+		if jump != nil && jump.PrivateKeyFile != "" {
+			settings[fmt.Sprintf("login_nodes[%s].jump_hosts[%s].private_key_file", name, jump.Host)] = jump.PrivateKeyFile
+		}
+	}
+}
+
+// authorizeServerSettings 这些设置只能来自配置文件或引导令牌认证的调用者，集群管理员会话只能保留 current 中已有的值
+func authorizeServerSettings(c *gin.Context, current, requested map[string]string) bool {
+	if public.IsAdminBootstrap(c) {
+		return true
+	}
+	for key, value := range requested {
+		if current[key] != value {
+			apierr.Abort(c, apierr.PermissionDenied, "%s can only be set with the admin bootstrap token", key)
+			return false
+		}
+	}
+	return true
+}
+
+// ListClusters lists all clusters
+// @Summary 获取集群列表
+// @Description 获取所有集群及其登录节点配置，不返回跳板机的密码、私钥和私钥口令
+// @Tags 集群管理
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Success 200 {object} object{clusters=[]models.Cluster,success=string} "集群列表"
+// @Failure 401 {object} apierr.Response "管理员令牌无效"
+// @Router /api/v1/admin/clusters/ [get]
+func (h *AdminHandler) ListClusters(c *gin.Context) {
+	clusters := make([]*models.Cluster, 0)
+	for _, cluster := range h.ClusterService.GetClusters() {
+		if public.CanManageCluster(c, cluster.Name) {
+			clusters = append(clusters, cluster.Redacted())
+		}
+	}
+	apierr.OK(c, http.StatusOK, gin.H{"clusters": clusters})
+}
+
+// GetCluster gets a cluster
+// @Summary 获取集群信息
+// @Description 获取指定集群及其登录节点配置，不返回跳板机的密码、私钥和私钥口令
+// @Tags 集群管理
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param name path string true "集群名称"
+// @Success 200 {object} object{cluster=models.Cluster,success=string} "集群信息"
+// @Failure 404 {object} apierr.Response "集群不存在"
+// @Router /api/v1/admin/clusters/{name}/ [get]
+func (h *AdminHandler) GetCluster(c *gin.Context) {
+	if !authorizeCluster(c, c.Param("name")) {
+		return
+	}
+	cluster := h.ClusterService.GetCluster(c.Param("name"))
+	if cluster == nil {
+		apierr.Respond(c, apierr.Wrap(apierr.NotFound, service.ErrClusterNotFound))
+		return
+	}
+	apierr.OK(c, http.StatusOK, gin.H{"cluster": cluster.Redacted()})
+}
+
+// CreateCluster creates a cluster
+// @Summary 新增集群
+// @Description 新增集群及其登录节点，配置写入集群配置文件。跳板机私钥文件和 slurmrestd 的地址、令牌文件、CA 文件只能使用引导令牌设置
+// @Tags 集群管理
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param request body models.Cluster true "集群配置"
+// @Success 201 {object} object{success=string} "创建成功"
+// @Failure 400 {object} apierr.Response "请求参数错误或集群已存在"
+// @Router /api/v1/admin/clusters/ [post]
+func (h *AdminHandler) CreateCluster(c *gin.Context) {
+	var cluster models.Cluster
+	if err := c.ShouldBindJSON(&cluster); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	if !authorizeCluster(c, cluster.Name) || !authorizeServerSettings(c, nil, serverSettings(&cluster)) {
+		return
+	}
+	if err := h.ClusterService.AddCluster(&cluster); err != nil {
+		clusterError(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusCreated, nil)
+}
+
+// UpdateCluster replaces a cluster
+// @Summary 修改集群
+// @Description 使用请求中的配置替换指定集群，集群名称不可修改。查询接口不返回跳板机凭据，修改时需要重新提供。集群管理员会话只能保留原有的跳板机私钥文件和 slurmrestd 的地址、令牌文件、CA 文件
+// @Tags 集群管理
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param name path string true "集群名称"
+// @Param request body models.Cluster true "集群配置"
+// @Success 200 {object} object{success=string} "修改成功"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 404 {object} apierr.Response "集群不存在"
+// @Router /api/v1/admin/clusters/{name}/ [put]
+func (h *AdminHandler) UpdateCluster(c *gin.Context) {
+	if !authorizeCluster(c, c.Param("name")) {
+		return
+	}
+	var cluster models.Cluster
+	if err := c.ShouldBindJSON(&cluster); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	current := serverSettings(h.ClusterService.GetCluster(c.Param("name")))
+	if !authorizeServerSettings(c, current, serverSettings(&cluster)) {
+		return
+	}
+	if err := h.ClusterService.UpdateCluster(c.Param("name"), &cluster); err != nil {
+		clusterError(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, nil)
+}
+
+// DeleteCluster removes a cluster
+// @Summary 删除集群
+// @Description 删除指定集群，已建立的会话不受影响
+// @Tags 集群管理
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param name path string true "集群名称"
+// @Success 200 {object} object{success=string} "删除成功"
+// @Failure 404 {object} apierr.Response "集群不存在"
+// @Router /api/v1/admin/clusters/{name}/ [delete]
+func (h *AdminHandler) DeleteCluster(c *gin.Context) {
+	if !authorizeCluster(c, c.Param("name")) {
+		return
+	}
+	if err := h.ClusterService.RemoveCluster(c.Param("name")); err != nil {
+		clusterError(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, nil)
+}
+
+// AddLoginNode adds a login node
+// @Summary 新增登录节点
+// @Description 为指定集群新增登录节点
+// @Tags 集群管理
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param name path string true "集群名称"
+// @Param request body models.LoginNode true "登录节点配置"
+// @Success 201 {object} object{success=string} "创建成功"
+// @Failure 400 {object} apierr.Response "请求参数错误或节点已存在"
+// @Failure 404 {object} apierr.Response "集群不存在"
+// @Router /api/v1/admin/clusters/{name}/nodes/ [post]
+func (h *AdminHandler) AddLoginNode(c *gin.Context) {
+	if !authorizeCluster(c, c.Param("name")) {
+		return
+	}
+	var node models.LoginNode
+	if err := c.ShouldBindJSON(&node); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	requested := make(map[string]string)
+	nodeServerSettings(requested, node.Name, &node)
+	if !authorizeServerSettings(c, nil, requested) {
+		return
+	}
+	if err := h.ClusterService.AddLoginNode(c.Param("name"), &node); err != nil {
+		clusterError(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusCreated, nil)
+}
+
+// UpdateLoginNode updates a login node
+// @Summary 修改登录节点
+// @Description 修改登录节点的地址、端口、标签和启用状态，节点名称不可修改
+// @Tags 集群管理
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param name path string true "集群名称"
+// @Param node path string true "登录节点名称"
+// @Param request body models.LoginNode true "登录节点配置"
+// @Success 200 {object} object{success=string} "修改成功"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 404 {object} apierr.Response "集群或节点不存在"
+// @Router /api/v1/admin/clusters/{name}/nodes/{node}/ [put]
+func (h *AdminHandler) UpdateLoginNode(c *gin.Context) {
+	if !authorizeCluster(c, c.Param("name")) {
+		return
+	}
+	var req models.LoginNode
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	current, requested := make(map[string]string), make(map[string]string)
+	if cluster := h.ClusterService.GetCluster(c.Param("name")); cluster != nil {
+		nodeServerSettings(current, c.Param("node"), cluster.GetLoginNode(c.Param("node")))
+	}
+	nodeServerSettings(requested, c.Param("node"), &req)
+	if !authorizeServerSettings(c, current, requested) {
+		return
+	}
+	err := h.ClusterService.UpdateLoginNode(c.Param("name"), c.Param("node"), func(node *models.LoginNode) {
+		*node = req
+	})
+	if err != nil {
+		clusterError(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, nil)
+}
+
+// DeleteLoginNode removes a login node
+// @Summary 删除登录节点
+// @Description 删除指定集群的登录节点
+// @Tags 集群管理
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param name path string true "集群名称"
+// @Param node path string true "登录节点名称"
+// @Success 200 {object} object{success=string} "删除成功"
+// @Failure 400 {object} apierr.Response "集群至少需要一个登录节点"
+// @Failure 404 {object} apierr.Response "集群或节点不存在"
+// @Router /api/v1/admin/clusters/{name}/nodes/{node}/ [delete]
+func (h *AdminHandler) DeleteLoginNode(c *gin.Context) {
+	if !authorizeCluster(c, c.Param("name")) {
+		return
+	}
+	if err := h.ClusterService.RemoveLoginNode(c.Param("name"), c.Param("node")); err != nil {
+		clusterError(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, nil)
+}
+
+// SetLoginNodeState enables or disables a login node
+// @Summary 启用或禁用登录节点
+// @Description 禁用的登录节点不会被分配给新的登录会话，已建立的会话不受影响
+// @Tags 集群管理
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param name path string true "集群名称"
+// @Param node path string true "登录节点名称"
+// @Param request body object{disabled=bool} true "节点状态"
+// @Success 200 {object} object{success=string} "修改成功"
+// @Failure 404 {object} apierr.Response "集群或节点不存在"
+// @Router /api/v1/admin/clusters/{name}/nodes/{node}/state/ [put]
+func (h *AdminHandler) SetLoginNodeState(c *gin.Context) {
+	if !authorizeCluster(c, c.Param("name")) {
+		return
+	}
+	var req struct {
+		Disabled bool `json:"disabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	err := h.ClusterService.UpdateLoginNode(c.Param("name"), c.Param("node"), func(node *models.LoginNode) {
+		node.Disabled = req.Disabled
+	})
+	if err != nil {
+		clusterError(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, nil)
+}
+
+// SetLoginNodeLabels sets login node labels
+// @Summary 设置登录节点标签
+// @Description 设置登录节点标签（如 gpu、visualization），登录时可按标签选择节点
+// @Tags 集群管理
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param name path string true "集群名称"
+// @Param node path string true "登录节点名称"
+// @Param request body object{labels=[]string} true "节点标签"
+// @Success 200 {object} object{success=string} "修改成功"
+// @Failure 404 {object} apierr.Response "集群或节点不存在"
+// @Router /api/v1/admin/clusters/{name}/nodes/{node}/labels/ [put]
+func (h *AdminHandler) SetLoginNodeLabels(c *gin.Context) {
+	if !authorizeCluster(c, c.Param("name")) {
+		return
+	}
+	var req struct {
+		Labels []string `json:"labels"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	err := h.ClusterService.UpdateLoginNode(c.Param("name"), c.Param("node"), func(node *models.LoginNode) {
+		node.Labels = req.Labels
+	})
+	if err != nil {
+		clusterError(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, nil)
+}
+
+// SetDefaultNode sets the default login node
+// @Summary 设置默认登录节点
+// @Description 设置集群默认登录节点，node 为空时清除默认设置
+// @Tags 集群管理
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param name path string true "集群名称"
+// @Param request body object{node=string} true "默认登录节点名称"
+// @Success 200 {object} object{success=string} "修改成功"
+// @Failure 404 {object} apierr.Response "集群或节点不存在"
+// @Router /api/v1/admin/clusters/{name}/default-node/ [put]
+func (h *AdminHandler) SetDefaultNode(c *gin.Context) {
+	if !authorizeCluster(c, c.Param("name")) {
+		return
+	}
+	var req struct {
+		Node string `json:"node"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	if err := h.ClusterService.SetDefaultNode(c.Param("name"), req.Node); err != nil {
+		clusterError(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, nil)
+}
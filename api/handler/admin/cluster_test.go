@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"star-dim/api/public"
+	"star-dim/internal/models"
+)
+
+func TestAuthorizeServerSettings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stored := &models.Cluster{Name: "hpc1", LoginNodes: []*models.LoginNode{{
+		Name:      "ln1",
+		JumpHosts: []*models.JumpHost{{Host: "jump", Port: "22", PrivateKeyFile: "/etc/star-dim/gateway_ed25519"}},
+	}}, SlurmRest: &models.SlurmRestConfig{URL: "https://slurm:6820", TokenFile: "/etc/star-dim/slurm.jwt"}}
+	authorize := func(bootstrap bool, requested *models.Cluster) (bool, int) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		if bootstrap {
+			c.Set(public.ContextAdminBootstrap, true)
+		}
+		ok := authorizeServerSettings(c, serverSettings(stored), serverSettings(requested))
+		return ok, w.Code
+	}
+	withKeyFile := func(path string) *models.Cluster {
+		cluster := stored.Clone()
+		cluster.LoginNodes[0].JumpHosts[0].PrivateKeyFile = path
+		return cluster
+	}
+
+	// 集群管理员会话可以保留或删除原有的私钥文件
+	ok, _ := authorize(false, withKeyFile("/etc/star-dim/gateway_ed25519"))
+	assert.True(t, ok)
+	ok, _ = authorize(false, withKeyFile(""))
+	assert.True(t, ok)
+
+	// 修改私钥文件只能使用引导令牌
+	ok, code := authorize(false, withKeyFile("/etc/shadow"))
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusForbidden, code)
+	ok, _ = authorize(true, withKeyFile("/etc/shadow"))
+	assert.True(t, ok)
+
+	// slurmrestd 的地址、令牌文件和 CA 文件同样只能使用引导令牌修改，其他设置不受限制
+	for _, set := range []func(rest *models.SlurmRestConfig){
+		func(rest *models.SlurmRestConfig) { rest.URL = "https://attacker:6820" },
+		func(rest *models.SlurmRestConfig) { rest.TokenFile = "/etc/shadow" },
+		func(rest *models.SlurmRestConfig) { rest.CAFile = "/tmp/ca.pem" },
+		func(rest *models.SlurmRestConfig) { rest.InsecureSkipVerify = true },
+	} {
+		cluster := stored.Clone()
+		set(cluster.SlurmRest)
+		ok, _ = authorize(false, cluster)
+		assert.False(t, ok)
+		ok, _ = authorize(true, cluster)
+		assert.True(t, ok)
+	}
+	cluster := stored.Clone()
+	cluster.SlurmRest.Version = "v0.0.41"
+	ok, _ = authorize(false, cluster)
+	assert.True(t, ok)
+}
@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
+	"star-dim/internal/service"
+)
+
+type HostKeyRequest struct {
+	Cluster     string `json:"cluster" binding:"required"`
+	Node        string `json:"node" binding:"required"`
+	Fingerprint string `json:"fingerprint"`
+	Key         string `json:"key"`
+}
+
+func hostKeyError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrHostKeyNotFound) {
+		apierr.Respond(c, apierr.Wrap(apierr.NotFound, err))
+		return
+	}
+	apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+}
+
+// ListHostKeys lists login node host keys
+// @Summary 获取主机密钥列表
+// @Description 获取各登录节点已信任和待审批的主机密钥
+// @Tags 集群管理
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Success 200 {object} object{host_keys=[]service.HostKeyEntry,success=string} "主机密钥列表"
+// @Router /api/v1/admin/hostkeys/ [get]
+func (h *AdminHandler) ListHostKeys(c *gin.Context) {
+	entries := make([]service.HostKeyEntry, 0)
+	for _, entry := range h.Server.HostKeys.List() {
+		if public.CanManageCluster(c, entry.Cluster) {
+			entries = append(entries, entry)
+		}
+	}
+	apierr.OK(c, http.StatusOK, gin.H{"host_keys": entries})
+}
+
+// ExportKnownHosts exports trusted host keys
+// @Summary 导出 known_hosts
+// @Description 以 OpenSSH known_hosts 格式导出已信任的主机密钥
+// @Tags 集群管理
+// @Produce plain
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Success 200 {string} string "known_hosts 文件内容"
+// @Router /api/v1/admin/hostkeys/known_hosts/ [get]
+func (h *AdminHandler) ExportKnownHosts(c *gin.Context) {
+	c.Data(http.StatusOK, "text/plain", h.Server.HostKeys.KnownHosts(func(cluster string) bool {
+		return public.CanManageCluster(c, cluster)
+	}))
+}
+
+// ApproveHostKey approves a pending host key
+// @Summary 批准主机密钥
+// @Description 批准登录节点待审批的主机密钥，替换原有的信任密钥
+// @Tags 集群管理
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param request body HostKeyRequest true "节点和待批准密钥指纹"
+// @Success 200 {object} object{success=string} "批准成功"
+// @Failure 404 {object} apierr.Response "没有待审批的密钥"
+// @Router /api/v1/admin/hostkeys/approve/ [post]
+func (h *AdminHandler) ApproveHostKey(c *gin.Context) {
+	var req HostKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	if !authorizeCluster(c, req.Cluster) {
+		return
+	}
+	if err := h.Server.HostKeys.Approve(req.Cluster, req.Node, req.Fingerprint); err != nil {
+		hostKeyError(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, nil)
+}
+
+// RotateHostKey replaces the trusted host key
+// @Summary 轮换主机密钥
+// @Description 将登录节点的信任密钥替换为指定公钥（authorized_keys 格式）
+// @Tags 集群管理
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param request body HostKeyRequest true "节点和新公钥"
+// @Success 200 {object} object{success=string} "轮换成功"
+// @Failure 400 {object} apierr.Response "公钥格式错误"
+// @Router /api/v1/admin/hostkeys/ [put]
+func (h *AdminHandler) RotateHostKey(c *gin.Context) {
+	var req HostKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	if !authorizeCluster(c, req.Cluster) {
+		return
+	}
+	if err := h.Server.HostKeys.Rotate(req.Cluster, req.Node, req.Key); err != nil {
+		hostKeyError(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, nil)
+}
+
+// ForgetHostKey removes host keys of a login node
+// @Summary 删除主机密钥
+// @Description 删除登录节点的全部主机密钥记录，tofu 策略下次连接时重新信任
+// @Tags 集群管理
+// @Produce json
+// @Param X-Admin-Token header string false "管理员引导令牌，未携带时需要集群管理员的访问令牌"
+// @Param cluster query string true "集群名称"
+// @Param node query string true "登录节点名称"
+// @Success 200 {object} object{success=string} "删除成功"
+// @Failure 404 {object} apierr.Response "密钥记录不存在"
+// @Router /api/v1/admin/hostkeys/ [delete]
+func (h *AdminHandler) ForgetHostKey(c *gin.Context) {
+	if !authorizeCluster(c, c.Query("cluster")) {
+		return
+	}
+	if err := h.Server.HostKeys.Forget(c.Query("cluster"), c.Query("node")); err != nil {
+		hostKeyError(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, nil)
+}
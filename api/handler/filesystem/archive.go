@@ -0,0 +1,148 @@
+package filesystem
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
+	"star-dim/internal/service"
+	"strconv"
+	"time"
+)
+
+// remoteArchiveTimeout 登录节点上打包的最长时间，客户端断开时随请求取消
+const remoteArchiveTimeout = 12 * time.Hour
+
+// archiveWriter 写出第一个字节时才发送响应头，打包开始前出错时仍可返回错误信息
+type archiveWriter struct {
+	c       *gin.Context
+	header  func()
+	started bool
+}
+
+func (w *archiveWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.header()
+	}
+	return w.c.Writer.Write(p)
+}
+
+// downloadDir 打包下载目录。zip 不压缩，支持 Range 请求和续传；tar、tar.gz、tar.zst 流式输出，
+// remote 为 true 时在登录节点上执行 tar，tar.zst 总是在登录节点上压缩
+func (h *FilesHandler) downloadDir(c *gin.Context, client *public.UserClient, dir string, info os.FileInfo) {
+	sftpClient := client.SFTP()
+
+	format, err := service.ArchiveFormat(c.Query("format"))
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	filter := &service.ArchiveFilter{Include: c.QueryArray("include"), Exclude: c.QueryArray("exclude")}
+	if err := filter.Validate(); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	// 请求只能调低服务端配置的大小限制
+	limit := h.Server.ArchiveMax
+	if maxSize := c.Query("max_size"); maxSize != "" {
+		n, err := strconv.ParseInt(maxSize, 10, 64)
+		if err != nil || n <= 0 {
+			apierr.Abort(c, apierr.BadRequest, "invalid max_size %q", maxSize)
+			return
+		}
+		if limit == 0 || n < limit {
+			limit = n
+		}
+	}
+	remote := format == service.ArchiveTarZst
+	if value := c.Query("remote"); value != "" {
+		if remote, err = strconv.ParseBool(value); err != nil {
+			apierr.Abort(c, apierr.BadRequest, "remote must be true or false")
+			return
+		}
+	}
+	switch {
+	case remote && format == service.ArchiveZip:
+		apierr.Abort(c, apierr.BadRequest, "remote archiving supports tar, tar.gz and tar.zst")
+		return
+	case !remote && format == service.ArchiveTarZst:
+		apierr.Abort(c, apierr.BadRequest, "tar.zst is only created on the login node")
+		return
+	}
+
+	// 遍历目录时累计文件大小，超过限制立即拒绝
+	entries, total, err := service.CollectArchiveEntries(sftpClient.Walk(dir), dir, info.Name(), filter, limit)
+	if err != nil {
+		log.Println(err)
+		apierr.Respond(c, err)
+		return
+	}
+	open := func(name string) (service.ReadAtCloser, error) {
+		return sftpClient.Open(name)
+	}
+	log.Printf("archive %s as %s: %d entries, %d bytes, remote %v", dir, format, len(entries), total, remote)
+
+	if format == service.ArchiveZip {
+		archive, err := service.NewZipArchive(entries, open, h.Server.Checksums)
+		if err != nil {
+			log.Println(err)
+			apierr.Respond(c, err)
+			return
+		}
+		defer archive.Close()
+		c.Header("Content-Type", "application/octet-stream")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.zip", info.Name()))
+		c.Header("ETag", archive.ETag())
+		http.ServeContent(c.Writer, c.Request, "", archive.ModTime(), archive)
+		return
+	}
+
+	// tar 格式无法预知长度，不支持 Range 请求
+	header := func() {
+		c.Header("Content-Type", service.ArchiveContentType(format))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", info.Name(), format))
+		c.Header("Accept-Ranges", "none")
+		c.Header("X-Archive-Content-Size", strconv.FormatInt(total, 10))
+		c.Status(http.StatusOK)
+	}
+	if c.Request.Method == http.MethodHead {
+		header()
+		return
+	}
+	out := &archiveWriter{c: c, header: header}
+	if remote {
+		cmd, err := service.RemoteTarCommand(path.Dir(dir), format)
+		if err == nil {
+			_, err = client.Executor().Run(c.Request.Context(), &service.Command{
+				Cmd:     cmd,
+				Stdin:   service.RemoteTarInput(entries),
+				Stdout:  out,
+				Timeout: remoteArchiveTimeout,
+			})
+		}
+		if service.IsTarWarning(err) {
+			log.Printf("tar %s: files changed while archiving", dir)
+			err = nil
+		}
+		if err != nil {
+			archiveFailed(c, out, dir, err)
+		}
+		return
+	}
+	if err := service.WriteTar(out, format, entries, open); err != nil {
+		archiveFailed(c, out, dir, err)
+	}
+}
+
+// archiveFailed 打包尚未输出时返回错误；已开始输出时只能中断，客户端得到不完整的归档
+func archiveFailed(c *gin.Context, out *archiveWriter, dir string, err error) {
+	log.Printf("archive %s: %v", dir, err)
+	if !out.started {
+		apierr.Respond(c, err)
+	}
+}
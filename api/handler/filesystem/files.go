@@ -1,8 +1,6 @@
 package filesystem
 
 import (
-	"archive/zip"
-	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/sftp"
@@ -11,8 +9,11 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"star-dim/api/apierr"
 	"star-dim/api/public"
 	"star-dim/internal/models"
+	"star-dim/internal/service"
+	"star-dim/internal/utils"
 	"strconv"
 	"strings"
 )
@@ -58,24 +59,14 @@ func FileInfoToJSON(fileInfo os.FileInfo) FileInfoJSON {
 	}
 }
 
-func (h *FilesHandler) GetKeyFromRequest(c *gin.Context) (string, *models.RequestInfo, error) {
-	// ssh session key must be in header
-	sessionKey := c.GetHeader("sessionKey")
-	if sessionKey == "" {
-		return "", nil, fmt.Errorf("sessionKey is required in header")
-	}
+// GetRequestInfo 解析请求参数，会话由 UserSession 中间件注入。
+// cluster 和 systemUsername 只记录在本次请求中，会话的集群和用户在登录时确定，不受请求参数影响
+func (h *FilesHandler) GetRequestInfo(c *gin.Context) (*models.RequestInfo, error) {
 	var requestInfo models.RequestInfo
-	// set current cluster and username
 	switch c.Request.Method {
-	case http.MethodGet:
-		systemUsername := c.Query("systemUsername")
-		cluster := c.Query("cluster")
-		if cluster != "" {
-			h.Server.Clients[sessionKey].UserInfo.Cluster.Name = cluster
-		}
-		if systemUsername != "" {
-			h.Server.Clients[sessionKey].UserInfo.Name = systemUsername
-		}
+	case http.MethodGet, http.MethodHead:
+		requestInfo.Cluster = c.Query("cluster")
+		requestInfo.SystemUsername = c.Query("systemUsername")
 		// if path is in query, set it to requestInfo
 		requestInfo.Path = c.Query("path")
 	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
@@ -83,37 +74,29 @@ func (h *FilesHandler) GetKeyFromRequest(c *gin.Context) (string, *models.Reques
 		switch {
 		case contentType == "application/json":
 			if err := c.ShouldBindJSON(&requestInfo); err != nil {
-				return "", nil, fmt.Errorf("failed to bind JSON: %v", err)
+				return nil, apierr.New(apierr.BadRequest, "failed to bind JSON: %v", err)
 			}
-			systemUsername := c.Query("systemUsername")
-			cluster := c.Query("cluster")
-			if cluster != "" {
-				h.Server.Clients[sessionKey].UserInfo.Cluster.Name = cluster
+			if cluster := c.Query("cluster"); cluster != "" {
+				requestInfo.Cluster = cluster
 			}
-			if systemUsername != "" {
-				h.Server.Clients[sessionKey].UserInfo.Name = systemUsername
+			if systemUsername := c.Query("systemUsername"); systemUsername != "" {
+				requestInfo.SystemUsername = systemUsername
 			}
 		case contentType == "application/x-www-form-urlencoded" || strings.HasPrefix(contentType, "multipart/form-data"):
-			cluster, _ := c.GetPostForm("cluster")
-			systemUsername, _ := c.GetPostForm("systemUsername")
-			if cluster != "" {
-				h.Server.Clients[sessionKey].UserInfo.Cluster.Name = cluster
-			}
-			if systemUsername != "" {
-				h.Server.Clients[sessionKey].UserInfo.Name = systemUsername
-			}
+			requestInfo.Cluster, _ = c.GetPostForm("cluster")
+			requestInfo.SystemUsername, _ = c.GetPostForm("systemUsername")
 			// 对于文件上传，我们也需要获取其他表单字段
 			if strings.HasPrefix(contentType, "multipart/form-data") {
 				requestInfo.Path, _ = c.GetPostForm("path")
 				requestInfo.Type, _ = c.GetPostForm("type")
 			}
 		default:
-			return "", nil, fmt.Errorf("unsupported content type: %s", contentType)
+			return nil, apierr.New(apierr.BadRequest, "unsupported content type: %s", contentType)
 		}
 	default:
-		return "", nil, fmt.Errorf("unsupported method: %s", c.Request.Method)
+		return nil, apierr.New(apierr.BadRequest, "unsupported method: %s", c.Request.Method)
 	}
-	return sessionKey, &requestInfo, nil
+	return &requestInfo, nil
 }
 
 // List objects
@@ -124,28 +107,29 @@ func (h *FilesHandler) GetKeyFromRequest(c *gin.Context) (string, *models.Reques
 // @Produce json
 // @Param cluster query string true "集群名称" example("hpc1")
 // @Param path query string true "目录路径" example("/ai")
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Success 200 {object} object{listContent=[]FileInfoJSON,listLength=int,success=string} "成功返回文件列表"
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/ [get]
 func (h *FilesHandler) List(c *gin.Context) {
 	// list Object
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
+		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+	path, err := client.RepackPath(req.Path)
+	if err != nil {
+		apierr.Respond(c, err)
 		return
 	}
-	sftpClient := h.Server.Clients[key].SftpClient
-	path := h.Server.Clients[key].RepackPath(req.Path)
-	fmt.Println("list path:", path, " home path:", h.Server.Clients[key].UserInfo.HomePath)
 	objs, err := sftpClient.ReadDir(path)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
 
@@ -158,7 +142,7 @@ func (h *FilesHandler) List(c *gin.Context) {
 		}
 	}
 	//c.JSON(200, map[string]ifapi{}{"objects": files, "filesCount": len(objs), "dirCount": dirCount}) // like ifapi
-	c.JSON(200, map[string]interface{}{"listContent": files, "listLength": len(objs), "success": "yes"}) //like api server
+	apierr.OK(c, http.StatusOK, gin.H{"listContent": files, "listLength": len(objs)}) //like api server
 	// curl test: curl -X POST -H "Content-Type: application/json" -d "{\"username\":\"root\",\"path\":\"/root/\"}" http://localhost:8080/api/v2/document/files/
 }
 
@@ -168,45 +152,46 @@ func (h *FilesHandler) List(c *gin.Context) {
 // @Tags 文件管理
 // @Accept multipart/form-data
 // @Produce json
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param cluster formData string true "集群名称" example("hpc1")
 // @Param path formData string true "上传路径" example("/ai/upload/test.txt")
 // @Param offset formData string false "文件偏移量（用于断点续传）" example("0")
 // @Param update formData string false "是否覆盖已存在文件" Enums(true,false) example("false")
 // @Param file formData file true "要上传的文件"
 // @Success 200 {object} object{success=string} "上传成功" example({"success":"yes"})
-// @Failure 400 {object} object{error=string} "请求参数错误或文件为空"
-// @Failure 409 {object} object{error=string} "文件已存在且未设置覆盖标志"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Failure 400 {object} apierr.Response "请求参数错误或文件为空"
+// @Failure 409 {object} apierr.Response "文件已存在且未设置覆盖标志"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/transmission/ [post]
 func (h *FilesHandler) Transmission(c *gin.Context) {
-	key, _, err := h.GetKeyFromRequest(c)
+	_, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	sftpClient := h.Server.Clients[key].SftpClient
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
 
 	path, _ := c.GetPostForm("path")
-	path = h.Server.Clients[key].RepackPath(path)
+	path, err = client.RepackPath(path)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
 	offsetStr, _ := c.GetPostForm("offset")
 	update, ok := c.GetPostForm("update")
 	if !ok {
 		update = "false"
 	}
 	if update != "true" && update != "false" {
-		c.JSON(http.StatusInternalServerError, errors.New("update must be true,false or empty"))
+		apierr.Abort(c, apierr.BadRequest, "update must be true,false or empty")
 		return
 	}
 	offset, _ := strconv.ParseInt(offsetStr, 10, 64)
 	input, err := c.FormFile("file")
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
 		return
 	}
 	_, err = sftpClient.Lstat(path)
@@ -217,7 +202,7 @@ func (h *FilesHandler) Transmission(c *gin.Context) {
 			// file exist
 			if update == "false" {
 				log.Println(err)
-				c.JSON(http.StatusInternalServerError, errors.New("file exist"))
+				apierr.Abort(c, apierr.PathExists, "file exist")
 				return
 			} else {
 				// force update
@@ -225,11 +210,11 @@ func (h *FilesHandler) Transmission(c *gin.Context) {
 				f, err := sftpClient.Create(path)
 				if err != nil {
 					log.Println(err)
-					c.JSON(http.StatusInternalServerError, err)
+					apierr.Respond(c, err)
 					return
 				}
 				_ = f.Close()
-				c.JSON(200, map[string]interface{}{"success": "yes"})
+				apierr.OK(c, http.StatusOK, nil)
 				return
 			}
 		} else {
@@ -238,15 +223,15 @@ func (h *FilesHandler) Transmission(c *gin.Context) {
 				f, err := sftpClient.Create(path)
 				if err != nil {
 					log.Println(err)
-					c.JSON(http.StatusInternalServerError, err)
+					apierr.Respond(c, err)
 					return
 				}
 				_ = f.Close()
-				c.JSON(200, map[string]interface{}{"success": "yes"})
+				apierr.OK(c, http.StatusOK, nil)
 				return
 			} else {
 				log.Println(err)
-				c.JSON(http.StatusInternalServerError, err)
+				apierr.Respond(c, err)
 				return
 			}
 		}
@@ -257,7 +242,7 @@ func (h *FilesHandler) Transmission(c *gin.Context) {
 			// file exist
 			if update == "false" {
 				log.Println(err)
-				c.JSON(http.StatusInternalServerError, errors.New("file exist"))
+				apierr.Abort(c, apierr.PathExists, "file exist")
 				return
 			}
 		}
@@ -266,114 +251,98 @@ func (h *FilesHandler) Transmission(c *gin.Context) {
 		} else {
 			dstFile, err = sftpClient.OpenFile(path, os.O_RDWR)
 		}
-		defer dstFile.Close()
 		if err != nil {
 			log.Println(err)
-			c.JSON(http.StatusInternalServerError, err)
+			apierr.Respond(c, err)
 			return
 		}
+		defer dstFile.Close()
 		_, _ = dstFile.Seek(offset, 0)
 		srcFile, err := input.Open()
 		if err != nil {
 			log.Println(err)
-			c.JSON(http.StatusInternalServerError, err)
+			apierr.Respond(c, err)
 			return
 		}
-		data, err := ioutil.ReadAll(srcFile)
+		defer srcFile.Close()
+		// 流式写入，不将整个文件读入内存
+		_, err = io.Copy(io.NewOffsetWriter(dstFile, offset), srcFile)
 		if err != nil {
 			log.Println(err)
-			c.JSON(http.StatusInternalServerError, err)
+			apierr.Respond(c, err)
 			return
 		}
-		_, err = dstFile.WriteAt(data, offset)
-		if err != nil {
-			log.Println(err)
-			c.JSON(http.StatusInternalServerError, err)
-			return
-		}
-		c.JSON(http.StatusOK, map[string]interface{}{"success": "yes"})
+		apierr.OK(c, http.StatusOK, nil)
 	}
 }
 
 // Download downloads a file or directory
 // @Summary 下载文件或目录
-// @Description 下载指定路径的文件或目录。文件直接下载，目录会被打包成ZIP文件下载
+// @Description 下载指定路径的文件或目录。文件直接下载，目录默认打包成不压缩的ZIP文件下载，也可选择 tar、tar.gz、tar.zst 格式流式下载，并按 glob 模式筛选文件。
+// @Description 文件和 ZIP 支持 HEAD 请求和 Range 请求（包括多个区间），通过 ETag、Last-Modified 配合 If-Range、If-None-Match 等条件请求实现断点续传
 // @Tags 文件管理
 // @Accept json
 // @Produce application/octet-stream
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Param Range header string false "下载的字节区间" example("bytes=0-1048575")
+// @Param If-Range header string false "ETag 或 Last-Modified，内容变化时返回完整内容"
 // @Param cluster query string true "集群名称" example("hpc1")
 // @Param path query string true "文件或目录路径" example("/ai/mcp")
-// @Success 200 {file} file "下载成功，返回文件内容或ZIP压缩包"
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 404 {object} object{error=string} "文件或目录不存在"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Param format query string false "目录的打包格式" Enums(zip,tar,tar.gz,tar.zst) default(zip)
+// @Param include query []string false "只打包匹配的文件，可重复；模式含 / 时匹配相对路径，否则匹配文件名" collectionFormat(multi) example("*.py")
+// @Param exclude query []string false "排除匹配的文件和目录，可重复" collectionFormat(multi) example(".git")
+// @Param max_size query int false "文件总大小上限（字节），不能超过服务端的限制"
+// @Param remote query bool false "在登录节点上执行 tar 打包，仅 tar 格式；tar.zst 总是在登录节点上打包"
+// @Success 200 {file} file "下载成功，返回文件内容或压缩包"
+// @Success 206 {file} file "返回请求的区间"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 404 {object} apierr.Response "文件或目录不存在"
+// @Failure 413 {object} apierr.Response "目录内容超过打包大小限制"
+// @Failure 416 {string} string "请求的区间超出文件长度"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/download/ [get]
+// @Router /api/v1/filesystem/files/download/ [head]
 func (h *FilesHandler) Download(c *gin.Context) {
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+	path, err := client.RepackPath(req.Path)
+	if err != nil {
+		apierr.Respond(c, err)
 		return
 	}
-	sftpClient := h.Server.Clients[key].SftpClient
-	path := h.Server.Clients[key].RepackPath(req.Path)
 	log.Println("download path:", path)
 	fileInfo, err := sftpClient.Lstat(path)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
 
 	// download dir
 	if fileInfo.IsDir() {
-		az := zip.NewWriter(c.Writer)
-		c.Header("Content-Type", "application/octet-stream")
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.zip", fileInfo.Name()))
-		walker := sftpClient.Walk(path)
-		for walker.Step() {
-			if err := walker.Err(); err != nil {
-				continue
-			}
-			fi, _ := sftpClient.Lstat(walker.Path())
-			if fi.IsDir() {
-				_, _ = az.Create(walker.Path() + "/")
-				continue
-			}
-			ds, _ := az.Create(walker.Path())
-			distFile, _ := sftpClient.OpenFile(walker.Path(), os.O_RDONLY)
-			_, _ = io.Copy(ds, distFile)
-		}
-		_ = az.Close()
-	} else {
-		// download file
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileInfo.Name()))
-		c.Header("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
-		c.Header("Content-Type", "application/octet-stream")
-		buffer := make([]byte, 1024*1024)
-		dstFile, _ := sftpClient.OpenFile(path, os.O_RDONLY)
-		for {
-			n, err := dstFile.Read(buffer)
-			if err != nil && err != io.EOF {
-				log.Println(err)
-				c.JSON(http.StatusInternalServerError, err)
-				return
-			}
-			if n == 0 {
-				break
-			}
-			_, err = c.Writer.Write(buffer[:n])
-			if err != nil {
-				log.Println(err)
-				c.JSON(http.StatusInternalServerError, err)
-				return
-			}
-		}
+		h.downloadDir(c, client, path, fileInfo)
+		return
+	}
+
+	// download file
+	dstFile, err := sftpClient.Open(path)
+	if err != nil {
+		log.Println(err)
+		apierr.Respond(c, err)
+		return
 	}
+	defer dstFile.Close()
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileInfo.Name()))
+	c.Header("Content-Type", "application/octet-stream")
+	// 由修改时间和大小生成 ETag，与 nginx 相同
+	c.Header("ETag", fmt.Sprintf(`"%x-%x"`, fileInfo.ModTime().Unix(), fileInfo.Size()))
+	// ServeContent 处理 HEAD、Range、If-Range 和其他条件请求
+	http.ServeContent(c.Writer, c.Request, "", fileInfo.ModTime(), service.NewRangeReader(dstFile, fileInfo.Size()))
 	// curl test: http://localhost:8080/api/v2/document/download/?username=root&path=/root/scritps
 }
 
@@ -383,35 +352,36 @@ func (h *FilesHandler) Download(c *gin.Context) {
 // @Tags 文件管理
 // @Accept json
 // @Produce json
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param cluster query string true "集群名称" example("hpc1")
 // @Param path query string true "文件或目录路径" example("/ai/new_folder_rename/test_renamed.sh")
-// @Success 200 {object} object{name=string,size=int,mode=string,modify=string,isDir=bool} "获取属性成功" example({"name":"test_renamed.sh","size":29,"mode":"-rw-r--r--","modify":"2025-08-07 10:16:36 +0800 CST","isDir":false})
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 404 {object} object{error=string} "文件或目录不存在"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Success 200 {object} object{attr=FileInfoJSON,success=string} "获取属性成功" example({"success":"yes","attr":{"name":"test_renamed.sh","size":29,"mode":"-rw-r--r--","modify":"2025-08-07 10:16:36 +0800 CST","isDir":false}})
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 404 {object} apierr.Response "文件或目录不存在"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/attr/ [get]
 func (h *FilesHandler) Attr(c *gin.Context) {
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+
+	path, err := client.RepackLinkPath(req.Path)
+	if err != nil {
+		apierr.Respond(c, err)
 		return
 	}
-	sftpClient := h.Server.Clients[key].SftpClient
-
-	path := h.Server.Clients[key].RepackPath(req.Path)
 	log.Println("attr path:", path)
 	fileInfo, err := sftpClient.Lstat(path)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
-	c.JSON(200, FileInfoToJSON(fileInfo))
+	apierr.OK(c, http.StatusOK, gin.H{"attr": FileInfoToJSON(fileInfo)})
 }
 
 // Rename renames a file or directory
@@ -420,36 +390,41 @@ func (h *FilesHandler) Attr(c *gin.Context) {
 // @Tags 文件管理
 // @Accept json
 // @Produce json
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param request body object{old_path=string,new_path=string} true "重命名请求参数" Example({"old_path":"/ai/new_folder","new_path":"/ai/new_folder_rename"})
 // @Success 200 {object} object{success=string} "重命名成功" example({"success":"yes"})
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 404 {object} object{error=string} "源文件或目录不存在"
-// @Failure 409 {object} object{error=string} "目标路径已存在"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 404 {object} apierr.Response "源文件或目录不存在"
+// @Failure 409 {object} apierr.Response "目标路径已存在"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/ [put]
 func (h *FilesHandler) Rename(c *gin.Context) {
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+	oldPath, err := client.RepackLinkPath(req.OldPath)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	newPath, err := client.RepackLinkPath(req.NewPath)
+	if err != nil {
+		apierr.Respond(c, err)
 		return
 	}
-	sftpClient := h.Server.Clients[key].SftpClient
-	oldPath := h.Server.Clients[key].RepackPath(req.OldPath)
-	newPath := h.Server.Clients[key].RepackPath(req.NewPath)
 	log.Println("rename oldPath:", oldPath, " newPath:", newPath)
 
 	err = sftpClient.Rename(oldPath, newPath)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
-	c.JSON(200, map[string]interface{}{"success": "yes"})
+	apierr.OK(c, http.StatusOK, nil)
 }
 
 // New creates a new file or directory
@@ -458,54 +433,58 @@ func (h *FilesHandler) Rename(c *gin.Context) {
 // @Tags 文件管理
 // @Accept json
 // @Produce json
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param request body object{path=string,type=string} true "创建请求参数" Example({"path":"/ai/new_folder","type":"dir"})
 // @Success 200 {object} object{success=string} "创建成功" example({"success":"yes"})
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 409 {object} object{error=string} "文件或目录已存在"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 409 {object} apierr.Response "文件或目录已存在"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/ [post]
 func (h *FilesHandler) New(c *gin.Context) {
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-	}
-	jumpClient := h.Server.Clients[key]
-	sftpClient := jumpClient.SftpClient
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
 
-	path := h.Server.Clients[key].RepackPath(req.Path)
+	path, err := client.RepackLinkPath(req.Path)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
 	fileType := req.Type
-	log.Println("new path:", path, " type:", fileType, " home path:", jumpClient.UserInfo.HomePath)
+	log.Println("new path:", path, " type:", fileType, " home path:", client.UserInfo.HomePath)
 	if fileType != "file" && fileType != "dir" {
-		c.JSON(http.StatusInternalServerError, errors.New("type must be file or dir"))
+		apierr.Abort(c, apierr.BadRequest, "type must be file or dir")
+		return
 	}
 	_, err = sftpClient.Lstat(path)
 	if err == nil {
-		c.JSON(http.StatusInternalServerError, errors.New("file exist"))
+		apierr.Abort(c, apierr.PathExists, "file exist")
 	} else {
 		if strings.Contains(err.Error(), "file does not exist") {
 			if fileType == "file" {
 				f, err := sftpClient.Create(path)
 				if err != nil {
 					log.Println(err)
-					c.JSON(http.StatusInternalServerError, err)
+					apierr.Respond(c, err)
+					return
 				}
 				_ = f.Close()
 			} else {
 				err = sftpClient.Mkdir(path)
 				if err != nil {
 					log.Println(err)
-					c.JSON(http.StatusInternalServerError, err)
+					apierr.Respond(c, err)
+					return
 				}
 			}
-			c.JSON(200, map[string]interface{}{"success": "yes"})
+			apierr.OK(c, http.StatusOK, nil)
 		} else {
 			log.Println(err)
-			c.JSON(http.StatusInternalServerError, err)
+			apierr.Respond(c, err)
 		}
 	}
 }
@@ -516,47 +495,40 @@ func (h *FilesHandler) New(c *gin.Context) {
 // @Tags 文件管理
 // @Accept json
 // @Produce json
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param request body object{path=string,type=string,force_dir=bool} true "删除请求参数" Example({"path":"/ai/new_folder/test.sh","type":"file", "force_dir":false})
 // @Success 200 {object} object{success=string} "删除成功" example({"success":"yes"})
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 404 {object} object{error=string} "文件或目录不存在"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 404 {object} apierr.Response "文件或目录不存在"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/ [delete]
 func (h *FilesHandler) Delete(c *gin.Context) {
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+	path, err := client.RepackLinkPath(req.Path)
+	if err != nil {
+		apierr.Respond(c, err)
 		return
 	}
-	sshClient := h.Server.Clients[key].SSHClient
-	sftpClient := h.Server.Clients[key].SftpClient
-	path := h.Server.Clients[key].RepackPath(req.Path)
 	log.Println("delete path:", path)
 	fileInfo, err := sftpClient.Lstat(path)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
 	if fileInfo.IsDir() {
 		if req.ForceDir {
-			sshSession, err := sshClient.NewSession()
-			if err != nil {
-				log.Println(err)
-				c.JSON(http.StatusInternalServerError, err)
-				return
-			}
-			defer sshSession.Close()
-			cmd := fmt.Sprintf("rm -rf %s", path)
-			err = sshSession.Run(cmd)
+			cmd := utils.RemoveCommand(path)
+			_, err = service.Exec(c.Request.Context(), client.Executor(), cmd)
 			if err != nil {
 				log.Println(err)
-				c.JSON(http.StatusInternalServerError, err)
+				apierr.Respond(c, err)
 				return
 			}
 		} else {
@@ -567,10 +539,10 @@ func (h *FilesHandler) Delete(c *gin.Context) {
 	}
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
-	c.JSON(200, map[string]interface{}{"success": "yes"})
+	apierr.OK(c, http.StatusOK, nil)
 }
 
 // Copy copies a file or directory
@@ -579,73 +551,58 @@ func (h *FilesHandler) Delete(c *gin.Context) {
 // @Tags 文件管理
 // @Accept json
 // @Produce json
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param request body object{src_path=string,dst_path=string} true "复制请求参数" Example({"src_path":"/ai/new_folder_rename/test_renamed.sh","dst_path":"/ai/new_folder_rename/test_copy.sh"})
 // @Success 200 {object} object{success=string} "复制成功" example({"success":"yes"})
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 404 {object} object{error=string} "源文件或目录不存在"
-// @Failure 409 {object} object{error=string} "目标路径已存在"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 404 {object} apierr.Response "源文件或目录不存在"
+// @Failure 409 {object} apierr.Response "目标路径已存在"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/copy/ [post]
 func (h *FilesHandler) Copy(c *gin.Context) {
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	sftpClient := h.Server.Clients[key].SftpClient
-	sshClient := h.Server.Clients[key].SSHClient
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
 
 	srcPath := req.SrcPath
 	dstPath := req.DstPath
-	srcPath = h.Server.Clients[key].RepackPath(srcPath)
-	dstPath = h.Server.Clients[key].RepackPath(dstPath)
-	fmt.Println("sftpClient:", sftpClient, "sshClient:", sshClient, " srcPath:", srcPath, "dstPath:", dstPath)
+	srcPath, err = client.RepackPath(srcPath)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	dstPath, err = client.RepackLinkPath(dstPath)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
 
 	srcFileInfo, err := sftpClient.Lstat(srcPath)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
 	_, err = sftpClient.Lstat(dstPath)
 	if err == nil {
 		// dist path exist
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, errors.New("file exist"))
+		apierr.Abort(c, apierr.PathExists, "file exist")
 		return
 	} else {
-		sshSession, err := sshClient.NewSession()
+		// copy file or dir
+		cmd := utils.CopyCommand(srcPath, dstPath, srcFileInfo.IsDir())
+		_, err = service.Exec(c.Request.Context(), client.Executor(), cmd)
 		if err != nil {
 			log.Println(err)
-			c.JSON(http.StatusInternalServerError, err)
+			apierr.Respond(c, err)
 			return
 		}
-		defer sshSession.Close()
-		if srcFileInfo.IsDir() {
-			// copy dir
-			cmd := fmt.Sprintf("cp -r %s %s", srcPath, dstPath)
-			err = sshSession.Run(cmd)
-			if err != nil {
-				log.Println(err)
-				c.JSON(http.StatusInternalServerError, err)
-				return
-			}
-		} else {
-			// copy file
-			cmd := fmt.Sprintf("cp %s %s", srcPath, dstPath)
-			err = sshSession.Run(cmd)
-			if err != nil {
-				log.Println(err)
-				c.JSON(http.StatusInternalServerError, err)
-				return
-			}
-			c.JSON(200, map[string]interface{}{"success": "yes"})
-		}
+		apierr.OK(c, http.StatusOK, nil)
 	}
 }
 
@@ -655,73 +612,58 @@ func (h *FilesHandler) Copy(c *gin.Context) {
 // @Tags 文件管理
 // @Accept json
 // @Produce json
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param request body object{src_path=string,dst_path=string} true "移动请求参数" Example({"src_path":"/ai/new_folder_rename/test_copy.sh","dst_path":"/ai/new_folder_rename/test_moved.sh"})
 // @Success 200 {object} object{success=string} "移动成功" example({"success":"yes"})
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 404 {object} object{error=string} "源文件或目录不存在"
-// @Failure 409 {object} object{error=string} "目标路径已存在"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 404 {object} apierr.Response "源文件或目录不存在"
+// @Failure 409 {object} apierr.Response "目标路径已存在"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/move/ [post]
 func (h *FilesHandler) Move(c *gin.Context) {
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	sftpClient := h.Server.Clients[key].SftpClient
-	sshClient := h.Server.Clients[key].SSHClient
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
 
 	srcPath := req.SrcPath
 	dstPath := req.DstPath
-	srcPath = h.Server.Clients[key].RepackPath(srcPath)
-	dstPath = h.Server.Clients[key].RepackPath(dstPath)
-	log.Println("sftpClient:", sftpClient, "sshClient:", sshClient, " srcPath:", srcPath, "dstPath:", dstPath)
+	srcPath, err = client.RepackLinkPath(srcPath)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	dstPath, err = client.RepackLinkPath(dstPath)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
 
-	srcFileInfo, err := sftpClient.Lstat(srcPath)
+	_, err = sftpClient.Lstat(srcPath)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
 	_, err = sftpClient.Lstat(dstPath)
 	if err == nil {
 		// dist path exist
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, errors.New("file exist"))
+		apierr.Abort(c, apierr.PathExists, "file exist")
 		return
 	} else {
-		sshSession, err := sshClient.NewSession()
+		// move file or dir
+		cmd := utils.MoveCommand(srcPath, dstPath)
+		_, err = service.Exec(c.Request.Context(), client.Executor(), cmd)
 		if err != nil {
 			log.Println(err)
-			c.JSON(http.StatusInternalServerError, err)
+			apierr.Respond(c, err)
 			return
 		}
-		defer sshSession.Close()
-		if srcFileInfo.IsDir() {
-			// move dir
-			cmd := fmt.Sprintf("mv %s %s", srcPath, dstPath)
-			err = sshSession.Run(cmd)
-			if err != nil {
-				log.Println(err)
-				c.JSON(http.StatusInternalServerError, err)
-				return
-			}
-		} else {
-			// move file
-			cmd := fmt.Sprintf("mv %s %s", srcPath, dstPath)
-			err = sshSession.Run(cmd)
-			if err != nil {
-				log.Println(err)
-				c.JSON(http.StatusInternalServerError, err)
-				return
-			}
-			c.JSON(200, map[string]interface{}{"success": "yes"})
-		}
+		apierr.OK(c, http.StatusOK, nil)
 	}
 }
 
@@ -731,43 +673,43 @@ func (h *FilesHandler) Move(c *gin.Context) {
 // @Tags 文件管理
 // @Accept json
 // @Produce plain
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param cluster query string true "集群名称" example("hpc1")
 // @Param path query string true "文件路径" example("/ai/new_folder_rename/test_renamed.sh")
 // @Success 200 {string} string "文件内容" example("#!/bin/bash\\necho Hello World")
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 404 {object} object{error=string} "文件不存在"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 404 {object} apierr.Response "文件不存在"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/content/ [get]
 func (h *FilesHandler) ReadFile(c *gin.Context) {
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+
+	path, err := client.RepackPath(req.Path)
+	if err != nil {
+		apierr.Respond(c, err)
 		return
 	}
-	sftpClient := h.Server.Clients[key].SftpClient
-
-	path := h.Server.Clients[key].RepackPath(req.Path)
-	log.Println("sftpClient:", sftpClient, "path:", path)
 	fileInfo, err := sftpClient.Lstat(path)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
 	if fileInfo.IsDir() {
-		c.JSON(http.StatusBadRequest, errors.New("path is a directory"))
+		apierr.Abort(c, apierr.BadRequest, "path is a directory")
 		return
 	}
 
 	file, err := sftpClient.OpenFile(path, os.O_RDONLY)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
 	defer file.Close()
@@ -775,7 +717,7 @@ func (h *FilesHandler) ReadFile(c *gin.Context) {
 	content, err := ioutil.ReadAll(file)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
 
@@ -788,32 +730,32 @@ func (h *FilesHandler) ReadFile(c *gin.Context) {
 // @Tags 文件管理
 // @Accept json
 // @Produce json
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param request body object{cluster=string,path=string,content=string} true "写入内容请求参数" Example({"cluster":"hpc1","path":"/ai/new_folder_rename/test_renamed.sh","content":"#!/bin/bash\\necho Hello World"})
-// @Success 200 {object} object{content=string,success=string} "写入成功" example({"content":"","success":"yes"})
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 404 {object} object{error=string} "文件路径不存在"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Success 200 {object} object{success=string} "写入成功" example({"success":"yes"})
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 404 {object} apierr.Response "文件路径不存在"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/content/ [post]
 func (h *FilesHandler) WriteFile(c *gin.Context) {
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+	path, err := client.RepackPath(req.Path)
+	if err != nil {
+		apierr.Respond(c, err)
 		return
 	}
-	sftpClient := h.Server.Clients[key].SftpClient
-	path := h.Server.Clients[key].RepackPath(req.Path)
 	content := req.Content
-	log.Println("sftpClient:", sftpClient, "path:", path, " content:", content)
 
 	file, err := sftpClient.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
 	defer file.Close()
@@ -821,11 +763,11 @@ func (h *FilesHandler) WriteFile(c *gin.Context) {
 	_, err = file.Write([]byte(content))
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
 
-	c.JSON(200, map[string]interface{}{"success": "yes"})
+	apierr.OK(c, http.StatusOK, nil)
 }
 
 // ExecuteFile executes a script file
@@ -834,55 +776,47 @@ func (h *FilesHandler) WriteFile(c *gin.Context) {
 // @Tags 文件管理
 // @Accept json
 // @Produce plain
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param request body object{cluster=string,path=string,path=string,command_params=string} true "执行脚本请求参数" Example({"cluster":"hpc1","path":"/ai/new_folder_rename/test_renamed.sh","command_params":"--verbose"})
 // @Success 200 {string} string "执行成功，返回脚本输出" example("Hello World\nScript executed successfully")
-// @Failure 400 {object} object{error=string} "请求参数错误或路径是目录"
-// @Failure 404 {object} object{error=string} "脚本文件不存在"
-// @Failure 500 {object} object{error=string} "服务器内部错误、用户未登录或脚本执行失败"
+// @Failure 400 {object} apierr.Response "请求参数错误或路径是目录"
+// @Failure 404 {object} apierr.Response "脚本文件不存在"
+// @Failure 500 {object} apierr.Response "服务器内部错误、用户未登录或脚本执行失败"
 // @Router /api/v1/filesystem/files/execute/ [post]
 func (h *FilesHandler) ExecuteFile(c *gin.Context) {
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+	path, err := client.RepackPath(req.Path)
+	if err != nil {
+		apierr.Respond(c, err)
 		return
 	}
-	sftpClient := h.Server.Clients[key].SftpClient
-	sshClient := h.Server.Clients[key].SSHClient
-	path := h.Server.Clients[key].RepackPath(req.Path)
-	log.Println("sftpClient:", sftpClient, "sshClient:", sshClient, "path:", path)
 
 	fileInfo, err := sftpClient.Lstat(path)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
 	if fileInfo.IsDir() {
-		c.JSON(http.StatusBadRequest, errors.New("path is a directory"))
+		apierr.Abort(c, apierr.BadRequest, "path is a directory")
 		return
 	}
 
-	cmd := fmt.Sprintf("bash %s", path)
-	if req.CommandParams != "" {
-		cmd = fmt.Sprintf("bash %s %s", path, req.CommandParams)
-	}
-	session, err := sshClient.NewSession()
+	cmd, err := utils.ExecuteCommand(path, req.CommandParams)
 	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
 		return
 	}
-	defer session.Close()
-
-	output, err := session.CombinedOutput(cmd)
+	output, err := service.CombinedOutput(c.Request.Context(), client.Executor(), cmd)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, apierr.From(err).With("output", string(output)))
 		return
 	}
 
@@ -895,43 +829,43 @@ func (h *FilesHandler) ExecuteFile(c *gin.Context) {
 // @Tags 文件管理
 // @Accept json
 // @Produce json
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param request body object{path=string,mode=string} true "修改权限请求参数" Example({"path":"/ai/new_folder_rename/test_renamed.sh","mode":"755"})
 // @Success 200 {object} object{success=string} "修改权限成功" example({"success":"yes"})
-// @Failure 400 {object} object{error=string} "请求参数错误或权限格式无效"
-// @Failure 404 {object} object{error=string} "文件或目录不存在"
-// @Failure 403 {object} object{error=string} "没有权限修改该文件"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Failure 400 {object} apierr.Response "请求参数错误或权限格式无效"
+// @Failure 404 {object} apierr.Response "文件或目录不存在"
+// @Failure 403 {object} apierr.Response "没有权限修改该文件"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/chmod/ [post]
 func (h *FilesHandler) Chmod(c *gin.Context) {
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+
+	path, err := client.RepackPath(req.Path)
+	if err != nil {
+		apierr.Respond(c, err)
 		return
 	}
-	sftpClient := h.Server.Clients[key].SftpClient
-
-	path := h.Server.Clients[key].RepackPath(req.Path)
 	modeStr := req.Mode
-	log.Println("sftpClient:", sftpClient, "path:", path, " mode:", modeStr)
 
 	mode, err := strconv.ParseUint(modeStr, 8, 32)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, fmt.Errorf("invalid mode %q: %v", modeStr, err)))
 		return
 	}
 
 	err = sftpClient.Chmod(path, os.FileMode(mode))
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
-	c.JSON(200, map[string]interface{}{"success": "yes"})
+	apierr.OK(c, http.StatusOK, nil)
 }
 
 // Chown changes file or directory ownership
@@ -940,51 +874,55 @@ func (h *FilesHandler) Chmod(c *gin.Context) {
 // @Tags 文件管理
 // @Accept json
 // @Produce json
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param request body object{path=string,owner=string,group=string} true "修改所有者请求参数" Example({"path":"/ai/new_folder_rename/test_renamed.sh","owner":"1000","group":"1000"})
 // @Success 200 {object} object{success=string} "修改所有者成功" example({"success":"yes"})
-// @Failure 400 {object} object{error=string} "请求参数错误或用户ID/组ID格式无效"
-// @Failure 404 {object} object{error=string} "文件或目录不存在"
-// @Failure 403 {object} object{error=string} "没有权限修改该文件所有者"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Failure 400 {object} apierr.Response "请求参数错误或用户ID/组ID格式无效"
+// @Failure 404 {object} apierr.Response "文件或目录不存在"
+// @Failure 403 {object} apierr.Response "没有权限修改该文件所有者"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/files/chown/ [post]
 func (h *FilesHandler) Chown(c *gin.Context) {
-	key, req, err := h.GetKeyFromRequest(c)
+	req, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
+	client := public.CurrentClient(c)
+	if err := client.Principal.AuthorizeChown(); err != nil {
+		apierr.Respond(c, err)
 		return
 	}
-	sftpClient := h.Server.Clients[key].SftpClient
+	sftpClient := client.SFTP()
 
-	path := h.Server.Clients[key].RepackPath(req.Path)
+	path, err := client.RepackPath(req.Path)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
 	owner := req.Owner
 	group := req.Group
-	log.Println("sftpClient:", sftpClient, "path:", path, " owner:", owner, " group:", group)
 	// Validate owner and group
 	if owner == "" || group == "" {
-		c.JSON(http.StatusBadRequest, errors.New("owner and group must be provided"))
+		apierr.Abort(c, apierr.BadRequest, "owner and group must be provided")
 		return
 	}
 	// Convert owner and group to int
 	ownerID, err := strconv.Atoi(owner)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, fmt.Errorf("invalid owner ID: %v", err))
+		apierr.Abort(c, apierr.BadRequest, "invalid owner ID: %v", err)
 		return
 	}
 	groupID, err := strconv.Atoi(group)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, fmt.Errorf("invalid group ID: %v", err))
+		apierr.Abort(c, apierr.BadRequest, "invalid group ID: %v", err)
 		return
 	}
 	err = sftpClient.Chown(path, ownerID, groupID)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, err)
 		return
 	}
-	c.JSON(200, map[string]interface{}{"success": "yes"})
+	apierr.OK(c, http.StatusOK, nil)
 }
@@ -4,8 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"log"
 	"net/http"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
+	"star-dim/internal/service"
+	"star-dim/internal/utils"
 	"strconv"
 	"strings"
 )
@@ -72,51 +75,33 @@ func ParseQuotaOutput(output string) (*QuotaInfo, error) {
 // @Tags 文件管理
 // @Accept json
 // @Produce json
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Param cluster query string true "集群名称" example("hpc1")
 // @Param path query string false "查询路径" example("/home/user")
-// @Success 200 {object} QuotaInfo "获取配额信息成功" example({"filesystem":"/dev/sda1","kbytes":1024000,"kbytes_quota":2048000,"kbytes_limit":2097152,"kbytes_grace":"none","files":1000,"files_quota":5000,"files_limit":10000,"files_grace":"none"})
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 404 {object} object{error=string} "路径不存在或配额信息不可用"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
+// @Success 200 {object} object{quota=QuotaInfo,success=string} "获取配额信息成功" example({"success":"yes","quota":{"filesystem":"/dev/sda1","kbytes":1024000,"kbytes_quota":2048000,"kbytes_limit":2097152,"kbytes_grace":"none","files":1000,"files_quota":5000,"files_limit":10000,"files_grace":"none"}})
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 404 {object} apierr.Response "路径不存在或配额信息不可用"
+// @Failure 500 {object} apierr.Response "服务器内部错误或用户未登录"
 // @Router /api/v1/filesystem/quota/ [get]
 func (h *FilesHandler) Quota(c *gin.Context) {
-	key, _, err := h.GetKeyFromRequest(c)
+	_, err := h.GetRequestInfo(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		apierr.Respond(c, err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	sshClient := h.Server.Clients[key].SSHClient
-
-	session, err := sshClient.NewSession()
-	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
-		return
-	}
-	defer session.Close()
-
-	cmd := "lfs quota -u " + h.Server.Clients[key].UserInfo.Name + " /"
-	output, err := session.CombinedOutput(cmd)
+	client := public.CurrentClient(c)
+	cmd := utils.NewCommand("lfs", "quota", "-u", client.UserInfo.Name, "/").String()
+	output, err := service.CombinedOutput(c.Request.Context(), client.Executor(), cmd)
 	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, apierr.From(err).With("command", cmd).With("output", strings.TrimSpace(string(output))))
 		return
 	}
 
 	quotaInfo, err := ParseQuotaOutput(string(output))
 	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, apierr.Wrap(apierr.Internal, err))
 		return
 	}
 
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"quota":   quotaInfo,
-		"success": "yes",
-	})
+	apierr.OK(c, http.StatusOK, gin.H{"quota": quotaInfo})
 }
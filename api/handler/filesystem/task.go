@@ -0,0 +1,298 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"path"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
+	"star-dim/internal/models"
+	"star-dim/internal/service"
+	"strings"
+	"time"
+)
+
+// archiveTaskTimeout 解压和压缩任务在登录节点上执行的最长时间
+const archiveTaskTimeout = 24 * time.Hour
+
+// startTask 在后台执行任务，执行期间会话不会因空闲而过期，返回 202 和任务状态
+func (h *FilesHandler) startTask(c *gin.Context, client *public.UserClient, typ, src, dst string,
+	run func(ctx context.Context, task *service.Task) error) {
+	client.Acquire()
+	task := h.Server.Tasks.Start(clusterUser(c), typ, src, dst, func(ctx context.Context, task *service.Task) error {
+		defer client.Release()
+		return run(ctx, task)
+	})
+	prefix := c.Request.URL.Path[:strings.Index(c.Request.URL.Path, "/files/")]
+	c.Header("Location", prefix+"/tasks/"+task.ID+"/")
+	apierr.OK(c, http.StatusAccepted, gin.H{"task": task.Status()})
+}
+
+// Extract extracts an archive on the login node
+// @Summary 解压
+// @Description 在登录节点上解压 zip、tar、tar.gz、tar.bz2、tar.zst 压缩包，作为后台任务执行，通过任务接口查询进度。
+// @Description 解压前列出全部条目，名称为绝对路径或包含 ..、链接指向解压目录以外、会写入指向允许访问的目录以外的已有目录时拒绝解压
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Param request body models.ExtractRequest true "解压参数"
+// @Success 202 {object} object{task=models.TaskStatus,success=string} "任务已创建"
+// @Failure 400 {object} apierr.Response "请求参数错误或不支持的格式"
+// @Failure 403 {object} apierr.Response "路径不在允许访问的目录内"
+// @Failure 404 {object} apierr.Response "压缩包不存在"
+// @Failure 500 {object} apierr.Response "服务器内部错误"
+// @Router /api/v1/filesystem/files/extract/ [post]
+func (h *FilesHandler) Extract(c *gin.Context) {
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+
+	var req models.ExtractRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	archive, err := client.RepackPath(req.Path)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	format, err := service.TaskArchiveFormat(req.Format, archive)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	if fi, err := sftpClient.Stat(archive); err != nil {
+		apierr.Respond(c, err)
+		return
+	} else if fi.IsDir() {
+		apierr.Abort(c, apierr.BadRequest, "%s is a directory", req.Path)
+		return
+	}
+	destination := req.Destination
+	if destination == "" {
+		destination = path.Dir(archive)
+	}
+	dest, err := client.RepackPath(destination)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	if fi, err := sftpClient.Stat(dest); err == nil && !fi.IsDir() {
+		apierr.Abort(c, apierr.BadRequest, "%s is not a directory", destination)
+		return
+	}
+
+	h.startTask(c, client, service.TaskExtract, archive, dest, func(ctx context.Context, task *service.Task) error {
+		return extract(ctx, client, task, archive, dest, format, req.Overwrite)
+	})
+}
+
+// extract 列出并检查压缩包的条目后解压
+func extract(ctx context.Context, client *public.UserClient, task *service.Task, archive, dest, format string, overwrite bool) error {
+	executor := client.Executor()
+
+	task.SetPhase(service.TaskListing, 0)
+	cmd, err := service.ArchiveListCommand(archive, format)
+	if err != nil {
+		return err
+	}
+	result, err := executor.Run(ctx, &service.Command{Cmd: cmd, Timeout: archiveTaskTimeout})
+	if err != nil {
+		return err
+	}
+	members, err := service.ParseArchiveListing(format, string(result.Stdout))
+	if err != nil {
+		return err
+	}
+	if err := service.CheckArchiveMembers(members); err != nil {
+		return err
+	}
+	if err := client.SFTP().MkdirAll(dest); err != nil {
+		return err
+	}
+	// 解压目录中已存在的目录可能是指向允许访问的目录以外的符号链接
+	for _, dir := range service.ArchiveMemberDirs(members) {
+		if _, err := client.RepackPath(path.Join(dest, dir)); err != nil {
+			return err
+		}
+	}
+
+	task.SetPhase(service.TaskExtracting, len(members))
+	if cmd, err = service.ExtractCommand(archive, dest, format, overwrite); err != nil {
+		return err
+	}
+	_, err = executor.Run(ctx, &service.Command{
+		Cmd: cmd,
+		Stdout: task.Lines(func(line string) (string, bool) {
+			return service.ArchiveProgressLine(format, line)
+		}),
+		Timeout: archiveTaskTimeout,
+	})
+	return err
+}
+
+// Compress creates an archive on the login node
+// @Summary 压缩
+// @Description 在登录节点上将同一目录下的文件和目录压缩为 zip、tar、tar.gz、tar.bz2、tar.zst 压缩包，作为后台任务执行，通过任务接口查询进度。
+// @Description 不打包符号链接；压缩包先写入同目录下的临时文件，完成后重命名
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Param request body models.CompressRequest true "压缩参数"
+// @Success 202 {object} object{task=models.TaskStatus,success=string} "任务已创建"
+// @Failure 400 {object} apierr.Response "请求参数错误或不支持的格式"
+// @Failure 403 {object} apierr.Response "路径不在允许访问的目录内"
+// @Failure 409 {object} apierr.Response "压缩包已存在且未设置覆盖标志"
+// @Failure 500 {object} apierr.Response "服务器内部错误"
+// @Router /api/v1/filesystem/files/compress/ [post]
+func (h *FilesHandler) Compress(c *gin.Context) {
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+
+	var req models.CompressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	dst, err := client.RepackPath(req.Destination)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	format, err := service.TaskArchiveFormat(req.Format, dst)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	if !req.Overwrite {
+		if _, err := sftpClient.Lstat(dst); err == nil {
+			apierr.Abort(c, apierr.PathExists, "file exist")
+			return
+		}
+	}
+	var sources []string
+	for _, p := range req.Paths {
+		src, err := client.RepackPath(p)
+		if err != nil {
+			apierr.Respond(c, err)
+			return
+		}
+		if _, err := sftpClient.Stat(src); err != nil {
+			apierr.Respond(c, err)
+			return
+		}
+		// 条目名称相对于共同的父目录
+		if len(sources) > 0 && path.Dir(src) != path.Dir(sources[0]) {
+			apierr.Abort(c, apierr.BadRequest, "paths must be in the same directory")
+			return
+		}
+		sources = append(sources, src)
+	}
+
+	h.startTask(c, client, service.TaskCompress, strings.Join(sources, ","), dst, func(ctx context.Context, task *service.Task) error {
+		return compress(ctx, client, task, sources, dst, format, req.Overwrite)
+	})
+}
+
+// compress 遍历要打包的文件，打包到临时文件后重命名为压缩包
+func compress(ctx context.Context, client *public.UserClient, task *service.Task, sources []string, dst, format string, overwrite bool) error {
+	sftpClient := client.SFTP()
+
+	task.SetPhase(service.TaskListing, 0)
+	var entries []service.ArchiveEntry
+	for _, src := range sources {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		found, _, err := service.CollectArchiveEntries(sftpClient.Walk(src), src, path.Base(src), nil, 0)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, found...)
+	}
+
+	task.SetPhase(service.TaskCompressing, len(entries))
+	part := path.Join(path.Dir(dst), ".star-dim-task-"+task.ID+".part")
+	cmd, input, err := service.CompressCommand(path.Dir(sources[0]), part, format, entries)
+	if err != nil {
+		return err
+	}
+	_, err = client.Executor().Run(ctx, &service.Command{
+		Cmd:   cmd,
+		Stdin: input,
+		Stdout: task.Lines(func(line string) (string, bool) {
+			return service.ArchiveProgressLine(format, line)
+		}),
+		Timeout: archiveTaskTimeout,
+	})
+	if format != service.ArchiveZip && service.IsTarWarning(err) {
+		log.Printf("tar %s: files changed while archiving", dst)
+		err = nil
+	}
+	// PosixRename 覆盖已存在的压缩包，Rename 在压缩包存在时失败
+	if err == nil && overwrite {
+		err = sftpClient.PosixRename(part, dst)
+	} else if err == nil {
+		err = sftpClient.Rename(part, dst)
+	}
+	if err != nil {
+		if removeErr := sftpClient.Remove(part); removeErr != nil {
+			log.Printf("remove partial archive %s: %v", part, removeErr)
+		}
+	}
+	return err
+}
+
+// ListTasks lists the background tasks of the cluster user
+// @Summary 列出后台任务
+// @Description 返回当前用户在该集群上的解压和压缩任务，重新登录后仍可查询，结束的任务保留一段时间后清理
+// @Tags 文件管理
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Success 200 {object} object{tasks=[]models.TaskStatus,success=string} "任务列表"
+// @Router /api/v1/filesystem/tasks/ [get]
+func (h *FilesHandler) ListTasks(c *gin.Context) {
+	apierr.OK(c, http.StatusOK, gin.H{"tasks": h.Server.Tasks.List(clusterUser(c))})
+}
+
+// TaskStatus returns the progress of a background task
+// @Summary 查询后台任务
+// @Description 返回任务的状态、阶段和进度，失败时包含错误信息
+// @Tags 文件管理
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Param id path string true "任务ID"
+// @Success 200 {object} object{task=models.TaskStatus,success=string} "任务状态"
+// @Failure 404 {object} apierr.Response "任务不存在或已清理"
+// @Router /api/v1/filesystem/tasks/{id}/ [get]
+func (h *FilesHandler) TaskStatus(c *gin.Context) {
+	task, err := h.Server.Tasks.Get(c.Param("id"), clusterUser(c))
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, gin.H{"task": task.Status()})
+}
+
+// CancelTask cancels a running task or removes a finished one
+// @Summary 取消后台任务
+// @Description 取消进行中的任务并终止登录节点上的命令，已解压的文件不会删除；已结束的任务从列表中移除
+// @Tags 文件管理
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Param id path string true "任务ID"
+// @Success 200 {object} object{task=models.TaskStatus,success=string} "任务状态"
+// @Failure 404 {object} apierr.Response "任务不存在或已清理"
+// @Router /api/v1/filesystem/tasks/{id}/ [delete]
+func (h *FilesHandler) CancelTask(c *gin.Context) {
+	task, err := h.Server.Tasks.Cancel(c.Param("id"), clusterUser(c))
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, gin.H{"task": task.Status()})
+}
@@ -0,0 +1,259 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
+	"star-dim/internal/models"
+	"star-dim/internal/service"
+	"star-dim/internal/utils"
+	"strconv"
+	"strings"
+)
+
+// clusterUser 返回当前集群用户，上传和后台任务按集群用户保存，重新登录后可以继续访问
+func clusterUser(c *gin.Context) string {
+	claims := public.CurrentClaims(c)
+	return service.UploadOwner(claims.Cluster, claims.Subject)
+}
+
+// CreateUpload starts a chunked upload
+// @Summary 创建分块上传
+// @Description 创建可断点续传的分块上传会话，并在目标目录下预分配临时文件。之后可按任意顺序并行上传分块，全部上传后调用完成接口
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Param request body models.UploadRequest true "上传参数"
+// @Success 201 {object} object{upload=models.UploadStatus,success=string} "创建成功"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 403 {object} apierr.Response "路径不在允许访问的目录内"
+// @Failure 409 {object} apierr.Response "文件已存在且未设置覆盖标志"
+// @Failure 500 {object} apierr.Response "服务器内部错误"
+// @Router /api/v1/filesystem/uploads/ [post]
+func (h *FilesHandler) CreateUpload(c *gin.Context) {
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+
+	var req models.UploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	dst, err := client.RepackPath(req.Path)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	if !req.Overwrite {
+		if _, err := sftpClient.Lstat(dst); err == nil {
+			apierr.Abort(c, apierr.PathExists, "file exist")
+			return
+		}
+	}
+	upload, err := h.Server.Uploads.Create(clusterUser(c), dst, &req)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+
+	// 预先创建临时文件并设置大小，分块可以按任意顺序写入
+	f, err := sftpClient.OpenFile(upload.PartPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err == nil {
+		err = f.Truncate(upload.Size)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		_ = h.Server.Uploads.Abort(upload.ID, upload.Owner)
+		apierr.Respond(c, err)
+		return
+	}
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+upload.ID+"/")
+	apierr.OK(c, http.StatusCreated, gin.H{"upload": upload.Status()})
+}
+
+// UploadStatus returns the progress of a chunked upload
+// @Summary 查询分块上传状态
+// @Description 返回已接收和缺失的分块，用于中断后续传。上传属于集群用户，会话过期重新登录后仍可查询和续传
+// @Tags 文件管理
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Param id path string true "上传ID"
+// @Success 200 {object} object{upload=models.UploadStatus,success=string} "上传状态"
+// @Failure 404 {object} apierr.Response "上传不存在或已过期"
+// @Router /api/v1/filesystem/uploads/{id}/ [get]
+func (h *FilesHandler) UploadStatus(c *gin.Context) {
+	upload, err := h.Server.Uploads.Get(c.Param("id"), clusterUser(c))
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, gin.H{"upload": upload.Status()})
+}
+
+// UploadChunk writes one chunk of a chunked upload
+// @Summary 上传分块
+// @Description 请求体为分块的原始内容，直接流式写入登录节点上的临时文件。可通过 Upload-Checksum 请求头（tus 格式，如 sha256 <base64>）校验分块内容，失败的分块可重新上传
+// @Tags 文件管理
+// @Accept application/octet-stream
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Param Upload-Checksum header string false "分块的 SHA-256" example("sha256 47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=")
+// @Param id path string true "上传ID"
+// @Param index path int true "分块序号，从 0 开始"
+// @Success 200 {object} object{upload=models.UploadStatus,success=string} "上传状态"
+// @Failure 400 {object} apierr.Response "分块序号或长度错误"
+// @Failure 404 {object} apierr.Response "上传不存在或已过期"
+// @Failure 460 {object} apierr.Response "分块校验失败"
+// @Failure 500 {object} apierr.Response "服务器内部错误"
+// @Router /api/v1/filesystem/uploads/{id}/chunks/{index}/ [put]
+func (h *FilesHandler) UploadChunk(c *gin.Context) {
+	client := public.CurrentClient(c)
+
+	upload, err := h.Server.Uploads.Get(c.Param("id"), clusterUser(c))
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest, "invalid chunk index %q", c.Param("index"))
+		return
+	}
+	checksum, err := service.ParseUploadChecksum(c.GetHeader("Upload-Checksum"))
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+
+	f, err := client.SFTP().OpenFile(upload.PartPath, os.O_WRONLY)
+	if err != nil {
+		log.Println(err)
+		apierr.Respond(c, err)
+		return
+	}
+	defer f.Close()
+	if err := upload.WriteChunk(f, index, c.Request.Body, checksum); err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, gin.H{"upload": upload.Status()})
+}
+
+// CompleteUpload verifies a chunked upload and moves it into place
+// @Summary 完成分块上传
+// @Description 所有分块上传后校验整个文件的 SHA-256，通过后将临时文件重命名为目标文件
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Param id path string true "上传ID"
+// @Param request body models.UploadCompleteRequest false "整个文件的 SHA-256"
+// @Success 200 {object} object{success=string,path=string,size=int,sha256=string} "上传完成"
+// @Failure 404 {object} apierr.Response "上传不存在或已过期"
+// @Failure 409 {object} apierr.Response "仍有分块未上传或目标文件已存在"
+// @Failure 460 {object} apierr.Response "文件校验失败"
+// @Failure 500 {object} apierr.Response "服务器内部错误"
+// @Router /api/v1/filesystem/uploads/{id}/complete/ [post]
+func (h *FilesHandler) CompleteUpload(c *gin.Context) {
+	client := public.CurrentClient(c)
+	sftpClient := client.SFTP()
+
+	upload, err := h.Server.Uploads.Get(c.Param("id"), clusterUser(c))
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	var req models.UploadCompleteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+			return
+		}
+	}
+	if missing := upload.Missing(); len(missing) > 0 {
+		apierr.Respond(c, apierr.From(service.ErrUploadIncomplete).With("missing", missing))
+		return
+	}
+
+	sum, err := fileSHA256(c, client, upload.PartPath)
+	if err != nil {
+		log.Println(err)
+		apierr.Respond(c, err)
+		return
+	}
+	if err := upload.VerifySHA256(req.SHA256, sum); err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+
+	// PosixRename 覆盖已存在的目标文件，Rename 在目标文件存在时失败
+	if upload.Overwrite {
+		err = sftpClient.PosixRename(upload.PartPath, upload.Path)
+	} else {
+		err = sftpClient.Rename(upload.PartPath, upload.Path)
+	}
+	if err != nil {
+		log.Println(err)
+		if _, statErr := sftpClient.Lstat(upload.Path); statErr == nil && !upload.Overwrite {
+			apierr.Abort(c, apierr.PathExists, "file exist")
+			return
+		}
+		apierr.Respond(c, err)
+		return
+	}
+	h.Server.Uploads.Remove(upload.ID)
+	apierr.OK(c, http.StatusOK, gin.H{"path": upload.Path, "size": upload.Size, "sha256": sum})
+}
+
+// AbortUpload cancels a chunked upload
+// @Summary 取消分块上传
+// @Description 取消上传并删除临时文件
+// @Tags 文件管理
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Param id path string true "上传ID"
+// @Success 200 {object} object{success=string} "取消成功"
+// @Failure 404 {object} apierr.Response "上传不存在或已过期"
+// @Router /api/v1/filesystem/uploads/{id}/ [delete]
+func (h *FilesHandler) AbortUpload(c *gin.Context) {
+	if err := h.Server.Uploads.Abort(c.Param("id"), clusterUser(c)); err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	apierr.OK(c, http.StatusOK, nil)
+}
+
+// fileSHA256 计算登录节点上文件的 SHA-256。优先在登录节点上执行 sha256sum，避免回传文件内容，
+// 命令不可用时通过 SFTP 读取文件计算
+func fileSHA256(c *gin.Context, client *public.UserClient, name string) (string, error) {
+	cmd := utils.SHA256Command(name)
+	if result, err := service.Exec(c.Request.Context(), client.Executor(), cmd); err == nil {
+		// 文件名含反斜杠或换行时 sha256sum 在输出行首加反斜杠
+		fields := strings.Fields(string(result.Stdout))
+		if len(fields) > 0 {
+			if sum := strings.TrimPrefix(fields[0], "\\"); len(sum) == sha256.Size*2 {
+				return sum, nil
+			}
+		}
+	}
+	f, err := client.SFTP().Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
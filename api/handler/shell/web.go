@@ -209,32 +209,18 @@ func NewWebshellHandler(server *public.Server) *WebshellHandler {
 	}
 }
 
-func (h *WebshellHandler) GetKeyFromRequest(c *gin.Context) (string, *models.RequestInfo, error) {
-	// ssh session key must be in header
-	sessionKey := c.GetHeader("sessionKey")
-	if sessionKey == "" {
-		return "", nil, fmt.Errorf("sessionKey is required in header")
-	}
-	var requestInfo models.RequestInfo
-	return sessionKey, &requestInfo, nil
-}
-
 // webssh
 func (h *WebshellHandler) SSH(c *gin.Context) {
-	key, _, err := h.GetKeyFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
+	client := public.CurrentClient(c)
 	ip := c.ClientIP()
 	ws, err := upgrade.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
+	// 终端打开期间会话不会因空闲而过期
+	client.Acquire()
+	defer client.Release()
 	//iows := IOWebsocket{
 	//	Conn:   ws,
 	//	RLock:  sync.Mutex{},
@@ -252,17 +238,17 @@ func (h *WebshellHandler) SSH(c *gin.Context) {
 	//<-ch
 	var recorder *models.Recorder
 	if h.Server.Record {
-		filename := fmt.Sprintf("%s_%s_%s_%s.cast", h.Server.Clients[key].UserInfo.Cluster.Name,
-			h.Server.Clients[key].UserInfo.Name, strings.Replace(ip, ":", "", -1), time.Now().Format("20060102_150405"))
+		filename := fmt.Sprintf("%s_%s_%s_%s.cast", client.UserInfo.Cluster.Name,
+			client.UserInfo.Name, strings.Replace(ip, ":", "", -1), time.Now().Format("20060102_150405"))
 		var recordFilePath = ""
 		var recordFileDir = ""
 		switch runtime.GOOS {
 		case "windows":
-			recordFileDir = filepath.Join(h.Server.RecordPath, h.Server.Clients[key].UserInfo.Cluster.Name, h.Server.Clients[key].UserInfo.Name)
+			recordFileDir = filepath.Join(h.Server.RecordPath, client.UserInfo.Cluster.Name, client.UserInfo.Name)
 			recordFilePath = filepath.Join(recordFileDir, filename)
 			break
 		case "linux":
-			recordFileDir = path.Join(h.Server.RecordPath, h.Server.Clients[key].UserInfo.Cluster.Name, h.Server.Clients[key].UserInfo.Name)
+			recordFileDir = path.Join(h.Server.RecordPath, client.UserInfo.Cluster.Name, client.UserInfo.Name)
 			recordFilePath = path.Join(recordFileDir, filename)
 			break
 		default:
@@ -270,18 +256,24 @@ func (h *WebshellHandler) SSH(c *gin.Context) {
 		}
 		err = os.MkdirAll(recordFileDir, 0766)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, err)
+			log.Println(err)
+			_ = ws.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()), time.Now().Add(time.Second))
+			return
 		}
 		fmt.Println("recordFilePath=", recordFilePath)
 		f, err := os.OpenFile(recordFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0766)
 		if err != nil {
-			c.AbortWithStatusJSON(200, gin.H{"ok": false, "msg": err.Error()})
+			log.Println(err)
+			_ = ws.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()), time.Now().Add(time.Second))
+			return
 		}
 		defer f.Close()
 		recorder = models.NewRecorder(f)
 	}
 
-	t, err := NewTransfer(ws, h.Server.Clients[key].SSHClient, recorder, h.Server.Clients[key].UserInfo.Cluster.Name, h.Server.Clients[key].UserInfo.Name)
+	t, err := NewTransfer(ws, client.SSH(), recorder, client.UserInfo.Cluster.Name, client.UserInfo.Name)
 
 	if err != nil {
 		_ = ws.WriteControl(websocket.CloseMessage,
@@ -299,7 +291,7 @@ func (h *WebshellHandler) SSH(c *gin.Context) {
 	wg.Add(2)
 	cmdLog := make(chan LogInfo, 1)
 	logFileDir := "logs"
-	logFilePath := fmt.Sprintf("logs/%s_%s_%s.log", h.Server.Clients[key].UserInfo.Cluster.Name, h.Server.Clients[key].UserInfo.Name, key)
+	logFilePath := fmt.Sprintf("logs/%s_%s_%s.log", client.UserInfo.Cluster.Name, client.UserInfo.Name, c.GetString(public.ContextSessionKey))
 	err = os.MkdirAll(logFileDir, 0766)
 	l, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0766)
 	defer l.Close()
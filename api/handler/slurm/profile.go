@@ -0,0 +1,40 @@
+package slurm
+
+import (
+	"net/http"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProfile 返回会话所在集群的 Slurm 版本和支持的选项
+// @Summary 获取集群的Slurm版本信息
+// @Description 返回集群的Slurm版本、随版本变化的选项是否支持以及部分配置，首次使用集群时探测并缓存，refresh=true 时重新探测
+// @Tags 作业管理
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Param refresh query bool false "是否重新探测"
+// @Success 200 {object} object{profile=models.SlurmProfile,success=string} "查询成功"
+// @Failure 401 {object} apierr.Response "用户未认证"
+// @Failure 422 {object} apierr.Response "无法探测Slurm版本"
+// @Failure 502 {object} apierr.Response "SSH连接失败"
+// @Router /api/v1/slurm/profile/ [get]
+func (h *SlurmHandler) GetProfile(c *gin.Context) {
+	client := public.CurrentClient(c)
+	claims := public.CurrentClaims(c)
+	if h.Server.Profiles == nil || claims == nil {
+		apierr.Abort(c, apierr.NotFound, "Slurm version detection is not enabled")
+		return
+	}
+	cluster := claims.Cluster
+	if c.Query("refresh") == "true" {
+		h.Server.Profiles.Forget(cluster)
+	}
+	profile, err := h.Server.Profiles.Get(c.Request.Context(), cluster, client.Executor())
+	if err != nil {
+		apierr.Respond(c, slurmError(err, "Failed to detect Slurm version: "+err.Error(), "", ""))
+		return
+	}
+	apierr.OK(c, http.StatusOK, gin.H{"profile": profile})
+}
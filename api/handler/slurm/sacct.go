@@ -1,11 +1,11 @@
 package slurm
 
 import (
-	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
 	"star-dim/internal/models"
-	"star-dim/internal/service"
 )
 
 // sacct to show job accounting information
@@ -15,11 +15,11 @@ import (
 // @Accept json
 // @Produce json
 // @Param request body models.SacctRequest true "sacct请求参数"
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Success 200 {object} object{home_path=string,session_key=string} "查询成功，返回作业会计信息列表"
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 401 {object} object{error=string} "认证失败，用户名或密码错误"
-// @Failure 500 {object} object{error=string} "服务器内部错误或SSH连接失败"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 401 {object} apierr.Response "认证失败，用户名或密码错误"
+// @Failure 500 {object} apierr.Response "服务器内部错误或SSH连接失败"
 // @Router /api/v1/slurm/sacct/jobs/ [post]
 //func (h *SlurmHandler) GetJobs(c *gin.Context) {
 //	key := c.GetHeader("sessionKey")
@@ -94,11 +94,11 @@ import (
 // @Accept json
 // @Produce json
 // @Param request body models.SacctRequest true "sacct请求参数"
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Success 200 {object} object{home_path=string,session_key=string} "查询成功，返回作业会计信息列表"
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 401 {object} object{error=string} "认证失败，用户名或密码错误"
-// @Failure 500 {object} object{error=string} "服务器内部错误或SSH连接失败"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 401 {object} apierr.Response "认证失败，用户名或密码错误"
+// @Failure 500 {object} apierr.Response "服务器内部错误或SSH连接失败"
 // @Router /api/v1/slurm/sacct/jobs/{jobid}/ [post]
 //func (h *SlurmHandler) GetJobDetail(c *gin.Context) {
 //	key := c.GetHeader("sessionKey")
@@ -147,11 +147,11 @@ import (
 // @Accept json
 // @Produce json
 // @Param request body models.SacctRequest true "sacct请求参数"
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Success 200 {object} object{home_path=string,session_key=string} "查询成功，返回作业会计信息列表"
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 401 {object} object{error=string} "认证失败，用户名或密码错误"
-// @Failure 500 {object} object{error=string} "服务器内部错误或SSH连接失败"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 401 {object} apierr.Response "认证失败，用户名或密码错误"
+// @Failure 500 {object} apierr.Response "服务器内部错误或SSH连接失败"
 // @Router /api/v1/slurm/sacct/job/user/{user}/ [post]
 //func (h *SlurmHandler) GetUserJobs(c *gin.Context) {
 //	key := c.GetHeader("sessionKey")
@@ -204,39 +204,36 @@ import (
 // @Accept json
 // @Produce json
 // @Param request body models.SacctRequest true "sacct请求参数"
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Success 200 {object} object{home_path=string,session_key=string} "查询成功，返回作业会计信息列表"
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 401 {object} object{error=string} "认证失败，用户名或密码错误"
-// @Failure 500 {object} object{error=string} "服务器内部错误或SSH连接失败"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 401 {object} apierr.Response "认证失败，用户名或密码错误"
+// @Failure 403 {object} apierr.Response "无权查询其他用户的作业"
+// @Failure 500 {object} apierr.Response "服务器内部错误或SSH连接失败"
 // @Router /api/v1/slurm/account/ [post]
 func (h *SlurmHandler) GetAccounting(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	client := public.CurrentClient(c)
+
+	var req models.SacctRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Abort(c, apierr.BadRequest, "Invalid request format: %v", err)
 		return
 	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
+	if err := h.Parser.ValidateSacctRequest(&req); err != nil {
+		apierr.Abort(c, apierr.BadRequest, "Validation error: %v", err)
 		return
 	}
-
-	var req models.SacctRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.SacctResponse{
-			Success: "no",
-			Message: "Invalid request format: " + err.Error(),
-		})
+	if err := client.Principal.AuthorizeAccounting(&req); err != nil {
+		apierr.Respond(c, err)
 		return
 	}
 	// 执行命令
-	h.SSHClient = h.Server.Clients[key].SSHClient
-	sacctService := service.NewSlurmService(h.SSHClient, h.Parser)
-	response := sacctService.ExecuteSacct(&req)
+	sacctService := h.backend(c, client, h.Parser)
+	response := sacctService.ExecuteSacct(c.Request.Context(), &req)
 
-	if response.Success == "yes" {
-		c.JSON(http.StatusOK, response)
-	} else {
-		c.JSON(http.StatusInternalServerError, response)
+	if response.Success != "yes" {
+		apierr.Respond(c, slurmError(response.Err, response.Message, response.Command, response.RawOutput))
+		return
 	}
+	c.JSON(http.StatusOK, response)
 }
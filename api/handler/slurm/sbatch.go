@@ -1,14 +1,13 @@
 package slurm
 
 import (
-	"errors"
+	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
-	"star-dim/internal/service"
-	"star-dim/internal/utils"
-
-	"github.com/gin-gonic/gin"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
 	"star-dim/internal/models"
+	"star-dim/internal/utils"
 )
 
 // submit job by sbatch command
@@ -18,93 +17,59 @@ import (
 // @Accept json
 // @Produce json
 // @Param request body models.SbatchRequest true "sbatch请求参数"
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Success 200 {object} object{home_path=string,session_key=string} "查询成功，返回作业会计信息列表"
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 401 {object} object{error=string} "认证失败，用户名或密码错误"
-// @Failure 500 {object} object{error=string} "服务器内部错误或SSH连接失败"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 401 {object} apierr.Response "认证失败，用户名或密码错误"
+// @Failure 500 {object} apierr.Response "服务器内部错误或SSH连接失败"
 // @Router /api/v1/slurm/job/ [post]
 func (h *SlurmHandler) SubmitJob(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	sshClient := h.Server.Clients[key].SSHClient
+	client := public.CurrentClient(c)
 	var req models.SbatchRequest
-	slurmService := service.NewSlurmService(sshClient, h.Parser)
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.SbatchResponse{
-			Success: "no",
-			Message: "Invalid request format: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Invalid request format: %v", err)
 		return
 	}
 
 	// 验证请求参数
-	parser := utils.NewSlurmParser(h.Server.Clients[key].UserInfo)
+	parser := utils.NewSlurmParser(client.UserInfo)
 	log.Println("parser: ", parser)
-	slurmService.SetParser(parser)
+	slurmService := h.backend(c, client, parser)
 	if err := parser.ValidateSbatchRequest(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.SbatchResponse{
-			Success: "no",
-			Message: "Request validation failed: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Request validation failed: %v", err)
 		return
 	}
 
 	// 执行作业提交
-	response := slurmService.ExecuteSbatch(&req)
+	response := slurmService.ExecuteSbatch(c.Request.Context(), &req)
 
-	if response.Success == "yes" {
-		c.JSON(http.StatusOK, response)
-	} else {
-		c.JSON(http.StatusInternalServerError, response)
+	if response.Success != "yes" {
+		apierr.Respond(c, slurmError(response.Err, response.Message, response.Command, response.RawOutput))
+		return
 	}
+	c.JSON(http.StatusOK, response)
 }
 
 // SubmitJobWithScript 通过上传脚本文件提交作业
 func (h *SlurmHandler) SubmitJobWithScript(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	h.SSHClient = h.Server.Clients[key].SSHClient
+	client := public.CurrentClient(c)
 	var req models.SbatchRequest
-	slurmService := service.NewSlurmService(h.SSHClient, h.Parser)
+	slurmService := h.backend(c, client, h.Parser)
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.SbatchResponse{
-			Success: "no",
-			Message: "Invalid request format: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Invalid request format: %v", err)
 		return
 	}
 
 	// 解析 multipart form
 	if err := c.Request.ParseMultipartForm(32 << 20); err != nil { // 32MB max
-		c.JSON(http.StatusBadRequest, models.SbatchResponse{
-			Success: "no",
-			Message: "Failed to parse multipart form: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Failed to parse multipart form: %v", err)
 		return
 	}
 
 	// 获取上传的脚本文件
 	file, header, err := c.Request.FormFile("script")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.SbatchResponse{
-			Success: "no",
-			Message: "Script file is required: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Script file is required: %v", err)
 		return
 	}
 	defer file.Close()
@@ -113,48 +78,33 @@ func (h *SlurmHandler) SubmitJobWithScript(c *gin.Context) {
 	scriptContent := make([]byte, header.Size)
 	_, err = file.Read(scriptContent)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.SbatchResponse{
-			Success: "no",
-			Message: "Failed to read script file: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Failed to read script file: %v", err)
 		return
 	}
 
 	h.parseFormData(c, &req)
 
 	// 验证请求参数
-	parser := utils.NewSlurmParser(h.Server.Clients[key].UserInfo)
+	parser := utils.NewSlurmParser(client.UserInfo)
 	if err := parser.ValidateSbatchRequest(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.SbatchResponse{
-			Success: "no",
-			Message: "Request validation failed: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Request validation failed: %v", err)
 		return
 	}
 
 	// 执行作业提交
-	response := slurmService.ExecuteSbatchWithUpload(&req, header.Filename)
+	response := slurmService.ExecuteSbatchWithUpload(c.Request.Context(), &req, header.Filename, scriptContent)
 
-	if response.Success == "yes" {
-		c.JSON(http.StatusOK, response)
-	} else {
-		c.JSON(http.StatusInternalServerError, response)
+	if response.Success != "yes" {
+		apierr.Respond(c, slurmError(response.Err, response.Message, response.Command, response.RawOutput))
+		return
 	}
+	c.JSON(http.StatusOK, response)
 }
 
 // QuickSubmit 快速提交作业（简化接口）
 func (h *SlurmHandler) QuickSubmit(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	h.SSHClient = h.Server.Clients[key].SSHClient
-	slurmService := service.NewSlurmService(h.SSHClient, h.Parser)
+	client := public.CurrentClient(c)
+	slurmService := h.backend(c, client, h.Parser)
 
 	// 简化的请求结构
 	type QuickSubmitRequest struct {
@@ -178,10 +128,7 @@ func (h *SlurmHandler) QuickSubmit(c *gin.Context) {
 
 	var quickReq QuickSubmitRequest
 	if err := c.ShouldBindJSON(&quickReq); err != nil {
-		c.JSON(http.StatusBadRequest, models.SbatchResponse{
-			Success: "no",
-			Message: "Invalid request format: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Invalid request format: %v", err)
 		return
 	}
 
@@ -203,24 +150,21 @@ func (h *SlurmHandler) QuickSubmit(c *gin.Context) {
 	}
 
 	// 验证请求参数
-	parser := utils.NewSlurmParser(h.Server.Clients[key].UserInfo)
+	parser := utils.NewSlurmParser(client.UserInfo)
 	if err := parser.ValidateSbatchRequest(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.SbatchResponse{
-			Success: "no",
-			Message: "Request validation failed: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Request validation failed: %v", err)
 		return
 	}
 
 	// 执行作业提交
 	var response *models.SbatchResponse
-	response = slurmService.ExecuteSbatch(&req)
+	response = slurmService.ExecuteSbatch(c.Request.Context(), &req)
 
-	if response.Success == "yes" {
-		c.JSON(http.StatusOK, response)
-	} else {
-		c.JSON(http.StatusInternalServerError, response)
+	if response.Success != "yes" {
+		apierr.Respond(c, slurmError(response.Err, response.Message, response.Command, response.RawOutput))
+		return
 	}
+	c.JSON(http.StatusOK, response)
 }
 
 // parseFormData 从表单数据解析参数
@@ -1,10 +1,9 @@
 package slurm
 
 import (
-	"bytes"
-	"errors"
-	"golang.org/x/crypto/ssh"
 	"net/http"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
 	"star-dim/internal/models"
 	"star-dim/internal/service"
 	"star-dim/internal/utils"
@@ -21,24 +20,15 @@ import (
 // @Accept json
 // @Produce json
 // @Param request body models.SinfoRequest true "sinfo请求参数"
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Success 200 {object} object{home_path=string,session_key=string} "查询成功，返回作业会计信息列表"
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 401 {object} object{error=string} "认证失败，用户名或密码错误"
-// @Failure 500 {object} object{error=string} "服务器内部错误或SSH连接失败"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 401 {object} apierr.Response "认证失败，用户名或密码错误"
+// @Failure 500 {object} apierr.Response "服务器内部错误或SSH连接失败"
 // @Router /api/v1/slurm/cluster/ [post]
 func (h *SlurmHandler) GetClusterInfo(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	h.SSHClient = h.Server.Clients[key].SSHClient
-	slurmService := service.NewSlurmService(h.SSHClient, h.Parser)
+	client := public.CurrentClient(c)
+	slurmService := h.backend(c, client, h.Parser)
 	var req models.SinfoRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -99,28 +89,16 @@ func (h *SlurmHandler) GetClusterInfo(c *gin.Context) {
 	}
 
 	// 处理请求
-	processClusterInfoRequest(c, req, slurmService.SSHClient())
+	processClusterInfoRequest(c, req, slurmService)
 }
 
 // GetNodeInfo 获取指定节点的信息
 func (h *SlurmHandler) GetNodeInfo(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
+	client := public.CurrentClient(c)
 	nodename := c.Param("nodename")
-	h.SSHClient = h.Server.Clients[key].SSHClient
-	slurmService := service.NewSlurmService(h.SSHClient, h.Parser)
+	slurmService := h.backend(c, client, h.Parser)
 	if nodename == "" {
-		c.JSON(http.StatusBadRequest, models.SinfoResponse{
-			Success: "no",
-			Message: "Node name is required",
-		})
+		apierr.Abort(c, apierr.BadRequest, "Node name is required")
 		return
 	}
 
@@ -138,28 +116,16 @@ func (h *SlurmHandler) GetNodeInfo(c *gin.Context) {
 	req.Verbose = c.Query("verbose") == "true"
 
 	// 处理请求
-	processClusterInfoRequest(c, req, slurmService.SSHClient())
+	processClusterInfoRequest(c, req, slurmService)
 }
 
 // GetPartitionInfo 获取指定分区的信息
 func (h *SlurmHandler) GetPartitionInfo(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	h.SSHClient = h.Server.Clients[key].SSHClient
-	slurmService := service.NewSlurmService(h.SSHClient, h.Parser)
+	client := public.CurrentClient(c)
+	slurmService := h.backend(c, client, h.Parser)
 	partition := c.Param("partition")
 	if partition == "" {
-		c.JSON(http.StatusBadRequest, models.SinfoResponse{
-			Success: "no",
-			Message: "Partition name is required",
-		})
+		apierr.Abort(c, apierr.BadRequest, "Partition name is required")
 		return
 	}
 
@@ -182,22 +148,13 @@ func (h *SlurmHandler) GetPartitionInfo(c *gin.Context) {
 	req.All = c.Query("all") == "true"
 
 	// 处理请求
-	processClusterInfoRequest(c, req, slurmService.SSHClient())
+	processClusterInfoRequest(c, req, slurmService)
 }
 
 // GetReservationInfo 获取预留信息
 func (h *SlurmHandler) GetReservationInfo(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	h.SSHClient = h.Server.Clients[key].SSHClient
-	slurmService := service.NewSlurmService(h.SSHClient, h.Parser)
+	client := public.CurrentClient(c)
+	slurmService := h.backend(c, client, h.Parser)
 	var req models.SinfoRequest
 
 	if portStr := c.Query("port"); portStr != "" {
@@ -211,49 +168,28 @@ func (h *SlurmHandler) GetReservationInfo(c *gin.Context) {
 	req.Long = c.Query("long") == "true"
 
 	// 处理请求
-	processClusterInfoRequest(c, req, slurmService.SSHClient())
+	processClusterInfoRequest(c, req, slurmService)
 }
 
 // QueryClusterInfo 复杂集群信息查询（POST 请求）
 func (h *SlurmHandler) QueryClusterInfo(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	h.SSHClient = h.Server.Clients[key].SSHClient
-	slurmService := service.NewSlurmService(h.SSHClient, h.Parser)
+	client := public.CurrentClient(c)
+	slurmService := h.backend(c, client, h.Parser)
 	var req models.SinfoRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.SinfoResponse{
-			Success: "no",
-			Message: "Invalid request parameters: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Invalid request parameters: %v", err)
 		return
 	}
 
 	// 处理请求
-	processClusterInfoRequest(c, req, slurmService.SSHClient())
+	processClusterInfoRequest(c, req, slurmService)
 }
 
 // GetClusterSummary 获取集群摘要信息
 func (h *SlurmHandler) GetClusterSummary(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	h.SSHClient = h.Server.Clients[key].SSHClient
-	slurmService := service.NewSlurmService(h.SSHClient, h.Parser)
+	client := public.CurrentClient(c)
+	slurmService := h.backend(c, client, h.Parser)
 	var req models.SinfoRequest
 
 	// 设置摘要模式
@@ -261,69 +197,22 @@ func (h *SlurmHandler) GetClusterSummary(c *gin.Context) {
 	req.NoHeader = true
 
 	// 处理请求
-	processClusterInfoRequest(c, req, slurmService.SSHClient())
+	processClusterInfoRequest(c, req, slurmService)
 }
 
 // processClusterInfoRequest 处理集群信息查询请求的通用逻辑
-func processClusterInfoRequest(c *gin.Context, req models.SinfoRequest, sshClient *ssh.Client) {
+func processClusterInfoRequest(c *gin.Context, req models.SinfoRequest, slurmService service.SlurmBackend) {
 	// 验证请求参数
 	parser := utils.NewSlurmParser(nil)
 	if err := parser.ValidateSinfoRequest(req); err != nil {
-		c.JSON(http.StatusBadRequest, models.SinfoResponse{
-			Success: "no",
-			Message: "Validation error: " + err.Error(),
-		})
-		return
-	}
-
-	session, err := sshClient.NewSession()
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	session.Stdout = &stdout
-	session.Stderr = &stderr
-	// 构建 sinfo 命令
-	cmd := parser.BuildSinfoCommand(req)
-	err = session.Run(cmd)
-	var output = stdout.String()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.SinfoResponse{
-			Success:   "no",
-			Message:   "Failed to execute sinfo command: " + err.Error(),
-			Command:   cmd,
-			RawOutput: output,
-		})
+		apierr.Abort(c, apierr.BadRequest, "Validation error: %v", err)
 		return
 	}
 
-	// 解析输出
-	data, err := parser.ParseSinfoOutput(output, req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.SinfoResponse{
-			Success:   "no",
-			Message:   "Failed to parse sinfo output: " + err.Error(),
-			Command:   cmd,
-			RawOutput: output,
-		})
+	response := slurmService.ExecuteSinfo(c.Request.Context(), req)
+	if response.Success != "yes" {
+		apierr.Respond(c, slurmError(response.Err, response.Message, response.Command, response.RawOutput))
 		return
 	}
-
-	// 计算总数
-	total := 0
-	switch v := data.(type) {
-	case []models.NodeInfo:
-		total = len(v)
-	case []models.NodeSummary:
-		total = len(v)
-	case []models.ReservationInfo:
-		total = len(v)
-	}
-
-	// 返回结果
-	c.JSON(http.StatusOK, models.SinfoResponse{
-		Success: "yes",
-		Message: "",
-		Data:    data,
-		Total:   total,
-		Command: cmd,
-	})
+	c.JSON(http.StatusOK, response)
 }
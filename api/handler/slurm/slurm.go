@@ -1,15 +1,18 @@
 package slurm
 
 import (
-	"golang.org/x/crypto/ssh"
+	"github.com/gin-gonic/gin"
+	"star-dim/api/apierr"
 	"star-dim/api/public"
+	"star-dim/internal/models"
+	"star-dim/internal/service"
 	"star-dim/internal/utils"
+	"strings"
 )
 
 type SlurmHandler struct {
-	Server    *public.Server
-	Parser    *utils.SlurmParser
-	SSHClient *ssh.Client
+	Server *public.Server
+	Parser *utils.SlurmParser
 }
 
 func NewSlurmHandler(server *public.Server) *SlurmHandler {
@@ -18,3 +21,51 @@ func NewSlurmHandler(server *public.Server) *SlurmHandler {
 		Server: server,
 	}
 }
+
+// backend 返回会话所在集群的 Slurm 后端，集群配置了 slurmrestd 时调用 OpenAPI，否则在登录节点上执行命令，
+// 命令支持时解析其 --json 输出。集群取自访问令牌，用户取自登录时确定的 Principal，不受请求参数影响
+func (h *SlurmHandler) backend(c *gin.Context, client *public.UserClient, parser *utils.SlurmParser) service.SlurmBackend {
+	slurmService := service.NewSlurmService(client.Executor(), parser)
+	claims := public.CurrentClaims(c)
+	if h.Server == nil || claims == nil || client.Principal == nil {
+		return slurmService
+	}
+	if h.Server.Clusters != nil {
+		if cluster := h.Server.Clusters.GetCluster(claims.Cluster); cluster != nil && cluster.SlurmRest != nil {
+			user := service.RestUser{Name: client.Principal.User, Home: client.UserInfo.HomePath, Token: client.SlurmToken}
+			return service.NewRestSlurmService(cluster.SlurmRest, user, client.Timeouts)
+		}
+	}
+	return slurmService.WithJSON(h.Server.SlurmJSON, claims.Cluster).
+		WithProfiles(h.Server.Profiles, claims.Cluster)
+}
+
+// profile 返回会话所在集群的 Slurm 版本信息，首次使用集群时探测，未能探测时返回 nil
+func (h *SlurmHandler) profile(c *gin.Context, client *public.UserClient) *models.SlurmProfile {
+	claims := public.CurrentClaims(c)
+	if h.Server == nil || h.Server.Profiles == nil || claims == nil {
+		return nil
+	}
+	profile, _ := h.Server.Profiles.Get(c.Request.Context(), claims.Cluster, client.Executor())
+	return profile
+}
+
+// slurmError 将 Slurm 命令的执行失败转换为接口错误，命令以非零状态退出时视为 Slurm 拒绝了请求，
+// 执行的命令和输出放在错误详情中
+func slurmError(err error, message, command, output string) *apierr.Error {
+	code := apierr.Internal
+	if err != nil {
+		code = apierr.From(err).Code
+	}
+	if code == apierr.CommandFailed {
+		code = apierr.SlurmRejected
+	}
+	e := &apierr.Error{Code: code, Message: message, Err: err}
+	if command != "" {
+		e.With("command", command)
+	}
+	if output = strings.TrimSpace(output); output != "" {
+		e.With("output", output)
+	}
+	return e
+}
@@ -1,11 +1,11 @@
 package slurm
 
 import (
-	"bytes"
-	"errors"
-	"golang.org/x/crypto/ssh"
 	"net/http"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
 	"star-dim/internal/models"
+	"star-dim/internal/service"
 	"star-dim/internal/utils"
 	"strconv"
 	"strings"
@@ -20,23 +20,15 @@ import (
 // @Accept json
 // @Produce json
 // @Param request body models.SqueueRequest true "squeue请求参数"
-// @Param sessionKey header string true "SSH会话密钥" example("tsh_a2e932b625c0d598db3800aa91b92016")
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
 // @Success 200 {object} object{home_path=string,session_key=string} "查询成功，返回作业会计信息列表"
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 401 {object} object{error=string} "认证失败，用户名或密码错误"
-// @Failure 500 {object} object{error=string} "服务器内部错误或SSH连接失败"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 401 {object} apierr.Response "认证失败，用户名或密码错误"
+// @Failure 500 {object} apierr.Response "服务器内部错误或SSH连接失败"
 // @Router /api/v1/slurm/jobs/ [post]
 func (h *SlurmHandler) GetQueue(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	h.SSHClient = h.Server.Clients[key].SSHClient
+	client := public.CurrentClient(c)
+	slurmService := h.backend(c, client, h.Parser)
 	var req models.SqueueRequest
 	// 从查询参数中获取过滤条件
 	if accounts := c.Query("accounts"); accounts != "" {
@@ -104,27 +96,16 @@ func (h *SlurmHandler) GetQueue(c *gin.Context) {
 	}
 
 	// 处理请求
-	processQueueRequest(c, req, h.SSHClient)
+	processQueueRequest(c, req, slurmService)
 }
 
 // GetJobQueue 获取指定作业的队列信息
 func (h *SlurmHandler) GetJobQueue(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	h.SSHClient = h.Server.Clients[key].SSHClient
+	client := public.CurrentClient(c)
+	slurmService := h.backend(c, client, h.Parser)
 	jobid := c.Param("jobid")
 	if jobid == "" {
-		c.JSON(http.StatusBadRequest, models.SqueueResponse{
-			Success: "no",
-			Message: "Job ID is required",
-		})
+		apierr.Abort(c, apierr.BadRequest, "Job ID is required")
 		return
 	}
 
@@ -151,27 +132,28 @@ func (h *SlurmHandler) GetJobQueue(c *gin.Context) {
 	req.Verbose = c.Query("verbose") == "true"
 
 	// 处理请求
-	processQueueRequest(c, req, h.SSHClient)
+	processQueueRequest(c, req, slurmService)
 }
 
 // GetUserQueue 获取指定用户的作业队列
+// @Summary 查询指定用户的作业队列
+// @Description 查询集群中指定用户的作业队列，仅集群管理员可用
+// @Tags 作业管理
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Param user path string true "用户名"
+// @Param states query string false "作业状态，逗号分隔"
+// @Param partitions query string false "分区，逗号分隔"
+// @Success 200 {object} models.SqueueResponse "查询成功"
+// @Failure 403 {object} apierr.Response "不是集群管理员"
+// @Failure 500 {object} models.SqueueResponse "服务器内部错误或SSH连接失败"
+// @Router /api/v1/slurm/admin/users/{user}/jobs/ [get]
 func (h *SlurmHandler) GetUserQueue(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	h.SSHClient = h.Server.Clients[key].SSHClient
+	client := public.CurrentClient(c)
+	slurmService := h.backend(c, client, h.Parser)
 	user := c.Param("user")
 	if user == "" {
-		c.JSON(http.StatusBadRequest, models.SqueueResponse{
-			Success: "no",
-			Message: "Username is required",
-		})
+		apierr.Abort(c, apierr.BadRequest, "Username is required")
 		return
 	}
 
@@ -206,46 +188,27 @@ func (h *SlurmHandler) GetUserQueue(c *gin.Context) {
 	req.Start = c.Query("start") == "true"
 
 	// 处理请求
-	processQueueRequest(c, req, h.SSHClient)
+	processQueueRequest(c, req, slurmService)
 }
 
 // QueryQueue 复杂队列查询（POST 请求）
 func (h *SlurmHandler) QueryQueue(c *gin.Context) {
 	var req models.SqueueRequest
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	h.SSHClient = h.Server.Clients[key].SSHClient
+	client := public.CurrentClient(c)
+	slurmService := h.backend(c, client, h.Parser)
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.SqueueResponse{
-			Success: "no",
-			Message: "Invalid request parameters: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Invalid request parameters: %v", err)
 		return
 	}
 
 	// 处理请求
-	processQueueRequest(c, req, h.SSHClient)
+	processQueueRequest(c, req, slurmService)
 }
 
 // GetQueueStats 获取队列统计信息
 func (h *SlurmHandler) GetQueueStats(c *gin.Context) {
-	key := c.GetHeader("sessionKey")
-	if key == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	if _, ok := h.Server.Clients[key]; !ok {
-		c.JSON(http.StatusInternalServerError, errors.New("user not login"))
-		return
-	}
-	h.SSHClient = h.Server.Clients[key].SSHClient
+	client := public.CurrentClient(c)
+	slurmService := h.backend(c, client, h.Parser)
 	var req models.SqueueRequest
 	// 设置获取所有状态的作业
 	req.States = []string{"all"}
@@ -255,32 +218,17 @@ func (h *SlurmHandler) GetQueueStats(c *gin.Context) {
 	// 验证请求参数
 	parser := utils.NewSlurmParser(nil)
 	if err := parser.ValidateSqueueRequest(req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "Validation error: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Validation error: %v", err)
 		return
 	}
 
-	session, err := h.SSHClient.NewSession()
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	session.Stdout = &stdout
-	session.Stderr = &stderr
-	// 构建 squeue 命令
-	cmd := parser.BuildSqueueCommand(req)
-	output := stdout.String()
-	// 解析输出并统计
-	jobs, err := parser.ParseSqueueOutput(output, false)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success":    false,
-			"message":    "Failed to parse squeue output: " + err.Error(),
-			"command":    cmd,
-			"raw_output": output,
-		})
+	response := slurmService.ExecuteSqueue(c.Request.Context(), req)
+	if response.Success != "yes" {
+		apierr.Respond(c, slurmError(response.Err, response.Message, response.Command, response.RawOutput))
 		return
 	}
+	cmd := response.Command
+	jobs := response.Data
 
 	// 统计各种状态的作业数量
 	stats := map[string]int{
@@ -308,8 +256,7 @@ func (h *SlurmHandler) GetQueueStats(c *gin.Context) {
 	}
 
 	// 返回统计结果
-	c.JSON(http.StatusOK, gin.H{
-		"success":    true,
+	apierr.OK(c, http.StatusOK, gin.H{
 		"message":    "Success",
 		"total_jobs": totalJobs,
 		"statistics": stats,
@@ -318,63 +265,18 @@ func (h *SlurmHandler) GetQueueStats(c *gin.Context) {
 }
 
 // processQueueRequest 处理队列查询请求的通用逻辑
-func processQueueRequest(c *gin.Context, req models.SqueueRequest, sshClient *ssh.Client) {
+func processQueueRequest(c *gin.Context, req models.SqueueRequest, slurmService service.SlurmBackend) {
 	// 验证请求参数
 	parser := utils.NewSlurmParser(nil)
 	if err := parser.ValidateSqueueRequest(req); err != nil {
-		c.JSON(http.StatusBadRequest, models.SqueueResponse{
-			Success: "no",
-			Message: "Validation error: " + err.Error(),
-		})
+		apierr.Abort(c, apierr.BadRequest, "Validation error: %v", err)
 		return
 	}
 
-	session, err := sshClient.NewSession()
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	session.Stdout = &stdout
-	session.Stderr = &stderr
-	// 构建 squeue 命令
-	cmd := parser.BuildSqueueCommand(req)
-	err = session.Run(cmd)
-	output := stdout.String()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.SqueueResponse{
-			Success:   "no",
-			Message:   "Failed to execute squeue command: " + err.Error(),
-			Command:   cmd,
-			RawOutput: output,
-		})
+	response := slurmService.ExecuteSqueue(c.Request.Context(), req)
+	if response.Success != "yes" {
+		apierr.Respond(c, slurmError(response.Err, response.Message, response.Command, response.RawOutput))
 		return
 	}
-
-	// 解析输出
-	var jobs []models.QueueJobInfo
-	if req.Long {
-		// 详细输出解析
-		jobs, err = parser.ParseSqueueDetailedOutput(output)
-	} else {
-		// 标准输出解析
-		hasHeader := !req.NoHeader
-		jobs, err = parser.ParseSqueueOutput(output, hasHeader)
-	}
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.SqueueResponse{
-			Success:   "no",
-			Message:   "Failed to parse squeue output: " + err.Error(),
-			Command:   cmd,
-			RawOutput: output,
-		})
-		return
-	}
-
-	// 返回结果
-	c.JSON(http.StatusOK, models.SqueueResponse{
-		Success: "yes",
-		Message: "",
-		Data:    jobs,
-		Total:   len(jobs),
-		Command: cmd,
-	})
+	c.JSON(http.StatusOK, response)
 }
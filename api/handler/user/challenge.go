@@ -0,0 +1,190 @@
+package user
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+	"net/http"
+	"star-dim/api/apierr"
+	"star-dim/internal/models"
+	"sync"
+	"time"
+)
+
+// challengeTimeout 等待客户端回答单轮质询的最长时间
+const challengeTimeout = 2 * time.Minute
+
+// ErrChallengeTimeout 客户端未在规定时间内回答质询
+var ErrChallengeTimeout = errors.New("login challenge timed out")
+
+type dialResult struct {
+	conn *ssh.Client
+	node *models.LoginNode
+	err  error
+}
+
+// loginChallenge 一次进行中的多步登录。SSH 握手在后台协程中进行，
+// 服务器的每轮提示通过 prompts 交给 HTTP 请求，客户端的回答通过 answers 送回握手。
+type loginChallenge struct {
+	id        string
+	info      *models.LoginInfo
+	cluster   *models.Cluster
+	prompts   chan *models.LoginChallenge
+	answers   chan []string
+	result    chan dialResult
+	mu        sync.Mutex // 同一质询同时只处理一个回答
+	current   *models.LoginChallenge
+	expiresAt time.Time
+}
+
+type challengeStore struct {
+	mu         sync.Mutex
+	challenges map[string]*loginChallenge
+}
+
+func newChallengeStore() *challengeStore {
+	return &challengeStore{challenges: make(map[string]*loginChallenge)}
+}
+
+func (s *challengeStore) get(id string) *loginChallenge {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.challenges[id]
+}
+
+func (s *challengeStore) add(ch *loginChallenge) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.challenges[ch.id] = ch
+}
+
+func (s *challengeStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.challenges, id)
+}
+
+// ask 作为 keyboard-interactive 回调，将服务器提示转交给客户端并等待回答
+func (ch *loginChallenge) ask(name, instruction string, questions []string, echos []bool) ([]string, error) {
+	// 部分服务器会发送不含提示的空质询
+	if len(questions) == 0 {
+		return nil, nil
+	}
+	prompt := &models.LoginChallenge{
+		ChallengeID: ch.id,
+		Name:        name,
+		Instruction: instruction,
+		ExpiresAt:   time.Now().Add(challengeTimeout),
+	}
+	for i, question := range questions {
+		prompt.Prompts = append(prompt.Prompts, models.ChallengePrompt{Prompt: question, Echo: echos[i]})
+	}
+	timer := time.NewTimer(challengeTimeout)
+	defer timer.Stop()
+	select {
+	case ch.prompts <- prompt:
+	case <-timer.C:
+		return nil, ErrChallengeTimeout
+	}
+	select {
+	case answers := <-ch.answers:
+		return answers, nil
+	case <-timer.C:
+		return nil, ErrChallengeTimeout
+	}
+}
+
+// startChallenge 在后台开始 SSH 握手，并返回服务器的第一轮提示
+func (h *UserHandler) startChallenge(c *gin.Context, loginInfo *models.LoginInfo, cluster *models.Cluster, nodes []*models.LoginNode) {
+	ch := &loginChallenge{
+		id:      uuid.NewString(),
+		info:    loginInfo,
+		cluster: cluster,
+		prompts: make(chan *models.LoginChallenge),
+		answers: make(chan []string, 1),
+		result:  make(chan dialResult, 1),
+	}
+	h.challenges.add(ch)
+	config := &ssh.ClientConfig{
+		User:    loginInfo.User.Name,
+		Auth:    []ssh.AuthMethod{ssh.KeyboardInteractive(ch.ask)},
+		Timeout: 10 * time.Second,
+	}
+	go func() {
+		conn, node, err := h.dialLoginNodes(cluster, nodes, config)
+		ch.result <- dialResult{conn: conn, node: node, err: err}
+		// 客户端放弃登录时关闭已建立的连接
+		time.AfterFunc(challengeTimeout, func() {
+			h.challenges.remove(ch.id)
+			select {
+			case res := <-ch.result:
+				if res.conn != nil {
+					_ = res.conn.Close()
+				}
+			default:
+			}
+		})
+	}()
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	h.awaitChallenge(c, ch)
+}
+
+// awaitChallenge 等待下一轮提示或握手结果，调用方需持有 ch.mu
+func (h *UserHandler) awaitChallenge(c *gin.Context, ch *loginChallenge) {
+	timer := time.NewTimer(challengeTimeout)
+	defer timer.Stop()
+	select {
+	case prompt := <-ch.prompts:
+		ch.current = prompt
+		ch.expiresAt = prompt.ExpiresAt
+		apierr.OK(c, http.StatusAccepted, gin.H{"challenge": prompt})
+	case res := <-ch.result:
+		h.challenges.remove(ch.id)
+		h.finishLogin(c, ch.info, ch.cluster, res.conn, res.node, res.err)
+	case <-timer.C:
+		h.challenges.remove(ch.id)
+		apierr.Respond(c, apierr.Wrap(apierr.Timeout, ErrChallengeTimeout))
+	}
+}
+
+// AnswerChallenge answers a keyboard-interactive challenge
+// @Summary 回答登录质询
+// @Description 回答多步登录中服务器的 keyboard-interactive 提示（如动态口令）。服务器继续质询时返回 202 和新的提示，认证完成后返回访问令牌和刷新令牌
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param request body models.ChallengeAnswer true "质询回答"
+// @Success 201 {object} object{access_token=string,refresh_token=string,token_type=string,expires_in=int,home_path=string,success=string} "登录成功，返回访问令牌、刷新令牌和用户主目录路径"
+// @Success 202 {object} object{challenge=models.LoginChallenge,success=string} "需要继续回答质询"
+// @Failure 400 {object} apierr.Response "回答数量与提示不一致"
+// @Failure 401 {object} apierr.Response "认证失败"
+// @Failure 404 {object} apierr.Response "质询不存在或已过期"
+// @Failure 504 {object} apierr.Response "未在规定时间内回答质询"
+// @Router /api/v1/user/login/challenge [post]
+func (h *UserHandler) AnswerChallenge(c *gin.Context) {
+	var req models.ChallengeAnswer
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	ch := h.challenges.get(req.ChallengeID)
+	if ch == nil {
+		apierr.Abort(c, apierr.NotFound, "challenge not found or expired")
+		return
+	}
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.current == nil || time.Now().After(ch.expiresAt) {
+		apierr.Abort(c, apierr.NotFound, "challenge not found or expired")
+		return
+	}
+	if len(req.Answers) != len(ch.current.Prompts) {
+		apierr.Abort(c, apierr.BadRequest, "number of answers does not match prompts")
+		return
+	}
+	ch.current = nil
+	ch.answers <- req.Answers
+	h.awaitChallenge(c, ch)
+}
@@ -0,0 +1,30 @@
+package user
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"star-dim/api/apierr"
+)
+
+// ClusterHealth shows login node health
+// @Summary 获取登录节点健康状态
+// @Description 获取后台探测得到的各登录节点可用状态、延迟和最近一次错误
+// @Tags 认证管理
+// @Produce json
+// @Param cluster query string false "集群名称，为空时返回全部集群" example("hpc1")
+// @Success 200 {object} object{nodes=[]service.NodeHealth,success=string} "节点健康状态"
+// @Router /api/v1/clusters/health/ [get]
+func (h *UserHandler) ClusterHealth(c *gin.Context) {
+	cluster := c.Query("cluster")
+	nodes := h.Server.Health.Status()
+	if cluster != "" {
+		filtered := nodes[:0]
+		for _, node := range nodes {
+			if node.Cluster == cluster {
+				filtered = append(filtered, node)
+			}
+		}
+		nodes = filtered
+	}
+	apierr.OK(c, http.StatusOK, gin.H{"nodes": nodes})
+}
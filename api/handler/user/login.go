@@ -1,145 +1,305 @@
 package user
 
 import (
-	"crypto/md5"
-	"encoding/hex"
-	"fmt"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 	"log"
 	"net/http"
+	"star-dim/api/apierr"
 	"star-dim/api/public"
 	"star-dim/internal/models"
 	"star-dim/internal/service"
+	"strings"
+	"time"
 )
 
 type UserHandler struct {
 	Server         *public.Server
 	ClusterService *service.ClusterService
 	UserService    *service.UserService
+	challenges     *challengeStore
 }
 
 func NewUserHandler(server *public.Server) *UserHandler {
 	return &UserHandler{
-		Server: server,
+		Server:         server,
+		ClusterService: server.Clusters,
+		challenges:     newChallengeStore(),
 	}
 }
 
 // Login creates a new SSH session
 // @Summary 用户登录认证
-// @Description 创建SSH连接会话，验证用户凭据并返回会话密钥用于后续API调用
+// @Description 创建SSH连接会话，验证用户凭据并返回访问令牌和刷新令牌，后续API调用在 Authorization: Bearer 头中携带访问令牌。支持密码、私钥、SSH CA 证书和 keyboard-interactive 认证
 // @Tags 认证管理
 // @Accept json
 // @Produce json
 // @Param request body models.LoginInfo true "登录请求参数"
-// @Success 200 {object} object{home_path=string,session_key=string} "登录成功，返回用户主目录路径和会话密钥"
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 401 {object} object{error=string} "认证失败，用户名或密码错误"
-// @Failure 500 {object} object{error=string} "服务器内部错误或SSH连接失败"
+// @Success 201 {object} object{access_token=string,refresh_token=string,token_type=string,expires_in=int,home_path=string,success=string} "登录成功，返回访问令牌、刷新令牌和用户主目录路径"
+// @Success 202 {object} object{challenge=models.LoginChallenge,success=string} "需要回答 keyboard-interactive 质询"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 401 {object} apierr.Response "认证失败，用户名或密码错误"
+// @Failure 404 {object} apierr.Response "集群或指定的登录节点不存在"
+// @Failure 502 {object} apierr.Response "无法连接登录节点"
+// @Failure 500 {object} apierr.Response "服务器内部错误"
 // @Router /api/v1/user/login [post]
 func (h *UserHandler) Login(c *gin.Context) {
 	var loginInfo models.LoginInfo
 	if err := c.ShouldBindJSON(&loginInfo); err != nil {
-		fmt.Println("error:", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
 		return
 	}
-	config := &ssh.ClientConfig{
-		User: loginInfo.User.Name,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(loginInfo.User.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 仅用于测试
+	if loginInfo.User == nil || loginInfo.User.Cluster == nil {
+		apierr.Abort(c, apierr.BadRequest, "user and cluster are required")
+		return
 	}
 	// choose login node
-	var loginNode *models.LoginNode
-	clusters := h.ClusterService.GetClusters()
-	if loginInfo.LoginNode == nil {
-		for _, cluster := range clusters {
-			if cluster.Name == loginInfo.User.Cluster.Name {
-				loginNode = cluster.LoginNodes[0]
-			}
-		}
-	} else {
-		for _, cluster := range clusters {
-			if cluster.Name == loginInfo.User.Cluster.Name {
-				for _, clusterNode := range cluster.LoginNodes {
-					if loginInfo.LoginNode.Name == clusterNode.Name {
-						loginNode = clusterNode
-					}
-				}
-			}
-		}
+	cluster := h.ClusterService.GetCluster(loginInfo.User.Cluster.Name)
+	if cluster == nil {
+		apierr.Abort(c, apierr.NotFound, "cluster not found")
+		return
+	}
+	nodeName := ""
+	if loginInfo.LoginNode != nil {
+		nodeName = loginInfo.LoginNode.Name
 	}
-	if loginNode == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "login node not found"})
+	nodes, err := h.ClusterService.LoginNodeCandidates(cluster, nodeName, loginInfo.Labels)
+	if errors.Is(err, service.ErrLoginNodeNotFound) {
+		apierr.Respond(c, apierr.Wrap(apierr.NotFound, err))
+		return
+	} else if err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
 		return
 	}
+	nodes = h.Server.Health.Rank(cluster, nodes, h.Server.NodeSessions(cluster.Name))
 
-	conn, err := ssh.Dial("tcp", fmt.Sprintf("%s:%s", loginNode.Host, loginNode.Port), config)
-	log.Println("Connecting to", loginNode.Host, "on port", loginNode.Port, "config:", config)
+	// 未提供答案的 keyboard-interactive 登录需要客户端逐步回答服务器质询
+	if loginInfo.AuthMethod == models.AuthKeyboardInteractive && len(loginInfo.Answers) == 0 {
+		h.startChallenge(c, &loginInfo, cluster, nodes)
+		return
+	}
+
+	auth, err := service.AuthMethods(&loginInfo)
 	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, err)
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
 		return
+	}
+	config := &ssh.ClientConfig{
+		User:    loginInfo.User.Name,
+		Auth:    auth,
+		Timeout: 10 * time.Second,
+	}
+	conn, loginNode, err := h.dialLoginNodes(cluster, nodes, config)
+	h.finishLogin(c, &loginInfo, cluster, conn, loginNode, err)
+}
 
+// finishLogin 根据连接结果创建 SFTP 客户端并注册用户会话
+func (h *UserHandler) finishLogin(c *gin.Context, loginInfo *models.LoginInfo, cluster *models.Cluster, conn *ssh.Client, loginNode *models.LoginNode, err error) {
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, ErrChallengeTimeout) {
+			apierr.Respond(c, apierr.Wrap(apierr.Timeout, err))
+		} else if isAuthError(err) {
+			apierr.Respond(c, apierr.Wrap(apierr.AuthFailed, err))
+		} else {
+			apierr.Respond(c, apierr.Wrap(apierr.SSHUnreachable, err))
+		}
+		return
 	}
 	sftpClient, err := sftp.NewClient(conn)
 	if err != nil {
-		log.Fatal(err)
-	}
-	// create session key and save ssh session
-	u, _ := uuid.NewUUID()
-	sessionStr := fmt.Sprintf("%s_%s_%s", loginInfo.User.Cluster.Name, loginInfo.LoginNode.Name, u.String())
-	// use md5 to hash sessionStr
-	hash := md5.Sum([]byte(sessionStr))
-	sessionKey := fmt.Sprintf("tsh_%s", hex.EncodeToString(hash[:]))
+		log.Println(err)
+		_ = conn.Close()
+		apierr.Respond(c, err)
+		return
+	}
+	// 会话密钥只出现在签名令牌中，客户端使用访问令牌调用接口
+	sessionKey := "tsh_" + strings.ReplaceAll(uuid.NewString(), "-", "")
 	client := &public.UserClient{
 		SftpClient: sftpClient,
 		SSHClient:  conn,
 		UserInfo: &models.User{
-			Cluster:    loginInfo.User.Cluster,
-			Name:       loginInfo.User.Name,
-			Password:   loginInfo.User.Password,
-			PrivateKey: loginInfo.User.PrivateKey,
-			HomePath:   "",
+			Cluster:     loginInfo.User.Cluster,
+			Name:        loginInfo.User.Name,
+			Password:    loginInfo.User.Password,
+			PrivateKey:  loginInfo.User.PrivateKey,
+			Passphrase:  loginInfo.User.Passphrase,
+			Certificate: loginInfo.User.Certificate,
+			HomePath:    "",
 		},
+		LoginNode: loginNode,
+		Principal: service.NewPrincipal(loginInfo.User.Name, cluster.Access.Clone()),
+		Redial:    h.redialer(loginInfo, cluster, loginNode),
+		Timeouts:  h.Server.Timeouts,
 	}
-	h.Server.Clients[sessionKey] = client
 	// get home path use sftp
 	homePath, err := sftpClient.Getwd()
 	if err == nil {
-		h.Server.Clients[sessionKey].UserInfo.HomePath = homePath
-		h.Server.Clients[sessionKey].KeepAlive()
+		client.UserInfo.HomePath = homePath
+		client.KeepAlive()
+	}
+	client.Paths = service.NewPathConfiner(homePath, loginInfo.User.Name, cluster.Paths.Clone())
+	tokens, err := h.Server.Tokens.Issue(sessionKey, loginInfo.User.Name, cluster.Name, loginNode.Name)
+	if err != nil {
+		log.Println(err)
+		client.Close()
+		apierr.Respond(c, err)
+		return
+	}
+	h.Server.Sessions.Add(sessionKey, client)
+	setTokenCookie(c, tokens)
+	fields := tokenFields(tokens)
+	fields["home_path"] = homePath
+	apierr.OK(c, http.StatusCreated, fields)
+}
+
+// dialLoginNodes 按顺序尝试连接登录节点，连接失败时自动切换到下一个节点，认证失败或质询超时时不再尝试其他节点
+func (h *UserHandler) dialLoginNodes(cluster *models.Cluster, nodes []*models.LoginNode, config *ssh.ClientConfig) (*ssh.Client, *models.LoginNode, error) {
+	return h.Server.Health.Failover(cluster.Name, nodes, func(node *models.LoginNode) (*ssh.Client, error) {
+		log.Println("Connecting to", node.Host, "on port", node.Port)
+		nodeConfig := *config
+		nodeConfig.HostKeyCallback = h.Server.HostKeys.Callback(cluster, node)
+		return service.DialLoginNode(node, &nodeConfig, h.jumpConfig(cluster, config))
+	}, isTerminalError)
+}
+
+// redialer 返回使用登录凭据重新连接同一登录节点的函数。
+// keyboard-interactive 登录使用的动态口令无法复用，返回 nil，断线后需要重新登录。
+func (h *UserHandler) redialer(loginInfo *models.LoginInfo, cluster *models.Cluster, node *models.LoginNode) func() (*ssh.Client, error) {
+	if loginInfo.AuthMethod == models.AuthKeyboardInteractive {
+		return nil
+	}
+	return func() (*ssh.Client, error) {
+		auth, err := service.AuthMethods(loginInfo)
+		if err != nil {
+			return nil, err
+		}
+		config := &ssh.ClientConfig{
+			User:            loginInfo.User.Name,
+			Auth:            auth,
+			HostKeyCallback: h.Server.HostKeys.Callback(cluster, node),
+			Timeout:         10 * time.Second,
+		}
+		return service.DialLoginNode(node, config, h.jumpConfig(cluster, config))
 	}
-	c.JSON(201, map[string]string{"session_key": sessionKey, "home_path": homePath})
-	// curl test :curl -X POST -H "Content-Type: application/json" -d "{\"name\":\"root\",\"password\":\"Ty83Hujy88\",\"host\":\"129.204.183.32\"}" http://localhost:8080/api/v2/document/login/
+}
+
+// jumpConfig 返回跳板机的 SSH 配置，跳板机未配置专用凭据时使用用户的登录凭据
+func (h *UserHandler) jumpConfig(cluster *models.Cluster, config *ssh.ClientConfig) service.JumpConfigFunc {
+	return func(jump *models.JumpHost) (*ssh.ClientConfig, error) {
+		jumpConfig := *config
+		if jump.User != "" {
+			jumpConfig.User = jump.User
+		}
+		if jump.HasCredentials() {
+			auth, err := service.JumpAuthMethods(jump)
+			if err != nil {
+				return nil, err
+			}
+			jumpConfig.Auth = auth
+		}
+		jumpConfig.HostKeyCallback = h.Server.HostKeys.JumpCallback(cluster, jump)
+		return &jumpConfig, nil
+	}
+}
+
+func isAuthError(err error) bool {
+	return strings.Contains(err.Error(), "unable to authenticate")
+}
+
+// isTerminalError 换其他登录节点也无法成功的错误：认证失败，或客户端未回答质询，
+// 此时不应标记节点不可用，也不应向客户端发起下一个节点的质询
+func isTerminalError(err error) bool {
+	return isAuthError(err) || errors.Is(err, ErrChallengeTimeout)
 }
 
 // Logout deletes the session
 // @Summary 用户登出
-// @Description 删除用户会话
+// @Description 删除用户会话并吊销该会话签发的全部令牌
 // @Tags 认证管理
 // @Accept json
 // @Produce json
-// @Param session_key header string true "会话��钥"
-// @Success 200 {object} object{message=string} "登出成功"
-// @Failure 400 {object} object{error=string} "请求参数错误"
-// @Failure 500 {object} object{error=string} "服务器内部错误或用户未登录"
-// @Router /api/v1/user/logout/ [get]
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Success 200 {object} object{message=string,success=string} "登出成功"
+// @Failure 401 {object} apierr.Response "令牌无效、已过期或用户未登录"
+// @Router /api/v1/user/logout [post]
 func (h *UserHandler) Logout(c *gin.Context) {
-	// delete session
-	sessionKey := c.GetHeader("session_key")
-	if sessionKey == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "session_key is required"})
+	sessionKey := c.GetString("session_key")
+	// 先吊销令牌，即使会话已过期也不能继续使用
+	if err := h.Server.Tokens.RevokeSession(sessionKey); err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	c.SetCookie(public.TokenCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+	// 删除会话并关闭 SSH 和 SFTP 连接
+	if !h.Server.Sessions.Remove(sessionKey) {
+		apierr.Abort(c, apierr.SessionExpired, "user not login")
+		return
+	}
+	apierr.OK(c, http.StatusOK, gin.H{"message": "logout success"})
+}
+
+// Refresh issues a new token pair
+// @Summary 刷新令牌
+// @Description 使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌随即失效。会话已登出或过期时需要重新登录
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshRequest true "刷新令牌"
+// @Success 200 {object} object{access_token=string,refresh_token=string,token_type=string,expires_in=int,success=string} "新的令牌"
+// @Failure 400 {object} apierr.Response "请求参数错误"
+// @Failure 401 {object} apierr.Response "刷新令牌无效、已使用或会话已失效"
+// @Router /api/v1/user/refresh [post]
+func (h *UserHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.BadRequest, err))
+		return
+	}
+	claims, err := h.Server.Tokens.Parse(req.RefreshToken, service.TokenRefresh)
+	if err != nil {
+		apierr.Respond(c, apierr.Wrap(apierr.Unauthorized, err))
+		return
+	}
+	// 会话可能由其他副本持有，通过注册表确认会话仍然有效
+	meta, err := h.Server.Sessions.Lookup(claims.Session)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	if meta == nil {
+		apierr.Abort(c, apierr.SessionExpired, "session expired, please login again")
 		return
 	}
-	if _, ok := h.Server.Clients[sessionKey]; !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "user not login"})
+	// 刷新令牌只能使用一次，并发刷新时只有一个请求成功
+	if err := h.Server.Tokens.Revoke(claims); err != nil {
+		apierr.Respond(c, err)
 		return
 	}
-	delete(h.Server.Clients, sessionKey)
-	c.JSON(http.StatusOK, gin.H{"message": "logout success"})
+	tokens, err := h.Server.Tokens.Issue(claims.Session, claims.Subject, claims.Cluster, claims.LoginNode)
+	if err != nil {
+		apierr.Respond(c, err)
+		return
+	}
+	setTokenCookie(c, tokens)
+	apierr.OK(c, http.StatusOK, tokenFields(tokens))
+}
+
+// tokenFields 登录和刷新响应中的令牌字段
+func tokenFields(tokens *service.TokenPair) gin.H {
+	return gin.H{
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
+	}
+}
+
+// setTokenCookie 将访问令牌写入 HttpOnly Cookie，供浏览器 WebSocket 等无法设置请求头的客户端使用
+func setTokenCookie(c *gin.Context, tokens *service.TokenPair) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(public.TokenCookie, tokens.AccessToken, int(tokens.ExpiresIn), "/", "", c.Request.TLS != nil, true)
 }
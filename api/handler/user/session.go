@@ -0,0 +1,22 @@
+package user
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
+)
+
+// SessionStatus shows the connection state of a session
+// @Summary 获取会话状态
+// @Description 获取会话的 SSH 连接状态。connected 表示连接正常，reconnecting 表示正在自动重连，reauth_required 表示无法自动重连，需要重新登录
+// @Tags 认证管理
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌" example("Bearer eyJhbGciOiJIUzI1NiIs...")
+// @Success 200 {object} object{session=public.SessionStatus,success=string} "会话状态"
+// @Failure 401 {object} apierr.Response "用户未登录或会话已过期"
+// @Router /api/v1/user/session/ [get]
+func (h *UserHandler) SessionStatus(c *gin.Context) {
+	client := public.CurrentClient(c)
+	apierr.OK(c, http.StatusOK, gin.H{"session": client.Status()})
+}
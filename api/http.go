@@ -2,29 +2,99 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
 	"log"
+	"net"
+	"os"
 	"star-dim/api/public"
 	"star-dim/api/router"
 	"star-dim/configs"
+	"star-dim/internal/service"
+	"time"
 )
 
 func StarHTTP(conf configs.Config) {
 	r := gin.Default()
 	server := public.Server{
-		Clients:     nil,
+		Sessions:    nil,
 		Record:      false,
 		RecordPath:  "",
 		Log:         false,
 		LogFilePath: "",
 	}
-	server.Clients = make(map[string]*public.UserClient)
+	server.Sessions = public.NewSessionStore(conf.SessionIdleTTL, conf.SessionMaxTTL)
+	server.Sessions.StartReaper(time.Minute, nil)
+	var redisClient *redis.Client
+	if conf.RedisURL != "" {
+		redisClient = setupSessionRegistry(&server, conf)
+	}
 	server.Record = true
 	server.RecordPath = "./"
 	server.Log = true
+	server.Clusters = service.NewClusterService(conf.ClusterFile)
+	if err := server.Clusters.Load(); err != nil {
+		log.Fatal("Error while loading clusters:", err)
+	}
+	server.Clusters.Watch(5*time.Second, nil)
+	server.AdminToken = conf.AdminToken
+	server.HostKeys = service.NewHostKeyStore(conf.HostKeyFile)
+	if err := server.HostKeys.Load(); err != nil {
+		log.Fatal("Error while loading host keys:", err)
+	}
+	tokens, err := service.NewTokenService(conf.JWTSecret, conf.JWTKeys, conf.AccessTokenTTL, conf.RefreshTokenTTL)
+	if err != nil {
+		log.Fatal("Error while creating token service:", err)
+	}
+	if redisClient != nil {
+		tokens.SetRevocations(public.NewRedisRevocations(redisClient))
+	}
+	server.Tokens = tokens
+	commands, err := service.ParseCommandTimeouts(conf.CommandTimeouts)
+	if err != nil {
+		log.Fatal("Error while parsing command timeouts:", err)
+	}
+	server.Timeouts = service.CommandTimeouts{Default: conf.CommandTimeout, Commands: commands}
+	server.SlurmJSON = service.NewJSONSupport()
+	server.Profiles = service.NewSlurmProfiles(24 * time.Hour)
+	server.Uploads = service.NewUploadStore(24 * time.Hour)
+	server.Uploads.SetRemover(server.RemoveUploadPart)
+	server.Uploads.StartReaper(10*time.Minute, nil)
+	server.Checksums = service.NewCRCCache(100000)
+	server.ArchiveMax = conf.ArchiveMaxSize
+	server.Tasks = service.NewTaskStore(24 * time.Hour)
+	server.Tasks.StartReaper(10*time.Minute, nil)
+	server.Health = service.NewHealthChecker(server.Clusters, server.HostKeys, 5*time.Second)
+	server.Health.Start(30*time.Second, nil)
 	router.SetupRouters(r, &server)
 
-	err := r.Run(conf.Host + ":" + conf.Port)
+	err = r.Run(conf.Host + ":" + conf.Port)
 	if err != nil {
 		log.Fatal("Error while starting server:", err)
 	}
 }
+
+// setupSessionRegistry 使用 Redis 保存会话元数据，返回的客户端同时用于共享令牌吊销记录。
+// Redis 不可用时退回进程内注册表并返回 nil
+func setupSessionRegistry(server *public.Server, conf configs.Config) *redis.Client {
+	opt, err := redis.ParseURL(conf.RedisURL)
+	if err != nil {
+		log.Fatal("Error while parsing redis url:", err)
+	}
+	client := redis.NewClient(opt)
+	if err := client.Ping().Err(); err != nil {
+		log.Println("redis is unavailable, using in-memory session registry:", err)
+		_ = client.Close()
+		return nil
+	}
+	replica := conf.AdvertiseAddr
+	if replica == "" {
+		host := conf.Host
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host, _ = os.Hostname()
+		}
+		replica = "http://" + net.JoinHostPort(host, conf.Port)
+	}
+	server.Sessions.SetRegistry(public.NewRedisRegistry(client), replica)
+	log.Printf("using redis session registry, replica address %s", replica)
+	return client
+}
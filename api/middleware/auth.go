@@ -1,41 +1,85 @@
 package middleware
 
-//func JWTAuth(userDB *models.UserDB) gin.HandlerFunc {
-//	return func(c *gin.Context) {
-//		authHeader := c.GetHeader("Authorization")
-//		if authHeader == "" {
-//			c.JSON(http.StatusUnauthorized, gin.H{"error": "需要Authorization头"})
-//			c.Abort()
-//			return
-//		}
-//
-//		parts := strings.Split(authHeader, " ")
-//		if len(parts) != 2 || parts[0] != "Bearer" {
-//			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization头格式必须为Bearer <token>"})
-//			c.Abort()
-//			return
-//		}
-//
-//		tokenString := parts[1]
-//		claims, err := utils.ValidateToken(tokenString)
-//		if err != nil {
-//			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效或过期的令牌"})
-//			c.Abort()
-//			return
-//		}
-//
-//		user, err := userDB.GetUserByID(claims.UserID)
-//		if err != nil {
-//			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在或已被删除"})
-//			c.Abort()
-//			return
-//		}
-//
-//		c.Set("user_id", user.ID)
-//		c.Set("username", user.Username)
-//		c.Set("user_role", user.Role)
-//		c.Set("user", user)
-//
-//		c.Next()
-//	}
-//}
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
+	"star-dim/internal/models"
+	"star-dim/internal/service"
+	"strings"
+)
+
+// bearerToken 读取访问令牌，依次查找 Authorization: Bearer 头、sessionKey 或 session_key 头和令牌 Cookie
+func bearerToken(c *gin.Context) string {
+	if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	if token := sessionKeyFromHeader(c); token != "" {
+		return token
+	}
+	token, _ := c.Cookie(public.TokenCookie)
+	return token
+}
+
+// JWTAuth 校验访问令牌，通过后将令牌声明、用户名和会话密钥写入上下文
+func JWTAuth(tokens *service.TokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := bearerToken(c)
+		if tokenString == "" {
+			apierr.Abort(c, apierr.Unauthorized, "access token required")
+			return
+		}
+		claims, err := tokens.Parse(tokenString, service.TokenAccess)
+		if err != nil {
+			apierr.Respond(c, apierr.Wrap(apierr.Unauthorized, err))
+			return
+		}
+		c.Set(public.ContextClaims, claims)
+		c.Set("username", claims.Subject)
+		c.Set(public.ContextSessionKey, claims.Session)
+		c.Next()
+	}
+}
+
+// AdminTokenHeader 携带管理员引导令牌的请求头
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminAuth 管理接口认证，返回需依次挂载的中间件。
+// 请求携带 X-Admin-Token 时校验引导令牌，通过后可以管理所有集群，未配置引导令牌时拒绝；
+// 否则依次执行 userAuth 中的会话认证（JWTAuth、ForwardSession、UserSession），
+// 要求会话为集群管理员，并且只能管理会话所在的集群。
+func AdminAuth(token string, userAuth ...gin.HandlerFunc) []gin.HandlerFunc {
+	handlers := []gin.HandlerFunc{func(c *gin.Context) {
+		reqToken := c.GetHeader(AdminTokenHeader)
+		if reqToken == "" {
+			c.Next()
+			return
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(reqToken), []byte(token)) != 1 {
+			apierr.Abort(c, apierr.Unauthorized, "invalid admin token")
+			return
+		}
+		c.Set(public.ContextAdminBootstrap, true)
+		c.Next()
+	}}
+	userAuth = append(userAuth, RequireRole(models.RoleClusterAdmin), func(c *gin.Context) {
+		c.Set(public.ContextAdminCluster, public.CurrentClaims(c).Cluster)
+		c.Next()
+	})
+	for _, handler := range userAuth {
+		handlers = append(handlers, unlessBootstrap(handler))
+	}
+	return handlers
+}
+
+// unlessBootstrap 已通过引导令牌认证的请求跳过 handler
+func unlessBootstrap(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool(public.ContextAdminBootstrap) {
+			c.Next()
+			return
+		}
+		handler(c)
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/api/public"
+	"star-dim/internal/models"
+	"star-dim/internal/service"
+)
+
+func TestAdminAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens, err := service.NewTokenService("", "", time.Minute, time.Hour)
+	require.NoError(t, err)
+	sessions := public.NewSessionStore(0, 0)
+	policy := &models.AccessPolicy{ClusterAdmins: []string{"root"}}
+	sessions.Add("tsh_admin", &public.UserClient{
+		UserInfo:  &models.User{Name: "root"},
+		Principal: service.NewPrincipal("root", policy),
+	})
+	sessions.Add("tsh_user", &public.UserClient{
+		UserInfo:  &models.User{Name: "alice"},
+		Principal: service.NewPrincipal("alice", policy),
+	})
+
+	r := gin.New()
+	admin := r.Group("/admin", AdminAuth("bootstrap", JWTAuth(tokens), UserSession(sessions))...)
+	admin.GET("/clusters/:name/", func(c *gin.Context) {
+		if !public.CanManageCluster(c, c.Param("name")) {
+			c.Status(http.StatusForbidden)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	get := func(cluster string, set func(req *http.Request)) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/clusters/"+cluster+"/", nil)
+		set(req)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	bearer := func(session, user string) func(req *http.Request) {
+		pair, err := tokens.Issue(session, user, "hpc1", "ln1")
+		require.NoError(t, err)
+		return func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+pair.AccessToken) }
+	}
+
+	// 引导令牌可以管理所有集群
+	bootstrap := func(req *http.Request) { req.Header.Set(AdminTokenHeader, "bootstrap") }
+	assert.Equal(t, http.StatusOK, get("hpc1", bootstrap))
+	assert.Equal(t, http.StatusOK, get("hpc2", bootstrap))
+	assert.Equal(t, http.StatusUnauthorized, get("hpc1", func(req *http.Request) { req.Header.Set(AdminTokenHeader, "wrong") }))
+	assert.Equal(t, http.StatusUnauthorized, get("hpc1", func(req *http.Request) {}))
+
+	// 集群管理员只能管理会话所在的集群
+	assert.Equal(t, http.StatusOK, get("hpc1", bearer("tsh_admin", "root")))
+	assert.Equal(t, http.StatusForbidden, get("hpc2", bearer("tsh_admin", "root")))
+
+	// 普通用户不能调用管理接口
+	assert.Equal(t, http.StatusForbidden, get("hpc1", bearer("tsh_user", "alice")))
+
+	// 未配置引导令牌时只接受集群管理员会话
+	r = gin.New()
+	r.GET("/admin/clusters/:name/", append(AdminAuth("", JWTAuth(tokens), UserSession(sessions)), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})...)
+	assert.Equal(t, http.StatusUnauthorized, get("hpc1", func(req *http.Request) { req.Header.Set(AdminTokenHeader, "") }))
+	assert.Equal(t, http.StatusUnauthorized, get("hpc1", bootstrap))
+	assert.Equal(t, http.StatusOK, get("hpc1", bearer("tsh_admin", "root")))
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
+)
+
+// ForwardedHeader 标记已被其他副本转发过的请求，避免循环转发
+const ForwardedHeader = "X-Star-Dim-Forwarded-By"
+
+// sessionKeyFromHeader 读取会话密钥，兼容 sessionKey 和 session_key 两种请求头
+func sessionKeyFromHeader(c *gin.Context) string {
+	if key := c.GetHeader("sessionKey"); key != "" {
+		return key
+	}
+	return c.GetHeader("session_key")
+}
+
+// ForwardSession 会话不在本副本时，按注册表中的副本地址将请求转发到持有 SSH 连接的副本。
+// 注册表不可用或会话不存在时交由本副本处理。
+func ForwardSession(sessions *public.SessionStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 经过 JWTAuth 时使用令牌中的会话密钥
+		key := c.GetString(public.ContextSessionKey)
+		if key == "" {
+			key = sessionKeyFromHeader(c)
+		}
+		if key == "" || c.GetHeader(ForwardedHeader) != "" || sessions.Has(key) {
+			c.Next()
+			return
+		}
+		meta, err := sessions.Lookup(key)
+		if err != nil {
+			log.Printf("lookup session %s: %v", key, err)
+			c.Next()
+			return
+		}
+		if meta == nil || meta.Replica == "" || meta.Replica == sessions.Replica() {
+			c.Next()
+			return
+		}
+		target, err := url.Parse(meta.Replica)
+		if err != nil {
+			log.Printf("session %s has invalid replica %s: %v", key, meta.Replica, err)
+			c.Next()
+			return
+		}
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("forward session %s to %s: %v", key, meta.Replica, err)
+			apierr.Abort(c, apierr.SSHUnreachable, "session replica unavailable, please login again")
+		}
+		c.Request.Header.Set(ForwardedHeader, sessions.Replica())
+		proxy.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"star-dim/api/public"
+	"star-dim/internal/models"
+)
+
+func TestForwardSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	// 副本 A 持有会话
+	storeA := public.NewSessionStore(0, 0)
+	routerA := gin.New()
+	routerA.Use(ForwardSession(storeA))
+	routerA.GET("/api/v1/files/", func(c *gin.Context) {
+		c.String(http.StatusOK, "replica-a forwarded-by=%s", c.GetHeader(ForwardedHeader))
+	})
+	replicaA := httptest.NewServer(routerA)
+	defer replicaA.Close()
+	storeA.SetRegistry(public.NewRedisRegistry(client), replicaA.URL)
+	storeA.Add("tsh_a", &public.UserClient{UserInfo: &models.User{Name: "alice"}})
+
+	// 副本 B 收到请求后转发给副本 A
+	storeB := public.NewSessionStore(0, 0)
+	storeB.SetRegistry(public.NewRedisRegistry(client), "http://replica-b:8080")
+	routerB := gin.New()
+	routerB.Use(ForwardSession(storeB))
+	routerB.GET("/api/v1/files/", func(c *gin.Context) {
+		c.String(http.StatusOK, "replica-b")
+	})
+
+	replicaB := httptest.NewServer(routerB)
+	defer replicaB.Close()
+	get := func(header, key string) (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, replicaB.URL+"/api/v1/files/", nil)
+		if key != "" {
+			req.Header.Set(header, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			return 0, ""
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	code, body := get("sessionKey", "tsh_a")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "replica-a forwarded-by=http://replica-b:8080", body)
+
+	// 未知会话和未携带会话密钥的请求由本副本处理
+	_, body = get("session_key", "tsh_unknown")
+	assert.Equal(t, "replica-b", body)
+	_, body = get("sessionKey", "")
+	assert.Equal(t, "replica-b", body)
+
+	// 会话所在副本不可用时返回 502
+	replicaA.Close()
+	code, _ = get("sessionKey", "tsh_a")
+	assert.Equal(t, http.StatusBadGateway, code)
+
+	// Redis 不可用时退回本副本处理
+	mr.Close()
+	_, body = get("sessionKey", "tsh_a")
+	assert.Equal(t, "replica-b", body)
+}
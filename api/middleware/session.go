@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"star-dim/api/apierr"
+	"star-dim/api/public"
+)
+
+// UserSession 获取 JWTAuth 解析出的会话并注入上下文，处理函数通过 public.CurrentClient 获取。
+// 需挂载在 JWTAuth 和 ForwardSession 之后，会话不存在或已过期时统一返回 401。
+func UserSession(sessions *public.SessionStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, ok := sessions.Get(c.GetString(public.ContextSessionKey))
+		if !ok {
+			apierr.Abort(c, apierr.SessionExpired, "session expired or not login, please login again")
+			return
+		}
+		c.Set(public.ContextUserClient, client)
+		c.Next()
+	}
+}
+
+// RequireRole 仅允许具有指定角色的会话访问，需挂载在 UserSession 之后
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := public.CurrentClient(c)
+		if client.Principal == nil || !client.Principal.HasRole(roles...) {
+			apierr.Abort(c, apierr.PermissionDenied, "permission denied")
+			return
+		}
+		c.Next()
+	}
+}
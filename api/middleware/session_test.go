@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/api/public"
+	"star-dim/internal/models"
+	"star-dim/internal/service"
+)
+
+func TestUserSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens, err := service.NewTokenService("", "", time.Minute, time.Hour)
+	require.NoError(t, err)
+	sessions := public.NewSessionStore(0, 0)
+	sessions.Add("tsh_a", &public.UserClient{UserInfo: &models.User{Name: "alice"}})
+
+	r := gin.New()
+	r.GET("/files/", JWTAuth(tokens), UserSession(sessions), func(c *gin.Context) {
+		c.String(http.StatusOK, public.CurrentClient(c).UserInfo.Name+"@"+public.CurrentClaims(c).Cluster)
+	})
+	get := func(set func(req *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/files/", nil)
+		set(req)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	pair, err := tokens.Issue("tsh_a", "alice", "hpc1", "ln1")
+	require.NoError(t, err)
+	// 令牌可以放在 Authorization 头、sessionKey 头或 Cookie 中
+	for _, set := range []func(req *http.Request){
+		func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+pair.AccessToken) },
+		func(req *http.Request) { req.Header.Set("sessionKey", pair.AccessToken) },
+		func(req *http.Request) {
+			req.AddCookie(&http.Cookie{Name: public.TokenCookie, Value: pair.AccessToken})
+		},
+	} {
+		w := get(set)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "alice@hpc1", w.Body.String())
+	}
+
+	// 刷新令牌不能用于访问接口
+	w := get(func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+pair.RefreshToken) })
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// 会话已删除时统一返回 401
+	sessions.Remove("tsh_a")
+	w = get(func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+pair.AccessToken) })
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"success":"no","code":"session_expired","error":"session expired or not login, please login again"}`, w.Body.String())
+
+	// 登出后令牌被吊销
+	require.NoError(t, tokens.RevokeSession("tsh_a"))
+	w = get(func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+pair.AccessToken) })
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,46 @@
+package public
+
+import (
+	"github.com/gin-gonic/gin"
+	"star-dim/internal/service"
+)
+
+// 认证中间件写入 Gin 上下文的键
+const (
+	ContextSessionKey = "session_key"
+	ContextUserClient = "user_client"
+	ContextClaims     = "claims"
+	// ContextAdminBootstrap 管理请求已通过引导令牌认证，可以管理所有集群
+	ContextAdminBootstrap = "admin_bootstrap"
+	// ContextAdminCluster 集群管理员会话所在的集群，只能管理该集群
+	ContextAdminCluster = "admin_cluster"
+)
+
+// TokenCookie 保存访问令牌的 Cookie 名称，供无法设置请求头的客户端（如浏览器 WebSocket）使用
+const TokenCookie = "star_dim_token"
+
+// CurrentClient 返回会话中间件注入的当前用户会话，只能在挂载了会话中间件的路由中调用
+func CurrentClient(c *gin.Context) *UserClient {
+	return c.MustGet(ContextUserClient).(*UserClient)
+}
+
+// CurrentClaims 返回认证中间件写入的访问令牌声明，集群和用户在登录时确定，未认证时返回 nil
+func CurrentClaims(c *gin.Context) *service.TokenClaims {
+	claims, _ := c.Get(ContextClaims)
+	tokenClaims, _ := claims.(*service.TokenClaims)
+	return tokenClaims
+}
+
+// IsAdminBootstrap 判断管理接口的调用者是否通过引导令牌认证，只能在挂载了 AdminAuth 的路由中调用
+func IsAdminBootstrap(c *gin.Context) bool {
+	return c.GetBool(ContextAdminBootstrap)
+}
+
+// CanManageCluster 判断管理接口的调用者是否可以管理指定集群，只能在挂载了 AdminAuth 的路由中调用
+func CanManageCluster(c *gin.Context, cluster string) bool {
+	if IsAdminBootstrap(c) {
+		return true
+	}
+	scope, ok := c.Get(ContextAdminCluster)
+	return ok && scope == cluster
+}
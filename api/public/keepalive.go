@@ -0,0 +1,210 @@
+package public
+
+import (
+	"errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"log"
+	"time"
+)
+
+const (
+	SessionConnected      = "connected"
+	SessionReconnecting   = "reconnecting"
+	SessionReauthRequired = "reauth_required"
+	SessionClosed         = "closed"
+)
+
+const (
+	// keepAliveInterval 心跳间隔
+	keepAliveInterval = 30 * time.Second
+	// heartbeatTimeout 等待心跳响应的最长时间
+	heartbeatTimeout = 15 * time.Second
+	// redialAttempts 连接断开后的重连次数，每次间隔翻倍
+	redialAttempts = 3
+)
+
+// SessionStatus 会话连接状态
+type SessionStatus struct {
+	State         string    `json:"state"`
+	Cluster       string    `json:"cluster"`
+	LoginNode     string    `json:"login_node"`
+	Role          string    `json:"role,omitempty"`
+	Projects      []string  `json:"projects,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastUsed      time.Time `json:"last_used"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	Reconnects    int       `json:"reconnects"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// Status 返回会话的连接状态
+func (uc *UserClient) Status() SessionStatus {
+	uc.connMu.RLock()
+	defer uc.connMu.RUnlock()
+	status := SessionStatus{
+		State:         uc.state,
+		CreatedAt:     uc.CreatedAt,
+		LastUsed:      uc.LastUsed(),
+		LastHeartbeat: uc.lastHeartbeat,
+		Reconnects:    uc.reconnects,
+		LastError:     uc.lastError,
+	}
+	if status.State == "" {
+		status.State = SessionConnected
+	}
+	if uc.UserInfo != nil && uc.UserInfo.Cluster != nil {
+		status.Cluster = uc.UserInfo.Cluster.Name
+	}
+	if uc.Principal != nil {
+		status.Role = uc.Principal.Role
+		status.Projects = uc.Principal.Projects
+	}
+	if uc.LoginNode != nil {
+		status.LoginNode = uc.LoginNode.Name
+	}
+	return status
+}
+
+func (uc *UserClient) setState(state string, err error) {
+	uc.connMu.Lock()
+	defer uc.connMu.Unlock()
+	if uc.state == SessionClosed {
+		return
+	}
+	uc.state = state
+	if err != nil {
+		uc.lastError = err.Error()
+	}
+}
+
+// KeepAlive 定期发送心跳，连接断开时使用登录凭据自动重连并重建 SFTP 客户端，
+// 无法重连时将会话标记为需要重新登录
+func (uc *UserClient) KeepAlive() {
+	uc.connMu.Lock()
+	uc.state = SessionConnected
+	uc.lastHeartbeat = time.Now()
+	uc.connMu.Unlock()
+	go func() {
+		ticker := time.NewTicker(keepAliveInterval)
+		defer ticker.Stop()
+		dropped := watch(uc.SSH())
+		for {
+			select {
+			case <-uc.closed():
+				return
+			case <-dropped:
+				log.Printf("会话连接已断开（%s）", uc.LoginNode.Name)
+			case <-ticker.C:
+				err := uc.heartbeat()
+				if err == nil {
+					continue
+				}
+				log.Printf("心跳发送失败: %v（连接可能已断开）", err)
+			}
+			// Close 关闭连接同样会触发 dropped，此时不再重连
+			if uc.isClosed() || !uc.reconnect() {
+				return
+			}
+			dropped = watch(uc.SSH())
+		}
+	}()
+}
+
+// watch 返回连接断开时关闭的 channel
+func watch(client *ssh.Client) <-chan struct{} {
+	dropped := make(chan struct{})
+	go func() {
+		_ = client.Wait()
+		close(dropped)
+	}()
+	return dropped
+}
+
+// heartbeat 发送 keepalive 全局请求，服务器不支持该请求时返回的失败响应同样说明连接正常
+func (uc *UserClient) heartbeat() error {
+	client := uc.SSH()
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+	case <-time.After(heartbeatTimeout):
+		return errors.New("heartbeat timed out")
+	}
+	uc.connMu.Lock()
+	uc.lastHeartbeat = time.Now()
+	uc.connMu.Unlock()
+	return nil
+}
+
+// reconnect 重新连接登录节点并替换 SSH 和 SFTP 客户端，返回是否成功。会话关闭后不再重连
+func (uc *UserClient) reconnect() bool {
+	if uc.isClosed() {
+		return false
+	}
+	if uc.Redial == nil {
+		uc.setState(SessionReauthRequired, errors.New("connection lost, please login again"))
+		return false
+	}
+	uc.setState(SessionReconnecting, nil)
+	backoff := 2 * time.Second
+	for attempt := 1; attempt <= redialAttempts; attempt++ {
+		if uc.isClosed() {
+			return false
+		}
+		conn, err := uc.Redial()
+		if err == nil {
+			var sftpClient *sftp.Client
+			sftpClient, err = sftp.NewClient(conn)
+			if err == nil {
+				uc.swap(conn, sftpClient)
+				log.Printf("会话已重新连接到登录节点 %s", uc.LoginNode.Name)
+				return true
+			}
+			_ = conn.Close()
+		}
+		log.Printf("重连登录节点 %s 失败（第 %d 次）: %v", uc.LoginNode.Name, attempt, err)
+		uc.setState(SessionReconnecting, err)
+		if attempt == redialAttempts {
+			break
+		}
+		select {
+		case <-uc.closed():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	uc.setState(SessionReauthRequired, nil)
+	return false
+}
+
+// swap 替换为新的连接并关闭旧连接，会话已关闭时直接关闭新连接
+func (uc *UserClient) swap(conn *ssh.Client, sftpClient *sftp.Client) {
+	uc.connMu.Lock()
+	if uc.state == SessionClosed {
+		uc.connMu.Unlock()
+		_ = sftpClient.Close()
+		_ = conn.Close()
+		return
+	}
+	oldSSH, oldSFTP := uc.SSHClient, uc.SftpClient
+	uc.SSHClient, uc.SftpClient = conn, sftpClient
+	uc.state = SessionConnected
+	uc.lastError = ""
+	uc.lastHeartbeat = time.Now()
+	uc.reconnects++
+	uc.connMu.Unlock()
+	if oldSFTP != nil {
+		_ = oldSFTP.Close()
+	}
+	if oldSSH != nil {
+		_ = oldSSH.Close()
+	}
+}
@@ -0,0 +1,27 @@
+package public
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"star-dim/internal/models"
+)
+
+func TestReconnectAfterClose(t *testing.T) {
+	redials := 0
+	uc := &UserClient{
+		LoginNode: &models.LoginNode{Name: "ln1"},
+		Redial: func() (*ssh.Client, error) {
+			redials++
+			return nil, errors.New("connection refused")
+		},
+	}
+
+	// 会话关闭后不再重连，状态保持为已关闭
+	uc.Close()
+	assert.False(t, uc.reconnect())
+	assert.Equal(t, 0, redials)
+	assert.Equal(t, SessionClosed, uc.Status().State)
+}
@@ -0,0 +1,167 @@
+package public
+
+import (
+	"encoding/json"
+	"github.com/go-redis/redis"
+	"star-dim/internal/service"
+	"sync"
+	"time"
+)
+
+// SessionMeta 会话元数据，多副本部署时共享，用于定位持有 SSH 连接的副本
+type SessionMeta struct {
+	Key       string    `json:"key"`
+	Owner     string    `json:"owner"`
+	Cluster   string    `json:"cluster"`
+	LoginNode string    `json:"login_node"`
+	Replica   string    `json:"replica"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// SessionRegistry 会话元数据注册表。ttl 为 0 时表示不过期，Lookup 未找到时返回 nil。
+type SessionRegistry interface {
+	Register(meta *SessionMeta, ttl time.Duration) error
+	Lookup(key string) (*SessionMeta, error)
+	Refresh(key string, ttl time.Duration) error
+	Unregister(key string) error
+}
+
+// memoryRegistry 进程内注册表，单副本部署或未配置 Redis 时使用
+type memoryRegistry struct {
+	mu    sync.Mutex
+	metas map[string]SessionMeta
+}
+
+func NewMemoryRegistry() SessionRegistry {
+	return &memoryRegistry{metas: make(map[string]SessionMeta)}
+}
+
+func expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func (r *memoryRegistry) Register(meta *SessionMeta, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := *meta
+	m.ExpiresAt = expiresAt(ttl)
+	r.metas[m.Key] = m
+	return nil
+}
+
+func (r *memoryRegistry) Lookup(key string) (*SessionMeta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.metas[key]
+	if !ok {
+		return nil, nil
+	}
+	if !m.ExpiresAt.IsZero() && time.Now().After(m.ExpiresAt) {
+		delete(r.metas, key)
+		return nil, nil
+	}
+	return &m, nil
+}
+
+func (r *memoryRegistry) Refresh(key string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metas[key]; ok {
+		m.ExpiresAt = expiresAt(ttl)
+		r.metas[key] = m
+	}
+	return nil
+}
+
+func (r *memoryRegistry) Unregister(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.metas, key)
+	return nil
+}
+
+// redisKeyPrefix Redis 中会话元数据的键前缀
+const redisKeyPrefix = "star-dim:session:"
+
+// redisRegistry 基于 Redis 的注册表，过期时间由 Redis 键的 TTL 维护
+type redisRegistry struct {
+	client *redis.Client
+}
+
+func NewRedisRegistry(client *redis.Client) SessionRegistry {
+	return &redisRegistry{client: client}
+}
+
+func (r *redisRegistry) Register(meta *SessionMeta, ttl time.Duration) error {
+	m := *meta
+	m.ExpiresAt = time.Time{}
+	data, err := json.Marshal(&m)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(redisKeyPrefix+m.Key, data, ttl).Err()
+}
+
+func (r *redisRegistry) Lookup(key string) (*SessionMeta, error) {
+	var get *redis.StringCmd
+	var pttl *redis.DurationCmd
+	_, err := r.client.Pipelined(func(pipe redis.Pipeliner) error {
+		get = pipe.Get(redisKeyPrefix + key)
+		pttl = pipe.PTTL(redisKeyPrefix + key)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var meta SessionMeta
+	if err := json.Unmarshal([]byte(get.Val()), &meta); err != nil {
+		return nil, err
+	}
+	if ttl := pttl.Val(); ttl > 0 {
+		meta.ExpiresAt = time.Now().Add(ttl)
+	}
+	return &meta, nil
+}
+
+func (r *redisRegistry) Refresh(key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return r.client.Persist(redisKeyPrefix + key).Err()
+	}
+	return r.client.Expire(redisKeyPrefix+key, ttl).Err()
+}
+
+func (r *redisRegistry) Unregister(key string) error {
+	return r.client.Del(redisKeyPrefix + key).Err()
+}
+
+// redisRevocationPrefix Redis 中令牌吊销记录的键前缀
+const redisRevocationPrefix = "star-dim:revoked:"
+
+// redisRevocations 基于 Redis 的令牌吊销记录，与会话注册表共享同一个 Redis，所有副本立即可见
+type redisRevocations struct {
+	client *redis.Client
+}
+
+func NewRedisRevocations(client *redis.Client) service.RevocationStore {
+	return &redisRevocations{client: client}
+}
+
+func (r *redisRevocations) Revoke(id string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(redisRevocationPrefix+id, 1, ttl).Result()
+}
+
+func (r *redisRevocations) Revoked(ids ...string) (bool, error) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = redisRevocationPrefix + id
+	}
+	n, err := r.client.Exists(keys...).Result()
+	return n > 0, err
+}
@@ -0,0 +1,145 @@
+package public
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return mr, client
+}
+
+func TestRedisRegistry(t *testing.T) {
+	mr, client := newTestRedis(t)
+	registry := NewRedisRegistry(client)
+
+	meta := &SessionMeta{Key: "tsh_1", Owner: "alice", Cluster: "hpc1", LoginNode: "ln1", Replica: "http://10.0.0.1:8080", CreatedAt: time.Now()}
+	require.NoError(t, registry.Register(meta, time.Minute))
+
+	got, err := registry.Lookup("tsh_1")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "alice", got.Owner)
+	assert.Equal(t, "http://10.0.0.1:8080", got.Replica)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), got.ExpiresAt, 2*time.Second)
+
+	// 未找到的会话返回 nil
+	got, err = registry.Lookup("tsh_missing")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	// 刷新后延长过期时间
+	require.NoError(t, registry.Refresh("tsh_1", time.Hour))
+	assert.Equal(t, time.Hour, mr.TTL(redisKeyPrefix+"tsh_1"))
+
+	// 过期后查询不到
+	mr.FastForward(2 * time.Hour)
+	got, err = registry.Lookup("tsh_1")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	require.NoError(t, registry.Register(meta, 0))
+	assert.Equal(t, time.Duration(0), mr.TTL(redisKeyPrefix+"tsh_1"))
+	require.NoError(t, registry.Unregister("tsh_1"))
+	assert.False(t, mr.Exists(redisKeyPrefix+"tsh_1"))
+}
+
+func TestRedisRegistryUnavailable(t *testing.T) {
+	mr, client := newTestRedis(t)
+	registry := NewRedisRegistry(client)
+	mr.Close()
+
+	_, err := registry.Lookup("tsh_1")
+	assert.Error(t, err)
+}
+
+func TestSessionStoreRegistry(t *testing.T) {
+	mr, client := newTestRedis(t)
+	store := NewSessionStore(10*time.Minute, time.Hour)
+	store.SetRegistry(NewRedisRegistry(client), "http://10.0.0.1:8080")
+
+	store.Add("tsh_1", &UserClient{
+		UserInfo:  &models.User{Name: "alice", Cluster: &models.Cluster{Name: "hpc1"}},
+		LoginNode: &models.LoginNode{Name: "ln1"},
+	})
+	assert.True(t, store.Has("tsh_1"))
+	assert.Equal(t, 10*time.Minute, mr.TTL(redisKeyPrefix+"tsh_1"))
+
+	// 其他副本可以查询到会话所在的副本
+	other := NewSessionStore(10*time.Minute, time.Hour)
+	other.SetRegistry(NewRedisRegistry(client), "http://10.0.0.2:8080")
+	assert.False(t, other.Has("tsh_1"))
+	meta, err := other.Lookup("tsh_1")
+	require.NoError(t, err)
+	require.NotNil(t, meta)
+	assert.Equal(t, "http://10.0.0.1:8080", meta.Replica)
+	assert.Equal(t, "hpc1", meta.Cluster)
+	assert.Equal(t, "ln1", meta.LoginNode)
+
+	// 其他副本不能删除不属于自己的会话
+	assert.False(t, other.Remove("tsh_1"))
+	assert.True(t, mr.Exists(redisKeyPrefix+"tsh_1"))
+
+	assert.True(t, store.Remove("tsh_1"))
+	assert.False(t, mr.Exists(redisKeyPrefix+"tsh_1"))
+}
+
+func TestSessionStoreExpiry(t *testing.T) {
+	store := NewSessionStore(time.Minute, time.Hour)
+	idle := &UserClient{UserInfo: &models.User{Name: "alice"}}
+	busy := &UserClient{UserInfo: &models.User{Name: "bob"}}
+	store.Add("idle", idle)
+	store.Add("busy", busy)
+
+	// 模拟两个会话都已空闲超过一分钟，正在使用中的会话不会过期
+	past := time.Now().Add(-2 * time.Minute).UnixNano()
+	idle.lastUsed = past
+	busy.lastUsed = past
+	busy.Acquire()
+
+	assert.Equal(t, 1, store.Reap())
+	_, ok := store.Get("idle")
+	assert.False(t, ok)
+	_, ok = store.Get("busy")
+	assert.True(t, ok)
+
+	// 超过绝对存活时间后，即使正在使用也会过期
+	busy.CreatedAt = time.Now().Add(-2 * time.Hour)
+	_, ok = store.Get("busy")
+	assert.False(t, ok)
+	assert.Equal(t, 0, store.Len())
+}
+
+func TestRedisRevocations(t *testing.T) {
+	mr, client := newTestRedis(t)
+	revocations := NewRedisRevocations(client)
+
+	// 同一记录只能吊销一次，过期后由 Redis 清理
+	ok, err := revocations.Revoke("jti", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = revocations.Revoke("jti", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	revoked, err := revocations.Revoked("sid:tsh_1", "jti")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	mr.FastForward(2 * time.Minute)
+	revoked, err = revocations.Revoked("jti")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	mr.Close()
+	_, err = revocations.Revoked("jti")
+	assert.Error(t, err)
+}
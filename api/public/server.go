@@ -1,18 +1,19 @@
 package public
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
-	"path"
+	"io/fs"
 	models2 "star-dim/internal/models"
+	"star-dim/internal/service"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type Config struct {
-	Clusters []*models2.Cluster `yaml:"clusters"`
-}
-
 type LogInfo struct {
 	Log string
 	Err error
@@ -22,37 +23,196 @@ type UserClient struct {
 	SftpClient *sftp.Client
 	SSHClient  *ssh.Client
 	UserInfo   *models2.User
+	LoginNode  *models2.LoginNode
+	CreatedAt  time.Time
+	// Principal 登录时根据集群访问策略确定的角色
+	Principal *service.Principal
+	// Paths 登录时根据集群配置确定的文件接口可访问目录
+	Paths *service.PathConfiner
+	// Redial 使用登录时的凭据重新连接登录节点，为 nil 时连接断开后需要重新登录
+	Redial func() (*ssh.Client, error)
+	// Timeouts 执行远程命令的超时时间
+	Timeouts service.CommandTimeouts
+	// slurmToken 访问 slurmrestd 的用户令牌
+	slurmToken service.ScontrolToken
+
+	connMu        sync.RWMutex // 保护 SSHClient、SftpClient 和连接状态
+	state         string
+	lastHeartbeat time.Time
+	lastError     string
+	reconnects    int
+
+	lastUsed  int64 // unix 纳秒，原子访问
+	refreshed int64 // 最近一次刷新注册表的时间，unix 纳秒，原子访问
+	active    int32 // 正在进行的长连接操作数，原子访问
+	doneOnce  sync.Once
+	closeOnce sync.Once
+	done      chan struct{}
 }
 
 type Server struct {
-	Clients     map[string]*UserClient
+	Sessions    *SessionStore
+	Clusters    *service.ClusterService
+	Health      *service.HealthChecker
+	HostKeys    *service.HostKeyStore
+	Tokens      *service.TokenService
+	Timeouts    service.CommandTimeouts // 用户会话执行远程命令的超时时间
+	SlurmJSON   *service.JSONSupport    // 各集群 Slurm 命令是否支持 --json 输出
+	Profiles    *service.SlurmProfiles  // 各集群的 Slurm 版本和支持的选项
+	Uploads     *service.UploadStore    // 进行中的分块上传
+	Checksums   *service.CRCCache       // 目录打包下载时计算的文件 CRC-32，用于续传
+	ArchiveMax  int64                   // 目录打包下载的文件总大小上限，0 表示不限制
+	Tasks       *service.TaskStore      // 解压和压缩等后台任务
+	AdminToken  string
 	Record      bool
 	RecordPath  string
 	Log         bool
 	LogFilePath string
 }
 
-func (uc *UserClient) RepackPath(pathStr string) string {
-	return path.Join(uc.UserInfo.HomePath, pathStr)
+// NodeSessions 统计集群各登录节点当前的会话数
+func (s *Server) NodeSessions(cluster string) map[string]int {
+	sessions := make(map[string]int)
+	s.Sessions.Range(func(_ string, client *UserClient) bool {
+		if client.LoginNode != nil && client.UserInfo.Cluster != nil && client.UserInfo.Cluster.Name == cluster {
+			sessions[client.LoginNode.Name]++
+		}
+		return true
+	})
+	return sessions
+}
+
+// RemoveUploadPart 通过用户在集群上任一在线会话的 SFTP 连接删除分块上传的临时文件，供 UploadStore.SetRemover 使用。
+// 创建上传的会话可能已过期或重连，因此不使用创建时的连接；没有在线会话时返回错误，等用户重新登录后重试
+func (s *Server) RemoveUploadPart(owner, part string) error {
+	// 遍历会话时持有会话表的读锁，先取出匹配的会话，释放后再访问登录节点
+	var clients []*UserClient
+	s.Sessions.Range(func(_ string, client *UserClient) bool {
+		if !client.isClosed() && client.UserInfo != nil && client.UserInfo.Cluster != nil &&
+			service.UploadOwner(client.UserInfo.Cluster.Name, client.UserInfo.Name) == owner {
+			clients = append(clients, client)
+		}
+		return true
+	})
+	err := fmt.Errorf("no live session of %s", owner)
+	for _, client := range clients {
+		sftpClient := client.SFTP()
+		if sftpClient == nil {
+			continue
+		}
+		if err = sftpClient.Remove(part); err == nil || errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+	}
+	return err
+}
+
+// RepackPath 将客户端路径转换为登录节点上的绝对路径，相对路径相对于家目录。
+// 路径及其指向的位置超出集群允许访问的目录时返回 service.ErrPermissionDenied
+func (uc *UserClient) RepackPath(pathStr string) (string, error) {
+	return uc.paths().Resolve(uc.SFTP(), pathStr, true)
+}
+
+// RepackLinkPath 与 RepackPath 相同，但不跟随最后一级符号链接，用于删除、重命名等作用于链接本身的操作
+func (uc *UserClient) RepackLinkPath(pathStr string) (string, error) {
+	return uc.paths().Resolve(uc.SFTP(), pathStr, false)
+}
+
+func (uc *UserClient) paths() *service.PathConfiner {
+	if uc.Paths == nil {
+		return service.NewPathConfiner(uc.UserInfo.HomePath, uc.UserInfo.Name, nil)
+	}
+	return uc.Paths
+}
+
+// Touch 刷新会话最近使用时间
+func (uc *UserClient) Touch() {
+	atomic.StoreInt64(&uc.lastUsed, time.Now().UnixNano())
+}
+
+// LastUsed 会话最近使用时间
+func (uc *UserClient) LastUsed() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&uc.lastUsed))
+}
+
+func (uc *UserClient) refreshedAt() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&uc.refreshed))
+}
+
+func (uc *UserClient) markRefreshed(t time.Time) {
+	atomic.StoreInt64(&uc.refreshed, t.UnixNano())
 }
 
-func (uc *UserClient) KeepAlive() {
-	go func() {
-		ticker := time.NewTicker(30 * time.Second) // 每 30 秒发送一次心跳
-		defer ticker.Stop()
+// Acquire 标记会话正在被长时间占用（如 Web 终端），占用期间不会因空闲而过期
+func (uc *UserClient) Acquire() {
+	atomic.AddInt32(&uc.active, 1)
+}
+
+// Release 结束占用并刷新最近使用时间
+func (uc *UserClient) Release() {
+	atomic.AddInt32(&uc.active, -1)
+	uc.Touch()
+}
 
-		for range ticker.C {
-			session, err := uc.SSHClient.NewSession()
-			if err != nil {
-				fmt.Printf("心跳会话创建失败: %v（可能连接已断开）\n", err)
-				return
-			}
-			_, err = session.CombinedOutput("echo -n")
-			session.Close()
-			if err != nil {
-				fmt.Printf("心跳发送失败: %v（连接可能已断开）\n", err)
-				return
-			}
+// InUse 会话是否正在被占用
+func (uc *UserClient) InUse() bool {
+	return atomic.LoadInt32(&uc.active) > 0
+}
+
+// Close 关闭 SFTP 和 SSH 连接并停止心跳，可重复调用
+func (uc *UserClient) Close() {
+	uc.closeOnce.Do(func() {
+		close(uc.closed())
+		uc.connMu.Lock()
+		defer uc.connMu.Unlock()
+		uc.state = SessionClosed
+		if uc.SftpClient != nil {
+			_ = uc.SftpClient.Close()
+		}
+		if uc.SSHClient != nil {
+			_ = uc.SSHClient.Close()
 		}
-	}()
+	})
+}
+
+// SSH 返回当前的 SSH 连接，断线重连后会替换为新的连接
+func (uc *UserClient) SSH() *ssh.Client {
+	uc.connMu.RLock()
+	defer uc.connMu.RUnlock()
+	return uc.SSHClient
+}
+
+// SFTP 返回当前的 SFTP 客户端，断线重连后会替换为新的客户端
+func (uc *UserClient) SFTP() *sftp.Client {
+	uc.connMu.RLock()
+	defer uc.connMu.RUnlock()
+	return uc.SftpClient
+}
+
+// Executor 返回在当前 SSH 连接上执行命令的执行器
+func (uc *UserClient) Executor() service.RemoteExecutor {
+	return service.WithTimeouts(service.NewSSHExecutor(uc.SSH()), uc.Timeouts)
+}
+
+// SlurmToken 返回访问 slurmrestd 的用户令牌，在登录节点上通过 scontrol token 生成并缓存
+func (uc *UserClient) SlurmToken(ctx context.Context) (string, error) {
+	return uc.slurmToken.Token(ctx, uc.Executor())
+}
+
+// closed 返回会话关闭时关闭的 channel
+func (uc *UserClient) closed() chan struct{} {
+	uc.doneOnce.Do(func() {
+		uc.done = make(chan struct{})
+	})
+	return uc.done
+}
+
+// isClosed 会话是否已关闭
+func (uc *UserClient) isClosed() bool {
+	select {
+	case <-uc.closed():
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,104 @@
+package public
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+	"star-dim/internal/service"
+)
+
+// pipeSFTP 返回连接到内存 SFTP 服务的客户端，多个客户端共享同一个文件系统
+func pipeSFTP(t *testing.T, handlers sftp.Handlers) *sftp.Client {
+	t.Helper()
+	clientRead, serverWrite := io.Pipe()
+	serverRead, clientWrite := io.Pipe()
+	server := sftp.NewRequestServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverRead, serverWrite}, handlers)
+	go func() {
+		_ = server.Serve()
+		// 客户端关闭后结束其读取
+		_ = serverWrite.Close()
+	}()
+	client, err := sftp.NewClientPipe(clientRead, clientWrite)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return client
+}
+
+func TestRemoveUploadPart(t *testing.T) {
+	handlers := sftp.InMemHandler()
+	hpc1 := &models.Cluster{Name: "hpc1"}
+	server := &Server{Sessions: NewSessionStore(0, 0)}
+	newSession := func(key, user string) *UserClient {
+		client := &UserClient{SftpClient: pipeSFTP(t, handlers), UserInfo: &models.User{Name: user, Cluster: hpc1}}
+		server.Sessions.Add(key, client)
+		return client
+	}
+
+	creator := newSession("tsh_a", "alice")
+	part := "/.star-dim-upload-1"
+	f, err := creator.SFTP().Create(part)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	owner := service.UploadOwner("hpc1", "alice")
+
+	// 创建上传的会话已关闭，其他用户的会话不能用来删除
+	creator.Close()
+	newSession("tsh_b", "bob")
+	assert.Error(t, server.RemoveUploadPart(owner, part))
+
+	// 重新登录后通过新的会话删除，文件已不存在时视为删除成功
+	relogin := newSession("tsh_c", "alice")
+	require.NoError(t, server.RemoveUploadPart(owner, part))
+	_, err = relogin.SFTP().Lstat(part)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoError(t, server.RemoveUploadPart(owner, part))
+}
+
+// lockCheckCmd 删除文件前向会话表添加会话，删除时仍持有会话表的锁则超时失败
+type lockCheckCmd struct {
+	sftp.FileCmder
+	sessions *SessionStore
+}
+
+func (c lockCheckCmd) Filecmd(r *sftp.Request) error {
+	if r.Method == "Remove" {
+		added := make(chan struct{})
+		go func() {
+			c.sessions.Add("tsh_probe", &UserClient{UserInfo: &models.User{Name: "probe"}})
+			close(added)
+		}()
+		select {
+		case <-added:
+		case <-time.After(5 * time.Second):
+			return errors.New("session store is locked during remove")
+		}
+	}
+	return c.FileCmder.Filecmd(r)
+}
+
+func TestRemoveUploadPartReleasesSessions(t *testing.T) {
+	server := &Server{Sessions: NewSessionStore(0, 0)}
+	handlers := sftp.InMemHandler()
+	handlers.FileCmd = lockCheckCmd{FileCmder: handlers.FileCmd, sessions: server.Sessions}
+	client := &UserClient{SftpClient: pipeSFTP(t, handlers), UserInfo: &models.User{Name: "alice", Cluster: &models.Cluster{Name: "hpc1"}}}
+	server.Sessions.Add("tsh_a", client)
+
+	part := "/.star-dim-upload-1"
+	f, err := client.SFTP().Create(part)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, server.RemoveUploadPart(service.UploadOwner("hpc1", "alice"), part))
+}
@@ -0,0 +1,222 @@
+package public
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// SessionStore 并发安全的用户会话存储，支持空闲超时和绝对超时。
+// 会话过期或登出时关闭对应的 SSH 和 SFTP 连接。
+// 会话元数据同时写入 registry，多副本部署时其他副本据此转发请求。
+type SessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*UserClient
+	// idleTTL 会话空闲超时，为 0 时不限制
+	idleTTL time.Duration
+	// maxTTL 会话自创建起的最长存活时间，为 0 时不限制
+	maxTTL time.Duration
+
+	registry SessionRegistry
+	// replica 本副本对其他副本可访问的地址，如 http://10.0.0.5:8080
+	replica string
+}
+
+func NewSessionStore(idleTTL, maxTTL time.Duration) *SessionStore {
+	return &SessionStore{
+		sessions: make(map[string]*UserClient),
+		idleTTL:  idleTTL,
+		maxTTL:   maxTTL,
+		registry: NewMemoryRegistry(),
+	}
+}
+
+// SetRegistry 设置会话注册表和本副本地址，需在注册会话前调用
+func (s *SessionStore) SetRegistry(registry SessionRegistry, replica string) {
+	s.registry = registry
+	s.replica = replica
+}
+
+// Replica 本副本地址
+func (s *SessionStore) Replica() string {
+	return s.replica
+}
+
+// Lookup 在注册表中查询会话元数据，会话可能属于其他副本
+func (s *SessionStore) Lookup(key string) (*SessionMeta, error) {
+	return s.registry.Lookup(key)
+}
+
+// Has 判断会话是否由本副本持有，不刷新使用时间
+func (s *SessionStore) Has(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.sessions[key]
+	return ok
+}
+
+// ttl 计算会话在注册表中的过期时间，取空闲超时和剩余存活时间中较短者
+func (s *SessionStore) ttl(client *UserClient, now time.Time) time.Duration {
+	ttl := s.idleTTL
+	if s.maxTTL > 0 {
+		remaining := s.maxTTL - now.Sub(client.CreatedAt)
+		if ttl == 0 || remaining < ttl {
+			ttl = remaining
+		}
+	}
+	return ttl
+}
+
+// refresh 延长会话在注册表中的过期时间，空闲超时的四分之一内最多刷新一次
+func (s *SessionStore) refresh(key string, client *UserClient, force bool) {
+	now := time.Now()
+	interval := s.idleTTL / 4
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if !force && now.Sub(client.refreshedAt()) < interval {
+		return
+	}
+	client.markRefreshed(now)
+	if err := s.registry.Refresh(key, s.ttl(client, now)); err != nil {
+		log.Printf("refresh session %s: %v", key, err)
+	}
+}
+
+// Add 注册会话
+func (s *SessionStore) Add(key string, client *UserClient) {
+	now := time.Now()
+	client.CreatedAt = now
+	client.Touch()
+	s.mu.Lock()
+	old := s.sessions[key]
+	s.sessions[key] = client
+	s.mu.Unlock()
+	if old != nil && old != client {
+		old.Close()
+	}
+	meta := &SessionMeta{
+		Key:       key,
+		Owner:     client.UserInfo.Name,
+		Replica:   s.replica,
+		CreatedAt: now,
+	}
+	if client.UserInfo.Cluster != nil {
+		meta.Cluster = client.UserInfo.Cluster.Name
+	}
+	if client.LoginNode != nil {
+		meta.LoginNode = client.LoginNode.Name
+	}
+	client.markRefreshed(now)
+	if err := s.registry.Register(meta, s.ttl(client, now)); err != nil {
+		log.Printf("register session %s: %v", key, err)
+	}
+}
+
+// Get 获取会话并刷新最近使用时间，已过期的会话会被立即关闭
+func (s *SessionStore) Get(key string) (*UserClient, bool) {
+	s.mu.RLock()
+	client, ok := s.sessions[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if s.expired(client, time.Now()) {
+		s.Remove(key)
+		return nil, false
+	}
+	client.Touch()
+	s.refresh(key, client, false)
+	return client, true
+}
+
+// Remove 删除本副本持有的会话并关闭连接
+func (s *SessionStore) Remove(key string) bool {
+	s.mu.Lock()
+	client, ok := s.sessions[key]
+	delete(s.sessions, key)
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+	client.Close()
+	if err := s.registry.Unregister(key); err != nil {
+		log.Printf("unregister session %s: %v", key, err)
+	}
+	return true
+}
+
+// Range 遍历全部会话，fn 返回 false 时停止。fn 在持有读锁时调用，不能修改会话表或访问登录节点
+func (s *SessionStore) Range(fn func(key string, client *UserClient) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for key, client := range s.sessions {
+		if !fn(key, client) {
+			return
+		}
+	}
+}
+
+// Len 当前会话数
+func (s *SessionStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.sessions)
+}
+
+// expired 判断会话是否过期，正在使用中的会话（如 Web 终端）不受空闲超时限制
+func (s *SessionStore) expired(client *UserClient, now time.Time) bool {
+	if s.maxTTL > 0 && now.Sub(client.CreatedAt) > s.maxTTL {
+		return true
+	}
+	if s.idleTTL > 0 && !client.InUse() && now.Sub(client.LastUsed()) > s.idleTTL {
+		return true
+	}
+	return false
+}
+
+// Reap 清理过期会话，返回清理的数量。正在使用中的会话同时刷新注册表中的过期时间。
+func (s *SessionStore) Reap() int {
+	now := time.Now()
+	expired := make(map[string]*UserClient)
+	inUse := make(map[string]*UserClient)
+	s.mu.Lock()
+	for key, client := range s.sessions {
+		if s.expired(client, now) {
+			expired[key] = client
+			delete(s.sessions, key)
+		} else if client.InUse() {
+			inUse[key] = client
+		}
+	}
+	s.mu.Unlock()
+	// 关闭连接和访问注册表可能较慢，不在锁内进行
+	for key, client := range expired {
+		client.Close()
+		if err := s.registry.Unregister(key); err != nil {
+			log.Printf("unregister session %s: %v", key, err)
+		}
+	}
+	for key, client := range inUse {
+		s.refresh(key, client, true)
+	}
+	return len(expired)
+}
+
+// StartReaper 启动后台协程定期清理过期会话
+func (s *SessionStore) StartReaper(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n := s.Reap(); n > 0 {
+					log.Printf("closed %d expired sessions", n)
+				}
+			}
+		}
+	}()
+}
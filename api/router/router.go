@@ -4,26 +4,44 @@ import (
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"star-dim/api/handler/admin"
 	"star-dim/api/handler/filesystem"
+	"star-dim/api/handler/shell"
 	"star-dim/api/handler/slurm"
 	"star-dim/api/handler/user"
+	"star-dim/api/middleware"
 	"star-dim/api/public"
+	"star-dim/internal/models"
 )
 
 func SetupRouters(r *gin.Engine, server *public.Server) {
 	userHandler := user.NewUserHandler(server)
 	filesHandler := filesystem.NewFilesHandler(server)
 	slurmHandler := slurm.NewSlurmHandler(server)
+	adminHandler := admin.NewAdminHandler(server)
+	shellHandler := shell.NewWebshellHandler(server)
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	v1 := r.Group("/api/v1")
+	// 除登录和管理接口外均需携带访问令牌；多副本部署时，会话所在副本以外的请求会被转发到持有 SSH 连接的副本
+	authed := v1.Group("", middleware.JWTAuth(server.Tokens), middleware.ForwardSession(server.Sessions))
+	// 需要 SSH 会话的接口由 UserSession 注入当前会话
+	session := authed.Group("", middleware.UserSession(server.Sessions))
 
-	userRouter := v1.Group("user")
-	userRouter.POST("/login", userHandler.Login)
+	loginRouter := v1.Group("user")
+	loginRouter.POST("/login", userHandler.Login)
+	loginRouter.POST("/login/challenge", userHandler.AnswerChallenge)
+	loginRouter.POST("/refresh", userHandler.Refresh)
+
+	userRouter := authed.Group("user")
 	userRouter.POST("/logout", userHandler.Logout)
+	userRouter.GET("/session/", middleware.UserSession(server.Sessions), userHandler.SessionStatus)
+
+	clusterRouter := authed.Group("/clusters")
+	clusterRouter.GET("/health/", userHandler.ClusterHealth)
 
-	fileRouter := v1.Group("filesystem")
+	fileRouter := session.Group("filesystem")
 	fileRouter.GET("/files/", filesHandler.List)                       //request param: path!,cluster? systemUsername? ok!
 	fileRouter.POST("files/", filesHandler.New)                        //request param: path!,cluster? systemUsername? ok!
 	fileRouter.DELETE("files/", filesHandler.Delete)                   //request param: path!,cluster? systemUsername? ok!
@@ -37,8 +55,19 @@ func SetupRouters(r *gin.Engine, server *public.Server) {
 	fileRouter.POST("/files/chown/", filesHandler.Chown)               //request param: path!,cluster? systemUsername?
 	fileRouter.POST("/files/transmission/", filesHandler.Transmission) //request param: path!,cluster? systemUsername?
 	fileRouter.GET("/files/download/", filesHandler.Download)          //request param: path!,cluster? systemUsername? ok!
+	fileRouter.HEAD("/files/download/", filesHandler.Download)         //request param: path!,cluster? systemUsername?
 	fileRouter.GET("/quota/", filesHandler.Quota)                      //request param: path!,cluster? systemUsername? no quota cmd!
 	fileRouter.POST("/files/execute/", filesHandler.ExecuteFile)
+	fileRouter.POST("/files/extract/", filesHandler.Extract)
+	fileRouter.POST("/files/compress/", filesHandler.Compress)
+	fileRouter.POST("/uploads/", filesHandler.CreateUpload)
+	fileRouter.GET("/uploads/:id/", filesHandler.UploadStatus)
+	fileRouter.DELETE("/uploads/:id/", filesHandler.AbortUpload)
+	fileRouter.PUT("/uploads/:id/chunks/:index/", filesHandler.UploadChunk)
+	fileRouter.POST("/uploads/:id/complete/", filesHandler.CompleteUpload)
+	fileRouter.GET("/tasks/", filesHandler.ListTasks)
+	fileRouter.GET("/tasks/:id/", filesHandler.TaskStatus)
+	fileRouter.DELETE("/tasks/:id/", filesHandler.CancelTask)
 
 	//slurmRouter := v1.Group("/slurm")
 	//sacctRouter := slurmRouter.Group("/sacct")
@@ -55,10 +84,38 @@ func SetupRouters(r *gin.Engine, server *public.Server) {
 	//sinfoRouter := slurmRouter.Group("/sinfo")
 	//sinfoRouter.POST("/cluster/", slurmHandler.GetClusterInfo)
 
-	slurmRouter := v1.Group("/slurm")
+	slurmRouter := session.Group("/slurm")
 	slurmRouter.POST("/job/", slurmHandler.SubmitJob)
 	slurmRouter.DELETE("/job/", slurmHandler.CancelJob)
 	slurmRouter.POST("/jobs/", slurmHandler.GetQueue)
 	slurmRouter.POST("/account/", slurmHandler.GetAccounting)
 	slurmRouter.POST("/cluster/", slurmHandler.GetClusterInfo)
+	slurmRouter.GET("/profile/", slurmHandler.GetProfile)
+	// 集群管理员专用接口
+	slurmAdminRouter := slurmRouter.Group("/admin", middleware.RequireRole(models.RoleClusterAdmin))
+	slurmAdminRouter.GET("/users/:user/jobs/", slurmHandler.GetUserQueue)
+
+	// 浏览器 WebSocket 无法设置请求头，可通过登录时下发的 Cookie 携带访问令牌
+	shellRouter := session.Group("/shell")
+	shellRouter.GET("/ssh/", shellHandler.SSH)
+
+	// 管理接口需要集群管理员会话，引导令牌仅用于首次配置或没有可登录集群时
+	adminRouter := v1.Group("/admin", middleware.AdminAuth(server.AdminToken,
+		middleware.JWTAuth(server.Tokens), middleware.ForwardSession(server.Sessions), middleware.UserSession(server.Sessions))...)
+	adminRouter.GET("/clusters/", adminHandler.ListClusters)
+	adminRouter.POST("/clusters/", adminHandler.CreateCluster)
+	adminRouter.GET("/clusters/:name/", adminHandler.GetCluster)
+	adminRouter.PUT("/clusters/:name/", adminHandler.UpdateCluster)
+	adminRouter.DELETE("/clusters/:name/", adminHandler.DeleteCluster)
+	adminRouter.PUT("/clusters/:name/default-node/", adminHandler.SetDefaultNode)
+	adminRouter.POST("/clusters/:name/nodes/", adminHandler.AddLoginNode)
+	adminRouter.PUT("/clusters/:name/nodes/:node/", adminHandler.UpdateLoginNode)
+	adminRouter.DELETE("/clusters/:name/nodes/:node/", adminHandler.DeleteLoginNode)
+	adminRouter.PUT("/clusters/:name/nodes/:node/state/", adminHandler.SetLoginNodeState)
+	adminRouter.PUT("/clusters/:name/nodes/:node/labels/", adminHandler.SetLoginNodeLabels)
+	adminRouter.GET("/hostkeys/", adminHandler.ListHostKeys)
+	adminRouter.PUT("/hostkeys/", adminHandler.RotateHostKey)
+	adminRouter.DELETE("/hostkeys/", adminHandler.ForgetHostKey)
+	adminRouter.POST("/hostkeys/approve/", adminHandler.ApproveHostKey)
+	adminRouter.GET("/hostkeys/known_hosts/", adminHandler.ExportKnownHosts)
 }
@@ -1,6 +1,33 @@
 package configs
 
+import "time"
+
 type Config struct {
 	Host string `json:"host"`
 	Port string `json:"port"`
+	// ClusterFile 集群及登录节点配置文件（YAML 或 JSON）
+	ClusterFile string `json:"cluster_file"`
+	// AdminToken 管理接口引导令牌，可管理所有集群；为空时只有集群管理员会话可以调用管理接口
+	AdminToken string `json:"admin_token"`
+	// HostKeyFile 登录节点主机密钥记录文件
+	HostKeyFile string `json:"host_key_file"`
+	// SessionIdleTTL 会话空闲超时，SessionMaxTTL 会话最长存活时间，为 0 时不限制
+	SessionIdleTTL time.Duration `json:"session_idle_ttl"`
+	SessionMaxTTL  time.Duration `json:"session_max_ttl"`
+	// RedisURL 会话注册表使用的 Redis 地址（如 redis://:password@host:6379/0），为空时仅使用进程内注册表
+	RedisURL string `json:"redis_url"`
+	// AdvertiseAddr 本副本对其他副本可访问的地址，用于转发请求
+	AdvertiseAddr string `json:"advertise_addr"`
+	// JWTSecret 令牌签名密钥，按原样使用；JWTKeys 用于轮换的密钥列表，逗号分隔的 kid=secret，第一个用于签名。
+	// 同时配置时 JWTSecret 只用于校验，均为空时使用随机密钥
+	JWTSecret string `json:"jwt_secret"`
+	JWTKeys   string `json:"jwt_keys"`
+	// AccessTokenTTL 访问令牌有效期，RefreshTokenTTL 刷新令牌有效期
+	AccessTokenTTL  time.Duration `json:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `json:"refresh_token_ttl"`
+	// CommandTimeout Slurm 命令的默认超时时间，文件操作和脚本执行默认不限制；CommandTimeouts 按命令名设置，如 sacct=5m,rm=30m
+	CommandTimeout  time.Duration `json:"command_timeout"`
+	CommandTimeouts string        `json:"command_timeouts"`
+	// ArchiveMaxSize 目录打包下载的文件总大小上限（字节），为 0 时不限制
+	ArchiveMaxSize int64 `json:"archive_max_size"`
 }
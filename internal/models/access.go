@@ -0,0 +1,79 @@
+package models
+
+import "fmt"
+
+// 门户用户角色
+const (
+	RoleUser         = "user"
+	RoleProjectAdmin = "project-admin"
+	RoleClusterAdmin = "cluster-admin"
+)
+
+// AccessPolicy 集群访问策略，角色在登录时确定并随会话保存，修改后需重新登录生效
+type AccessPolicy struct {
+	// ClusterAdmins 集群管理员，可以管理所有用户的作业和文件属主
+	ClusterAdmins []string `json:"cluster_admins,omitempty" yaml:"cluster_admins,omitempty"`
+	// ProjectAdmins 项目管理员，键为 Slurm 账户名，可以管理该账户下所有用户的作业
+	ProjectAdmins map[string][]string `json:"project_admins,omitempty" yaml:"project_admins,omitempty"`
+	// ViewAllJobs 允许普通用户查询其他用户的作业记录（sacct --allusers）
+	ViewAllJobs bool `json:"view_all_jobs,omitempty" yaml:"view_all_jobs,omitempty"`
+}
+
+// Validate 校验访问策略
+func (p *AccessPolicy) Validate() error {
+	for _, name := range p.ClusterAdmins {
+		if name == "" {
+			return fmt.Errorf("empty cluster admin")
+		}
+	}
+	for account, admins := range p.ProjectAdmins {
+		if account == "" {
+			return fmt.Errorf("empty project account")
+		}
+		for _, name := range admins {
+			if name == "" {
+				return fmt.Errorf("project %s has an empty admin", account)
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve 返回用户的角色以及作为项目管理员管理的账户，未配置策略时均为普通用户
+func (p *AccessPolicy) Resolve(username string) (string, []string) {
+	if p == nil {
+		return RoleUser, nil
+	}
+	for _, name := range p.ClusterAdmins {
+		if name == username {
+			return RoleClusterAdmin, nil
+		}
+	}
+	var projects []string
+	for account, admins := range p.ProjectAdmins {
+		for _, name := range admins {
+			if name == username {
+				projects = append(projects, account)
+				break
+			}
+		}
+	}
+	if len(projects) > 0 {
+		return RoleProjectAdmin, projects
+	}
+	return RoleUser, nil
+}
+
+// Clone 深拷贝访问策略
+func (p *AccessPolicy) Clone() *AccessPolicy {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	clone.ClusterAdmins = append([]string(nil), p.ClusterAdmins...)
+	clone.ProjectAdmins = make(map[string][]string, len(p.ProjectAdmins))
+	for account, admins := range p.ProjectAdmins {
+		clone.ProjectAdmins[account] = append([]string(nil), admins...)
+	}
+	return &clone
+}
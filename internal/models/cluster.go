@@ -1,6 +1,181 @@
 package models
 
+import (
+	"fmt"
+)
+
 type Cluster struct {
-	Name       string       `json:"name"`
-	LoginNodes []*LoginNode `json:"login_nodes"`
+	Name       string       `json:"name" yaml:"name"`
+	LoginNodes []*LoginNode `json:"login_nodes" yaml:"login_nodes"`
+	// DefaultNode 未指定登录节点时优先使用的节点
+	DefaultNode string `json:"default_node,omitempty" yaml:"default_node,omitempty"`
+	// Strategy 登录节点选择策略：空（默认节点优先）、round-robin、least-sessions
+	Strategy string `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	// HostKeyPolicy 主机密钥校验策略：tofu（默认）、pinned、known_hosts、insecure
+	HostKeyPolicy string `json:"host_key_policy,omitempty" yaml:"host_key_policy,omitempty"`
+	// KnownHostsFile known_hosts 策略使用的 known_hosts 文件
+	KnownHostsFile string `json:"known_hosts_file,omitempty" yaml:"known_hosts_file,omitempty"`
+	// Access 访问策略，为空时所有用户均为普通用户
+	Access *AccessPolicy `json:"access,omitempty" yaml:"access,omitempty"`
+	// SlurmRest slurmrestd 配置，为空时通过登录节点执行 Slurm 命令
+	SlurmRest *SlurmRestConfig `json:"slurmrestd,omitempty" yaml:"slurmrestd,omitempty"`
+	// Paths 文件接口允许访问的目录，为空时只能访问家目录
+	Paths *PathPolicy `json:"paths,omitempty" yaml:"paths,omitempty"`
+}
+
+const (
+	StrategyRoundRobin    = "round-robin"
+	StrategyLeastSessions = "least-sessions"
+)
+
+const (
+	HostKeyPolicyTOFU       = "tofu"
+	HostKeyPolicyPinned     = "pinned"
+	HostKeyPolicyKnownHosts = "known_hosts"
+	HostKeyPolicyInsecure   = "insecure"
+)
+
+// ClusterConfig 集群配置文件结构
+type ClusterConfig struct {
+	Clusters []*Cluster `json:"clusters" yaml:"clusters"`
+}
+
+// Validate 校验集群配置
+func (c *Cluster) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("cluster name is required")
+	}
+	if len(c.LoginNodes) == 0 {
+		return fmt.Errorf("cluster %s has no login nodes", c.Name)
+	}
+	names := make(map[string]bool)
+	for _, node := range c.LoginNodes {
+		if node == nil {
+			return fmt.Errorf("cluster %s has an empty login node", c.Name)
+		}
+		if err := node.Validate(); err != nil {
+			return fmt.Errorf("cluster %s: %v", c.Name, err)
+		}
+		if names[node.Name] {
+			return fmt.Errorf("cluster %s: duplicate login node %s", c.Name, node.Name)
+		}
+		names[node.Name] = true
+	}
+	switch c.Strategy {
+	case "", StrategyRoundRobin, StrategyLeastSessions:
+	default:
+		return fmt.Errorf("cluster %s: unknown strategy %s", c.Name, c.Strategy)
+	}
+	switch c.HostKeyPolicy {
+	case "", HostKeyPolicyTOFU, HostKeyPolicyKnownHosts, HostKeyPolicyInsecure:
+	case HostKeyPolicyPinned:
+		for _, node := range c.LoginNodes {
+			if node.HostKey == "" {
+				return fmt.Errorf("cluster %s: login node %s has no pinned host key", c.Name, node.Name)
+			}
+			for _, jump := range node.JumpHosts {
+				if jump.HostKey == "" {
+					return fmt.Errorf("cluster %s: jump host %s has no pinned host key", c.Name, jump.Host)
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("cluster %s: unknown host key policy %s", c.Name, c.HostKeyPolicy)
+	}
+	if c.DefaultNode != "" && !names[c.DefaultNode] {
+		return fmt.Errorf("cluster %s: default node %s not found", c.Name, c.DefaultNode)
+	}
+	if c.Access != nil {
+		if err := c.Access.Validate(); err != nil {
+			return fmt.Errorf("cluster %s: access: %v", c.Name, err)
+		}
+	}
+	if c.SlurmRest != nil {
+		if err := c.SlurmRest.Validate(); err != nil {
+			return fmt.Errorf("cluster %s: slurmrestd: %v", c.Name, err)
+		}
+	}
+	if c.Paths != nil {
+		if err := c.Paths.Validate(); err != nil {
+			return fmt.Errorf("cluster %s: paths: %v", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// GetLoginNode 按名称查找登录节点
+func (c *Cluster) GetLoginNode(name string) *LoginNode {
+	for _, node := range c.LoginNodes {
+		if node.Name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+// CandidateNodes 返回可用且包含全部指定标签的登录节点，默认节点排在最前
+func (c *Cluster) CandidateNodes(labels []string) []*LoginNode {
+	var nodes []*LoginNode
+	for _, node := range c.LoginNodes {
+		if node.Disabled || !node.HasLabels(labels) {
+			continue
+		}
+		if node.Name == c.DefaultNode {
+			nodes = append([]*LoginNode{node}, nodes...)
+		} else {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Clone 深拷贝集群配置
+func (c *Cluster) Clone() *Cluster {
+	clone := *c
+	clone.LoginNodes = make([]*LoginNode, 0, len(c.LoginNodes))
+	for _, node := range c.LoginNodes {
+		n := *node
+		n.Labels = append([]string(nil), node.Labels...)
+		n.JumpHosts = make([]*JumpHost, 0, len(node.JumpHosts))
+		for _, jump := range node.JumpHosts {
+			j := *jump
+			n.JumpHosts = append(n.JumpHosts, &j)
+		}
+		clone.LoginNodes = append(clone.LoginNodes, &n)
+	}
+	clone.Access = c.Access.Clone()
+	clone.SlurmRest = c.SlurmRest.Clone()
+	clone.Paths = c.Paths.Clone()
+	return &clone
+}
+
+// Redacted 返回隐藏跳板机密码、私钥和私钥口令的副本，用于接口输出
+func (c *Cluster) Redacted() *Cluster {
+	clone := c.Clone()
+	for _, node := range clone.LoginNodes {
+		for _, jump := range node.JumpHosts {
+			jump.Password = ""
+			jump.PrivateKey = ""
+			jump.Passphrase = ""
+		}
+	}
+	return clone
+}
+
+// Validate 校验集群配置文件，集群名称不能重复
+func (c *ClusterConfig) Validate() error {
+	names := make(map[string]bool)
+	for _, cluster := range c.Clusters {
+		if cluster == nil {
+			return fmt.Errorf("empty cluster entry")
+		}
+		if err := cluster.Validate(); err != nil {
+			return err
+		}
+		if names[cluster.Name] {
+			return fmt.Errorf("duplicate cluster %s", cluster.Name)
+		}
+		names[cluster.Name] = true
+	}
+	return nil
 }
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"strconv"
+)
+
+// JumpHost 登录节点的跳板机，按 ProxyJump 语义依次连接。
+// 未配置凭据时使用用户的登录凭据认证。
+type JumpHost struct {
+	Host string `json:"host" yaml:"host"`
+	Port string `json:"port" yaml:"port"`
+	// User 跳板机用户名，为空时使用登录用户名
+	User     string `json:"user,omitempty" yaml:"user,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// PrivateKey 私钥内容，PrivateKeyFile 为服务器上的私钥文件路径，二者任选其一
+	PrivateKey     string `json:"private_key,omitempty" yaml:"private_key,omitempty"`
+	PrivateKeyFile string `json:"private_key_file,omitempty" yaml:"private_key_file,omitempty"`
+	Passphrase     string `json:"passphrase,omitempty" yaml:"passphrase,omitempty"`
+	// HostKey 固定的主机公钥（authorized_keys 格式），pinned 策略下必填
+	HostKey string `json:"host_key,omitempty" yaml:"host_key,omitempty"`
+}
+
+// Validate 校验跳板机配置，端口为空时默认使用 22
+func (j *JumpHost) Validate() error {
+	if j.Host == "" {
+		return fmt.Errorf("jump host: host is required")
+	}
+	if j.Port == "" {
+		j.Port = "22"
+	}
+	port, err := strconv.Atoi(j.Port)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("jump host %s: invalid port %s", j.Host, j.Port)
+	}
+	if j.HostKey != "" {
+		if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(j.HostKey)); err != nil {
+			return fmt.Errorf("jump host %s: invalid host key: %v", j.Host, err)
+		}
+	}
+	return nil
+}
+
+// Address 跳板机连接地址
+func (j *JumpHost) Address() string {
+	return net.JoinHostPort(j.Host, j.Port)
+}
+
+// HasCredentials 是否配置了跳板机专用凭据
+func (j *JumpHost) HasCredentials() bool {
+	return j.Password != "" || j.PrivateKey != "" || j.PrivateKeyFile != ""
+}
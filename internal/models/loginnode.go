@@ -1,7 +1,69 @@
 package models
 
+import (
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"strconv"
+)
+
 type LoginNode struct {
-	Name string `json:"name"`
-	Host string `json:"host"`
-	Port string `json:"port"`
+	Name string `json:"name" yaml:"name"`
+	Host string `json:"host" yaml:"host"`
+	Port string `json:"port" yaml:"port"`
+	// Disabled 禁用的节点不会被分配给新的登录会话
+	Disabled bool `json:"disabled" yaml:"disabled,omitempty"`
+	// Labels 节点标签，如 gpu、visualization
+	Labels []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// HostKey 固定的主机公钥（authorized_keys 格式），pinned 策略下必填
+	HostKey string `json:"host_key,omitempty" yaml:"host_key,omitempty"`
+	// JumpHosts 跳板机链，按顺序连接，为空时直接连接登录节点
+	JumpHosts []*JumpHost `json:"jump_hosts,omitempty" yaml:"jump_hosts,omitempty"`
+}
+
+// Validate 校验登录节点配置，端口为空时默认使用 22
+func (n *LoginNode) Validate() error {
+	if n.Name == "" {
+		return fmt.Errorf("login node name is required")
+	}
+	if n.Host == "" {
+		return fmt.Errorf("login node %s: host is required", n.Name)
+	}
+	if n.Port == "" {
+		n.Port = "22"
+	}
+	port, err := strconv.Atoi(n.Port)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("login node %s: invalid port %s", n.Name, n.Port)
+	}
+	if n.HostKey != "" {
+		if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(n.HostKey)); err != nil {
+			return fmt.Errorf("login node %s: invalid host key: %v", n.Name, err)
+		}
+	}
+	for _, jump := range n.JumpHosts {
+		if jump == nil {
+			return fmt.Errorf("login node %s has an empty jump host", n.Name)
+		}
+		if err := jump.Validate(); err != nil {
+			return fmt.Errorf("login node %s: %v", n.Name, err)
+		}
+	}
+	return nil
+}
+
+// HasLabels 判断节点是否包含全部指定标签
+func (n *LoginNode) HasLabels(labels []string) bool {
+	for _, label := range labels {
+		found := false
+		for _, l := range n.Labels {
+			if l == label {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PathPolicy 文件接口允许访问的目录。用户家目录始终允许访问，
+// 目录中的 {user} 替换为登录用户名，{home} 替换为家目录
+type PathPolicy struct {
+	// Roots 家目录以外允许访问的目录，如 /scratch/{user}、/lustre/projects
+	Roots []string `json:"roots,omitempty" yaml:"roots,omitempty"`
+}
+
+// Validate 校验目录均为绝对路径
+func (p *PathPolicy) Validate() error {
+	for _, root := range p.Roots {
+		if !path.IsAbs(p.expand(root, "/home/user", "user")) {
+			return fmt.Errorf("root %q is not an absolute path", root)
+		}
+	}
+	return nil
+}
+
+// AllowedRoots 返回用户允许访问的目录，家目录排在最前
+func (p *PathPolicy) AllowedRoots(home, user string) []string {
+	roots := []string{home}
+	if p == nil {
+		return roots
+	}
+	for _, root := range p.Roots {
+		roots = append(roots, path.Clean(p.expand(root, home, user)))
+	}
+	return roots
+}
+
+func (p *PathPolicy) expand(root, home, user string) string {
+	return strings.NewReplacer("{home}", home, "{user}", user).Replace(root)
+}
+
+// Clone 复制配置
+func (p *PathPolicy) Clone() *PathPolicy {
+	if p == nil {
+		return nil
+	}
+	return &PathPolicy{Roots: append([]string(nil), p.Roots...)}
+}
@@ -66,6 +66,7 @@ type SacctRequest struct {
 	ArrayJobs   bool     `json:"arrayjobs,omitempty"`
 	Completion  bool     `json:"completion,omitempty"`
 	Allocations bool     `json:"allocations,omitempty"`
+	JSON        string   `json:"json,omitempty"`
 }
 
 type SacctResponse struct {
@@ -75,6 +76,8 @@ type SacctResponse struct {
 	Total     int       `json:"total"`
 	Command   string    `json:"command,omitempty"`
 	RawOutput string    `json:"raw_output,omitempty"`
+	// Err 执行失败的原因，不序列化
+	Err error `json:"-"`
 }
 
 type SSHConfig struct {
@@ -196,6 +199,8 @@ type SbatchResponse struct {
 	Cluster   string `json:"cluster,omitempty"`
 	Command   string `json:"command,omitempty"`
 	RawOutput string `json:"raw_output,omitempty"`
+	// Err 执行失败的原因，不序列化
+	Err error `json:"-"`
 }
 
 // QueueJobInfo 表示 squeue 返回的作业队列信息
@@ -286,6 +291,10 @@ type SqueueResponse struct {
 	Total     int            `json:"total"`
 	Command   string         `json:"command,omitempty"`
 	RawOutput string         `json:"raw_output,omitempty"`
+	// Warnings 集群不支持而被忽略的请求选项
+	Warnings []string `json:"warnings,omitempty"`
+	// Err 执行失败的原因，不序列化
+	Err error `json:"-"`
 }
 
 // SinfoRequest 表示 sinfo 命令请求参数
@@ -326,6 +335,8 @@ type SinfoResponse struct {
 	Total     int         `json:"total"`
 	Command   string      `json:"command,omitempty"`
 	RawOutput string      `json:"raw_output,omitempty"`
+	// Err 执行失败的原因，不序列化
+	Err error `json:"-"`
 }
 
 type NodeInfo struct {
@@ -418,3 +429,14 @@ type ScancelRequest struct {
 	NodeList    string   `json:"nodelist,omitempty"`    // 只作用于这些节点上的作业
 	WCKey       string   `json:"wckey,omitempty"`       // 只作用于此工作负载特征键的作业
 }
+
+// SlurmProfile 集群的 Slurm 版本和随版本变化的选项支持情况，首次使用集群时探测
+type SlurmProfile struct {
+	Cluster string `json:"cluster"`
+	Version string `json:"version" example:"23.02.6"`
+	// Features 键为请求字段名，如 json、tres_bind
+	Features map[string]bool `json:"features"`
+	// Config scontrol show config 中的部分配置
+	Config     map[string]string `json:"config,omitempty"`
+	DetectedAt time.Time         `json:"detected_at"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// DefaultSlurmRestVersion 未配置时使用的 slurmrestd OpenAPI 版本
+const DefaultSlurmRestVersion = "v0.0.40"
+
+var slurmRestVersion = regexp.MustCompile(`^v\d+\.\d+\.\d+$`)
+
+// SlurmRestConfig 集群的 slurmrestd 配置。配置后 squeue、sinfo、sacct、sbatch 请求通过
+// slurmrestd 的 OpenAPI 执行，文件操作和终端仍使用登录节点的 SSH 连接
+type SlurmRestConfig struct {
+	// URL slurmrestd 地址，如 https://slurm.example.org:6820
+	URL string `json:"url" yaml:"url"`
+	// Version OpenAPI 版本，默认为 v0.0.40
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// TokenFile SlurmUser 的 JWT 文件路径，配置后以该令牌代表登录用户访问（需要 slurmrestd 允许代理用户）；
+	// 为空时在登录节点上执行 scontrol token 为每个用户生成令牌
+	TokenFile string `json:"token_file,omitempty" yaml:"token_file,omitempty"`
+	// CAFile 校验 slurmrestd 证书的 CA 文件
+	CAFile string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+	// InsecureSkipVerify 不校验 slurmrestd 证书，仅用于测试环境
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+// Validate 校验 slurmrestd 配置，版本为空时使用默认版本
+func (r *SlurmRestConfig) Validate() error {
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", r.URL)
+	}
+	if r.Version == "" {
+		r.Version = DefaultSlurmRestVersion
+	}
+	if !slurmRestVersion.MatchString(r.Version) {
+		return fmt.Errorf("invalid version %q", r.Version)
+	}
+	return nil
+}
+
+// Clone 复制配置
+func (r *SlurmRestConfig) Clone() *SlurmRestConfig {
+	if r == nil {
+		return nil
+	}
+	clone := *r
+	return &clone
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// 后台任务的状态
+const (
+	TaskRunning   = "running"
+	TaskSucceeded = "succeeded"
+	TaskFailed    = "failed"
+	TaskCanceled  = "canceled"
+)
+
+// ExtractRequest 在登录节点上解压的请求
+type ExtractRequest struct {
+	// Path 压缩包路径
+	Path string `json:"path" binding:"required" example:"data/dataset.tar.gz"`
+	// Destination 解压到的目录，不存在时创建，为空时解压到压缩包所在目录
+	Destination string `json:"destination,omitempty" example:"data/dataset"`
+	// Format 压缩包格式：zip、tar、tar.gz、tar.bz2、tar.zst，为空时按扩展名判断
+	Format string `json:"format,omitempty" example:"tar.gz"`
+	// Overwrite 覆盖已存在的文件，否则遇到已存在的文件时 tar 报错，zip 跳过
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+// CompressRequest 在登录节点上压缩的请求
+type CompressRequest struct {
+	// Paths 要压缩的文件或目录，须位于同一目录下
+	Paths []string `json:"paths" binding:"required,min=1" example:"data/run1,data/run2"`
+	// Destination 生成的压缩包路径
+	Destination string `json:"destination" binding:"required" example:"data/runs.tar.zst"`
+	// Format 压缩包格式：zip、tar、tar.gz、tar.bz2、tar.zst，为空时按扩展名判断
+	Format string `json:"format,omitempty" example:"tar.zst"`
+	// Overwrite 覆盖已存在的压缩包
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+// TaskStatus 后台任务的状态和进度
+type TaskStatus struct {
+	TaskID string `json:"task_id"`
+	// Type 任务类型：extract、compress
+	Type string `json:"type"`
+	// State 状态：running、succeeded、failed、canceled
+	State string `json:"state"`
+	// Phase 当前阶段，如 listing、extracting
+	Phase string `json:"phase,omitempty"`
+	// Total 条目总数，Done 已处理的条目数，Current 最近处理的条目
+	Total    int     `json:"total"`
+	Done     int     `json:"done"`
+	Progress float64 `json:"progress"`
+	Current  string  `json:"current,omitempty"`
+	// Path 压缩包路径，Destination 解压目录或生成的压缩包
+	Path        string     `json:"path,omitempty"`
+	Destination string     `json:"destination,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// UploadRequest 创建分块上传会话的请求
+type UploadRequest struct {
+	// Path 上传的目标文件
+	Path string `json:"path" binding:"required" example:"data/input.tar"`
+	// Size 文件大小，单位字节
+	Size int64 `json:"size" example:"104857600"`
+	// ChunkSize 分块大小，除最后一块外每块大小相同，为 0 时使用默认值
+	ChunkSize int64 `json:"chunk_size,omitempty" example:"8388608"`
+	// SHA256 整个文件的 SHA-256（十六进制），完成上传时校验，也可在完成时提供
+	SHA256 string `json:"sha256,omitempty"`
+	// Overwrite 目标文件已存在时覆盖
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+// UploadStatus 分块上传会话的状态
+type UploadStatus struct {
+	UploadID      string    `json:"upload_id"`
+	Path          string    `json:"path"`
+	Size          int64     `json:"size"`
+	ChunkSize     int64     `json:"chunk_size"`
+	Chunks        int       `json:"chunks"`
+	Received      []int     `json:"received"`
+	Missing       []int     `json:"missing"`
+	BytesReceived int64     `json:"bytes_received"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// UploadCompleteRequest 完成分块上传的请求
+type UploadCompleteRequest struct {
+	// SHA256 整个文件的 SHA-256（十六进制），创建会话时已提供则须一致
+	SHA256 string `json:"sha256,omitempty"`
+}
@@ -1,14 +1,62 @@
 package models
 
+import "time"
+
 type User struct {
 	Cluster    *Cluster `json:"cluster"`
 	Name       string   `json:"username"`
 	Password   string   `json:"password"`
 	PrivateKey string   `json:"private_key"`
-	HomePath   string   `json:"home_path"`
+	// Passphrase 私钥口令，私钥未加密时为空
+	Passphrase string `json:"passphrase,omitempty"`
+	// Certificate SSH CA 签发的用户证书（authorized_keys 格式，即 *-cert.pub 内容），需与 PrivateKey 配合使用
+	Certificate string `json:"certificate,omitempty"`
+	HomePath    string `json:"home_path"`
 }
 
+const (
+	AuthPassword            = "password"
+	AuthPublicKey           = "publickey"
+	AuthCertificate         = "certificate"
+	AuthKeyboardInteractive = "keyboard-interactive"
+)
+
 type LoginInfo struct {
 	User      *User      `json:"user"`
 	LoginNode *LoginNode `json:"login_node"`
+	// Labels 未指定登录节点时，按标签筛选登录节点
+	Labels []string `json:"labels,omitempty"`
+	// AuthMethod 认证方式：password、publickey、certificate、keyboard-interactive。
+	// 为空时根据提供的凭据自动选择。不支持 ssh-agent 认证
+	AuthMethod string `json:"auth_method,omitempty"`
+	// Answers keyboard-interactive 认证时按顺序回答服务器的提示（如密码、动态口令），
+	// 为空时进入多步登录，由客户端逐步回答质询
+	Answers []string `json:"answers,omitempty"`
+}
+
+// ChallengePrompt keyboard-interactive 质询中的单个提示
+type ChallengePrompt struct {
+	Prompt string `json:"prompt"`
+	// Echo 为 false 时表示输入内容应隐藏显示（如密码）
+	Echo bool `json:"echo"`
+}
+
+// LoginChallenge 多步登录时返回给客户端的质询
+type LoginChallenge struct {
+	ChallengeID string            `json:"challenge_id"`
+	Name        string            `json:"name,omitempty"`
+	Instruction string            `json:"instruction,omitempty"`
+	Prompts     []ChallengePrompt `json:"prompts"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+}
+
+// ChallengeAnswer 客户端对质询的回答，answers 与 prompts 一一对应
+type ChallengeAnswer struct {
+	ChallengeID string   `json:"challenge_id" binding:"required"`
+	Answers     []string `json:"answers"`
+}
+
+// RefreshRequest 使用刷新令牌换取新的令牌
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
@@ -0,0 +1,481 @@
+package service
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// readAheadSize 读取登录节点上的文件时每次请求的大小，SFTP 客户端会将大的读取拆分为并发请求
+const readAheadSize = 1 << 20
+
+// ReadAtCloser *sftp.File 实现了该接口
+type ReadAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// readAhead 为 ReaderAt 加上预读缓冲，将小块的顺序读取合并为大块读取，减少往返次数
+type readAhead struct {
+	r    io.ReaderAt
+	size int64
+	buf  []byte // 缓冲 [off, off+len(buf)) 的内容
+	off  int64
+}
+
+func newReadAhead(r io.ReaderAt, size int64) *readAhead {
+	return &readAhead{r: r, size: size, buf: make([]byte, 0, readAheadSize)}
+}
+
+func (r *readAhead) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.size {
+			return n, io.EOF
+		}
+		if pos < r.off || pos >= r.off+int64(len(r.buf)) {
+			buf := r.buf[:min(int64(cap(r.buf)), r.size-pos)]
+			m, err := r.r.ReadAt(buf, pos)
+			r.buf, r.off = buf[:m], pos
+			if m == 0 {
+				if err == nil || err == io.EOF {
+					// 文件在读取过程中变短
+					err = io.ErrUnexpectedEOF
+				}
+				return n, err
+			}
+		}
+		n += copy(p[n:], r.buf[pos-r.off:])
+	}
+	return n, nil
+}
+
+// NewRangeReader 为长度为 size 的 ReaderAt 加上预读缓冲并支持 Seek，用于 http.ServeContent 处理 Range 请求
+func NewRangeReader(r io.ReaderAt, size int64) io.ReadSeeker {
+	return io.NewSectionReader(newReadAhead(r, size), 0, size)
+}
+
+// ArchiveEntry 打包下载的条目
+type ArchiveEntry struct {
+	Name    string // 归档内的名称，目录以 / 结尾
+	Path    string // 登录节点上的路径
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+}
+
+func (e *ArchiveEntry) isDir() bool {
+	return strings.HasSuffix(e.Name, "/")
+}
+
+// CRCCache 缓存打包时计算的文件 CRC-32，键包含路径、大小和修改时间，文件变化后不再命中。
+// 续传目录压缩包时用于生成已跳过的文件的数据描述符和中央目录
+type CRCCache struct {
+	mu      sync.Mutex
+	entries map[string]uint32
+	max     int
+}
+
+func NewCRCCache(max int) *CRCCache {
+	return &CRCCache{entries: make(map[string]uint32), max: max}
+}
+
+func crcKey(e *ArchiveEntry) string {
+	return fmt.Sprintf("%s\x00%d\x00%d", e.Path, e.Size, e.ModTime.UnixNano())
+}
+
+func (c *CRCCache) get(e *ArchiveEntry) (uint32, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	crc, ok := c.entries[crcKey(e)]
+	return crc, ok
+}
+
+func (c *CRCCache) put(e *ArchiveEntry, crc uint32) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 超过容量时随机淘汰
+	for key := range c.entries {
+		if len(c.entries) < c.max {
+			break
+		}
+		delete(c.entries, key)
+	}
+	c.entries[crcKey(e)] = crc
+}
+
+type zipSegmentKind int
+
+const (
+	zipHeader     zipSegmentKind = iota // 本地文件头
+	zipContent                          // 文件内容
+	zipDescriptor                       // 数据描述符，含文件的 CRC-32
+	zipCentral                          // 中央目录和目录结束记录，含所有文件的 CRC-32
+)
+
+// zipSegment 归档中的一段，文件内容以外的部分在创建时生成
+type zipSegment struct {
+	kind   zipSegmentKind
+	offset int64
+	size   int64
+	data   []byte
+	entry  int // 文件内容和数据描述符对应的条目
+}
+
+// ZipArchive 以不压缩的 ZIP 格式打包目录。归档内容完全由条目列表决定，打包前即可得到长度和 ETag，
+// 并且可以 Seek，配合 http.ServeContent 支持 Range 请求和断点续传。
+// 文件内容按需从登录节点读取，CRC-32 在顺序读取时计算；续传时跳过的文件的 CRC-32 从 CRCCache 获取，
+// 没有缓存时重新读取文件计算。不能并发使用
+type ZipArchive struct {
+	entries  []ArchiveEntry
+	segments []zipSegment
+	size     int64
+	open     func(name string) (ReadAtCloser, error)
+	cache    *CRCCache
+
+	crcs    []uint32
+	known   []bool
+	central []int // 中央目录中各条目 CRC-32 字段的位置
+	patched bool  // 中央目录已填入 CRC-32
+
+	pos       int64
+	file      ReadAtCloser
+	reader    *readAhead
+	fileEntry int
+	hash      hash.Hash32
+	hashEntry int
+	hashNext  int64
+}
+
+// zipRecorder 记录 zip.Writer 写出的文件内容以外的部分，文件内容只计数
+type zipRecorder struct {
+	offset  int64
+	data    []byte
+	content bool
+}
+
+func (r *zipRecorder) Write(p []byte) (int, error) {
+	if !r.content {
+		r.data = append(r.data, p...)
+	}
+	r.offset += int64(len(p))
+	return len(p), nil
+}
+
+// take 返回上次调用以来记录的内容及其在归档中的位置
+func (r *zipRecorder) take() ([]byte, int64) {
+	data := r.data
+	r.data = nil
+	return data, r.offset - int64(len(data))
+}
+
+// zeros 文件内容的占位，不填充缓冲区
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// NewZipArchive 按条目顺序生成归档结构，open 打开登录节点上的文件，cache 可以为 nil
+func NewZipArchive(entries []ArchiveEntry, open func(name string) (ReadAtCloser, error), cache *CRCCache) (*ZipArchive, error) {
+	z := &ZipArchive{
+		entries:   entries,
+		open:      open,
+		cache:     cache,
+		crcs:      make([]uint32, len(entries)),
+		known:     make([]bool, len(entries)),
+		fileEntry: -1,
+		hashEntry: -1,
+	}
+	for i := range entries {
+		if entries[i].isDir() || entries[i].Size == 0 {
+			z.known[i] = true
+		} else {
+			z.crcs[i], z.known[i] = cache.get(&entries[i])
+		}
+	}
+
+	rec := &zipRecorder{}
+	w := zip.NewWriter(rec)
+	buf := make([]byte, readAheadSize)
+	last := -1 // 上一个写出数据描述符的条目
+	// flush 将已记录的内容拆分为上一个文件的数据描述符和 kind 类型的段
+	flush := func(kind zipSegmentKind) {
+		data, offset := rec.take()
+		if last >= 0 {
+			n := 16 // 签名、CRC-32 和两个 32 位大小
+			if entries[last].Size > 1<<32-1 {
+				n = 24
+			}
+			z.add(zipSegment{kind: zipDescriptor, offset: offset, data: data[:n], entry: last})
+			data, offset = data[n:], offset+int64(n)
+			last = -1
+		}
+		z.add(zipSegment{kind: kind, offset: offset, data: data})
+	}
+	for i := range entries {
+		e := &entries[i]
+		fw, err := w.CreateRaw(zipFileHeader(e))
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("add %s to zip: %w", e.Name, err)
+		}
+		flush(zipHeader)
+		if e.isDir() {
+			continue
+		}
+		rec.content = true
+		if _, err := io.CopyBuffer(fw, io.LimitReader(zeros{}, e.Size), buf); err != nil {
+			return nil, err
+		}
+		if err := w.Flush(); err != nil {
+			return nil, err
+		}
+		rec.content = false
+		z.add(zipSegment{kind: zipContent, offset: rec.offset - e.Size, size: e.Size, entry: i})
+		last = i
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	flush(zipCentral)
+	z.size = rec.offset
+
+	// 中央目录每条记录 46 字节，CRC-32 位于第 16 字节，之后是名称、扩展字段和注释
+	central := z.segments[len(z.segments)-1].data
+	for pos := 0; len(z.central) < len(entries); {
+		z.central = append(z.central, pos+16)
+		pos += 46 + int(binary.LittleEndian.Uint16(central[pos+28:])) +
+			int(binary.LittleEndian.Uint16(central[pos+30:])) + int(binary.LittleEndian.Uint16(central[pos+32:]))
+	}
+	return z, nil
+}
+
+func (z *ZipArchive) add(s zipSegment) {
+	if s.data != nil {
+		s.size = int64(len(s.data))
+	}
+	if s.size > 0 {
+		z.segments = append(z.segments, s)
+	}
+}
+
+// zipFileHeader 生成条目的文件头。CreateRaw 不处理修改时间和编码，这里按 CreateHeader 的方式设置
+func zipFileHeader(e *ArchiveEntry) *zip.FileHeader {
+	fh := &zip.FileHeader{
+		Name:           e.Name,
+		Method:         zip.Store,
+		CreatorVersion: 20,
+		ReaderVersion:  20,
+		Modified:       e.ModTime,
+	}
+	fh.SetMode(e.Mode)
+	if utf8.ValidString(e.Name) && strings.IndexFunc(e.Name, func(r rune) bool { return r >= utf8.RuneSelf }) >= 0 {
+		fh.Flags |= 0x800
+	}
+	mtime := e.ModTime.UTC()
+	fh.ModifiedDate = uint16(mtime.Day() + int(mtime.Month())<<5 + max(mtime.Year()-1980, 0)<<9)
+	fh.ModifiedTime = uint16(mtime.Second()/2 + mtime.Minute()<<5 + mtime.Hour()<<11)
+	// Info-ZIP 的扩展时间戳，精确到秒
+	fh.Extra = binary.LittleEndian.AppendUint16(fh.Extra, 0x5455)
+	fh.Extra = binary.LittleEndian.AppendUint16(fh.Extra, 5)
+	fh.Extra = append(fh.Extra, 1)
+	fh.Extra = binary.LittleEndian.AppendUint32(fh.Extra, uint32(mtime.Unix()))
+	if !e.isDir() {
+		fh.Flags |= 0x8
+		fh.CompressedSize64 = uint64(e.Size)
+		fh.UncompressedSize64 = uint64(e.Size)
+		if e.Size > 1<<32-1 {
+			fh.ReaderVersion = 45
+		}
+	}
+	return fh
+}
+
+// Size 返回归档的长度
+func (z *ZipArchive) Size() int64 {
+	return z.size
+}
+
+// ETag 由条目的名称、大小、权限和修改时间计算，目录内容不变时保持不变
+func (z *ZipArchive) ETag() string {
+	h := sha256.New()
+	for _, e := range z.entries {
+		fmt.Fprintf(h, "%s\x00%d\x00%o\x00%d\n", e.Name, e.Size, e.Mode, e.ModTime.UnixNano())
+	}
+	return `"zip-` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// ModTime 返回条目中最晚的修改时间
+func (z *ZipArchive) ModTime() time.Time {
+	var latest time.Time
+	for _, e := range z.entries {
+		if e.ModTime.After(latest) {
+			latest = e.ModTime
+		}
+	}
+	return latest
+}
+
+func (z *ZipArchive) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += z.pos
+	case io.SeekEnd:
+		offset += z.size
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("zip archive: negative position %d", offset)
+	}
+	z.pos = offset
+	return offset, nil
+}
+
+func (z *ZipArchive) Read(p []byte) (int, error) {
+	if z.pos >= z.size {
+		return 0, io.EOF
+	}
+	// 找到包含当前位置的段
+	lo, hi := 0, len(z.segments)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if z.segments[mid].offset <= z.pos {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	s := &z.segments[lo]
+	off := z.pos - s.offset
+	p = p[:min(int64(len(p)), s.size-off)]
+
+	var n int
+	var err error
+	switch s.kind {
+	case zipContent:
+		n, err = z.readContent(s.entry, p, off)
+	case zipDescriptor:
+		if err = z.ensureCRC(s.entry); err == nil {
+			binary.LittleEndian.PutUint32(s.data[4:], z.crcs[s.entry])
+			n = copy(p, s.data[off:])
+		}
+	case zipCentral:
+		if err = z.patchCentral(s.data); err == nil {
+			n = copy(p, s.data[off:])
+		}
+	default:
+		n = copy(p, s.data[off:])
+	}
+	z.pos += int64(n)
+	return n, err
+}
+
+// Close 关闭正在读取的文件
+func (z *ZipArchive) Close() error {
+	if z.file == nil {
+		return nil
+	}
+	err := z.file.Close()
+	z.file, z.reader, z.fileEntry = nil, nil, -1
+	return err
+}
+
+// readerOf 返回条目的文件，同一时间只打开一个文件
+func (z *ZipArchive) readerOf(i int) (*readAhead, error) {
+	if z.fileEntry == i {
+		return z.reader, nil
+	}
+	_ = z.Close()
+	f, err := z.open(z.entries[i].Path)
+	if err != nil {
+		return nil, err
+	}
+	z.file, z.reader, z.fileEntry = f, newReadAhead(f, z.entries[i].Size), i
+	return z.reader, nil
+}
+
+// readContent 读取文件内容，从头顺序读取时同时计算 CRC-32
+func (z *ZipArchive) readContent(i int, p []byte, off int64) (int, error) {
+	r, err := z.readerOf(i)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.ReadAt(p, off)
+	if err == io.EOF && n == len(p) {
+		err = nil
+	}
+	if !z.known[i] {
+		if off == 0 && z.hashEntry != i {
+			z.hash, z.hashEntry, z.hashNext = crc32.NewIEEE(), i, 0
+		}
+		if z.hashEntry == i && off == z.hashNext {
+			z.hash.Write(p[:n])
+			z.hashNext += int64(n)
+			if z.hashNext == z.entries[i].Size {
+				z.setCRC(i, z.hash.Sum32())
+			}
+		}
+	}
+	return n, err
+}
+
+func (z *ZipArchive) setCRC(i int, crc uint32) {
+	z.crcs[i], z.known[i] = crc, true
+	z.cache.put(&z.entries[i], crc)
+	if z.hashEntry == i {
+		z.hash, z.hashEntry = nil, -1
+	}
+}
+
+// ensureCRC 计算没有从头顺序读取的文件的 CRC-32，已计算部分时从中断处继续
+func (z *ZipArchive) ensureCRC(i int) error {
+	if z.known[i] {
+		return nil
+	}
+	if z.hashEntry != i {
+		z.hash, z.hashEntry, z.hashNext = crc32.NewIEEE(), i, 0
+	}
+	r, err := z.readerOf(i)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(z.hash, io.NewSectionReader(r, z.hashNext, z.entries[i].Size-z.hashNext)); err != nil {
+		return fmt.Errorf("checksum %s: %w", z.entries[i].Path, err)
+	}
+	z.setCRC(i, z.hash.Sum32())
+	return nil
+}
+
+// patchCentral 在中央目录中填入所有文件的 CRC-32
+func (z *ZipArchive) patchCentral(data []byte) error {
+	if z.patched {
+		return nil
+	}
+	for i, pos := range z.central {
+		if err := z.ensureCRC(i); err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint32(data[pos:], z.crcs[i])
+	}
+	z.patched = true
+	return nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"star-dim/internal/utils"
+)
+
+// 目录下载支持的归档格式
+const (
+	ArchiveZip    = "zip"
+	ArchiveTar    = "tar"
+	ArchiveTarGz  = "tar.gz"
+	ArchiveTarZst = "tar.zst"
+)
+
+// ArchiveFormat 规范化归档格式，为空时使用 zip
+func ArchiveFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", ArchiveZip:
+		return ArchiveZip, nil
+	case ArchiveTar:
+		return ArchiveTar, nil
+	case ArchiveTarGz, "tgz":
+		return ArchiveTarGz, nil
+	case ArchiveTarZst, "tzst":
+		return ArchiveTarZst, nil
+	}
+	return "", fmt.Errorf("%w: archive format %q", ErrUnsupported, format)
+}
+
+// ArchiveContentType 返回归档格式的 Content-Type
+func ArchiveContentType(format string) string {
+	switch format {
+	case ArchiveTar:
+		return "application/x-tar"
+	case ArchiveTarGz:
+		return "application/gzip"
+	case ArchiveTarZst:
+		return "application/zstd"
+	}
+	return "application/zip"
+}
+
+// ArchiveFilter 按 glob 模式（path.Match 语法）筛选打包的条目。模式含 / 时匹配相对于打包目录的路径，
+// 否则匹配文件或目录名，与 tar --exclude 相同。排除的目录不再遍历；Include 不为空时只打包匹配的文件，
+// 目录结构保留
+type ArchiveFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// Validate 检查模式的语法
+func (f *ArchiveFilter) Validate() error {
+	for _, pattern := range append(append([]string(nil), f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded 判断相对路径是否被排除
+func (f *ArchiveFilter) excluded(rel string) bool {
+	return f != nil && matchAny(f.Exclude, rel)
+}
+
+// included 判断相对路径的文件是否打包
+func (f *ArchiveFilter) included(rel string) bool {
+	return f == nil || len(f.Include) == 0 || matchAny(f.Include, rel)
+}
+
+// ArchiveTooLargeError 打包内容超过大小限制
+type ArchiveTooLargeError struct {
+	Limit int64
+}
+
+func (e *ArchiveTooLargeError) Error() string {
+	return fmt.Sprintf("directory contents exceed the archive size limit of %d bytes", e.Limit)
+}
+
+// ArchiveWalker 遍历登录节点上的目录，*fs.Walker（sftp.Client.Walk 返回）实现了该接口
+type ArchiveWalker interface {
+	Step() bool
+	Err() error
+	Path() string
+	Stat() os.FileInfo
+	SkipDir()
+}
+
+// CollectArchiveEntries 遍历 root 目录，生成以 name 为顶层目录的条目并按名称排序，使同一目录每次生成相同的归档。
+// 只打包普通文件和目录，不跟随符号链接，避免打包允许访问的目录以外的文件。
+// limit 大于 0 时文件总大小超过 limit 立即停止遍历并返回 *ArchiveTooLargeError。
+// 无法读取的文件或目录（如没有权限）返回错误，不会生成缺少文件的归档
+func CollectArchiveEntries(walker ArchiveWalker, root, name string, filter *ArchiveFilter, limit int64) ([]ArchiveEntry, int64, error) {
+	var entries []ArchiveEntry
+	var total int64
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, total, fmt.Errorf("walk %s: %w", walker.Path(), err)
+		}
+		fi := walker.Stat()
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), root), "/")
+		if rel != "" && filter.excluded(rel) {
+			if fi.IsDir() {
+				walker.SkipDir()
+			}
+			continue
+		}
+		entry := ArchiveEntry{Name: path.Join(name, rel), Path: walker.Path(), Mode: fi.Mode(), ModTime: fi.ModTime()}
+		switch {
+		case fi.IsDir():
+			entry.Name += "/"
+		case fi.Mode().IsRegular():
+			if !filter.included(rel) {
+				continue
+			}
+			entry.Size = fi.Size()
+			if total += entry.Size; limit > 0 && total > limit {
+				return nil, total, &ArchiveTooLargeError{Limit: limit}
+			}
+		default:
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, total, nil
+}
+
+// WriteTar 将条目写为 tar 流，format 为 tar 或 tar.gz，文件内容通过 open 从登录节点读取
+func WriteTar(w io.Writer, format string, entries []ArchiveEntry, open func(name string) (ReadAtCloser, error)) error {
+	var gz *gzip.Writer
+	switch format {
+	case ArchiveTar:
+	case ArchiveTarGz:
+		gz = gzip.NewWriter(w)
+		w = gz
+	default:
+		return fmt.Errorf("%w: %s is only created on the login node", ErrUnsupported, format)
+	}
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.Name, Mode: int64(e.Mode.Perm()), ModTime: e.ModTime, Typeflag: tar.TypeDir}
+		if !e.isDir() {
+			hdr.Typeflag, hdr.Size = tar.TypeReg, e.Size
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if e.isDir() || e.Size == 0 {
+			continue
+		}
+		if err := copyFile(tw, e, open); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+func copyFile(w io.Writer, e ArchiveEntry, open func(name string) (ReadAtCloser, error)) error {
+	f, err := open(e.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// 只写入遍历时的大小，文件在打包过程中变短时返回错误
+	if _, err := io.Copy(w, io.NewSectionReader(newReadAhead(f, e.Size), 0, e.Size)); err != nil {
+		return fmt.Errorf("read %s: %w", e.Path, err)
+	}
+	return nil
+}
+
+// RemoteTarCommand 返回在登录节点上打包的命令。tar 在 dir 下执行，条目名称以 NUL 分隔从标准输入读取，
+// 归档写到标准输出；需要 GNU tar，tar.zst 还需要 zstd
+func RemoteTarCommand(dir, format string) (string, error) {
+	compress, err := tarCompressOption(format)
+	if err != nil {
+		return "", err
+	}
+	cmd := utils.NewCommand("tar", "-C", dir, "--null", "--no-recursion", "-T", "-", "-cf", "-")
+	if compress != "" {
+		cmd.Arg(compress)
+	}
+	return cmd.String(), nil
+}
+
+// tarCompressOption 返回 GNU tar 压缩或解压归档格式的选项
+func tarCompressOption(format string) (string, error) {
+	switch format {
+	case ArchiveTar:
+		return "", nil
+	case ArchiveTarGz:
+		return "-z", nil
+	case ArchiveTarBz2:
+		return "-j", nil
+	case ArchiveTarZst:
+		return "--use-compress-program=zstd", nil
+	}
+	return "", fmt.Errorf("%w: archive format %s on the login node", ErrUnsupported, format)
+}
+
+// RemoteTarInput 返回 RemoteTarCommand 的标准输入，即以 NUL 分隔的条目名称。
+// 名称加上 ./ 前缀，避免旧版 tar 将 - 开头的名称当作选项
+func RemoteTarInput(entries []ArchiveEntry) io.Reader {
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString("./" + e.Name)
+		b.WriteByte(0)
+	}
+	return strings.NewReader(b.String())
+}
+
+// IsTarWarning 判断远程 tar 的错误是否只是警告。GNU tar 在文件打包过程中被修改时以状态 1 退出，归档仍然完整
+func IsTarWarning(err error) bool {
+	var exit *ExitError
+	return errors.As(err, &exit) && exit.ExitCode == 1
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWalker 按深度优先顺序遍历预设的路径，目录以 / 结尾
+type fakeWalker struct {
+	paths []string
+	files *memFiles
+	cur   int
+	skip  string
+	errs  map[string]error
+}
+
+func newFakeWalker(files *memFiles, paths ...string) *fakeWalker {
+	return &fakeWalker{paths: paths, files: files, cur: -1}
+}
+
+func (w *fakeWalker) Step() bool {
+	for w.cur++; w.cur < len(w.paths); w.cur++ {
+		if w.skip == "" || !strings.HasPrefix(w.paths[w.cur], w.skip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *fakeWalker) Err() error   { return w.errs[w.paths[w.cur]] }
+func (w *fakeWalker) Path() string { return strings.TrimSuffix(w.paths[w.cur], "/") }
+func (w *fakeWalker) SkipDir()     { w.skip = w.paths[w.cur] }
+
+func (w *fakeWalker) Stat() os.FileInfo {
+	name := w.paths[w.cur]
+	mode, size := os.FileMode(0644), int64(len(w.files.files[name]))
+	switch {
+	case strings.HasSuffix(name, "/"):
+		mode, size = os.ModeDir|0755, 4096
+	case strings.HasSuffix(name, ".lnk"):
+		mode = os.ModeSymlink | 0777
+	}
+	return fakeInfo{name: name, mode: mode, size: size}
+}
+
+type fakeInfo struct {
+	name string
+	mode os.FileMode
+	size int64
+}
+
+func (i fakeInfo) Name() string       { return i.name }
+func (i fakeInfo) Size() int64        { return i.size }
+func (i fakeInfo) Mode() os.FileMode  { return i.mode }
+func (i fakeInfo) ModTime() time.Time { return time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC) }
+func (i fakeInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i fakeInfo) Sys() interface{}   { return nil }
+
+func testWalker() *fakeWalker {
+	files := &memFiles{files: map[string][]byte{
+		"/data/run/train.py":      []byte("print('train')\n"),
+		"/data/run/out/model.bin": bytes.Repeat([]byte{7}, 4000),
+		"/data/run/out/log.txt":   []byte("loss 0.1\n"),
+		"/data/run/.git/HEAD":     []byte("ref: refs/heads/main\n"),
+	}}
+	return newFakeWalker(files, "/data/run/", "/data/run/train.py", "/data/run/out/", "/data/run/out/model.bin",
+		"/data/run/out/log.txt", "/data/run/.git/", "/data/run/.git/HEAD", "/data/run/secret.lnk")
+}
+
+func entryNames(entries []ArchiveEntry) []string {
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestCollectArchiveEntries(t *testing.T) {
+	entries, total, err := CollectArchiveEntries(testWalker(), "/data/run", "run", nil, 0)
+	require.NoError(t, err)
+	// 不包含符号链接，按名称排序
+	assert.Equal(t, []string{"run/", "run/.git/", "run/.git/HEAD", "run/out/", "run/out/log.txt", "run/out/model.bin", "run/train.py"}, entryNames(entries))
+	assert.Equal(t, int64(4045), total)
+
+	filter := &ArchiveFilter{Exclude: []string{".git", "out/*.bin"}}
+	require.NoError(t, filter.Validate())
+	entries, total, err = CollectArchiveEntries(testWalker(), "/data/run", "run", filter, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"run/", "run/out/", "run/out/log.txt", "run/train.py"}, entryNames(entries))
+	assert.Equal(t, int64(24), total)
+
+	filter = &ArchiveFilter{Include: []string{"*.py", "*.txt"}, Exclude: []string{".git"}}
+	entries, _, err = CollectArchiveEntries(testWalker(), "/data/run", "run", filter, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"run/", "run/out/", "run/out/log.txt", "run/train.py"}, entryNames(entries))
+
+	assert.Error(t, (&ArchiveFilter{Include: []string{"[a-"}}).Validate())
+
+	// 超过大小限制时停止遍历
+	walker := testWalker()
+	_, _, err = CollectArchiveEntries(walker, "/data/run", "run", nil, 1000)
+	var tooLarge *ArchiveTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, int64(1000), tooLarge.Limit)
+	assert.Equal(t, 3, walker.cur)
+
+	// 无法读取的目录不会被跳过
+	walker = testWalker()
+	walker.errs = map[string]error{"/data/run/out/": os.ErrPermission}
+	_, _, err = CollectArchiveEntries(walker, "/data/run", "run", nil, 0)
+	assert.ErrorIs(t, err, os.ErrPermission)
+	assert.Contains(t, err.Error(), "/data/run/out")
+}
+
+func TestWriteTar(t *testing.T) {
+	walker := testWalker()
+	entries, _, err := CollectArchiveEntries(walker, "/data/run", "run", &ArchiveFilter{Exclude: []string{".git"}}, 0)
+	require.NoError(t, err)
+
+	for _, format := range []string{ArchiveTar, ArchiveTarGz} {
+		var buf bytes.Buffer
+		require.NoError(t, WriteTar(&buf, format, entries, walker.files.open))
+		var r io.Reader = &buf
+		if format == ArchiveTarGz {
+			gz, err := gzip.NewReader(&buf)
+			require.NoError(t, err)
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		var names []string
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			names = append(names, hdr.Name)
+			if hdr.Typeflag == tar.TypeReg {
+				content, err := io.ReadAll(tr)
+				require.NoError(t, err)
+				assert.Equal(t, walker.files.files["/data/"+hdr.Name], content, hdr.Name)
+			}
+		}
+		assert.Equal(t, []string{"run/", "run/out/", "run/out/log.txt", "run/out/model.bin", "run/train.py"}, names, format)
+	}
+
+	assert.ErrorIs(t, WriteTar(io.Discard, ArchiveTarZst, entries, walker.files.open), ErrUnsupported)
+
+	// 文件在打包过程中变短
+	walker.files.files["/data/run/out/model.bin"] = []byte{7}
+	assert.ErrorIs(t, WriteTar(io.Discard, ArchiveTar, entries, walker.files.open), io.ErrUnexpectedEOF)
+}
+
+func TestRemoteTarCommand(t *testing.T) {
+	cmd, err := RemoteTarCommand("/data/my run", ArchiveTarZst)
+	require.NoError(t, err)
+	assert.Equal(t, "tar -C '/data/my run' --null --no-recursion -T - -cf - --use-compress-program=zstd", cmd)
+	_, err = RemoteTarCommand("/data", ArchiveZip)
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	input, err := io.ReadAll(RemoteTarInput([]ArchiveEntry{{Name: "run/"}, {Name: "run/-rf"}}))
+	require.NoError(t, err)
+	assert.Equal(t, "./run/\x00./run/-rf\x00", string(input))
+
+	assert.True(t, IsTarWarning(&ExitError{Command: "tar", ExitCode: 1}))
+	assert.False(t, IsTarWarning(&ExitError{Command: "tar", ExitCode: 2}))
+
+	format, err := ArchiveFormat("TGZ")
+	require.NoError(t, err)
+	assert.Equal(t, ArchiveTarGz, format)
+	_, err = ArchiveFormat("rar")
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
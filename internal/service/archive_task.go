@@ -0,0 +1,226 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"star-dim/internal/utils"
+)
+
+// ArchiveTarBz2 只在登录节点上解压和压缩的格式
+const ArchiveTarBz2 = "tar.bz2"
+
+// 解压和压缩任务的阶段
+const (
+	TaskListing     = "listing"
+	TaskExtracting  = "extracting"
+	TaskCompressing = "compressing"
+)
+
+// TaskArchiveFormat 规范化解压和压缩的归档格式，format 为空时按文件扩展名判断
+func TaskArchiveFormat(format, name string) (string, error) {
+	switch strings.ToLower(format) {
+	case ArchiveZip:
+		return ArchiveZip, nil
+	case ArchiveTar:
+		return ArchiveTar, nil
+	case ArchiveTarGz, "tgz":
+		return ArchiveTarGz, nil
+	case ArchiveTarBz2, "tbz2", "tbz":
+		return ArchiveTarBz2, nil
+	case ArchiveTarZst, "tzst":
+		return ArchiveTarZst, nil
+	case "":
+		name = strings.ToLower(name)
+		for _, ext := range []string{ArchiveTarGz, ArchiveTarBz2, ArchiveTarZst, ArchiveZip, ArchiveTar, "tgz", "tbz2", "tbz", "tzst"} {
+			if strings.HasSuffix(name, "."+ext) {
+				return TaskArchiveFormat(ext, "")
+			}
+		}
+		return "", fmt.Errorf("%w: cannot tell the archive format of %s", ErrUnsupported, path.Base(name))
+	}
+	return "", fmt.Errorf("%w: archive format %q", ErrUnsupported, format)
+}
+
+// ArchiveMember 压缩包中的条目。Type 与 ls -l 的类型字符相同，Link 为符号链接或硬链接的目标
+type ArchiveMember struct {
+	Name string
+	Type byte
+	Link string
+}
+
+// ArchiveListCommand 返回列出压缩包条目的命令，输出由 ParseArchiveListing 解析
+func ArchiveListCommand(archive, format string) (string, error) {
+	if format == ArchiveZip {
+		return utils.NewCommand("unzip", "-Z", "-s", archive).String(), nil
+	}
+	compress, err := tarCompressOption(format)
+	if err != nil {
+		return "", err
+	}
+	return utils.NewCommand("tar", "-tvf", archive, "--numeric-owner", "--quoting-style=escape").
+		Flag(compress, compress != "").String(), nil
+}
+
+var (
+	// tarListLine GNU tar -tv 的输出：权限 属主/属组 大小 日期 时间 名称
+	tarListLine = regexp.MustCompile(`^(\S)\S*\s+\S+\s+\S+\s+\d{4}-\d\d-\d\d\s+\d\d:\d\d(?::\d\d)?\s(.*)$`)
+	// zipListLine zipinfo -s 的输出：权限 版本 系统 大小 类型 压缩方法 日期 时间 名称
+	zipListLine = regexp.MustCompile(`^(\S)\S*\s+\S+\s+\S+\s+\d+\s+\S+\s+\S+\s+\S+\s+\d\d:\d\d\s(.*)$`)
+)
+
+// ParseArchiveListing 解析 ArchiveListCommand 的输出
+func ParseArchiveListing(format, output string) ([]ArchiveMember, error) {
+	re := tarListLine
+	if format == ArchiveZip {
+		re = zipListLine
+	}
+	var members []ArchiveMember
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			// zipinfo 的首尾两行是压缩包的汇总信息
+			if format == ArchiveZip || line == "" {
+				continue
+			}
+			return nil, fmt.Errorf("unexpected tar listing: %q", line)
+		}
+		member := ArchiveMember{Name: m[2], Type: m[1][0]}
+		switch {
+		case format == ArchiveZip:
+		case member.Type == 'l':
+			member.Name, member.Link, _ = strings.Cut(member.Name, " -> ")
+		case member.Type == 'h':
+			member.Name, member.Link, _ = strings.Cut(member.Name, " link to ")
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// unsafeName 判断条目名称是否为绝对路径或包含 .. 而可能指向解压目录以外
+func unsafeName(name string) bool {
+	if strings.HasPrefix(name, "/") {
+		return true
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckArchiveMembers 检查压缩包的条目都位于解压目录内：名称不能是绝对路径或包含 ..，
+// tar 的符号链接和硬链接目标同样如此，zip 中的符号链接无法预先得知目标，一律拒绝；
+// 只允许普通文件、目录和链接，违反时返回 ErrPermissionDenied
+func CheckArchiveMembers(members []ArchiveMember) error {
+	for _, m := range members {
+		if unsafeName(m.Name) {
+			return denied("archive member %q is outside the destination", m.Name)
+		}
+		switch m.Type {
+		case '-', 'd':
+		case 'l', 'h':
+			if m.Link == "" {
+				return denied("archive member %q is a symbolic link", m.Name)
+			}
+			// 名称本身含 " -> " 时无法区分名称和目标，逐段检查
+			for _, part := range strings.Split(m.Name+" -> "+m.Link, " -> ") {
+				if unsafeName(part) {
+					return denied("archive member %q links outside the destination", m.Name)
+				}
+			}
+		default:
+			return denied("archive member %q is not a regular file or directory", m.Name)
+		}
+	}
+	return nil
+}
+
+// ArchiveMemberDirs 返回解压时会写入的目录（相对于解压目录），按名称排序。
+// 解压目录中已存在的同名目录可能是符号链接，解压前需要逐个检查其指向
+func ArchiveMemberDirs(members []ArchiveMember) []string {
+	seen := make(map[string]bool)
+	for _, m := range members {
+		dir := path.Clean(m.Name)
+		if m.Type != 'd' {
+			dir = path.Dir(dir)
+		}
+		for ; dir != "." && dir != "/" && !seen[dir]; dir = path.Dir(dir) {
+			seen[dir] = true
+		}
+	}
+	dirs := make([]string, 0, len(seen))
+	for dir := range seen {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// ExtractCommand 返回将压缩包解压到 dest 的命令，每解压一个条目输出一行。
+// overwrite 为 false 时 tar 遇到已存在的文件报错，unzip 跳过已存在的文件
+func ExtractCommand(archive, dest, format string, overwrite bool) (string, error) {
+	if format == ArchiveZip {
+		mode := "-n"
+		if overwrite {
+			mode = "-o"
+		}
+		return utils.NewCommand("unzip", mode, archive, "-d", dest).String(), nil
+	}
+	compress, err := tarCompressOption(format)
+	if err != nil {
+		return "", err
+	}
+	return utils.NewCommand("tar", "-xvf", archive, "-C", dest, "--no-same-owner").
+		Flag("--keep-old-files", !overwrite).Flag(compress, compress != "").String(), nil
+}
+
+// CompressCommand 返回在 dir 下将条目打包为 output 的命令及其标准输入，每打包一个条目输出一行。
+// 条目名称从标准输入读取，不经过 shell 参数；zip 的名称以换行分隔，不支持含换行的名称
+func CompressCommand(dir, output, format string, entries []ArchiveEntry) (string, io.Reader, error) {
+	if format != ArchiveZip {
+		compress, err := tarCompressOption(format)
+		if err != nil {
+			return "", nil, err
+		}
+		cmd := utils.NewCommand("tar", "-C", dir, "--null", "--no-recursion", "-T", "-", "-cvf", output).
+			Flag(compress, compress != "")
+		return cmd.String(), RemoteTarInput(entries), nil
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		if strings.Contains(e.Name, "\n") {
+			return "", nil, fmt.Errorf("%w: file name with a newline in zip archives: %q", ErrUnsupported, e.Name)
+		}
+		b.WriteString(e.Name + "\n")
+	}
+	cmd := utils.NewCommand("cd", dir).String() + " && " + utils.NewCommand("zip", "-@", output).String()
+	return cmd, strings.NewReader(b.String()), nil
+}
+
+// ArchiveProgressLine 识别 ExtractCommand 和 CompressCommand 输出中已处理的条目。
+// tar -v 每行一个名称，unzip 和 zip 的行以 inflating:、adding: 等开头
+func ArchiveProgressLine(format, line string) (string, bool) {
+	if format != ArchiveZip {
+		return line, line != ""
+	}
+	action, name, ok := strings.Cut(strings.TrimSpace(line), ": ")
+	switch action {
+	case "inflating", "extracting", "creating", "linking":
+	case "adding":
+		// zip 在名称后输出压缩率，如 adding: run/a.txt (deflated 40%)
+		if i := strings.LastIndex(name, " ("); i > 0 {
+			name = name[:i]
+		}
+	default:
+		return "", false
+	}
+	return strings.TrimSpace(name), ok
+}
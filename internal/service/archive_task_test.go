@@ -0,0 +1,202 @@
+package service
+
+import (
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// GNU tar 1.34 和 zipinfo 3.0 的输出
+const (
+	tarListing = `drwxr-xr-x 1000/1000         0 2024-03-01 10:00 run/
+-rw-r--r-- 1000/1000         3 2024-03-01 10:00 run/a.txt
+-rw-r--r-- 1000/1000         2 2024-03-01 10:00 run/sub/b c.txt
+lrwxrwxrwx 1000/1000         0 2024-03-01 10:00 run/sub/l -> a.txt
+hrw-r--r-- 1000/1000         0 2024-03-01 10:00 run/hard link to run/a.txt
+`
+	zipListing = `Archive:  runs.zip
+Zip file size: 911 bytes, number of entries: 3
+drwxr-xr-x  3.0 unx        0 bx stor 24-Mar-01 10:00 run/
+-rw-r--r--  3.0 unx        3 tx stor 24-Mar-01 10:00 run/a.txt
+-rw-r--r--  3.0 unx        2 tx stor 24-Mar-01 10:00 run/sub/b c.txt
+3 files, 5 bytes uncompressed, 5 bytes compressed:  0.0%
+`
+)
+
+func TestTaskArchiveFormat(t *testing.T) {
+	for name, want := range map[string]string{
+		"data/a.zip":     ArchiveZip,
+		"data/a.TAR":     ArchiveTar,
+		"data/a.tgz":     ArchiveTarGz,
+		"data/a.tar.gz":  ArchiveTarGz,
+		"data/a.tar.bz2": ArchiveTarBz2,
+		"data/a.tzst":    ArchiveTarZst,
+	} {
+		format, err := TaskArchiveFormat("", name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, format, name)
+	}
+	// 指定的格式优先于扩展名
+	format, err := TaskArchiveFormat("tbz2", "data/a.zip")
+	require.NoError(t, err)
+	assert.Equal(t, ArchiveTarBz2, format)
+
+	_, err = TaskArchiveFormat("", "data/a.rar")
+	assert.ErrorIs(t, err, ErrUnsupported)
+	_, err = TaskArchiveFormat("7z", "data/a.zip")
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestParseArchiveListing(t *testing.T) {
+	members, err := ParseArchiveListing(ArchiveTarGz, tarListing)
+	require.NoError(t, err)
+	assert.Equal(t, []ArchiveMember{
+		{Name: "run/", Type: 'd'},
+		{Name: "run/a.txt", Type: '-'},
+		{Name: "run/sub/b c.txt", Type: '-'},
+		{Name: "run/sub/l", Type: 'l', Link: "a.txt"},
+		{Name: "run/hard", Type: 'h', Link: "run/a.txt"},
+	}, members)
+	require.NoError(t, CheckArchiveMembers(members))
+	assert.Equal(t, []string{"run", "run/sub"}, ArchiveMemberDirs(members))
+
+	members, err = ParseArchiveListing(ArchiveZip, zipListing)
+	require.NoError(t, err)
+	assert.Equal(t, []ArchiveMember{
+		{Name: "run/", Type: 'd'},
+		{Name: "run/a.txt", Type: '-'},
+		{Name: "run/sub/b c.txt", Type: '-'},
+	}, members)
+
+	_, err = ParseArchiveListing(ArchiveTar, "tar: This does not look like a tar archive\n")
+	assert.Error(t, err)
+}
+
+func TestCheckArchiveMembers(t *testing.T) {
+	for _, m := range []ArchiveMember{
+		{Name: "/etc/passwd", Type: '-'},
+		{Name: "run/../../.bashrc", Type: '-'},
+		{Name: "..", Type: 'd'},
+		{Name: "run/l", Type: 'l', Link: "/etc"},
+		{Name: "run/l", Type: 'l', Link: "../a.txt"},
+		{Name: "run/x -> y", Type: 'l', Link: "/etc"}, // 名称含 " -> " 时目标被拆到名称中
+		{Name: "run/hard", Type: 'h', Link: "../../.ssh/authorized_keys"},
+		{Name: "run/l", Type: 'l'}, // zip 中的符号链接
+		{Name: "run/dev", Type: 'c'},
+	} {
+		assert.ErrorIs(t, CheckArchiveMembers([]ArchiveMember{m}), ErrPermissionDenied, m.Name)
+	}
+	assert.NoError(t, CheckArchiveMembers([]ArchiveMember{{Name: "./run/..data/a", Type: '-'}}))
+}
+
+func TestExtractCommand(t *testing.T) {
+	cmd, err := ExtractCommand("/data/my runs.tar.zst", "/data/out", ArchiveTarZst, false)
+	require.NoError(t, err)
+	assert.Equal(t, "tar -xvf '/data/my runs.tar.zst' -C /data/out --no-same-owner --keep-old-files --use-compress-program=zstd", cmd)
+	cmd, err = ExtractCommand("/data/a.zip", "/data/out", ArchiveZip, true)
+	require.NoError(t, err)
+	assert.Equal(t, "unzip -o /data/a.zip -d /data/out", cmd)
+
+	cmd, err = ArchiveListCommand("/data/a.tar.bz2", ArchiveTarBz2)
+	require.NoError(t, err)
+	assert.Equal(t, "tar -tvf /data/a.tar.bz2 --numeric-owner --quoting-style=escape -j", cmd)
+	cmd, err = ArchiveListCommand("/data/a.zip", ArchiveZip)
+	require.NoError(t, err)
+	assert.Equal(t, "unzip -Z -s /data/a.zip", cmd)
+}
+
+func TestCompressCommand(t *testing.T) {
+	entries := []ArchiveEntry{{Name: "run/"}, {Name: "run/a b.txt"}}
+	cmd, input, err := CompressCommand("/data", "/data/.part", ArchiveTarGz, entries)
+	require.NoError(t, err)
+	assert.Equal(t, "tar -C /data --null --no-recursion -T - -cvf /data/.part -z", cmd)
+	stdin, err := io.ReadAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, "./run/\x00./run/a b.txt\x00", string(stdin))
+
+	cmd, input, err = CompressCommand("/data/my runs", "/data/.part", ArchiveZip, entries)
+	require.NoError(t, err)
+	assert.Equal(t, "cd '/data/my runs' && zip -@ /data/.part", cmd)
+	stdin, err = io.ReadAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, "run/\nrun/a b.txt\n", string(stdin))
+
+	_, _, err = CompressCommand("/data", "/data/.part", ArchiveZip, []ArchiveEntry{{Name: "run/a\nb"}})
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestArchiveProgressLine(t *testing.T) {
+	for _, tc := range []struct {
+		format, line, name string
+		ok                 bool
+	}{
+		{ArchiveTar, "run/sub/b c.txt", "run/sub/b c.txt", true},
+		{ArchiveTar, "", "", false},
+		{ArchiveZip, "Archive:  runs.zip", "", false},
+		{ArchiveZip, " extracting: out/run/a.txt           ", "out/run/a.txt", true},
+		{ArchiveZip, "  inflating: out/run/model.bin", "out/run/model.bin", true},
+		{ArchiveZip, "   creating: out/run/sub/", "out/run/sub/", true},
+		{ArchiveZip, "  adding: run/sub/b c.txt (deflated 40%)", "run/sub/b c.txt", true},
+	} {
+		name, ok := ArchiveProgressLine(tc.format, tc.line)
+		assert.Equal(t, tc.ok, ok, tc.line)
+		assert.Equal(t, tc.name, name, tc.line)
+	}
+}
+
+// shellCalls 交给 /bin/sh 执行，names 中的命令替换为输出命令名和参数的函数，
+// 返回依次执行的各命令名和参数，用于检查复合命令行的拆分结果
+func shellCalls(t testing.TB, cmdline string, names ...string) []string {
+	t.Helper()
+	var script strings.Builder
+	for _, name := range names {
+		script.WriteString(name + `() { printf '%s\0' ` + name + ` "$@"; }; `)
+	}
+	out, err := exec.Command("/bin/sh", "-c", script.String()+cmdline).Output()
+	require.NoError(t, err, cmdline)
+	args := strings.Split(string(out), "\x00")
+	return args[:len(args)-1]
+}
+
+// FuzzArchiveCommands 压缩包路径和目录都不能改变 shell 拆分得到的参数列表，
+// zip 的 cd && zip 复合命令也只能执行这两条命令
+func FuzzArchiveCommands(f *testing.F) {
+	for _, seed := range []string{"; rm -rf ~", "$(id)", "`id`", "a'b", "\n", "-rf", "*", "x && id", "|id", "'; id; '", `\`, ""} {
+		f.Add(seed, seed)
+	}
+	names := []string{"tar", "unzip", "zip", "cd"}
+	f.Fuzz(func(t *testing.T, archive, dir string) {
+		if strings.IndexByte(archive, 0) >= 0 || strings.IndexByte(dir, 0) >= 0 {
+			t.Skip("NUL cannot be passed in argv")
+		}
+		cmd, err := ArchiveListCommand(archive, ArchiveTarGz)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tar", "-tvf", archive, "--numeric-owner", "--quoting-style=escape", "-z"}, shellCalls(t, cmd, names...))
+		cmd, err = ArchiveListCommand(archive, ArchiveZip)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"unzip", "-Z", "-s", archive}, shellCalls(t, cmd, names...))
+
+		cmd, err = ExtractCommand(archive, dir, ArchiveTarZst, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tar", "-xvf", archive, "-C", dir, "--no-same-owner", "--keep-old-files", "--use-compress-program=zstd"},
+			shellCalls(t, cmd, names...))
+		cmd, err = ExtractCommand(archive, dir, ArchiveZip, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"unzip", "-o", archive, "-d", dir}, shellCalls(t, cmd, names...))
+
+		cmd, _, err = CompressCommand(dir, archive, ArchiveTarBz2, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tar", "-C", dir, "--null", "--no-recursion", "-T", "-", "-cvf", archive, "-j"}, shellCalls(t, cmd, names...))
+		cmd, _, err = CompressCommand(dir, archive, ArchiveZip, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"cd", dir, "zip", "-@", archive}, shellCalls(t, cmd, names...))
+
+		cmd, err = RemoteTarCommand(dir, ArchiveTar)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tar", "-C", dir, "--null", "--no-recursion", "-T", "-", "-cf", "-"}, shellCalls(t, cmd, names...))
+	})
+}
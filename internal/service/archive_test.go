@@ -0,0 +1,171 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memFiles 模拟登录节点上的文件，记录读取的字节数
+type memFiles struct {
+	files map[string][]byte
+	read  int64
+}
+
+type memReader struct {
+	*bytes.Reader
+	fs *memFiles
+}
+
+func (r memReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.Reader.ReadAt(p, off)
+	r.fs.read += int64(n)
+	return n, err
+}
+
+func (memReader) Close() error { return nil }
+
+func (m *memFiles) open(name string) (ReadAtCloser, error) {
+	data, ok := m.files[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return memReader{bytes.NewReader(data), m}, nil
+}
+
+func testArchive() (*memFiles, []ArchiveEntry) {
+	mtime := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	fs := &memFiles{files: map[string][]byte{
+		"/data/run/a.txt":      []byte("hello"),
+		"/data/run/ckpt/model": bytes.Repeat([]byte("checkpoint"), 300000),
+		"/data/run/ckpt/empty": {},
+		"/data/run/日志/out.log": []byte(strings.Repeat("step ok\n", 1000)),
+	}}
+	entries := []ArchiveEntry{{Name: "run/", Path: "/data/run", Mode: os.ModeDir | 0755, ModTime: mtime}}
+	for _, name := range []string{"a.txt", "ckpt/", "ckpt/empty", "ckpt/model", "日志/", "日志/out.log"} {
+		e := ArchiveEntry{Name: "run/" + name, Path: "/data/run/" + strings.TrimSuffix(name, "/"), Mode: 0644, ModTime: mtime}
+		if strings.HasSuffix(name, "/") {
+			e.Mode = os.ModeDir | 0755
+		}
+		e.Size = int64(len(fs.files[e.Path]))
+		entries = append(entries, e)
+	}
+	return fs, entries
+}
+
+func TestZipArchive(t *testing.T) {
+	fs, entries := testArchive()
+	cache := NewCRCCache(100)
+	z, err := NewZipArchive(entries, fs.open, cache)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(z)
+	require.NoError(t, err)
+	require.NoError(t, z.Close())
+	assert.Equal(t, z.Size(), int64(len(data)))
+
+	// 标准库读取时校验 CRC-32
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, r.File, len(entries))
+	for i, f := range r.File {
+		assert.Equal(t, entries[i].Name, f.Name)
+		assert.Equal(t, entries[i].Mode, f.Mode(), f.Name)
+		assert.True(t, entries[i].ModTime.Equal(f.Modified), f.Name)
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err, f.Name)
+		assert.Equal(t, fs.files[entries[i].Path], content)
+	}
+	assert.Len(t, cache.entries, 3) // 空文件和目录不缓存
+
+	// 相同条目生成相同的 ETag，修改时间变化后不同
+	again, err := NewZipArchive(entries, fs.open, nil)
+	require.NoError(t, err)
+	assert.Equal(t, z.ETag(), again.ETag())
+	modified := slices.Clone(entries)
+	modified[1].ModTime = modified[1].ModTime.Add(time.Second)
+	changed, err := NewZipArchive(modified, fs.open, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, z.ETag(), changed.ETag())
+}
+
+func TestZipArchiveResume(t *testing.T) {
+	fs, entries := testArchive()
+	z, err := NewZipArchive(entries, fs.open, nil)
+	require.NoError(t, err)
+	full, err := io.ReadAll(z)
+	require.NoError(t, err)
+
+	// 从文件内容中间续传，缓存中没有 CRC-32 时重新读取已跳过的文件
+	for _, cache := range []*CRCCache{nil, NewCRCCache(100)} {
+		if cache != nil {
+			warm, err := NewZipArchive(entries, fs.open, cache)
+			require.NoError(t, err)
+			_, err = io.Copy(io.Discard, warm)
+			require.NoError(t, err)
+		}
+		fs.read = 0
+		resumed, err := NewZipArchive(entries, fs.open, cache)
+		require.NoError(t, err)
+		offset := int64(len(full)) / 2
+		_, err = resumed.Seek(offset, io.SeekStart)
+		require.NoError(t, err)
+		rest, err := io.ReadAll(resumed)
+		require.NoError(t, err)
+		assert.Equal(t, full[offset:], rest)
+		if cache != nil {
+			assert.Less(t, fs.read, int64(len(full))-offset+readAheadSize)
+		}
+	}
+
+	// 区间读取
+	section := io.NewSectionReader(readerAt{z}, 100, 5000)
+	part, err := io.ReadAll(section)
+	require.NoError(t, err)
+	assert.Equal(t, full[100:5100], part)
+}
+
+// readerAt 通过 Seek 和 Read 实现 ReaderAt
+type readerAt struct {
+	z *ZipArchive
+}
+
+func (r readerAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := r.z.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(r.z, p)
+}
+
+func TestRangeReader(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), readAheadSize/5)
+	fs := &memFiles{files: map[string][]byte{"f": data}}
+	f, _ := fs.open("f")
+	r := NewRangeReader(f, int64(len(data)))
+
+	buf := make([]byte, 100)
+	_, err := io.ReadFull(r, buf)
+	require.NoError(t, err)
+	assert.Equal(t, data[:100], buf)
+	// 顺序的小块读取合并为一次读取
+	assert.Equal(t, int64(readAheadSize), fs.read)
+
+	_, err = r.Seek(int64(len(data))-50, io.SeekStart)
+	require.NoError(t, err)
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, data[len(data)-50:], rest)
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"os"
+	"star-dim/internal/models"
+	"time"
+)
+
+// AuthMethods 根据登录信息构造 SSH 认证方式。
+// 不支持 ssh-agent 认证：服务端进程的 agent 不属于发起登录的门户用户，REST 客户端也无法转发自己的 agent
+func AuthMethods(info *models.LoginInfo) ([]ssh.AuthMethod, error) {
+	user := info.User
+	method := info.AuthMethod
+	if method == "" {
+		switch {
+		case user.Certificate != "":
+			method = models.AuthCertificate
+		case user.PrivateKey != "":
+			method = models.AuthPublicKey
+		default:
+			method = models.AuthPassword
+		}
+	}
+
+	switch method {
+	case models.AuthPassword:
+		if user.Password == "" {
+			return nil, errors.New("password is required")
+		}
+		return passwordMethods(user.Password), nil
+	case models.AuthPublicKey, models.AuthCertificate:
+		signer, err := parseSigner(user.PrivateKey, user.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		if method == models.AuthCertificate {
+			if signer, err = certSigner(signer, user.Certificate); err != nil {
+				return nil, err
+			}
+		}
+		methods := []ssh.AuthMethod{ssh.PublicKeys(signer)}
+		// 部分集群要求公钥和密码双重认证
+		if user.Password != "" {
+			methods = append(methods, passwordMethods(user.Password)...)
+		}
+		return methods, nil
+	case models.AuthKeyboardInteractive:
+		if len(info.Answers) == 0 {
+			return nil, errors.New("answers are required")
+		}
+		return []ssh.AuthMethod{ssh.KeyboardInteractive(answerInOrder(info.Answers))}, nil
+	default:
+		return nil, fmt.Errorf("unknown auth method %s", method)
+	}
+}
+
+// passwordMethods 密码认证，同时以 keyboard-interactive 方式回答密码提示
+func passwordMethods(password string) []ssh.AuthMethod {
+	return []ssh.AuthMethod{
+		ssh.Password(password),
+		ssh.KeyboardInteractive(answerWith(password)),
+	}
+}
+
+// answerWith 使用同一个答案回答全部提示
+func answerWith(answer string) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i := range answers {
+			answers[i] = answer
+		}
+		return answers, nil
+	}
+}
+
+// answerInOrder 按顺序回答服务器的提示，可跨越多轮质询
+func answerInOrder(answers []string) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		if len(questions) > len(answers) {
+			return nil, fmt.Errorf("not enough answers for prompts %q", questions)
+		}
+		replies := answers[:len(questions)]
+		answers = answers[len(questions):]
+		return replies, nil
+	}
+}
+
+// parseSigner 解析 PEM 或 OpenSSH 格式的私钥
+func parseSigner(privateKey, passphrase string) (ssh.Signer, error) {
+	if privateKey == "" {
+		return nil, errors.New("private key is required")
+	}
+	var signer ssh.Signer
+	var err error
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(privateKey), []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(privateKey))
+	}
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, errors.New("private key is protected by a passphrase")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+	return signer, nil
+}
+
+// certSigner 使用 SSH CA 签发的用户证书包装私钥
+func certSigner(signer ssh.Signer, certificate string) (ssh.Signer, error) {
+	if certificate == "" {
+		return nil, errors.New("certificate is required")
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certificate))
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %v", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok || cert.CertType != ssh.UserCert {
+		return nil, errors.New("certificate is not an ssh user certificate")
+	}
+	now := uint64(time.Now().Unix())
+	if now < cert.ValidAfter || (cert.ValidBefore != ssh.CertTimeInfinity && now >= cert.ValidBefore) {
+		return nil, errors.New("certificate is expired or not yet valid")
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate does not match private key: %v", err)
+	}
+	return certSigner, nil
+}
+
+// JumpAuthMethods 根据跳板机配置的凭据构造认证方式，未配置凭据时返回空
+func JumpAuthMethods(jump *models.JumpHost) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	privateKey := jump.PrivateKey
+	if jump.PrivateKeyFile != "" {
+		data, err := os.ReadFile(jump.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("jump host %s: read private key: %v", jump.Host, err)
+		}
+		privateKey = string(data)
+	}
+	if privateKey != "" {
+		signer, err := parseSigner(privateKey, jump.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("jump host %s: %v", jump.Host, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if jump.Password != "" {
+		methods = append(methods, passwordMethods(jump.Password)...)
+	}
+	return methods, nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"star-dim/internal/models"
+)
+
+// newPrivateKey 生成 OpenSSH 格式的私钥，passphrase 不为空时加密
+func newPrivateKey(t *testing.T, passphrase string) (string, ssh.Signer) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	var block *pem.Block
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	}
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(block)), signer
+}
+
+// newCertificate 使用新生成的 CA 为 signer 签发证书
+func newCertificate(t *testing.T, signer ssh.Signer, certType uint32, validBefore uint64) string {
+	t.Helper()
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ca, err := ssh.NewSignerFromKey(caKey)
+	require.NoError(t, err)
+	cert := &ssh.Certificate{
+		Key:             signer.PublicKey(),
+		CertType:        certType,
+		ValidPrincipals: []string{"alice"},
+		ValidAfter:      uint64(time.Now().Add(-time.Hour).Unix()),
+		ValidBefore:     validBefore,
+	}
+	require.NoError(t, cert.SignCert(rand.Reader, ca))
+	return string(ssh.MarshalAuthorizedKey(cert))
+}
+
+func TestAuthMethods(t *testing.T) {
+	key, signer := newPrivateKey(t, "")
+	encrypted, _ := newPrivateKey(t, "secret")
+	valid := uint64(time.Now().Add(time.Hour).Unix())
+	cert := newCertificate(t, signer, ssh.UserCert, valid)
+	login := func(method string, user models.User, answers ...string) ([]ssh.AuthMethod, error) {
+		return AuthMethods(&models.LoginInfo{User: &user, AuthMethod: method, Answers: answers})
+	}
+
+	// 未指定认证方式时根据凭据自动选择，公钥认证附带密码时同时进行密码认证
+	methods, err := login("", models.User{Password: "pw"})
+	require.NoError(t, err)
+	assert.Len(t, methods, 2)
+	methods, err = login("", models.User{PrivateKey: key})
+	require.NoError(t, err)
+	assert.Len(t, methods, 1)
+	methods, err = login("", models.User{PrivateKey: key, Password: "pw"})
+	require.NoError(t, err)
+	assert.Len(t, methods, 3)
+	methods, err = login("", models.User{PrivateKey: key, Certificate: cert})
+	require.NoError(t, err)
+	assert.Len(t, methods, 1)
+	_, err = login("", models.User{PrivateKey: encrypted, Passphrase: "secret"})
+	require.NoError(t, err)
+	methods, err = login(models.AuthKeyboardInteractive, models.User{}, "pw", "123456")
+	require.NoError(t, err)
+	assert.Len(t, methods, 1)
+
+	for name, tc := range map[string]struct {
+		method string
+		user   models.User
+		err    string
+	}{
+		"missing password":   {models.AuthPassword, models.User{}, "password is required"},
+		"missing key":        {models.AuthPublicKey, models.User{}, "private key is required"},
+		"invalid key":        {models.AuthPublicKey, models.User{PrivateKey: "not a key"}, "invalid private key"},
+		"missing passphrase": {models.AuthPublicKey, models.User{PrivateKey: encrypted}, "protected by a passphrase"},
+		"wrong passphrase":   {models.AuthPublicKey, models.User{PrivateKey: encrypted, Passphrase: "wrong"}, "invalid private key"},
+		"missing cert":       {models.AuthCertificate, models.User{PrivateKey: key}, "certificate is required"},
+		"invalid cert":       {models.AuthCertificate, models.User{PrivateKey: key, Certificate: "garbage"}, "invalid certificate"},
+		"plain public key":   {models.AuthCertificate, models.User{PrivateKey: key, Certificate: string(ssh.MarshalAuthorizedKey(signer.PublicKey()))}, "not an ssh user certificate"},
+		"host cert":          {models.AuthCertificate, models.User{PrivateKey: key, Certificate: newCertificate(t, signer, ssh.HostCert, valid)}, "not an ssh user certificate"},
+		"expired cert":       {models.AuthCertificate, models.User{PrivateKey: key, Certificate: newCertificate(t, signer, ssh.UserCert, uint64(time.Now().Add(-time.Minute).Unix()))}, "expired"},
+		"cert key mismatch":  {models.AuthCertificate, models.User{PrivateKey: encrypted, Passphrase: "secret", Certificate: cert}, "does not match"},
+		"missing answers":    {models.AuthKeyboardInteractive, models.User{}, "answers are required"},
+		"unknown method":     {"agent", models.User{Password: "pw"}, "unknown auth method agent"},
+	} {
+		_, err := login(tc.method, tc.user)
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), tc.err, name)
+		}
+	}
+}
+
+func TestAnswerInOrder(t *testing.T) {
+	challenge := answerInOrder([]string{"pw", "123456"})
+
+	// 多轮质询依次消耗答案
+	replies, err := challenge("", "", []string{"Password: "}, []bool{false})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pw"}, replies)
+	replies, err = challenge("", "", []string{"OTP: "}, []bool{true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"123456"}, replies)
+	_, err = challenge("", "", []string{"OTP: "}, []bool{true})
+	assert.Error(t, err)
+
+	replies, err = answerWith("pw")("", "", []string{"Password: ", "Password again: "}, []bool{false, false})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pw", "pw"}, replies)
+}
+
+func TestJumpAuthMethods(t *testing.T) {
+	key, _ := newPrivateKey(t, "")
+	methods, err := JumpAuthMethods(&models.JumpHost{Host: "jump"})
+	require.NoError(t, err)
+	assert.Empty(t, methods)
+
+	methods, err = JumpAuthMethods(&models.JumpHost{Host: "jump", PrivateKey: key, Password: "pw"})
+	require.NoError(t, err)
+	assert.Len(t, methods, 3)
+
+	// 私钥文件优先于内联私钥
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(path, []byte(key), 0600))
+	methods, err = JumpAuthMethods(&models.JumpHost{Host: "jump", PrivateKey: "not a key", PrivateKeyFile: path})
+	require.NoError(t, err)
+	assert.Len(t, methods, 1)
+
+	_, err = JumpAuthMethods(&models.JumpHost{Host: "jump", PrivateKeyFile: filepath.Join(t.TempDir(), "missing")})
+	assert.ErrorContains(t, err, "jump host jump")
+	_, err = JumpAuthMethods(&models.JumpHost{Host: "jump", PrivateKey: "not a key"})
+	assert.ErrorContains(t, err, "invalid private key")
+}
@@ -1,30 +1,439 @@
 package service
 
-import "star-dim/internal/models"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"star-dim/internal/models"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
 
-type ClusterService struct{}
+// ClusterService 集群注册表，从 YAML/JSON 配置文件加载集群和登录节点信息
+type ClusterService struct {
+	path     string
+	mu       sync.RWMutex
+	clusters []*models.Cluster
+	modTime  time.Time
+	size     int64
+}
+
+func NewClusterService(path string) *ClusterService {
+	return &ClusterService{
+		path: path,
+	}
+}
+
+// Path 返回集群配置文件路径
+func (s *ClusterService) Path() string {
+	return s.path
+}
+
+// Load 读取并校验配置文件，校验失败时保留原有配置
+func (s *ClusterService) Load() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("stat cluster config %s: %v", s.path, err)
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("read cluster config %s: %v", s.path, err)
+	}
 
-func NewClusterServer() *ClusterService {
-	return &ClusterService{}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 记录文件状态，避免错误的配置文件被反复重新加载
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	conf, err := parseClusterConfig(s.path, data)
+	if err != nil {
+		return err
+	}
+	s.clusters = conf.Clusters
+	log.Printf("loaded %d clusters from %s", len(conf.Clusters), s.path)
+	return nil
+}
+
+func parseClusterConfig(path string, data []byte) (*models.ClusterConfig, error) {
+	var conf models.ClusterConfig
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &conf)
+	default:
+		err = yaml.Unmarshal(data, &conf)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse cluster config %s: %v", path, err)
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid cluster config %s: %v", path, err)
+	}
+	return &conf, nil
 }
 
 func (s *ClusterService) GetClusters() []*models.Cluster {
-	// 从数据库读取所有cluster信息
-	// test
-	clusters := []*models.Cluster{}
-	clusters = append(clusters, &models.Cluster{
-		Name: "hpc1",
-		LoginNodes: []*models.LoginNode{
-			{
-				Name: "ln1",
-				Host: "1.94.239.51",
-				Port: "22",
-			},
-		},
-	})
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	clusters := make([]*models.Cluster, len(s.clusters))
+	copy(clusters, s.clusters)
 	return clusters
 }
 
 func (s *ClusterService) GetCluster(name string) *models.Cluster {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, cluster := range s.clusters {
+		if cluster.Name == name {
+			return cluster
+		}
+	}
+	return nil
+}
+
+var (
+	ErrClusterNotFound   = errors.New("cluster not found")
+	ErrLoginNodeNotFound = errors.New("login node not found")
+)
+
+// update 在集群配置副本上执行修改，校验通过后写回配置文件并替换当前配置
+func (s *ClusterService) update(fn func(conf *models.ClusterConfig) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conf := &models.ClusterConfig{}
+	for _, cluster := range s.clusters {
+		conf.Clusters = append(conf.Clusters, cluster.Clone())
+	}
+	if err := fn(conf); err != nil {
+		return err
+	}
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	if err := s.save(conf); err != nil {
+		return err
+	}
+	s.clusters = conf.Clusters
+	return nil
+}
+
+// save 以原文件格式写回配置文件，YAML 文件保留原有的注释，调用方需持有写锁
+func (s *ClusterService) save(conf *models.ClusterConfig) error {
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(s.path)) {
+	case ".json":
+		data, err = json.MarshalIndent(conf, "", "  ")
+	default:
+		original, _ := os.ReadFile(s.path)
+		data, err = marshalClusterYAML(conf, original)
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeFileSync(s.path+".tmp", data, configFileMode(s.path)); err != nil {
+		return fmt.Errorf("write cluster config: %v", err)
+	}
+	if err := os.Rename(s.path+".tmp", s.path); err != nil {
+		return fmt.Errorf("write cluster config: %v", err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+		s.size = info.Size()
+	}
 	return nil
 }
+
+// configFileMode 集群配置包含跳板机的密码和私钥，新建时权限为 0600；
+// 已有文件保留属主和属组的权限，去掉其他用户的权限
+func configFileMode(path string) os.FileMode {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0600
+	}
+	return info.Mode().Perm() & 0770
+}
+
+// writeFileSync 以指定权限写入文件并落盘，用于随后重命名替换原文件
+func writeFileSync(path string, data []byte, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	// 临时文件可能由之前的写入遗留，权限以本次为准
+	if err = f.Chmod(mode); err == nil {
+		if _, err = f.Write(data); err == nil {
+			err = f.Sync()
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// marshalClusterYAML 序列化集群配置，并将 original 中的注释复制到对应的字段和列表元素上
+func marshalClusterYAML(conf *models.ClusterConfig, original []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(conf); err != nil {
+		return nil, err
+	}
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&node}}
+	var old yaml.Node
+	if err := yaml.Unmarshal(original, &old); err == nil && old.Kind == yaml.DocumentNode {
+		copyComments(doc, &old)
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// copyComments 复制注释，映射按键匹配，列表元素按 name 字段匹配，没有 name 字段时按位置匹配
+func copyComments(dst, src *yaml.Node) {
+	if dst.Kind != src.Kind {
+		return
+	}
+	dst.HeadComment, dst.LineComment, dst.FootComment = src.HeadComment, src.LineComment, src.FootComment
+	switch dst.Kind {
+	case yaml.DocumentNode:
+		if len(dst.Content) > 0 && len(src.Content) > 0 {
+			copyComments(dst.Content[0], src.Content[0])
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			for j := 0; j+1 < len(src.Content); j += 2 {
+				if dst.Content[i].Value == src.Content[j].Value {
+					copyComments(dst.Content[i], src.Content[j])
+					copyComments(dst.Content[i+1], src.Content[j+1])
+					break
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range dst.Content {
+			if match := matchItem(src.Content, item, i); match != nil {
+				copyComments(item, match)
+			}
+		}
+	}
+}
+
+func matchItem(items []*yaml.Node, item *yaml.Node, index int) *yaml.Node {
+	name := nodeName(item)
+	if name == "" {
+		if index < len(items) {
+			return items[index]
+		}
+		return nil
+	}
+	for _, candidate := range items {
+		if nodeName(candidate) == name {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// nodeName 返回映射节点的 name 字段
+func nodeName(node *yaml.Node) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "name" {
+			return node.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+func findCluster(conf *models.ClusterConfig, name string) (int, *models.Cluster) {
+	for i, cluster := range conf.Clusters {
+		if cluster.Name == name {
+			return i, cluster
+		}
+	}
+	return -1, nil
+}
+
+// AddCluster 新增集群
+func (s *ClusterService) AddCluster(cluster *models.Cluster) error {
+	return s.update(func(conf *models.ClusterConfig) error {
+		if _, c := findCluster(conf, cluster.Name); c != nil {
+			return fmt.Errorf("cluster %s already exists", cluster.Name)
+		}
+		conf.Clusters = append(conf.Clusters, cluster.Clone())
+		return nil
+	})
+}
+
+// UpdateCluster 替换集群配置，集群名称不可修改
+func (s *ClusterService) UpdateCluster(name string, cluster *models.Cluster) error {
+	return s.update(func(conf *models.ClusterConfig) error {
+		i, c := findCluster(conf, name)
+		if c == nil {
+			return ErrClusterNotFound
+		}
+		clone := cluster.Clone()
+		clone.Name = name
+		conf.Clusters[i] = clone
+		return nil
+	})
+}
+
+// RemoveCluster 删除集群
+func (s *ClusterService) RemoveCluster(name string) error {
+	return s.update(func(conf *models.ClusterConfig) error {
+		i, c := findCluster(conf, name)
+		if c == nil {
+			return ErrClusterNotFound
+		}
+		conf.Clusters = append(conf.Clusters[:i], conf.Clusters[i+1:]...)
+		return nil
+	})
+}
+
+// AddLoginNode 为集群新增登录节点
+func (s *ClusterService) AddLoginNode(clusterName string, node *models.LoginNode) error {
+	return s.update(func(conf *models.ClusterConfig) error {
+		_, c := findCluster(conf, clusterName)
+		if c == nil {
+			return ErrClusterNotFound
+		}
+		if c.GetLoginNode(node.Name) != nil {
+			return fmt.Errorf("login node %s already exists", node.Name)
+		}
+		n := *node
+		n.Labels = append([]string(nil), node.Labels...)
+		c.LoginNodes = append(c.LoginNodes, &n)
+		return nil
+	})
+}
+
+// UpdateLoginNode 修改登录节点
+func (s *ClusterService) UpdateLoginNode(clusterName, nodeName string, fn func(node *models.LoginNode)) error {
+	return s.update(func(conf *models.ClusterConfig) error {
+		_, c := findCluster(conf, clusterName)
+		if c == nil {
+			return ErrClusterNotFound
+		}
+		node := c.GetLoginNode(nodeName)
+		if node == nil {
+			return fmt.Errorf("%w: %s", ErrLoginNodeNotFound, nodeName)
+		}
+		fn(node)
+		node.Name = nodeName
+		return nil
+	})
+}
+
+// RemoveLoginNode 删除登录节点，默认节点被删除时同时清除默认设置
+func (s *ClusterService) RemoveLoginNode(clusterName, nodeName string) error {
+	return s.update(func(conf *models.ClusterConfig) error {
+		_, c := findCluster(conf, clusterName)
+		if c == nil {
+			return ErrClusterNotFound
+		}
+		for i, node := range c.LoginNodes {
+			if node.Name == nodeName {
+				c.LoginNodes = append(c.LoginNodes[:i], c.LoginNodes[i+1:]...)
+				if c.DefaultNode == nodeName {
+					c.DefaultNode = ""
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrLoginNodeNotFound, nodeName)
+	})
+}
+
+// SetDefaultNode 设置集群默认登录节点，nodeName 为空时清除
+func (s *ClusterService) SetDefaultNode(clusterName, nodeName string) error {
+	return s.update(func(conf *models.ClusterConfig) error {
+		_, c := findCluster(conf, clusterName)
+		if c == nil {
+			return ErrClusterNotFound
+		}
+		if nodeName != "" && c.GetLoginNode(nodeName) == nil {
+			return fmt.Errorf("%w: %s", ErrLoginNodeNotFound, nodeName)
+		}
+		c.DefaultNode = nodeName
+		return nil
+	})
+}
+
+// LoginNodeCandidates 返回登录可用的节点：指定名称时只返回该节点，否则按标签筛选且默认节点在前
+func (s *ClusterService) LoginNodeCandidates(cluster *models.Cluster, name string, labels []string) ([]*models.LoginNode, error) {
+	if name != "" {
+		node := cluster.GetLoginNode(name)
+		if node == nil {
+			return nil, fmt.Errorf("%w: %s", ErrLoginNodeNotFound, name)
+		}
+		if node.Disabled {
+			return nil, fmt.Errorf("login node %s is disabled", name)
+		}
+		return []*models.LoginNode{node}, nil
+	}
+	nodes := cluster.CandidateNodes(labels)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no available login node in cluster %s", cluster.Name)
+	}
+	return nodes, nil
+}
+
+// changed 判断配置文件自上次加载后是否被修改
+func (s *ClusterService) changed() bool {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !info.ModTime().Equal(s.modTime) || info.Size() != s.size
+}
+
+// Watch 在收到 SIGHUP 或配置文件变化时重新加载集群配置。
+// 已建立的会话持有各自的集群信息，重新加载不会影响它们。
+func (s *ClusterService) Watch(interval time.Duration, stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer signal.Stop(hup)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-hup:
+				log.Println("SIGHUP received, reloading cluster config")
+				if err := s.Load(); err != nil {
+					log.Println("reload cluster config failed:", err)
+				}
+			case <-ticker.C:
+				if !s.changed() {
+					continue
+				}
+				log.Println("cluster config changed, reloading")
+				if err := s.Load(); err != nil {
+					log.Println("reload cluster config failed:", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+)
+
+const clusterYAML = `clusters:
+  - name: hpc1
+    default_node: ln2
+    login_nodes:
+      - name: ln1
+        host: 10.0.0.1
+      - name: ln2
+        host: 10.0.0.2
+        port: "2222"
+        labels: [gpu]
+`
+
+// writeConfig 写入配置文件并修改时间，保证 changed 能检测到变化
+func writeConfig(t *testing.T, path, data string, mtime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func TestClusterServiceLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clusters.yaml")
+	writeConfig(t, path, clusterYAML, time.Now().Add(-time.Hour))
+	s := NewClusterService(path)
+	require.NoError(t, s.Load())
+
+	cluster := s.GetCluster("hpc1")
+	require.NotNil(t, cluster)
+	require.Len(t, cluster.LoginNodes, 2)
+	// 端口为空时默认使用 22
+	assert.Equal(t, "22", cluster.LoginNodes[0].Port)
+	assert.Equal(t, "2222", cluster.LoginNodes[1].Port)
+	assert.Nil(t, s.GetCluster("hpc2"))
+	assert.False(t, s.changed())
+
+	// 校验失败时保留原有配置，且不会反复重新加载同一个错误文件
+	writeConfig(t, path, "clusters:\n  - name: hpc2\n", time.Now())
+	assert.True(t, s.changed())
+	assert.Error(t, s.Load())
+	assert.NotNil(t, s.GetCluster("hpc1"))
+	assert.Nil(t, s.GetCluster("hpc2"))
+	assert.False(t, s.changed())
+
+	// 修正后重新加载
+	writeConfig(t, path, "clusters:\n  - name: hpc2\n    login_nodes:\n      - name: ln1\n        host: 10.0.1.1\n", time.Now().Add(time.Minute))
+	assert.True(t, s.changed())
+	require.NoError(t, s.Load())
+	assert.Nil(t, s.GetCluster("hpc1"))
+	assert.NotNil(t, s.GetCluster("hpc2"))
+}
+
+func TestClusterServiceLoadJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clusters.json")
+	writeConfig(t, path, `{"clusters":[{"name":"hpc1","login_nodes":[{"name":"ln1","host":"10.0.0.1","port":"22"}]}]}`, time.Now())
+	s := NewClusterService(path)
+	require.NoError(t, s.Load())
+	assert.NotNil(t, s.GetCluster("hpc1"))
+
+	require.Error(t, NewClusterService(filepath.Join(t.TempDir(), "missing.yaml")).Load())
+}
+
+func TestClusterConfigValidate(t *testing.T) {
+	node := func(name string) *models.LoginNode {
+		return &models.LoginNode{Name: name, Host: "10.0.0.1"}
+	}
+	for name, conf := range map[string]*models.ClusterConfig{
+		"missing name":     {Clusters: []*models.Cluster{{LoginNodes: []*models.LoginNode{node("ln1")}}}},
+		"no login nodes":   {Clusters: []*models.Cluster{{Name: "hpc1"}}},
+		"duplicate node":   {Clusters: []*models.Cluster{{Name: "hpc1", LoginNodes: []*models.LoginNode{node("ln1"), node("ln1")}}}},
+		"duplicate":        {Clusters: []*models.Cluster{{Name: "hpc1", LoginNodes: []*models.LoginNode{node("ln1")}}, {Name: "hpc1", LoginNodes: []*models.LoginNode{node("ln1")}}}},
+		"missing host":     {Clusters: []*models.Cluster{{Name: "hpc1", LoginNodes: []*models.LoginNode{{Name: "ln1"}}}}},
+		"invalid port":     {Clusters: []*models.Cluster{{Name: "hpc1", LoginNodes: []*models.LoginNode{{Name: "ln1", Host: "h", Port: "70000"}}}}},
+		"unknown default":  {Clusters: []*models.Cluster{{Name: "hpc1", DefaultNode: "ln9", LoginNodes: []*models.LoginNode{node("ln1")}}}},
+		"unknown strategy": {Clusters: []*models.Cluster{{Name: "hpc1", Strategy: "random", LoginNodes: []*models.LoginNode{node("ln1")}}}},
+		"pinned no key":    {Clusters: []*models.Cluster{{Name: "hpc1", HostKeyPolicy: models.HostKeyPolicyPinned, LoginNodes: []*models.LoginNode{node("ln1")}}}},
+	} {
+		assert.Error(t, conf.Validate(), name)
+	}
+	valid := &models.ClusterConfig{Clusters: []*models.Cluster{{Name: "hpc1", Strategy: models.StrategyRoundRobin, LoginNodes: []*models.LoginNode{node("ln1")}}}}
+	assert.NoError(t, valid.Validate())
+}
+
+func TestClusterServiceSaveKeepsComments(t *testing.T) {
+	original, err := os.ReadFile("../../configs/clusters.yaml")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "clusters.yaml")
+	writeConfig(t, path, string(original), time.Now())
+	s := NewClusterService(path)
+	require.NoError(t, s.Load())
+
+	require.NoError(t, s.AddLoginNode("hpc1", &models.LoginNode{Name: "ln2", Host: "10.0.0.2", Port: "22"}))
+	require.NoError(t, s.SetDefaultNode("hpc1", "ln2"))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, comment := range []string{"# star-dim 集群配置", "# jump_hosts:", "# access:", "#     - /lustre/projects"} {
+		assert.Contains(t, string(data), comment)
+	}
+
+	// 配置包含跳板机凭据，写回时去掉其他用户的权限
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Mode().Perm()&0007)
+
+	// 写回的文件可以重新加载
+	reloaded := NewClusterService(path)
+	require.NoError(t, reloaded.Load())
+	cluster := reloaded.GetCluster("hpc1")
+	require.NotNil(t, cluster)
+	assert.Equal(t, "ln2", cluster.DefaultNode)
+	assert.Len(t, cluster.LoginNodes, 2)
+}
+
+func TestClusterServiceNotFound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clusters.yaml")
+	writeConfig(t, path, clusterYAML, time.Now())
+	s := NewClusterService(path)
+	require.NoError(t, s.Load())
+
+	assert.ErrorIs(t, s.SetDefaultNode("hpc9", "ln1"), ErrClusterNotFound)
+	assert.ErrorIs(t, s.SetDefaultNode("hpc1", "ln9"), ErrLoginNodeNotFound)
+	assert.ErrorIs(t, s.UpdateLoginNode("hpc1", "ln9", func(*models.LoginNode) {}), ErrLoginNodeNotFound)
+	assert.ErrorIs(t, s.RemoveLoginNode("hpc1", "ln9"), ErrLoginNodeNotFound)
+	_, err := s.LoginNodeCandidates(s.GetCluster("hpc1"), "ln9", nil)
+	assert.ErrorIs(t, err, ErrLoginNodeNotFound)
+}
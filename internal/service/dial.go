@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"star-dim/internal/models"
+)
+
+// ErrJumpHost 连接跳板机失败
+var ErrJumpHost = errors.New("jump host")
+
+// JumpConfigFunc 返回连接指定跳板机使用的 SSH 配置
+type JumpConfigFunc func(jump *models.JumpHost) (*ssh.ClientConfig, error)
+
+// dialVia 直接连接目标地址，via 不为空时经已建立的 SSH 连接转发
+func dialVia(via *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	if via == nil {
+		return ssh.Dial("tcp", addr, config)
+	}
+	conn, err := via.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// DialLoginNode 连接登录节点，配置了跳板机时依次经跳板机建立隧道（ProxyJump）。
+// 返回的连接关闭后自动关闭全部跳板机连接，SFTP 等客户端直接基于返回的连接创建即可。
+func DialLoginNode(node *models.LoginNode, config *ssh.ClientConfig, jumpConfig JumpConfigFunc) (*ssh.Client, error) {
+	var chain []*ssh.Client
+	closeChain := func() {
+		for i := len(chain) - 1; i >= 0; i-- {
+			_ = chain[i].Close()
+		}
+	}
+	var via *ssh.Client
+	for _, jump := range node.JumpHosts {
+		jc, err := jumpConfig(jump)
+		if err != nil {
+			closeChain()
+			return nil, fmt.Errorf("%w %s: %w", ErrJumpHost, jump.Address(), err)
+		}
+		client, err := dialVia(via, jump.Address(), jc)
+		if err != nil {
+			closeChain()
+			return nil, fmt.Errorf("%w %s: %w", ErrJumpHost, jump.Address(), err)
+		}
+		chain = append(chain, client)
+		via = client
+	}
+	client, err := dialVia(via, net.JoinHostPort(node.Host, node.Port), config)
+	if err != nil {
+		closeChain()
+		return nil, err
+	}
+	if len(chain) > 0 {
+		go func() {
+			_ = client.Wait()
+			closeChain()
+		}()
+	}
+	return client, nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"regexp"
+	"sort"
+	"star-dim/internal/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RemoteExecutor 在登录节点上执行命令。Slurm 和文件操作通过它执行命令，
+// 便于替换为本地执行、slurmrestd 等后端，测试时使用 FakeExecutor 返回录制的输出
+type RemoteExecutor interface {
+	// Run 执行命令并等待结束。命令以非零状态退出时同时返回结果和 *ExitError，
+	// ctx 取消或超时时终止命令并返回 *TimeoutError
+	Run(ctx context.Context, cmd *Command) (*Result, error)
+}
+
+// Command 要执行的命令
+type Command struct {
+	// Cmd 交给 shell 执行的命令行
+	Cmd string
+	// Env 附加的环境变量
+	Env map[string]string
+	// Stdin 命令的标准输入，可为空
+	Stdin io.Reader
+	// Stdout、Stderr 为空时输出保存在 Result 中
+	Stdout io.Writer
+	Stderr io.Writer
+	// Timeout 命令的最长执行时间，0 表示不限制
+	Timeout time.Duration
+}
+
+// Result 命令执行结果
+type Result struct {
+	ExitCode int
+	Stdout   []byte
+	Stderr   []byte
+}
+
+// ExitError 命令以非零状态退出
+type ExitError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("Process exited with status %d", e.ExitCode)
+}
+
+// ErrConnection 无法在 SSH 连接上打开会话
+var ErrConnection = errors.New("ssh connection unavailable")
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Exec 执行命令行并返回结果
+func Exec(ctx context.Context, executor RemoteExecutor, cmdline string) (*Result, error) {
+	return executor.Run(ctx, &Command{Cmd: cmdline})
+}
+
+// CombinedOutput 执行命令行并返回合并后的标准输出和标准错误
+func CombinedOutput(ctx context.Context, executor RemoteExecutor, cmdline string) ([]byte, error) {
+	var out lockedBuffer
+	_, err := executor.Run(ctx, &Command{Cmd: cmdline, Stdout: &out, Stderr: &out})
+	return out.Bytes(), err
+}
+
+// lockedBuffer 可并发写入的缓冲区，用于合并标准输出和标准错误
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+// withTimeout 按命令的超时时间派生 ctx
+func withTimeout(ctx context.Context, cmd *Command) (context.Context, context.CancelFunc) {
+	if cmd.Timeout > 0 {
+		return context.WithTimeout(ctx, cmd.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// outputs 返回命令的输出目标，未指定时写入结果缓冲区
+func outputs(cmd *Command, stdout, stderr *bytes.Buffer) (io.Writer, io.Writer) {
+	var outW, errW io.Writer = stdout, stderr
+	if cmd.Stdout != nil {
+		outW = cmd.Stdout
+	}
+	if cmd.Stderr != nil {
+		errW = cmd.Stderr
+	}
+	return outW, errW
+}
+
+// SSHExecutor 通过 SSH 会话在登录节点上执行命令
+type SSHExecutor struct {
+	client *ssh.Client
+}
+
+func NewSSHExecutor(client *ssh.Client) *SSHExecutor {
+	return &SSHExecutor{client: client}
+}
+
+func (e *SSHExecutor) Run(ctx context.Context, cmd *Command) (*Result, error) {
+	cmdline, err := shellCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx, cmd)
+	defer cancel()
+	if ctx.Err() != nil {
+		return nil, interrupted(ctx, cmd)
+	}
+
+	session, err := e.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConnection, err)
+	}
+	defer session.Close()
+	var stdout, stderr bytes.Buffer
+	session.Stdin = cmd.Stdin
+	session.Stdout, session.Stderr = outputs(cmd, &stdout, &stderr)
+	if err := session.Start(cmdline); err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// 部分 sshd 不处理 signal 请求，关闭会话保证远端进程收到 SIGHUP
+		_ = session.Signal(ssh.SIGKILL)
+		_ = session.Close()
+		<-done
+		return nil, interrupted(ctx, cmd)
+	}
+
+	result := &Result{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	var exit *ssh.ExitError
+	if errors.As(err, &exit) {
+		result.ExitCode = exit.ExitStatus()
+		return result, &ExitError{Command: cmd.Cmd, ExitCode: result.ExitCode, Stderr: string(result.Stderr)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// shellCommand 在命令行前导出环境变量。sshd 默认不接受客户端传入的环境变量，
+// 因此不使用 Setenv 请求
+func shellCommand(cmd *Command) (string, error) {
+	if len(cmd.Env) == 0 {
+		return cmd.Cmd, nil
+	}
+	names := make([]string, 0, len(cmd.Env))
+	for name := range cmd.Env {
+		if !envName.MatchString(name) {
+			return "", fmt.Errorf("invalid environment variable name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "export %s=%s; ", name, utils.Quote(cmd.Env[name]))
+	}
+	b.WriteString(cmd.Cmd)
+	return b.String(), nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeResponse FakeExecutor 为命令返回的录制输出
+type FakeResponse struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	// Err 不为空时模拟连接错误等执行失败
+	Err error
+	// Delay 模拟命令的执行时间
+	Delay time.Duration
+}
+
+// FakeCall FakeExecutor 收到的命令
+type FakeCall struct {
+	Cmd     string
+	Env     map[string]string
+	Stdin   string
+	Timeout time.Duration
+}
+
+// FakeExecutor 按命令前缀返回录制输出的执行器，用于测试。
+// 没有匹配的命令时与 shell 一致，以状态 127 退出
+type FakeExecutor struct {
+	mu        sync.Mutex
+	prefixes  []string
+	responses []FakeResponse
+	calls     []FakeCall
+}
+
+func NewFakeExecutor() *FakeExecutor {
+	return &FakeExecutor{}
+}
+
+// Handle 为以 prefix 开头的命令设置输出，后设置的优先匹配
+func (f *FakeExecutor) Handle(prefix string, resp FakeResponse) *FakeExecutor {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prefixes = append(f.prefixes, prefix)
+	f.responses = append(f.responses, resp)
+	return f
+}
+
+// Calls 返回已执行的命令
+func (f *FakeExecutor) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeCall(nil), f.calls...)
+}
+
+func (f *FakeExecutor) Run(ctx context.Context, cmd *Command) (*Result, error) {
+	ctx, cancel := withTimeout(ctx, cmd)
+	defer cancel()
+	call := FakeCall{Cmd: cmd.Cmd, Env: cmd.Env, Timeout: cmd.Timeout}
+	if cmd.Stdin != nil {
+		stdin, err := io.ReadAll(cmd.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		call.Stdin = string(stdin)
+	}
+	resp := f.match(call)
+
+	if resp.Delay > 0 {
+		timer := time.NewTimer(resp.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, interrupted(ctx, cmd)
+	}
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	result := &Result{ExitCode: resp.ExitCode}
+	if cmd.Stdout != nil {
+		_, _ = io.WriteString(cmd.Stdout, resp.Stdout)
+	} else {
+		result.Stdout = []byte(resp.Stdout)
+	}
+	if cmd.Stderr != nil {
+		_, _ = io.WriteString(cmd.Stderr, resp.Stderr)
+	} else {
+		result.Stderr = []byte(resp.Stderr)
+	}
+	if resp.ExitCode != 0 {
+		return result, &ExitError{Command: cmd.Cmd, ExitCode: resp.ExitCode, Stderr: resp.Stderr}
+	}
+	return result, nil
+}
+
+// match 记录命令并返回匹配的输出
+func (f *FakeExecutor) match(call FakeCall) FakeResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+	for i := len(f.prefixes) - 1; i >= 0; i-- {
+		if strings.HasPrefix(call.Cmd, f.prefixes[i]) {
+			return f.responses[i]
+		}
+	}
+	name := strings.Fields(call.Cmd + " sh")[0]
+	return FakeResponse{Stderr: "sh: " + name + ": command not found\n", ExitCode: 127}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// LocalExecutor 使用本机 shell 执行命令，适用于服务部署在登录节点上的场景
+type LocalExecutor struct {
+	// Shell 执行命令的 shell，默认为 /bin/sh
+	Shell string
+	// Dir 命令的工作目录，默认为服务进程的工作目录
+	Dir string
+}
+
+func (e *LocalExecutor) Run(ctx context.Context, cmd *Command) (*Result, error) {
+	ctx, cancel := withTimeout(ctx, cmd)
+	defer cancel()
+	shell := e.Shell
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	c := exec.CommandContext(ctx, shell, "-c", cmd.Cmd)
+	c.Dir = e.Dir
+	killProcessGroup(c)
+	// 后台进程继承输出管道时不会无限等待
+	c.WaitDelay = time.Second
+	if len(cmd.Env) > 0 {
+		c.Env = os.Environ()
+		for name, value := range cmd.Env {
+			if !envName.MatchString(name) {
+				return nil, fmt.Errorf("invalid environment variable name %q", name)
+			}
+			c.Env = append(c.Env, name+"="+value)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	c.Stdin = cmd.Stdin
+	c.Stdout, c.Stderr = outputs(cmd, &stdout, &stderr)
+
+	err := c.Run()
+	if ctx.Err() != nil {
+		return nil, interrupted(ctx, cmd)
+	}
+	result := &Result{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		result.ExitCode = exit.ExitCode()
+		return result, &ExitError{Command: cmd.Cmd, ExitCode: result.ExitCode, Stderr: string(result.Stderr)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
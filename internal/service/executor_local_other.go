@@ -0,0 +1,7 @@
+//go:build !unix
+
+package service
+
+import "os/exec"
+
+func killProcessGroup(c *exec.Cmd) {}
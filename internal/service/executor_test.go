@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalExecutor(t *testing.T) {
+	executor := &LocalExecutor{}
+	ctx := context.Background()
+
+	result, err := executor.Run(ctx, &Command{
+		Cmd:   `cat; echo "$GREETING"`,
+		Env:   map[string]string{"GREETING": "it's me"},
+		Stdin: strings.NewReader("input\n"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "input\nit's me\n", string(result.Stdout))
+
+	// 非零退出同时返回结果和 ExitError
+	result, err = executor.Run(ctx, &Command{Cmd: "echo oops >&2; exit 3"})
+	var exit *ExitError
+	require.ErrorAs(t, err, &exit)
+	assert.Equal(t, 3, exit.ExitCode)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.Equal(t, "oops\n", exit.Stderr)
+
+	// 超时终止命令
+	start := time.Now()
+	_, err = executor.Run(ctx, &Command{Cmd: "sleep 5", Timeout: 50 * time.Millisecond})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	_, err = executor.Run(ctx, &Command{Cmd: "true", Env: map[string]string{"A;B": "x"}})
+	assert.Error(t, err)
+}
+
+func TestShellCommand(t *testing.T) {
+	cmdline, err := shellCommand(&Command{Cmd: "squeue", Env: map[string]string{"SQUEUE_FORMAT": "%i %j", "A": "it's"}})
+	require.NoError(t, err)
+	assert.Equal(t, `export A='it'\''s'; export SQUEUE_FORMAT='%i %j'; squeue`, cmdline)
+
+	// 导出的命令行交给 shell 执行后得到原始的值
+	result, err := (&LocalExecutor{}).Run(context.Background(), &Command{Cmd: cmdline + `; echo "$A"`})
+	require.NoError(t, err)
+	assert.Equal(t, "it's\n", string(result.Stdout))
+}
+
+func TestFakeExecutor(t *testing.T) {
+	fake := NewFakeExecutor().
+		Handle("squeue", FakeResponse{Stdout: "all jobs\n"}).
+		Handle("squeue -u alice", FakeResponse{Stdout: "alice jobs\n"}).
+		Handle("scancel", FakeResponse{Stderr: "scancel: error: Invalid job id\n", ExitCode: 1}).
+		Handle("sinfo", FakeResponse{Err: errors.New("connection lost")}).
+		Handle("sacct", FakeResponse{Delay: time.Second})
+	ctx := context.Background()
+
+	// 后设置的前缀优先匹配
+	result, err := Exec(ctx, fake, "squeue -u alice")
+	require.NoError(t, err)
+	assert.Equal(t, "alice jobs\n", string(result.Stdout))
+	result, err = Exec(ctx, fake, "squeue -u bob")
+	require.NoError(t, err)
+	assert.Equal(t, "all jobs\n", string(result.Stdout))
+
+	output, err := CombinedOutput(ctx, fake, "scancel 1")
+	var exit *ExitError
+	require.ErrorAs(t, err, &exit)
+	assert.Equal(t, 1, exit.ExitCode)
+	assert.Equal(t, "scancel: error: Invalid job id\n", string(output))
+
+	_, err = Exec(ctx, fake, "sinfo")
+	assert.EqualError(t, err, "connection lost")
+
+	_, err = fake.Run(ctx, &Command{Cmd: "sacct", Timeout: 10 * time.Millisecond})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// 未设置的命令与 shell 一致返回 127
+	_, err = fake.Run(ctx, &Command{Cmd: "sbatch job.sh", Stdin: strings.NewReader("#!/bin/bash")})
+	require.ErrorAs(t, err, &exit)
+	assert.Equal(t, 127, exit.ExitCode)
+
+	calls := fake.Calls()
+	require.Len(t, calls, 6)
+	assert.Equal(t, "sbatch job.sh", calls[5].Cmd)
+	assert.Equal(t, "#!/bin/bash", calls[5].Stdin)
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"log"
+	"sort"
+	"star-dim/internal/models"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NodeHealth 登录节点健康状态
+type NodeHealth struct {
+	Cluster   string    `json:"cluster"`
+	Node      string    `json:"node"`
+	Up        bool      `json:"up"`
+	Latency   int64     `json:"latency_ms"`
+	LastCheck time.Time `json:"last_check"`
+	LastError string    `json:"last_error,omitempty"`
+	Failures  int       `json:"failures"`
+}
+
+// HealthChecker 定期对所有登录节点进行 TCP 连接和 SSH 握手探测
+type HealthChecker struct {
+	clusters *ClusterService
+	hostKeys *HostKeyStore
+	timeout  time.Duration
+	mu       sync.RWMutex
+	status   map[string]*NodeHealth
+	rr       sync.Map // cluster name -> *uint64，轮询计数
+}
+
+func NewHealthChecker(clusters *ClusterService, hostKeys *HostKeyStore, timeout time.Duration) *HealthChecker {
+	return &HealthChecker{
+		clusters: clusters,
+		hostKeys: hostKeys,
+		timeout:  timeout,
+		status:   make(map[string]*NodeHealth),
+	}
+}
+
+func healthKey(cluster, node string) string {
+	return cluster + "/" + node
+}
+
+// Start 启动后台探测
+func (h *HealthChecker) Start(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		h.CheckAll()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				h.CheckAll()
+			}
+		}
+	}()
+}
+
+// CheckAll 并发探测所有集群的登录节点
+func (h *HealthChecker) CheckAll() {
+	var wg sync.WaitGroup
+	known := make(map[string]bool)
+	for _, cluster := range h.clusters.GetClusters() {
+		for _, node := range cluster.LoginNodes {
+			known[healthKey(cluster.Name, node.Name)] = true
+			wg.Add(1)
+			go func(cluster *models.Cluster, node *models.LoginNode) {
+				defer wg.Done()
+				latency, err := h.probe(cluster, node)
+				if err != nil {
+					h.MarkDown(cluster.Name, node.Name, err)
+					return
+				}
+				h.markUp(cluster.Name, node.Name, latency)
+			}(cluster, node)
+		}
+	}
+	wg.Wait()
+
+	// 清理已从配置中移除的节点
+	h.mu.Lock()
+	for key := range h.status {
+		if !known[key] {
+			delete(h.status, key)
+		}
+	}
+	h.mu.Unlock()
+}
+
+// probe 完成 SSH 握手即认为节点可用，认证失败不影响结果。
+// 经跳板机的节点使用跳板机配置的凭据建立隧道，跳板机未配置凭据时只能探测到第一个跳板机。
+// 跳板机按集群的主机密钥策略校验，校验通过后才发送凭据。
+func (h *HealthChecker) probe(cluster *models.Cluster, node *models.LoginNode) (time.Duration, error) {
+	start := time.Now()
+	config := &ssh.ClientConfig{
+		User:            "star-dim-probe",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 仅探测可用性，不建立会话
+		Timeout:         h.timeout,
+	}
+	jumpConfig := func(jump *models.JumpHost) (*ssh.ClientConfig, error) {
+		jc := *config
+		jc.HostKeyCallback = h.hostKeys.JumpCallback(cluster, jump)
+		if jump.User != "" {
+			jc.User = jump.User
+		}
+		if jump.HasCredentials() {
+			auth, err := JumpAuthMethods(jump)
+			if err != nil {
+				return nil, err
+			}
+			jc.Auth = auth
+		}
+		return &jc, nil
+	}
+	done := make(chan error, 1)
+	go func() {
+		client, err := DialLoginNode(node, config, jumpConfig)
+		if err == nil {
+			_ = client.Close()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil && !strings.Contains(err.Error(), "unable to authenticate") {
+			return 0, err
+		}
+	case <-time.After(h.timeout * time.Duration(len(node.JumpHosts)+1)):
+		return 0, fmt.Errorf("probe %s:%s timed out", node.Host, node.Port)
+	}
+	return time.Since(start), nil
+}
+
+func (h *HealthChecker) markUp(cluster, node string, latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status[healthKey(cluster, node)] = &NodeHealth{
+		Cluster:   cluster,
+		Node:      node,
+		Up:        true,
+		Latency:   latency.Milliseconds(),
+		LastCheck: time.Now(),
+	}
+}
+
+// MarkDown 标记节点不可用，登录时连接失败也会调用
+func (h *HealthChecker) MarkDown(cluster, node string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := healthKey(cluster, node)
+	failures := 1
+	if old, ok := h.status[key]; ok && !old.Up {
+		failures = old.Failures + 1
+	}
+	if failures == 1 {
+		log.Printf("login node %s is down: %v", key, err)
+	}
+	h.status[key] = &NodeHealth{
+		Cluster:   cluster,
+		Node:      node,
+		Up:        false,
+		LastCheck: time.Now(),
+		LastError: err.Error(),
+		Failures:  failures,
+	}
+}
+
+// Status 返回所有节点的健康状态
+func (h *HealthChecker) Status() []NodeHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var list []NodeHealth
+	for _, status := range h.status {
+		list = append(list, *status)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return healthKey(list[i].Cluster, list[i].Node) < healthKey(list[j].Cluster, list[j].Node)
+	})
+	return list
+}
+
+// NodeStatus 返回单个节点的健康状态，未探测过的节点返回 nil
+func (h *HealthChecker) NodeStatus(cluster, node string) *NodeHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if status, ok := h.status[healthKey(cluster, node)]; ok {
+		s := *status
+		return &s
+	}
+	return nil
+}
+
+// Failover 按顺序连接候选节点，连接失败时标记节点不可用并切换到下一个节点。
+// terminal 判断的错误（如认证失败）说明换节点也无济于事，立即返回；主机密钥校验失败不影响节点健康状态
+func (h *HealthChecker) Failover(cluster string, nodes []*models.LoginNode, dial func(node *models.LoginNode) (*ssh.Client, error),
+	terminal func(err error) bool) (*ssh.Client, *models.LoginNode, error) {
+	lastErr := fmt.Errorf("no available login node in cluster %s", cluster)
+	for _, node := range nodes {
+		conn, err := dial(node)
+		if err == nil {
+			return conn, node, nil
+		}
+		if terminal(err) {
+			return nil, nil, err
+		}
+		if !errors.Is(err, ErrHostKeyMismatch) && !errors.Is(err, ErrHostKeyUnknown) {
+			h.MarkDown(cluster, node.Name, err)
+		}
+		lastErr = fmt.Errorf("login node %s: %w", node.Name, err)
+	}
+	return nil, nil, lastErr
+}
+
+// Rank 按集群策略对候选节点排序：可用节点在前，不可用节点作为最后的重试对象。
+// sessions 为各节点当前的会话数，用于 least-sessions 策略。
+func (h *HealthChecker) Rank(cluster *models.Cluster, nodes []*models.LoginNode, sessions map[string]int) []*models.LoginNode {
+	var up, down []*models.LoginNode
+	for _, node := range nodes {
+		if status := h.NodeStatus(cluster.Name, node.Name); status != nil && !status.Up {
+			down = append(down, node)
+		} else {
+			up = append(up, node)
+		}
+	}
+
+	switch cluster.Strategy {
+	case models.StrategyRoundRobin:
+		if len(up) > 1 {
+			v, _ := h.rr.LoadOrStore(cluster.Name, new(uint64))
+			n := int(atomic.AddUint64(v.(*uint64), 1) % uint64(len(up)))
+			up = append(append([]*models.LoginNode{}, up[n:]...), up[:n]...)
+		}
+	case models.StrategyLeastSessions:
+		sort.SliceStable(up, func(i, j int) bool {
+			return sessions[up[i].Name] < sessions[up[j].Name]
+		})
+	}
+	return append(up, down...)
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"star-dim/internal/models"
+)
+
+// startSSHServer 启动只完成握手、拒绝所有认证的 SSH 服务器，返回监听地址和主机公钥。
+// passwords 不为空时记录客户端发送的密码
+func startSSHServer(t *testing.T, passwords chan<- string) (string, ssh.PublicKey) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if passwords != nil {
+				passwords <- string(password)
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	config.AddHostKey(signer)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _, _, _ = ssh.NewServerConn(conn, config)
+			}()
+		}
+	}()
+	return l.Addr().String(), signer.PublicKey()
+}
+
+// closedAddr 返回没有监听的本地地址
+func closedAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	return addr
+}
+
+func testNodes(names ...string) []*models.LoginNode {
+	var nodes []*models.LoginNode
+	for _, name := range names {
+		nodes = append(nodes, &models.LoginNode{Name: name, Host: "10.0.0.1", Port: "22"})
+	}
+	return nodes
+}
+
+func nodeNames(nodes []*models.LoginNode) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	return names
+}
+
+func TestHealthRank(t *testing.T) {
+	h := NewHealthChecker(nil, nil, time.Second)
+	nodes := testNodes("ln1", "ln2", "ln3")
+	cluster := &models.Cluster{Name: "hpc1", LoginNodes: nodes}
+
+	// 未探测过的节点视为可用，不可用节点排在最后作为重试对象
+	assert.Equal(t, []string{"ln1", "ln2", "ln3"}, nodeNames(h.Rank(cluster, nodes, nil)))
+	h.MarkDown("hpc1", "ln1", errors.New("connection refused"))
+	assert.Equal(t, []string{"ln2", "ln3", "ln1"}, nodeNames(h.Rank(cluster, nodes, nil)))
+
+	cluster.Strategy = models.StrategyLeastSessions
+	assert.Equal(t, []string{"ln3", "ln2", "ln1"}, nodeNames(h.Rank(cluster, nodes, map[string]int{"ln2": 5, "ln3": 1})))
+
+	// 轮询只在可用节点之间进行
+	cluster.Strategy = models.StrategyRoundRobin
+	first := h.Rank(cluster, nodes, nil)
+	second := h.Rank(cluster, nodes, nil)
+	assert.NotEqual(t, first[0].Name, second[0].Name)
+	assert.Equal(t, "ln1", first[2].Name)
+	assert.Equal(t, "ln1", second[2].Name)
+}
+
+func TestHealthMarkDown(t *testing.T) {
+	h := NewHealthChecker(nil, nil, time.Second)
+	assert.Nil(t, h.NodeStatus("hpc1", "ln1"))
+	for i := 0; i < 3; i++ {
+		h.MarkDown("hpc1", "ln1", errors.New("timeout"))
+	}
+	status := h.NodeStatus("hpc1", "ln1")
+	require.NotNil(t, status)
+	assert.False(t, status.Up)
+	assert.Equal(t, 3, status.Failures)
+	assert.Equal(t, "timeout", status.LastError)
+
+	// 恢复后失败次数清零
+	h.markUp("hpc1", "ln1", 5*time.Millisecond)
+	status = h.NodeStatus("hpc1", "ln1")
+	assert.True(t, status.Up)
+	assert.Equal(t, 0, status.Failures)
+	assert.Equal(t, int64(5), status.Latency)
+}
+
+func TestHealthCheckAll(t *testing.T) {
+	up, _ := startSSHServer(t, nil)
+	upHost, upPort, _ := net.SplitHostPort(up)
+	downHost, downPort, _ := net.SplitHostPort(closedAddr(t))
+	path := filepath.Join(t.TempDir(), "clusters.yaml")
+	writeConfig(t, path, fmt.Sprintf(`clusters:
+  - name: hpc1
+    login_nodes:
+      - name: up
+        host: %s
+        port: "%s"
+      - name: down
+        host: %s
+        port: "%s"
+`, upHost, upPort, downHost, downPort), time.Now())
+	clusters := NewClusterService(path)
+	require.NoError(t, clusters.Load())
+
+	h := NewHealthChecker(clusters, NewHostKeyStore(filepath.Join(t.TempDir(), "host_keys.json")), time.Second)
+	h.MarkDown("hpc1", "removed", errors.New("timeout"))
+	h.CheckAll()
+
+	// 认证失败不影响探测结果，已从配置中移除的节点被清理
+	status := h.Status()
+	require.Len(t, status, 2)
+	assert.Equal(t, "hpc1/down", healthKey(status[0].Cluster, status[0].Node))
+	assert.False(t, status[0].Up)
+	assert.Equal(t, "up", status[1].Node)
+	assert.True(t, status[1].Up, status[1].LastError)
+}
+
+func TestHealthProbeJumpHost(t *testing.T) {
+	passwords := make(chan string, 10)
+	addr, key := startSSHServer(t, passwords)
+	host, port, _ := net.SplitHostPort(addr)
+	jump := &models.JumpHost{Host: host, Port: port, User: "jump", Password: "secret", HostKey: authorizedKey(newHostKey(t))}
+	node := &models.LoginNode{Name: "ln1", Host: "10.0.0.1", Port: "22", JumpHosts: []*models.JumpHost{jump}}
+	cluster := &models.Cluster{Name: "hpc1", HostKeyPolicy: models.HostKeyPolicyPinned, LoginNodes: []*models.LoginNode{node}}
+	h := NewHealthChecker(nil, NewHostKeyStore(filepath.Join(t.TempDir(), "host_keys.json")), time.Second)
+
+	// 跳板机主机密钥校验失败时不发送凭据
+	_, err := h.probe(cluster, node)
+	assert.ErrorIs(t, err, ErrJumpHost)
+	assert.Empty(t, passwords)
+
+	// 校验通过后使用跳板机凭据认证，认证失败不影响探测结果
+	jump.HostKey = authorizedKey(key)
+	_, err = h.probe(cluster, node)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", <-passwords)
+}
+
+func TestHealthFailover(t *testing.T) {
+	h := NewHealthChecker(nil, nil, time.Second)
+	nodes := testNodes("ln1", "ln2", "ln3")
+	refused := errors.New("connection refused")
+	authFailed := errors.New("ssh: unable to authenticate")
+	isAuth := func(err error) bool { return err == authFailed }
+	dial := func(errs map[string]error) (func(*models.LoginNode) (*ssh.Client, error), *[]string) {
+		var tried []string
+		return func(node *models.LoginNode) (*ssh.Client, error) {
+			tried = append(tried, node.Name)
+			if err, ok := errs[node.Name]; ok {
+				return nil, err
+			}
+			return &ssh.Client{}, nil
+		}, &tried
+	}
+
+	// 连接失败时切换到下一个节点并标记不可用，主机密钥错误不影响健康状态
+	fn, tried := dial(map[string]error{"ln1": refused, "ln2": fmt.Errorf("%w: ln2", ErrHostKeyMismatch)})
+	conn, node, err := h.Failover("hpc1", nodes, fn, isAuth)
+	require.NoError(t, err)
+	assert.NotNil(t, conn)
+	assert.Equal(t, "ln3", node.Name)
+	assert.Equal(t, []string{"ln1", "ln2", "ln3"}, *tried)
+	assert.False(t, h.NodeStatus("hpc1", "ln1").Up)
+	assert.Nil(t, h.NodeStatus("hpc1", "ln2"))
+
+	// 认证失败不再尝试其他节点
+	fn, tried = dial(map[string]error{"ln1": authFailed})
+	_, _, err = h.Failover("hpc1", nodes, fn, isAuth)
+	assert.Equal(t, authFailed, err)
+	assert.Equal(t, []string{"ln1"}, *tried)
+
+	fn, _ = dial(map[string]error{"ln1": refused, "ln2": refused, "ln3": refused})
+	_, _, err = h.Failover("hpc1", nodes, fn, isAuth)
+	assert.ErrorIs(t, err, refused)
+	assert.Contains(t, err.Error(), "ln3")
+}
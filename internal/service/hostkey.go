@@ -0,0 +1,290 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"log"
+	"net"
+	"os"
+	"star-dim/internal/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HostKeyTrusted = "trusted"
+	HostKeyPending = "pending"
+)
+
+// ErrHostKeyMismatch 主机密钥与已信任的密钥不一致
+var ErrHostKeyMismatch = errors.New("host key mismatch")
+
+// ErrHostKeyNotFound 主机密钥记录不存在
+var ErrHostKeyNotFound = errors.New("host key not found")
+
+// ErrHostKeyUnknown 主机密钥未被信任，需要管理员确认
+var ErrHostKeyUnknown = errors.New("host key not trusted")
+
+// HostKeyEntry 登录节点的主机密钥记录
+type HostKeyEntry struct {
+	Cluster     string    `json:"cluster"`
+	Node        string    `json:"node"`
+	Address     string    `json:"address"`
+	Type        string    `json:"type"`
+	Fingerprint string    `json:"fingerprint"`
+	Key         string    `json:"key"`
+	Status      string    `json:"status"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// HostKeyStore 管理登录节点主机密钥，支持首次使用信任（TOFU）和管理员审批
+type HostKeyStore struct {
+	path    string
+	mu      sync.Mutex
+	entries []*HostKeyEntry
+}
+
+func NewHostKeyStore(path string) *HostKeyStore {
+	return &HostKeyStore{
+		path: path,
+	}
+}
+
+// Load 读取主机密钥文件，文件不存在时视为空
+func (s *HostKeyStore) Load() error {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read host keys %s: %v", s.path, err)
+	}
+	var entries []*HostKeyEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("parse host keys %s: %v", s.path, err)
+	}
+	s.mu.Lock()
+	s.entries = entries
+	s.mu.Unlock()
+	return nil
+}
+
+// save 写回主机密钥文件，调用方需持有锁
+func (s *HostKeyStore) save() error {
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write host keys: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// find 查找节点指定状态的密钥记录，调用方需持有锁
+func (s *HostKeyStore) find(cluster, node, status string) *HostKeyEntry {
+	for _, entry := range s.entries {
+		if entry.Cluster == cluster && entry.Node == node && entry.Status == status {
+			return entry
+		}
+	}
+	return nil
+}
+
+// remove 删除节点指定状态的密钥记录，status 为空时删除全部，调用方需持有锁
+func (s *HostKeyStore) remove(cluster, node, status string) bool {
+	removed := false
+	entries := s.entries[:0]
+	for _, entry := range s.entries {
+		if entry.Cluster == cluster && entry.Node == node && (status == "" || entry.Status == status) {
+			removed = true
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	s.entries = entries
+	return removed
+}
+
+func newHostKeyEntry(cluster, node, address string, key ssh.PublicKey, status string) *HostKeyEntry {
+	now := time.Now()
+	return &HostKeyEntry{
+		Cluster:     cluster,
+		Node:        node,
+		Address:     address,
+		Type:        key.Type(),
+		Fingerprint: ssh.FingerprintSHA256(key),
+		Key:         strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))),
+		Status:      status,
+		FirstSeen:   now,
+		LastSeen:    now,
+	}
+}
+
+// recordPending 记录待审批的密钥，调用方需持有锁
+func (s *HostKeyStore) recordPending(cluster, node, address string, key ssh.PublicKey) {
+	if pending := s.find(cluster, node, HostKeyPending); pending != nil && pending.Fingerprint == ssh.FingerprintSHA256(key) {
+		pending.LastSeen = time.Now()
+		return
+	}
+	s.remove(cluster, node, HostKeyPending)
+	s.entries = append(s.entries, newHostKeyEntry(cluster, node, address, key, HostKeyPending))
+	if err := s.save(); err != nil {
+		log.Println(err)
+	}
+}
+
+// checkTrusted 校验密钥是否为已信任密钥。tofu 为 true 时首次见到的密钥自动信任。
+func (s *HostKeyStore) checkTrusted(cluster, node, address string, key ssh.PublicKey, tofu bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	trusted := s.find(cluster, node, HostKeyTrusted)
+	if trusted != nil {
+		if trusted.Key == strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))) {
+			trusted.LastSeen = time.Now()
+			return nil
+		}
+		s.recordPending(cluster, node, address, key)
+		log.Printf("host key mismatch for %s/%s: trusted %s, got %s", cluster, node, trusted.Fingerprint, ssh.FingerprintSHA256(key))
+		return fmt.Errorf("%w for %s/%s: got %s, awaiting admin approval", ErrHostKeyMismatch, cluster, node, ssh.FingerprintSHA256(key))
+	}
+	if !tofu {
+		s.recordPending(cluster, node, address, key)
+		return fmt.Errorf("%w for %s/%s: %s, awaiting admin approval", ErrHostKeyUnknown, cluster, node, ssh.FingerprintSHA256(key))
+	}
+	log.Printf("trusting host key %s for %s/%s on first use", ssh.FingerprintSHA256(key), cluster, node)
+	s.entries = append(s.entries, newHostKeyEntry(cluster, node, address, key, HostKeyTrusted))
+	return s.save()
+}
+
+// Callback 按集群的主机密钥策略返回登录节点的 HostKeyCallback
+func (s *HostKeyStore) Callback(cluster *models.Cluster, node *models.LoginNode) ssh.HostKeyCallback {
+	return s.callback(cluster, node.Name, node.HostKey)
+}
+
+// JumpCallback 返回跳板机的 HostKeyCallback，密钥记录以 jump:地址 作为节点名称
+func (s *HostKeyStore) JumpCallback(cluster *models.Cluster, jump *models.JumpHost) ssh.HostKeyCallback {
+	return s.callback(cluster, "jump:"+jump.Address(), jump.HostKey)
+}
+
+func (s *HostKeyStore) callback(cluster *models.Cluster, node, hostKey string) ssh.HostKeyCallback {
+	switch cluster.HostKeyPolicy {
+	case models.HostKeyPolicyInsecure:
+		return ssh.InsecureIgnoreHostKey()
+	case models.HostKeyPolicyPinned:
+		pinned, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+		if err != nil {
+			return func(string, net.Addr, ssh.PublicKey) error {
+				return fmt.Errorf("invalid pinned host key for %s/%s: %v", cluster.Name, node, err)
+			}
+		}
+		return ssh.FixedHostKey(pinned)
+	case models.HostKeyPolicyKnownHosts:
+		var fileCallback ssh.HostKeyCallback
+		if cluster.KnownHostsFile != "" {
+			cb, err := knownhosts.New(cluster.KnownHostsFile)
+			if err != nil {
+				log.Printf("load known_hosts %s: %v", cluster.KnownHostsFile, err)
+			} else {
+				fileCallback = cb
+			}
+		}
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if fileCallback != nil {
+				err := fileCallback(hostname, remote, key)
+				if err == nil {
+					return nil
+				}
+				// 已知主机的密钥发生变化时直接拒绝，未知主机再查询托管的密钥记录
+				var keyErr *knownhosts.KeyError
+				if !errors.As(err, &keyErr) || len(keyErr.Want) > 0 {
+					return err
+				}
+			}
+			return s.checkTrusted(cluster.Name, node, hostname, key, false)
+		}
+	default:
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return s.checkTrusted(cluster.Name, node, hostname, key, true)
+		}
+	}
+}
+
+// List 返回全部主机密钥记录
+func (s *HostKeyStore) List() []HostKeyEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]HostKeyEntry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		list = append(list, *entry)
+	}
+	return list
+}
+
+// Approve 批准待审批的密钥，替换节点原有的信任密钥
+func (s *HostKeyStore) Approve(cluster, node, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := s.find(cluster, node, HostKeyPending)
+	if pending == nil || (fingerprint != "" && pending.Fingerprint != fingerprint) {
+		return fmt.Errorf("%w: no pending key %s for %s/%s", ErrHostKeyNotFound, fingerprint, cluster, node)
+	}
+	s.remove(cluster, node, HostKeyTrusted)
+	pending.Status = HostKeyTrusted
+	return s.save()
+}
+
+// Rotate 将节点的信任密钥替换为指定公钥（authorized_keys 格式）
+func (s *HostKeyStore) Rotate(cluster, node, authorizedKey string) error {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		return fmt.Errorf("invalid host key: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	address := ""
+	for _, entry := range s.entries {
+		if entry.Cluster == cluster && entry.Node == node {
+			address = entry.Address
+		}
+	}
+	s.remove(cluster, node, "")
+	s.entries = append(s.entries, newHostKeyEntry(cluster, node, address, key, HostKeyTrusted))
+	return s.save()
+}
+
+// Forget 删除节点的全部密钥记录，tofu 策略下下次连接时重新信任
+func (s *HostKeyStore) Forget(cluster, node string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.remove(cluster, node, "") {
+		return ErrHostKeyNotFound
+	}
+	return s.save()
+}
+
+// KnownHosts 以 OpenSSH known_hosts 格式导出已信任的密钥，include 不为 nil 时只导出其返回 true 的集群
+func (s *HostKeyStore) KnownHosts(include func(cluster string) bool) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var buf bytes.Buffer
+	for _, entry := range s.entries {
+		if entry.Status != HostKeyTrusted || entry.Address == "" || (include != nil && !include(entry.Cluster)) {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(entry.Key))
+		if err != nil {
+			continue
+		}
+		buf.WriteString(knownhosts.Line([]string{entry.Address}, key))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"star-dim/internal/models"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+	return key
+}
+
+func authorizedKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+var testAddr = &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}
+
+func TestHostKeyTOFU(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "host_keys.json")
+	store := NewHostKeyStore(path)
+	cluster := &models.Cluster{Name: "hpc1"}
+	node := &models.LoginNode{Name: "ln1", Host: "10.0.0.1", Port: "22"}
+	callback := store.Callback(cluster, node)
+	key, other := newHostKey(t), newHostKey(t)
+
+	// 首次见到的密钥自动信任并写入文件
+	require.NoError(t, callback("10.0.0.1:22", testAddr, key))
+	require.NoError(t, callback("10.0.0.1:22", testAddr, key))
+	entries := store.List()
+	require.Len(t, entries, 1)
+	assert.Equal(t, HostKeyTrusted, entries[0].Status)
+	assert.Equal(t, ssh.FingerprintSHA256(key), entries[0].Fingerprint)
+
+	reloaded := NewHostKeyStore(path)
+	require.NoError(t, reloaded.Load())
+	require.NoError(t, reloaded.Callback(cluster, node)("10.0.0.1:22", testAddr, key))
+
+	// 密钥变化时拒绝连接并记录待审批的密钥
+	assert.ErrorIs(t, callback("10.0.0.1:22", testAddr, other), ErrHostKeyMismatch)
+	assert.ErrorIs(t, callback("10.0.0.1:22", testAddr, other), ErrHostKeyMismatch)
+	entries = store.List()
+	require.Len(t, entries, 2)
+	assert.Equal(t, HostKeyPending, entries[1].Status)
+	assert.Equal(t, ssh.FingerprintSHA256(other), entries[1].Fingerprint)
+
+	// 批准的指纹必须与待审批的密钥一致，批准后替换原有的信任密钥
+	assert.ErrorIs(t, store.Approve("hpc1", "ln1", ssh.FingerprintSHA256(key)), ErrHostKeyNotFound)
+	require.NoError(t, store.Approve("hpc1", "ln1", ssh.FingerprintSHA256(other)))
+	require.NoError(t, callback("10.0.0.1:22", testAddr, other))
+	assert.ErrorIs(t, callback("10.0.0.1:22", testAddr, key), ErrHostKeyMismatch)
+
+	// 删除记录后重新信任
+	require.NoError(t, store.Forget("hpc1", "ln1"))
+	assert.ErrorIs(t, store.Forget("hpc1", "ln1"), ErrHostKeyNotFound)
+	require.NoError(t, callback("10.0.0.1:22", testAddr, key))
+}
+
+func TestHostKeyPolicies(t *testing.T) {
+	dir := t.TempDir()
+	store := NewHostKeyStore(filepath.Join(dir, "host_keys.json"))
+	key, other := newHostKey(t), newHostKey(t)
+	node := &models.LoginNode{Name: "ln1", Host: "10.0.0.1", Port: "22", HostKey: authorizedKey(key)}
+
+	pinned := store.Callback(&models.Cluster{Name: "hpc1", HostKeyPolicy: models.HostKeyPolicyPinned}, node)
+	assert.NoError(t, pinned("10.0.0.1:22", testAddr, key))
+	assert.Error(t, pinned("10.0.0.1:22", testAddr, other))
+
+	// known_hosts 中没有的主机需要管理员批准
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+	require.NoError(t, os.WriteFile(knownHostsFile, []byte(knownhosts.Line([]string{"10.0.0.1"}, key)+"\n"), 0644))
+	cluster := &models.Cluster{Name: "hpc2", HostKeyPolicy: models.HostKeyPolicyKnownHosts, KnownHostsFile: knownHostsFile}
+	knownHosts := store.Callback(cluster, node)
+	assert.NoError(t, knownHosts("10.0.0.1:22", testAddr, key))
+	assert.Error(t, knownHosts("10.0.0.1:22", testAddr, other))
+	unknown := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 22}
+	node2 := &models.LoginNode{Name: "ln2", Host: "10.0.0.2", Port: "22"}
+	assert.ErrorIs(t, store.Callback(cluster, node2)("10.0.0.2:22", unknown, other), ErrHostKeyUnknown)
+	require.NoError(t, store.Approve("hpc2", "ln2", ""))
+	assert.NoError(t, store.Callback(cluster, node2)("10.0.0.2:22", unknown, other))
+
+	// 导出已信任的密钥
+	assert.Contains(t, string(store.KnownHosts(nil)), "10.0.0.2")
+}
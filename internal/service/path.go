@@ -0,0 +1,141 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"star-dim/internal/models"
+)
+
+// RealPather 将路径解析为不含符号链接的绝对路径，*sftp.Client 实现了该接口。
+// RealPath 在路径不存在时返回错误，此时通过 Lstat 和 ReadLink 检查无法解析的符号链接
+type RealPather interface {
+	RealPath(path string) (string, error)
+	Lstat(path string) (os.FileInfo, error)
+	ReadLink(path string) (string, error)
+}
+
+// maxLinkHops 解析无法解析的符号链接时最多跟随的次数，与 Linux 的 MAXSYMLINKS 一致，
+// 超过时登录节点同样无法访问该路径
+const maxLinkHops = 40
+
+// PathConfiner 将文件接口的路径限制在集群允许访问的目录内。路径先按字面清理，
+// 再经登录节点解析符号链接，解析后的实际位置须位于某个允许的目录之下
+type PathConfiner struct {
+	home   string
+	user   string
+	policy *models.PathPolicy
+
+	mu    sync.Mutex
+	roots []string // 解析符号链接后的允许目录，首次使用时解析
+}
+
+// NewPathConfiner home 为空时首次使用时以 SFTP 的当前目录作为家目录
+func NewPathConfiner(home, user string, policy *models.PathPolicy) *PathConfiner {
+	return &PathConfiner{home: home, user: user, policy: policy}
+}
+
+// Resolve 将客户端路径转换为登录节点上的绝对路径，相对路径相对于家目录。
+// follow 为 true 时最后一级符号链接指向的位置也须在允许的目录内；为 false 时只检查链接本身所在的目录，
+// 用于删除、重命名等作用于链接本身的操作。路径超出允许的目录时返回 ErrPermissionDenied
+func (p *PathConfiner) Resolve(fs RealPather, name string, follow bool) (string, error) {
+	roots, err := p.allowedRoots(fs)
+	if err != nil {
+		return "", err
+	}
+	if !path.IsAbs(name) {
+		name = path.Join(p.home, name)
+	}
+	name = path.Clean(name)
+
+	resolved := name
+	if follow {
+		resolved, err = realPath(fs, name, 0)
+	} else if name != "/" {
+		resolved, err = realPath(fs, path.Dir(name), 0)
+		resolved = path.Join(resolved, path.Base(name))
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, root := range roots {
+		if within(resolved, root) {
+			return resolved, nil
+		}
+	}
+	return "", denied("%s is outside the allowed directories (%s)", name, strings.Join(roots, ", "))
+}
+
+// Roots 返回解析符号链接后允许访问的目录
+func (p *PathConfiner) Roots(fs RealPather) ([]string, error) {
+	roots, err := p.allowedRoots(fs)
+	return append([]string(nil), roots...), err
+}
+
+func (p *PathConfiner) allowedRoots(fs RealPather) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.roots != nil {
+		return p.roots, nil
+	}
+	if p.home == "" {
+		home, err := fs.RealPath(".")
+		if err != nil {
+			return nil, fmt.Errorf("resolve home directory: %w", err)
+		}
+		p.home = home
+	}
+	var roots []string
+	for _, root := range p.policy.AllowedRoots(p.home, p.user) {
+		resolved, err := realPath(fs, root, 0)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, resolved)
+	}
+	p.roots = roots
+	return p.roots, nil
+}
+
+// realPath 解析路径中的符号链接。路径不存在时解析最近的已存在上级目录，再拼接其余部分，
+// 以便检查待创建的文件。最近的已存在部分是悬空链接时，按链接目标继续解析，
+// 以免经由指向允许目录以外的悬空链接创建文件
+func realPath(fs RealPather, name string, hops int) (string, error) {
+	dir, rest := name, ""
+	for {
+		if real, err := fs.RealPath(dir); err == nil {
+			return path.Join(real, rest), nil
+		}
+		if info, err := fs.Lstat(dir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if hops >= maxLinkHops {
+				// 循环链接，访问时由登录节点报错
+				return path.Join(dir, rest), nil
+			}
+			target, err := fs.ReadLink(dir)
+			if err != nil {
+				return "", denied("cannot read symbolic link %s: %v", dir, err)
+			}
+			if !path.IsAbs(target) {
+				parent, err := realPath(fs, path.Dir(dir), hops+1)
+				if err != nil {
+					return "", err
+				}
+				target = path.Join(parent, target)
+			}
+			return realPath(fs, path.Join(target, rest), hops+1)
+		}
+		if dir == "/" || dir == "." {
+			return name, nil
+		}
+		rest = path.Join(path.Base(dir), rest)
+		dir = path.Dir(dir)
+	}
+}
+
+// within 判断 name 是否为 root 或位于 root 之下
+func within(name, root string) bool {
+	return name == root || root == "/" || strings.HasPrefix(name, root+"/")
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+)
+
+// fakeFS 模拟登录节点上的目录和符号链接，RealPath 与 sftp-server 一致，路径不存在时返回错误
+type fakeFS struct {
+	cwd   string
+	dirs  map[string]bool
+	links map[string]string
+}
+
+func (f *fakeFS) RealPath(name string) (string, error) {
+	if !path.IsAbs(name) {
+		name = path.Join(f.cwd, name)
+	}
+	resolved := "/"
+	parts := strings.Split(strings.Trim(path.Clean(name), "/"), "/")
+	for hops := 0; len(parts) > 0; {
+		part := parts[0]
+		parts = parts[1:]
+		if part == "" {
+			continue
+		}
+		next := path.Join(resolved, part)
+		if target, ok := f.links[next]; ok {
+			if hops++; hops > 40 {
+				return "", os.ErrInvalid
+			}
+			if !path.IsAbs(target) {
+				target = path.Join(resolved, target)
+			}
+			parts = append(strings.Split(strings.Trim(target, "/"), "/"), parts...)
+			resolved = "/"
+			continue
+		}
+		if !f.dirs[next] {
+			return "", os.ErrNotExist
+		}
+		resolved = next
+	}
+	return resolved, nil
+}
+
+func (f *fakeFS) Lstat(name string) (os.FileInfo, error) {
+	if !path.IsAbs(name) {
+		name = path.Join(f.cwd, name)
+	}
+	if name = path.Clean(name); name == "/" {
+		return fakeInfo{name: name, mode: os.ModeDir | 0755}, nil
+	}
+	dir, err := f.RealPath(path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	name = path.Join(dir, path.Base(name))
+	if _, ok := f.links[name]; ok {
+		return fakeInfo{name: name, mode: os.ModeSymlink | 0777}, nil
+	}
+	if f.dirs[name] {
+		return fakeInfo{name: name, mode: os.ModeDir | 0755}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (f *fakeFS) ReadLink(name string) (string, error) {
+	if info, err := f.Lstat(name); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return "", os.ErrInvalid
+	}
+	dir, _ := f.RealPath(path.Dir(name))
+	return f.links[path.Join(dir, path.Base(name))], nil
+}
+
+func newFakeFS() *fakeFS {
+	return &fakeFS{
+		cwd: "/home/alice",
+		dirs: map[string]bool{
+			"/etc": true, "/etc/passwd": true, "/gpfs": true, "/gpfs/home": true, "/gpfs/home/alice": true,
+			"/gpfs/home/alice/data": true, "/gpfs/home/alice/data/a.txt": true, "/gpfs/home/bob": true,
+			"/scratch": true, "/scratch/alice": true, "/scratch/bob": true,
+		},
+		links: map[string]string{
+			"/home":                          "/gpfs/home",
+			"/gpfs/home/alice/etc":           "/etc",
+			"/gpfs/home/alice/passwd":        "/etc/passwd",
+			"/gpfs/home/alice/latest":        "data/a.txt",
+			"/gpfs/home/alice/scratch":       "/scratch/alice",
+			"/gpfs/home/alice/data/bob":      "../../bob",
+			"/scratch/alice/loop":            "/scratch/alice/loop",
+			"/scratch/alice/dangling":        "/etc/cron.d/job",
+			"/scratch/alice/pending":         "../alice/results/out.log",
+			"/scratch/alice/projects-shared": "/scratch/bob",
+		},
+	}
+}
+
+func TestPathConfinerTraversal(t *testing.T) {
+	fs := newFakeFS()
+	p := NewPathConfiner("/home/alice", "alice", nil)
+
+	resolved, err := p.Resolve(fs, "data/a.txt", true)
+	require.NoError(t, err)
+	assert.Equal(t, "/gpfs/home/alice/data/a.txt", resolved)
+
+	resolved, err = p.Resolve(fs, "/home/alice/data/../data/new.txt", true)
+	require.NoError(t, err)
+	assert.Equal(t, "/gpfs/home/alice/data/new.txt", resolved)
+
+	resolved, err = p.Resolve(fs, "", true)
+	require.NoError(t, err)
+	assert.Equal(t, "/gpfs/home/alice", resolved)
+
+	for _, name := range []string{"../../etc/passwd", "../bob", "/etc", "/", "data/../../../etc", "/home/alice/../bob/x", "/home/alice2"} {
+		_, err := p.Resolve(fs, name, true)
+		assert.ErrorIs(t, err, ErrPermissionDenied, name)
+		_, err = p.Resolve(fs, name, false)
+		assert.ErrorIs(t, err, ErrPermissionDenied, name)
+	}
+}
+
+func TestPathConfinerSymlinks(t *testing.T) {
+	fs := newFakeFS()
+	p := NewPathConfiner("/home/alice", "alice", nil)
+
+	// 指向允许目录以外的链接不能被读取，但可以删除或重命名链接本身
+	for _, name := range []string{"passwd", "etc", "etc/passwd", "etc/new.conf", "data/bob", "scratch"} {
+		_, err := p.Resolve(fs, name, true)
+		assert.ErrorIs(t, err, ErrPermissionDenied, name)
+	}
+	resolved, err := p.Resolve(fs, "passwd", false)
+	require.NoError(t, err)
+	assert.Equal(t, "/gpfs/home/alice/passwd", resolved)
+
+	// 经过链接目录的路径按实际位置判断
+	_, err = p.Resolve(fs, "etc/passwd", false)
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+	_, err = p.Resolve(fs, "data/bob/secret", false)
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+
+	resolved, err = p.Resolve(fs, "latest", true)
+	require.NoError(t, err)
+	assert.Equal(t, "/gpfs/home/alice/data/a.txt", resolved)
+}
+
+func TestPathConfinerRoots(t *testing.T) {
+	fs := newFakeFS()
+	policy := &models.PathPolicy{Roots: []string{"/scratch/{user}", "{home}/../shared"}}
+	require.NoError(t, policy.Validate())
+	assert.Error(t, (&models.PathPolicy{Roots: []string{"scratch/{user}"}}).Validate())
+
+	// 家目录为空时使用 SFTP 的当前目录
+	p := NewPathConfiner("", "alice", policy)
+	roots, err := p.Roots(fs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/gpfs/home/alice", "/scratch/alice", "/gpfs/home/shared"}, roots)
+
+	resolved, err := p.Resolve(fs, "scratch/run1/out.log", true)
+	require.NoError(t, err)
+	assert.Equal(t, "/scratch/alice/run1/out.log", resolved)
+
+	resolved, err = p.Resolve(fs, "/scratch/alice", true)
+	require.NoError(t, err)
+	assert.Equal(t, "/scratch/alice", resolved)
+
+	for _, name := range []string{"/scratch/bob", "/scratch/alice/projects-shared/x", "/scratch"} {
+		_, err := p.Resolve(fs, name, true)
+		assert.ErrorIs(t, err, ErrPermissionDenied, name)
+	}
+
+	// 无法解析的循环链接按所在目录判断，访问时由登录节点报错
+	_, err = p.Resolve(fs, "/scratch/alice/loop", true)
+	assert.NoError(t, err)
+
+	// 悬空链接按链接目标判断，不能经由它在允许的目录以外创建文件，但可以删除链接本身
+	for _, name := range []string{"/scratch/alice/dangling", "/scratch/alice/dangling/x"} {
+		_, err = p.Resolve(fs, name, true)
+		assert.ErrorIs(t, err, ErrPermissionDenied, name)
+	}
+	_, err = p.Resolve(fs, "/scratch/alice/dangling/x", false)
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+	resolved, err = p.Resolve(fs, "/scratch/alice/dangling", false)
+	require.NoError(t, err)
+	assert.Equal(t, "/scratch/alice/dangling", resolved)
+	resolved, err = p.Resolve(fs, "/scratch/alice/pending", true)
+	require.NoError(t, err)
+	assert.Equal(t, "/scratch/alice/results/out.log", resolved)
+
+	_, err = p.Resolve(fs, "/etc/passwd", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/etc/passwd is outside the allowed directories (/gpfs/home/alice, /scratch/alice, /gpfs/home/shared)")
+}
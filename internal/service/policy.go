@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"star-dim/internal/models"
+)
+
+var (
+	ErrPermissionDenied    = errors.New("permission denied")
+	ErrJobSelectorRequired = errors.New("job ids or filters are required")
+)
+
+// Principal 发起操作的门户用户及其在集群中的角色
+type Principal struct {
+	User string
+	Role string
+	// Projects 项目管理员管理的 Slurm 账户
+	Projects []string
+	Policy   *models.AccessPolicy
+}
+
+func NewPrincipal(user string, policy *models.AccessPolicy) *Principal {
+	role, projects := policy.Resolve(user)
+	return &Principal{User: user, Role: role, Projects: projects, Policy: policy}
+}
+
+// HasRole 判断是否具有任一指定角色
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// manages 判断项目管理员是否管理全部指定账户
+func (p *Principal) manages(accounts ...string) bool {
+	if len(accounts) == 0 {
+		return false
+	}
+	for _, account := range accounts {
+		found := false
+		for _, project := range p.Projects {
+			if project == account {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func denied(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrPermissionDenied, fmt.Sprintf(format, args...))
+}
+
+// hasJobSelector 是否指定了作业 ID 或过滤条件
+func hasJobSelector(req *models.ScancelRequest) bool {
+	return len(req.JobIDs) > 0 || req.Account != "" || req.Name != "" || req.Partition != "" || req.QOS != "" ||
+		req.Reservation != "" || req.State != "" || req.NodeList != "" || req.WCKey != "" || req.User != ""
+}
+
+// AuthorizeCancel 校验 scancel 请求。必须指定作业 ID 或过滤条件，否则限定为本人后会取消本人的全部作业；
+// 普通用户只能取消自己的作业，未指定用户时限定为本人；项目管理员可以取消所管理账户下任意用户的作业。
+func (p *Principal) AuthorizeCancel(req *models.ScancelRequest) error {
+	if !hasJobSelector(req) {
+		return ErrJobSelectorRequired
+	}
+	if p.Role == models.RoleClusterAdmin {
+		return nil
+	}
+	if p.Role == models.RoleProjectAdmin && req.Account != "" && p.manages(req.Account) {
+		return nil
+	}
+	if req.User != "" && req.User != p.User {
+		return denied("cannot cancel jobs of user %s", req.User)
+	}
+	req.User = p.User
+	return nil
+}
+
+// AuthorizeAccounting 校验 sacct 请求。查询其他用户的作业需要集群开启 view_all_jobs，
+// 或者是集群管理员，或者是限定在所管理账户内的项目管理员。
+func (p *Principal) AuthorizeAccounting(req *models.SacctRequest) error {
+	others := req.AllUsers
+	for _, user := range req.Users {
+		if user != p.User {
+			others = true
+		}
+	}
+	if !others || p.Role == models.RoleClusterAdmin || (p.Policy != nil && p.Policy.ViewAllJobs) {
+		return nil
+	}
+	if p.Role == models.RoleProjectAdmin && p.manages(req.Accounts...) {
+		return nil
+	}
+	return denied("cannot query jobs of other users")
+}
+
+// AuthorizeChown 只有集群管理员可以修改文件属主
+func (p *Principal) AuthorizeChown() error {
+	if p.Role == models.RoleClusterAdmin {
+		return nil
+	}
+	return denied("only cluster admins can change file owners")
+}
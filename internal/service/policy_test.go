@@ -0,0 +1,87 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+)
+
+var testPolicy = &models.AccessPolicy{
+	ClusterAdmins: []string{"root"},
+	ProjectAdmins: map[string][]string{"proj1": {"pi"}, "proj2": {"pi", "bob"}},
+}
+
+func TestNewPrincipal(t *testing.T) {
+	assert.Equal(t, models.RoleClusterAdmin, NewPrincipal("root", testPolicy).Role)
+	pi := NewPrincipal("pi", testPolicy)
+	assert.Equal(t, models.RoleProjectAdmin, pi.Role)
+	assert.ElementsMatch(t, []string{"proj1", "proj2"}, pi.Projects)
+	assert.Equal(t, models.RoleUser, NewPrincipal("alice", testPolicy).Role)
+	assert.Equal(t, models.RoleUser, NewPrincipal("root", nil).Role)
+}
+
+func TestAuthorizeCancel(t *testing.T) {
+	alice := NewPrincipal("alice", testPolicy)
+	pi := NewPrincipal("pi", testPolicy)
+	root := NewPrincipal("root", testPolicy)
+
+	// 未指定作业 ID 和过滤条件时拒绝，避免取消本人的全部作业
+	for _, p := range []*Principal{alice, pi, root} {
+		req := &models.ScancelRequest{Signal: "TERM", Full: true}
+		assert.ErrorIs(t, p.AuthorizeCancel(req), ErrJobSelectorRequired, p.User)
+		assert.Empty(t, req.User)
+	}
+
+	// 普通用户限定为本人的作业
+	req := &models.ScancelRequest{JobIDs: []string{"100"}}
+	require.NoError(t, alice.AuthorizeCancel(req))
+	assert.Equal(t, "alice", req.User)
+	req = &models.ScancelRequest{State: "PENDING"}
+	require.NoError(t, alice.AuthorizeCancel(req))
+	assert.Equal(t, "alice", req.User)
+	// 明确指定本人时取消本人的全部作业
+	require.NoError(t, alice.AuthorizeCancel(&models.ScancelRequest{User: "alice"}))
+	assert.ErrorIs(t, alice.AuthorizeCancel(&models.ScancelRequest{User: "bob"}), ErrPermissionDenied)
+
+	// 项目管理员只能取消所管理账户下其他用户的作业
+	req = &models.ScancelRequest{Account: "proj1", User: "bob"}
+	require.NoError(t, pi.AuthorizeCancel(req))
+	assert.Equal(t, "bob", req.User)
+	assert.ErrorIs(t, pi.AuthorizeCancel(&models.ScancelRequest{Account: "proj3", User: "bob"}), ErrPermissionDenied)
+	req = &models.ScancelRequest{Account: "proj3"}
+	require.NoError(t, pi.AuthorizeCancel(req))
+	assert.Equal(t, "pi", req.User)
+
+	// 集群管理员不受限制
+	req = &models.ScancelRequest{Partition: "gpu"}
+	require.NoError(t, root.AuthorizeCancel(req))
+	assert.Empty(t, req.User)
+}
+
+func TestAuthorizeAccounting(t *testing.T) {
+	alice := NewPrincipal("alice", testPolicy)
+	pi := NewPrincipal("pi", testPolicy)
+
+	assert.NoError(t, alice.AuthorizeAccounting(&models.SacctRequest{}))
+	assert.NoError(t, alice.AuthorizeAccounting(&models.SacctRequest{Users: []string{"alice"}}))
+	assert.ErrorIs(t, alice.AuthorizeAccounting(&models.SacctRequest{Users: []string{"bob"}}), ErrPermissionDenied)
+	assert.ErrorIs(t, alice.AuthorizeAccounting(&models.SacctRequest{AllUsers: true}), ErrPermissionDenied)
+	assert.NoError(t, NewPrincipal("root", testPolicy).AuthorizeAccounting(&models.SacctRequest{AllUsers: true}))
+
+	// 项目管理员查询其他用户时必须限定在所管理的账户内
+	assert.NoError(t, pi.AuthorizeAccounting(&models.SacctRequest{AllUsers: true, Accounts: []string{"proj1", "proj2"}}))
+	assert.ErrorIs(t, pi.AuthorizeAccounting(&models.SacctRequest{AllUsers: true, Accounts: []string{"proj1", "proj3"}}), ErrPermissionDenied)
+	assert.ErrorIs(t, pi.AuthorizeAccounting(&models.SacctRequest{AllUsers: true}), ErrPermissionDenied)
+
+	// 集群开启 view_all_jobs 时普通用户可以查询所有作业
+	open := NewPrincipal("alice", &models.AccessPolicy{ViewAllJobs: true})
+	assert.NoError(t, open.AuthorizeAccounting(&models.SacctRequest{AllUsers: true}))
+}
+
+func TestAuthorizeChown(t *testing.T) {
+	assert.NoError(t, NewPrincipal("root", testPolicy).AuthorizeChown())
+	assert.ErrorIs(t, NewPrincipal("pi", testPolicy).AuthorizeChown(), ErrPermissionDenied)
+	assert.ErrorIs(t, NewPrincipal("alice", testPolicy).AuthorizeChown(), ErrPermissionDenied)
+}
@@ -2,8 +2,8 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"golang.org/x/crypto/ssh"
 	"log"
 	"path/filepath"
 	"star-dim/internal/models"
@@ -11,15 +11,31 @@ import (
 	"time"
 )
 
+// SlurmBackend 执行 Slurm 查询和作业提交。SlurmService 在登录节点上执行命令并解析输出，
+// RestSlurmService 调用 slurmrestd 的 OpenAPI，二者返回相同的响应结构
+type SlurmBackend interface {
+	ExecuteSacct(ctx context.Context, req *models.SacctRequest) models.SacctResponse
+	ExecuteSqueue(ctx context.Context, req models.SqueueRequest) models.SqueueResponse
+	ExecuteSinfo(ctx context.Context, req models.SinfoRequest) models.SinfoResponse
+	ExecuteSbatch(ctx context.Context, req *models.SbatchRequest) *models.SbatchResponse
+	// ExecuteSbatchWithUpload 提交客户端上传的作业脚本
+	ExecuteSbatchWithUpload(ctx context.Context, req *models.SbatchRequest, filename string, script []byte) *models.SbatchResponse
+}
+
 type SlurmService struct {
-	sshClient *ssh.Client
-	parser    *utils.SlurmParser
+	executor RemoteExecutor
+	parser   *utils.SlurmParser
+	// json 和 cluster 用于判断集群是否支持 --json 输出，json 为 nil 时只使用文本输出
+	json    *JSONSupport
+	cluster string
+	// profiles 为 nil 时不按 Slurm 版本检查请求选项
+	profiles *SlurmProfiles
 }
 
-func NewSlurmService(sshClient *ssh.Client, parser *utils.SlurmParser) *SlurmService {
+func NewSlurmService(executor RemoteExecutor, parser *utils.SlurmParser) *SlurmService {
 	return &SlurmService{
-		sshClient: sshClient,
-		parser:    parser,
+		executor: executor,
+		parser:   parser,
 	}
 }
 
@@ -27,41 +43,33 @@ func (s *SlurmService) SetParser(parser *utils.SlurmParser) {
 	s.parser = parser
 }
 
-func (s *SlurmService) SSHClient() *ssh.Client {
-	return s.sshClient
+func (s *SlurmService) Executor() RemoteExecutor {
+	return s.executor
 }
 
-// ExecuteSacct 执行 sacct 命令
-func (s *SlurmService) ExecuteSacct(req *models.SacctRequest) models.SacctResponse {
-	command := s.parser.BuildSacctCommand(req)
-	session, err := s.sshClient.NewSession()
-	if err != nil {
-		return models.SacctResponse{
-			Success:   "no",
-			Message:   err.Error(),
-			Data:      nil,
-			Total:     0,
-			Command:   "",
-			RawOutput: "",
+// ExecuteSacct 执行 sacct 命令，集群支持时使用 --json 输出
+func (s *SlurmService) ExecuteSacct(ctx context.Context, req *models.SacctRequest) models.SacctResponse {
+	profile := s.profile(ctx)
+	if err := CheckSacct(profile, req); err != nil {
+		return models.SacctResponse{Success: "no", Message: err.Error(), Err: err}
+	}
+	if s.useJSON(profile, "sacct", req.JSON, "") {
+		if response, ok := s.executeSacctJSON(ctx, req); ok {
+			return response
 		}
 	}
-	defer session.Close()
-
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	session.Stdout = &stdout
-	session.Stderr = &stderr
-	err = session.Run(command)
-
+	command := s.parser.BuildSacctCommand(req)
+	result, err := Exec(ctx, s.executor, command)
 	if err != nil {
 		return models.SacctResponse{
 			Success:   "no",
 			Message:   "Failed to execute sacct command: " + err.Error(),
 			Command:   command,
-			RawOutput: stdout.String(),
+			RawOutput: rawOutput(result),
+			Err:       err,
 		}
 	}
-	output := stdout.String()
+	output := string(result.Stdout)
 	jobs, err := s.parser.ParseSacctOutput(output, req.Format)
 	if err != nil {
 		return models.SacctResponse{
@@ -69,6 +77,7 @@ func (s *SlurmService) ExecuteSacct(req *models.SacctRequest) models.SacctRespon
 			Message:   "Failed to parse sacct output: " + err.Error(),
 			Command:   command,
 			RawOutput: output,
+			Err:       err,
 		}
 	}
 
@@ -81,25 +90,143 @@ func (s *SlurmService) ExecuteSacct(req *models.SacctRequest) models.SacctRespon
 	}
 }
 
-func (s *SlurmService) ExecuteSbatch(req *models.SbatchRequest) *models.SbatchResponse {
+// ExecuteSqueue 执行 squeue 命令，集群支持时使用 --json 输出
+func (s *SlurmService) ExecuteSqueue(ctx context.Context, req models.SqueueRequest) models.SqueueResponse {
+	profile := s.profile(ctx)
+	warnings, err := CheckSqueue(profile, &req)
+	if err != nil {
+		return models.SqueueResponse{Success: "no", Message: err.Error(), Err: err}
+	}
+	response := s.executeSqueue(ctx, profile, req)
+	response.Warnings = warnings
+	return response
+}
+
+func (s *SlurmService) executeSqueue(ctx context.Context, profile *models.SlurmProfile, req models.SqueueRequest) models.SqueueResponse {
+	if s.useJSON(profile, "squeue", req.JSON, req.YAML) {
+		if response, ok := s.executeSqueueJSON(ctx, req); ok {
+			return response
+		}
+	}
+	command := s.parser.BuildSqueueCommand(req)
+	result, err := Exec(ctx, s.executor, command)
+	if err != nil {
+		return models.SqueueResponse{
+			Success:   "no",
+			Message:   "Failed to execute squeue command: " + err.Error(),
+			Command:   command,
+			RawOutput: rawOutput(result),
+			Err:       err,
+		}
+	}
+	output := string(result.Stdout)
+	var jobs []models.QueueJobInfo
+	if req.Long {
+		// 详细输出解析
+		jobs, err = s.parser.ParseSqueueDetailedOutput(output)
+	} else {
+		// 标准输出解析
+		jobs, err = s.parser.ParseSqueueOutput(output, !req.NoHeader)
+	}
+	if err != nil {
+		return models.SqueueResponse{
+			Success:   "no",
+			Message:   "Failed to parse squeue output: " + err.Error(),
+			Command:   command,
+			RawOutput: output,
+			Err:       err,
+		}
+	}
+
+	return models.SqueueResponse{
+		Success: "yes",
+		Data:    jobs,
+		Total:   len(jobs),
+		Command: command,
+	}
+}
+
+// ExecuteSinfo 执行 sinfo 命令，集群支持时使用 --json 输出
+func (s *SlurmService) ExecuteSinfo(ctx context.Context, req models.SinfoRequest) models.SinfoResponse {
+	profile := s.profile(ctx)
+	if err := CheckSinfo(profile, &req); err != nil {
+		return models.SinfoResponse{Success: "no", Message: err.Error(), Err: err}
+	}
+	// sinfo -T 不支持 JSON 输出
+	if !req.Reservation && s.useJSON(profile, "sinfo", req.JSON, req.YAML) {
+		if response, ok := s.executeSinfoJSON(ctx, req); ok {
+			return response
+		}
+	}
+	command := s.parser.BuildSinfoCommand(req)
+	result, err := Exec(ctx, s.executor, command)
+	if err != nil {
+		return models.SinfoResponse{
+			Success:   "no",
+			Message:   "Failed to execute sinfo command: " + err.Error(),
+			Command:   command,
+			RawOutput: rawOutput(result),
+			Err:       err,
+		}
+	}
+	output := string(result.Stdout)
+	data, err := s.parser.ParseSinfoOutput(output, req)
+	if err != nil {
+		return models.SinfoResponse{
+			Success:   "no",
+			Message:   "Failed to parse sinfo output: " + err.Error(),
+			Command:   command,
+			RawOutput: output,
+			Err:       err,
+		}
+	}
+
+	return models.SinfoResponse{
+		Success: "yes",
+		Data:    data,
+		Total:   dataTotal(data),
+		Command: command,
+	}
+}
+
+// dataTotal 计算 sinfo 结果的记录数
+func dataTotal(data interface{}) int {
+	switch v := data.(type) {
+	case []models.NodeInfo:
+		return len(v)
+	case []models.NodeSummary:
+		return len(v)
+	case []models.ReservationInfo:
+		return len(v)
+	}
+	return 0
+}
+
+func (s *SlurmService) ExecuteSbatch(ctx context.Context, req *models.SbatchRequest) *models.SbatchResponse {
+	if err := CheckSbatch(s.profile(ctx), req); err != nil {
+		return &models.SbatchResponse{Success: "no", Message: err.Error(), Err: err}
+	}
 	command, err := s.parser.BuildSbatchCommand(req)
-	session, err := s.sshClient.NewSession()
 	if err != nil {
 		return &models.SbatchResponse{
 			Success: "no",
-			Message: "Failed to execute sbatch command: " + err.Error(),
+			Message: "Failed to build sbatch command: " + err.Error(),
+			Err:     err,
 		}
 	}
-	defer session.Close()
-
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	session.Stdout = &stdout
-	session.Stderr = &stderr
-	err = session.Run(command)
+	result, err := Exec(ctx, s.executor, command)
 	log.Println("sbatch command:", command)
+	if err != nil {
+		return &models.SbatchResponse{
+			Success:   "no",
+			Message:   "Failed to execute sbatch command: " + err.Error(),
+			Command:   command,
+			RawOutput: rawOutput(result),
+			Err:       err,
+		}
+	}
 	// 解析输出
-	output := stdout.String()
+	output := string(result.Stdout)
 	response, err := s.parser.ParseSbatchOutput(output)
 	if err != nil {
 		return &models.SbatchResponse{
@@ -107,6 +234,7 @@ func (s *SlurmService) ExecuteSbatch(req *models.SbatchRequest) *models.SbatchRe
 			Message:   "Failed to parse sbatch output: " + err.Error(),
 			Command:   command,
 			RawOutput: output,
+			Err:       err,
 		}
 	}
 
@@ -114,69 +242,58 @@ func (s *SlurmService) ExecuteSbatch(req *models.SbatchRequest) *models.SbatchRe
 	return response
 }
 
-// ExecuteSbatchWithUpload 执行带脚本上传的 sbatch 命令
-func (s *SlurmService) ExecuteSbatchWithUpload(req *models.SbatchRequest, filename string) *models.SbatchResponse {
-	session, err := s.sshClient.NewSession()
-	if err != nil {
-		return &models.SbatchResponse{
-			Success: "no",
-			Message: "Failed to execute sbatch command: " + err.Error(),
-		}
+// ExecuteSbatchWithUpload 将脚本上传到登录节点的临时文件后执行 sbatch 命令，提交后删除临时文件
+func (s *SlurmService) ExecuteSbatchWithUpload(ctx context.Context, req *models.SbatchRequest, filename string, script []byte) *models.SbatchResponse {
+	if err := CheckSbatch(s.profile(ctx), req); err != nil {
+		return &models.SbatchResponse{Success: "no", Message: err.Error(), Err: err}
 	}
 	// 生成临时脚本文件路径
 	timestamp := time.Now().Format("20060102_150405")
-	scriptPath := filepath.Join("/tmp", fmt.Sprintf("sbatch_script_%s_%s", timestamp, filename))
+	scriptPath := filepath.Join("/tmp", fmt.Sprintf("sbatch_script_%s_%s", timestamp, filepath.Base(filename)))
 
 	// 上传脚本文件
-	//uploadCommand := fmt.Sprintf("cat > %s << 'EOF'\n%s\nEOF", scriptPath, req.Script)
-	var stdout bytes.Buffer
-	//var stderr bytes.Buffer
-	//session.Stdout = &stdout
-	//session.Stderr = &stderr
-	//err = session.Run(uploadCommand)
-	//if err != nil {
-	//	return &models.SbatchResponse{
-	//		Success: "no",
-	//		Message: "Failed to upload script file: " + err.Error(),
-	//	}
-	//}
-
-	chmodCommand := fmt.Sprintf("chmod +x %s", scriptPath)
-	err = session.Run(chmodCommand)
+	uploadCommand := fmt.Sprintf("cat > %s && chmod +x %s", utils.Quote(scriptPath), utils.Quote(scriptPath))
+	_, err := s.executor.Run(ctx, &Command{Cmd: uploadCommand, Stdin: bytes.NewReader(script)})
 	if err != nil {
 		return &models.SbatchResponse{
 			Success: "no",
-			Message: "Failed to set script permissions: " + err.Error(),
+			Message: "Failed to upload script file: " + err.Error(),
+			Err:     err,
 		}
 	}
+	// 清理临时文件
+	defer func() {
+		// 请求取消或超时后仍需删除临时文件
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if _, err := Exec(cleanupCtx, s.executor, utils.NewCommand("rm", "-f", scriptPath).String()); err != nil {
+			log.Printf("remove sbatch script %s: %v", scriptPath, err)
+		}
+	}()
 
 	req.ScriptFile = scriptPath
 	command, err := s.parser.BuildSbatchCommand(req)
 	if err != nil {
-		// 清理临时文件
-		err = session.Run(fmt.Sprintf("rm -f %s", scriptPath))
 		return &models.SbatchResponse{
 			Success: "no",
 			Message: "Failed to build sbatch command: " + err.Error(),
+			Err:     err,
 		}
 	}
 
-	err = session.Run(command)
-	// 清理临时文件
-	cleanupCommand := fmt.Sprintf("rm -f %s", scriptPath)
-	err = session.Run(cleanupCommand)
-
-	var output = stdout.String()
+	result, err := Exec(ctx, s.executor, command)
 	if err != nil {
 		return &models.SbatchResponse{
 			Success:   "no",
 			Message:   "Failed to execute sbatch command: " + err.Error(),
 			Command:   command,
-			RawOutput: output,
+			RawOutput: rawOutput(result),
+			Err:       err,
 		}
 	}
 
 	// 解析输出
+	output := string(result.Stdout)
 	response, err := s.parser.ParseSbatchOutput(output)
 	if err != nil {
 		return &models.SbatchResponse{
@@ -184,9 +301,18 @@ func (s *SlurmService) ExecuteSbatchWithUpload(req *models.SbatchRequest, filena
 			Message:   "Failed to parse sbatch output: " + err.Error(),
 			Command:   command,
 			RawOutput: output,
+			Err:       err,
 		}
 	}
 
 	response.Command = command
 	return response
 }
+
+// rawOutput 返回执行失败时命令的全部输出
+func rawOutput(result *Result) string {
+	if result == nil {
+		return ""
+	}
+	return string(result.Stdout) + string(result.Stderr)
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"star-dim/internal/models"
+	"star-dim/internal/utils"
+)
+
+// JSONSupport 记录各集群的 Slurm 命令是否支持 --json 输出。未知时先以 JSON 模式执行，
+// 命令不认识 --json 或缺少 data_parser 插件时回退到文本输出，并记住该集群的命令不支持
+type JSONSupport struct {
+	mu sync.RWMutex
+	// unsupported 键为 集群名/命令名
+	unsupported map[string]bool
+}
+
+func NewJSONSupport() *JSONSupport {
+	return &JSONSupport{unsupported: make(map[string]bool)}
+}
+
+// Enabled 判断集群的命令是否使用 JSON 输出，j 为 nil 时始终使用文本输出
+func (j *JSONSupport) Enabled(cluster, command string) bool {
+	if j == nil {
+		return false
+	}
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return !j.unsupported[cluster+"/"+command]
+}
+
+// Disable 记录集群的命令不支持 JSON 输出
+func (j *JSONSupport) Disable(cluster, command string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.unsupported[cluster+"/"+command] = true
+}
+
+// WithJSON 在集群支持时使用 --json 输出并解析，不支持时回退到文本输出
+func (s *SlurmService) WithJSON(support *JSONSupport, cluster string) *SlurmService {
+	s.json = support
+	s.cluster = cluster
+	return s
+}
+
+// useJSON 判断是否以 JSON 模式执行命令，请求显式指定 --json 时始终使用，
+// 已知集群的 Slurm 版本不支持 --json 时不再尝试
+func (s *SlurmService) useJSON(profile *models.SlurmProfile, name, requested, yaml string) bool {
+	return requested != "" || (yaml == "" && supports(profile, "json") && s.json.Enabled(s.cluster, name))
+}
+
+// runJSON 以 JSON 模式执行命令。命令不支持 JSON 输出时记录下来并返回 false，调用方回退到文本输出；
+// 请求显式指定了 --json 时不回退
+func (s *SlurmService) runJSON(ctx context.Context, name, command string, explicit bool) (*Result, bool, error) {
+	result, err := Exec(ctx, s.executor, command)
+	if explicit || !jsonUnsupported(result, err) {
+		return result, true, err
+	}
+	s.json.Disable(s.cluster, name)
+	return nil, false, nil
+}
+
+// jsonUnsupported 判断命令是否因不支持 --json 而失败，如 unrecognized option '--json'
+// 或 serializer/json 插件加载失败
+func jsonUnsupported(result *Result, err error) bool {
+	var exit *ExitError
+	if errors.As(err, &exit) {
+		return strings.Contains(strings.ToLower(exit.Stderr), "json")
+	}
+	return err == nil && result != nil && !utils.IsJSONOutput(result.Stdout)
+}
+
+// executeSqueueJSON 以 JSON 模式执行 squeue，返回 false 时回退到文本输出
+func (s *SlurmService) executeSqueueJSON(ctx context.Context, req models.SqueueRequest) (models.SqueueResponse, bool) {
+	jsonReq := req
+	if jsonReq.JSON == "" {
+		jsonReq.JSON = "default"
+	}
+	command := s.parser.BuildSqueueCommand(jsonReq)
+	result, ok, err := s.runJSON(ctx, "squeue", command, req.JSON != "")
+	if !ok {
+		return models.SqueueResponse{}, false
+	}
+	if err != nil {
+		return models.SqueueResponse{
+			Success:   "no",
+			Message:   "Failed to execute squeue command: " + err.Error(),
+			Command:   command,
+			RawOutput: rawOutput(result),
+			Err:       err,
+		}, true
+	}
+	jobs, err := s.parser.ParseSqueueJSON(result.Stdout, req, time.Now())
+	if err != nil {
+		return models.SqueueResponse{
+			Success:   "no",
+			Message:   "Failed to parse squeue output: " + err.Error(),
+			Command:   command,
+			RawOutput: string(result.Stdout),
+			Err:       err,
+		}, true
+	}
+	return models.SqueueResponse{Success: "yes", Data: jobs, Total: len(jobs), Command: command}, true
+}
+
+// executeSinfoJSON 以 JSON 模式执行 sinfo，返回 false 时回退到文本输出
+func (s *SlurmService) executeSinfoJSON(ctx context.Context, req models.SinfoRequest) (models.SinfoResponse, bool) {
+	jsonReq := req
+	if jsonReq.JSON == "" {
+		jsonReq.JSON = "default"
+	}
+	command := s.parser.BuildSinfoCommand(jsonReq)
+	result, ok, err := s.runJSON(ctx, "sinfo", command, req.JSON != "")
+	if !ok {
+		return models.SinfoResponse{}, false
+	}
+	if err != nil {
+		return models.SinfoResponse{
+			Success:   "no",
+			Message:   "Failed to execute sinfo command: " + err.Error(),
+			Command:   command,
+			RawOutput: rawOutput(result),
+			Err:       err,
+		}, true
+	}
+	data, err := s.parser.ParseSinfoJSON(result.Stdout, req)
+	if err != nil {
+		return models.SinfoResponse{
+			Success:   "no",
+			Message:   "Failed to parse sinfo output: " + err.Error(),
+			Command:   command,
+			RawOutput: string(result.Stdout),
+			Err:       err,
+		}, true
+	}
+	return models.SinfoResponse{Success: "yes", Data: data, Total: dataTotal(data), Command: command}, true
+}
+
+// executeSacctJSON 以 JSON 模式执行 sacct，返回 false 时回退到文本输出
+func (s *SlurmService) executeSacctJSON(ctx context.Context, req *models.SacctRequest) (models.SacctResponse, bool) {
+	jsonReq := *req
+	if jsonReq.JSON == "" {
+		jsonReq.JSON = "default"
+	}
+	command := s.parser.BuildSacctCommand(&jsonReq)
+	result, ok, err := s.runJSON(ctx, "sacct", command, req.JSON != "")
+	if !ok {
+		return models.SacctResponse{}, false
+	}
+	if err != nil {
+		return models.SacctResponse{
+			Success:   "no",
+			Message:   "Failed to execute sacct command: " + err.Error(),
+			Command:   command,
+			RawOutput: rawOutput(result),
+			Err:       err,
+		}, true
+	}
+	jobs, err := s.parser.ParseSacctJSON(result.Stdout, req)
+	if err != nil {
+		return models.SacctResponse{
+			Success:   "no",
+			Message:   "Failed to parse sacct output: " + err.Error(),
+			Command:   command,
+			RawOutput: string(result.Stdout),
+			Err:       err,
+		}, true
+	}
+	return models.SacctResponse{Success: "yes", Data: jobs, Total: len(jobs), Command: command}, true
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"star-dim/internal/models"
+)
+
+// slurmVersion Slurm 版本号，如 23.02.6
+type slurmVersion [3]int
+
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// parseSlurmVersion 从 sinfo --version 等输出中提取版本号，如 slurm 23.02.6、slurm-wlm 21.08.5
+func parseSlurmVersion(s string) (slurmVersion, bool) {
+	match := versionPattern.FindStringSubmatch(s)
+	if match == nil {
+		return slurmVersion{}, false
+	}
+	var v slurmVersion
+	for i := range v {
+		v[i], _ = strconv.Atoi(match[i+1])
+	}
+	return v, true
+}
+
+func (v slurmVersion) less(o slurmVersion) bool {
+	for i := range v {
+		if v[i] != o[i] {
+			return v[i] < o[i]
+		}
+	}
+	return false
+}
+
+func (v slurmVersion) String() string {
+	return fmt.Sprintf("%d.%02d", v[0], v[1])
+}
+
+// slurmFeatures 随 Slurm 版本变化的命令选项，name 与请求字段名一致
+var slurmFeatures = []struct {
+	name   string
+	option string
+	since  slurmVersion
+}{
+	{"sibling", "--sibling", slurmVersion{17, 11}},
+	{"cron", "--cron", slurmVersion{20, 11}},
+	{"json", "--json", slurmVersion{21, 8}},
+	{"yaml", "--yaml", slurmVersion{21, 8}},
+	{"only_job_state", "--only-job-state", slurmVersion{23, 2}},
+	{"tres_bind", "--tres-bind", slurmVersion{23, 11}},
+}
+
+// profileConfig 探测时保留的 scontrol show config 配置项
+var profileConfig = []string{
+	"ClusterName", "SLURM_VERSION", "SelectType", "SelectTypeParameters", "SchedulerType",
+	"PriorityType", "AccountingStorageType", "MaxArraySize", "MaxJobCount",
+}
+
+// UnsupportedError 集群的 Slurm 版本不支持请求的选项，errors.Is 时等同于 ErrUnsupported
+type UnsupportedError struct {
+	Option  string
+	Since   string // 支持该选项的最低版本
+	Cluster string
+	Version string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%s requires Slurm %s or later, cluster %s runs %s", e.Option, e.Since, e.Cluster, e.Version)
+}
+
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+// DetectSlurmProfile 执行 sinfo --version 和 scontrol show config 探测集群的 Slurm 版本，
+// sinfo 失败时使用配置中的 SLURM_VERSION
+func DetectSlurmProfile(ctx context.Context, executor RemoteExecutor, cluster string) (*models.SlurmProfile, error) {
+	config := make(map[string]string)
+	result, configErr := Exec(ctx, executor, "scontrol show config")
+	if configErr == nil {
+		config = parseSlurmConfig(result.Stdout)
+	}
+	version, ok := parseSlurmVersion(config["SLURM_VERSION"])
+	result, err := Exec(ctx, executor, "sinfo --version")
+	if err == nil {
+		if v, found := parseSlurmVersion(string(result.Stdout)); found {
+			version, ok = v, true
+		}
+	}
+	if !ok {
+		if err = errors.Join(err, configErr); err == nil {
+			err = errors.New("no version in sinfo --version output")
+		}
+		return nil, fmt.Errorf("detect slurm version: %w", err)
+	}
+
+	profile := &models.SlurmProfile{
+		Cluster:    cluster,
+		Version:    fmt.Sprintf("%d.%02d.%d", version[0], version[1], version[2]),
+		Features:   make(map[string]bool, len(slurmFeatures)),
+		Config:     config,
+		DetectedAt: time.Now(),
+	}
+	for _, feature := range slurmFeatures {
+		profile.Features[feature.name] = !version.less(feature.since)
+	}
+	return profile, nil
+}
+
+// parseSlurmConfig 解析 scontrol show config 输出中 profileConfig 列出的配置项
+func parseSlurmConfig(output []byte) map[string]string {
+	config := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		for _, name := range profileConfig {
+			if key == name {
+				config[key] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return config
+}
+
+// SlurmProfiles 缓存各集群的 Slurm 版本信息。首次使用集群或缓存过期时探测，
+// 探测失败后一分钟内不再重试，期间不检查请求选项
+type SlurmProfiles struct {
+	mu      sync.Mutex
+	entries map[string]*profileEntry
+	ttl     time.Duration
+}
+
+type profileEntry struct {
+	mu       sync.Mutex
+	profile  *models.SlurmProfile
+	err      error
+	failedAt time.Time
+}
+
+// profileRetry 探测失败后重试的间隔
+const profileRetry = time.Minute
+
+// NewSlurmProfiles ttl 为缓存的有效期，0 表示不过期
+func NewSlurmProfiles(ttl time.Duration) *SlurmProfiles {
+	return &SlurmProfiles{entries: make(map[string]*profileEntry), ttl: ttl}
+}
+
+// Get 返回集群的 Slurm 版本信息，需要探测时在 executor 上执行命令。同一集群同时只探测一次
+func (p *SlurmProfiles) Get(ctx context.Context, cluster string, executor RemoteExecutor) (*models.SlurmProfile, error) {
+	p.mu.Lock()
+	entry, ok := p.entries[cluster]
+	if !ok {
+		entry = &profileEntry{}
+		p.entries[cluster] = entry
+	}
+	p.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.profile != nil && (p.ttl == 0 || time.Since(entry.profile.DetectedAt) < p.ttl) {
+		return entry.profile, nil
+	}
+	if entry.err != nil && time.Since(entry.failedAt) < profileRetry {
+		return nil, entry.err
+	}
+	profile, err := DetectSlurmProfile(ctx, executor, cluster)
+	if err != nil {
+		log.Printf("cluster %s: %v", cluster, err)
+		entry.err, entry.failedAt = err, time.Now()
+		return nil, err
+	}
+	entry.profile, entry.err = profile, nil
+	return profile, nil
+}
+
+// Forget 清除集群的缓存，下次使用时重新探测
+func (p *SlurmProfiles) Forget(cluster string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.entries, cluster)
+}
+
+// supports 判断集群是否支持选项，版本未知时视为支持
+func supports(profile *models.SlurmProfile, name string) bool {
+	if profile == nil {
+		return true
+	}
+	supported, ok := profile.Features[name]
+	return !ok || supported
+}
+
+// checkFeatures 返回第一个集群不支持的选项的错误
+func checkFeatures(profile *models.SlurmProfile, names ...string) error {
+	for _, feature := range slurmFeatures {
+		for _, name := range names {
+			if feature.name == name && !supports(profile, name) {
+				return &UnsupportedError{Option: feature.option, Since: feature.since.String(), Cluster: profile.Cluster, Version: profile.Version}
+			}
+		}
+	}
+	return nil
+}
+
+// outputFeatures 返回请求使用的 JSON、YAML 输出选项
+func outputFeatures(json, yaml string) []string {
+	var names []string
+	if json != "" {
+		names = append(names, "json")
+	}
+	if yaml != "" {
+		names = append(names, "yaml")
+	}
+	return names
+}
+
+// CheckSqueue 检查 squeue 请求中随版本变化的选项。--only-job-state 只改变 squeue 的查询方式，
+// 不支持时忽略并返回提示，其余不支持的选项返回 *UnsupportedError
+func CheckSqueue(profile *models.SlurmProfile, req *models.SqueueRequest) ([]string, error) {
+	var warnings []string
+	if req.OnlyJobState && !supports(profile, "only_job_state") {
+		req.OnlyJobState = false
+		warnings = append(warnings, fmt.Sprintf("only_job_state ignored: not supported by Slurm %s", profile.Version))
+	}
+	names := outputFeatures(req.JSON, req.YAML)
+	if req.Sibling {
+		names = append(names, "sibling")
+	}
+	return warnings, checkFeatures(profile, names...)
+}
+
+// CheckSinfo 检查 sinfo 请求中随版本变化的选项
+func CheckSinfo(profile *models.SlurmProfile, req *models.SinfoRequest) error {
+	return checkFeatures(profile, outputFeatures(req.JSON, req.YAML)...)
+}
+
+// CheckSacct 检查 sacct 请求中随版本变化的选项
+func CheckSacct(profile *models.SlurmProfile, req *models.SacctRequest) error {
+	return checkFeatures(profile, outputFeatures(req.JSON, "")...)
+}
+
+// CheckSbatch 检查 sbatch 请求中随版本变化的选项
+func CheckSbatch(profile *models.SlurmProfile, req *models.SbatchRequest) error {
+	if req.TRESBind != "" {
+		return checkFeatures(profile, "tres_bind")
+	}
+	return nil
+}
+
+// CheckScancel 检查 scancel 请求中随版本变化的选项
+func CheckScancel(profile *models.SlurmProfile, req *models.ScancelRequest) error {
+	var names []string
+	if req.Cron {
+		names = append(names, "cron")
+	}
+	if req.Sibling != "" {
+		names = append(names, "sibling")
+	}
+	return checkFeatures(profile, names...)
+}
+
+// WithProfiles 执行命令前按集群的 Slurm 版本检查请求选项，版本未知时不检查
+func (s *SlurmService) WithProfiles(profiles *SlurmProfiles, cluster string) *SlurmService {
+	s.profiles = profiles
+	s.cluster = cluster
+	return s
+}
+
+// profile 返回集群的 Slurm 版本信息，未启用探测或探测失败时返回 nil
+func (s *SlurmService) profile(ctx context.Context) *models.SlurmProfile {
+	if s.profiles == nil {
+		return nil
+	}
+	profile, _ := s.profiles.Get(ctx, s.cluster, s.executor)
+	return profile
+}
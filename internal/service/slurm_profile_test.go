@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+	"star-dim/internal/utils"
+)
+
+// 录制自 Slurm 21.08 的 scontrol show config 输出片段
+const scontrolConfig = `Configuration data as of 2024-03-01T10:00:00
+AccountingStorageType   = accounting_storage/slurmdbd
+AuthInfo                = (null)
+ClusterName             = hpc
+SchedulerType           = sched/backfill
+SelectType              = select/cons_tres
+SLURM_VERSION           = 21.08.5
+`
+
+func TestDetectSlurmProfile(t *testing.T) {
+	fake := NewFakeExecutor().
+		Handle("scontrol show config", FakeResponse{Stdout: scontrolConfig}).
+		Handle("sinfo --version", FakeResponse{Stdout: "slurm-wlm 21.08.8\n"})
+	profile, err := DetectSlurmProfile(context.Background(), fake, "hpc")
+	require.NoError(t, err)
+	assert.Equal(t, "21.08.8", profile.Version)
+	assert.True(t, profile.Features["json"])
+	assert.True(t, profile.Features["cron"])
+	assert.False(t, profile.Features["only_job_state"])
+	assert.False(t, profile.Features["tres_bind"])
+	assert.Equal(t, "select/cons_tres", profile.Config["SelectType"])
+	assert.NotContains(t, profile.Config, "AuthInfo")
+
+	// sinfo 不可用时使用配置中的版本
+	fake = NewFakeExecutor().Handle("scontrol show config", FakeResponse{Stdout: scontrolConfig})
+	profile, err = DetectSlurmProfile(context.Background(), fake, "hpc")
+	require.NoError(t, err)
+	assert.Equal(t, "21.08.5", profile.Version)
+
+	_, err = DetectSlurmProfile(context.Background(), NewFakeExecutor(), "hpc")
+	assert.Error(t, err)
+}
+
+func TestSlurmProfilesCache(t *testing.T) {
+	fake := NewFakeExecutor().Handle("sinfo --version", FakeResponse{Stdout: "slurm 23.02.6\n"})
+	profiles := NewSlurmProfiles(0)
+
+	for i := 0; i < 3; i++ {
+		profile, err := profiles.Get(context.Background(), "hpc", fake)
+		require.NoError(t, err)
+		assert.Equal(t, "23.02.6", profile.Version)
+	}
+	assert.Len(t, fake.Calls(), 2)
+
+	profiles.Forget("hpc")
+	_, err := profiles.Get(context.Background(), "hpc", fake)
+	require.NoError(t, err)
+	assert.Len(t, fake.Calls(), 4)
+
+	// 探测失败后暂不重试
+	broken := NewFakeExecutor()
+	_, err = profiles.Get(context.Background(), "old", broken)
+	assert.Error(t, err)
+	_, err = profiles.Get(context.Background(), "old", broken)
+	assert.Error(t, err)
+	assert.Len(t, broken.Calls(), 2)
+}
+
+func TestSlurmServiceProfileChecks(t *testing.T) {
+	fake := NewFakeExecutor().
+		Handle("sinfo --version", FakeResponse{Stdout: "slurm 20.11.9\n"}).
+		Handle("squeue", FakeResponse{Stdout: squeueOutput}).
+		Handle("sbatch", FakeResponse{Stdout: "Submitted batch job 4242\n"})
+	s := NewSlurmService(fake, utils.NewSlurmParser(nil)).
+		WithJSON(NewJSONSupport(), "hpc").
+		WithProfiles(NewSlurmProfiles(0), "hpc")
+
+	// 20.11 不支持 --json，不尝试 JSON 输出；--only-job-state 被忽略
+	response := s.ExecuteSqueue(context.Background(), models.SqueueRequest{OnlyJobState: true})
+	require.Equal(t, "yes", response.Success, response.Message)
+	assert.NotContains(t, response.Command, "--json")
+	assert.NotContains(t, response.Command, "--only-job-state")
+	require.Len(t, response.Warnings, 1)
+	assert.Contains(t, response.Warnings[0], "20.11.9")
+
+	// 显式请求不支持的选项时返回错误
+	response = s.ExecuteSqueue(context.Background(), models.SqueueRequest{JSON: "default"})
+	assert.Equal(t, "no", response.Success)
+	assert.ErrorIs(t, response.Err, ErrUnsupported)
+	assert.Equal(t, "--json requires Slurm 21.08 or later, cluster hpc runs 20.11.9", response.Message)
+
+	sbatch := s.ExecuteSbatch(context.Background(), &models.SbatchRequest{Wrap: "hostname", TRESBind: "gres/gpu:closest"})
+	assert.Equal(t, "no", sbatch.Success)
+	assert.ErrorIs(t, sbatch.Err, ErrUnsupported)
+	for _, call := range fake.Calls() {
+		assert.False(t, strings.HasPrefix(call.Cmd, "sbatch"), call.Cmd)
+	}
+
+	sbatch = s.ExecuteSbatch(context.Background(), &models.SbatchRequest{Wrap: "hostname"})
+	assert.Equal(t, "yes", sbatch.Success, sbatch.Message)
+
+	assert.NoError(t, CheckScancel(nil, &models.ScancelRequest{Cron: true}))
+}
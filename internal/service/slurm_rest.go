@@ -0,0 +1,674 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"star-dim/internal/models"
+	"star-dim/internal/utils"
+)
+
+// ErrUnsupported 请求参数无法通过 slurmrestd 执行，或集群的 Slurm 版本不支持，见 UnsupportedError
+var ErrUnsupported = errors.New("not supported by slurmrestd")
+
+// RestError slurmrestd 返回的错误响应
+type RestError struct {
+	Method string
+	Path   string
+	Status int
+	// Errors 响应中 errors 字段的错误信息
+	Errors []string
+}
+
+func (e *RestError) Error() string {
+	message := fmt.Sprintf("slurmrestd %s %s: %s", e.Method, e.Path, http.StatusText(e.Status))
+	if len(e.Errors) > 0 {
+		message += ": " + strings.Join(e.Errors, "; ")
+	}
+	return message
+}
+
+// RestUser 访问 slurmrestd 的登录用户
+type RestUser struct {
+	Name string
+	// Home 用户主目录，作为提交作业的默认工作目录
+	Home string
+	// Token 获取用户的 JWT，集群配置了 token_file 时不使用
+	Token func(ctx context.Context) (string, error)
+}
+
+// RestSlurmService 通过 slurmrestd 的 OpenAPI 查询作业和节点、提交作业，
+// 响应转换为与 SlurmService 相同的结构
+type RestSlurmService struct {
+	config   *models.SlurmRestConfig
+	user     RestUser
+	timeouts CommandTimeouts
+	parser   *utils.SlurmParser
+	now      func() time.Time
+}
+
+func NewRestSlurmService(config *models.SlurmRestConfig, user RestUser, timeouts CommandTimeouts) *RestSlurmService {
+	return &RestSlurmService{
+		config:   config,
+		user:     user,
+		timeouts: timeouts,
+		parser:   utils.NewSlurmParser(nil),
+		now:      time.Now,
+	}
+}
+
+// restClients 按 CA 文件和证书校验选项复用的 HTTP 客户端
+var restClients sync.Map
+
+// httpClient 返回访问 slurmrestd 的 HTTP 客户端
+func (s *RestSlurmService) httpClient() (*http.Client, error) {
+	if s.config.CAFile == "" && !s.config.InsecureSkipVerify {
+		return http.DefaultClient, nil
+	}
+	key := fmt.Sprintf("%s|%t", s.config.CAFile, s.config.InsecureSkipVerify)
+	if client, ok := restClients.Load(key); ok {
+		return client.(*http.Client), nil
+	}
+	config := &tls.Config{InsecureSkipVerify: s.config.InsecureSkipVerify}
+	if s.config.CAFile != "" {
+		pem, err := os.ReadFile(s.config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read slurmrestd ca file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", s.config.CAFile)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	client, _ := restClients.LoadOrStore(key, &http.Client{Transport: transport})
+	return client.(*http.Client), nil
+}
+
+// authToken 返回请求使用的 JWT，配置了 token_file 时使用 SlurmUser 的令牌代表登录用户访问
+func (s *RestSlurmService) authToken(ctx context.Context) (string, error) {
+	if s.config.TokenFile != "" {
+		data, err := os.ReadFile(s.config.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("read slurmrestd token: %w", err)
+		}
+		return strings.TrimPrefix(strings.TrimSpace(string(data)), "SLURM_JWT="), nil
+	}
+	if s.user.Token == nil {
+		return "", errors.New("no slurmrestd token available")
+	}
+	return s.user.Token(ctx)
+}
+
+// endpoint 返回接口路径，如 /slurm/v0.0.40/jobs
+func (s *RestSlurmService) endpoint(plugin, name string) string {
+	return "/" + plugin + "/" + s.config.Version + "/" + name
+}
+
+// do 调用 slurmrestd 接口，返回请求描述和响应内容。
+// 超时时间按对应的 Slurm 命令名（squeue、sinfo、sacct、sbatch）取 CommandTimeouts 中的配置
+func (s *RestSlurmService) do(ctx context.Context, name, method, endpoint string, query url.Values, in interface{}) (string, string, error) {
+	command := method + " " + endpoint
+	if timeout := s.timeouts.For(name); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	client, err := s.httpClient()
+	if err != nil {
+		return command, "", err
+	}
+	token, err := s.authToken(ctx)
+	if err != nil {
+		return command, "", err
+	}
+
+	u := strings.TrimSuffix(s.config.URL, "/") + endpoint
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return command, "", err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return command, "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-SLURM-USER-NAME", s.user.Name)
+	req.Header.Set("X-SLURM-USER-TOKEN", token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return command, "", &TimeoutError{Command: name, Timeout: s.timeouts.For(name), Err: ctx.Err()}
+		}
+		return command, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 256<<20))
+	if err != nil {
+		if ctx.Err() != nil {
+			return command, "", &TimeoutError{Command: name, Timeout: s.timeouts.For(name), Err: ctx.Err()}
+		}
+		return command, "", err
+	}
+	raw := string(data)
+
+	if errs := utils.JSONErrors(data); resp.StatusCode >= http.StatusBadRequest || len(errs) > 0 {
+		return command, raw, &RestError{Method: method, Path: endpoint, Status: resp.StatusCode, Errors: errs}
+	}
+	return command, raw, nil
+}
+
+// ExecuteSqueue 查询 slurmctld 中的作业，按 squeue 的规则在本地过滤
+func (s *RestSlurmService) ExecuteSqueue(ctx context.Context, req models.SqueueRequest) models.SqueueResponse {
+	if err := unsupported(map[string]bool{"licenses": len(req.Licenses) > 0, "steps": len(req.Steps) > 0, "clusters": len(req.Clusters) > 0}); err != nil {
+		return models.SqueueResponse{Success: "no", Message: err.Error(), Err: err}
+	}
+	command, raw, err := s.do(ctx, "squeue", http.MethodGet, s.endpoint("slurm", "jobs"), nil, nil)
+	if err != nil {
+		return models.SqueueResponse{
+			Success:   "no",
+			Message:   "Failed to query jobs from slurmrestd: " + err.Error(),
+			Command:   command,
+			RawOutput: raw,
+			Err:       err,
+		}
+	}
+
+	jobs, err := s.parser.ParseSqueueJSON([]byte(raw), req, s.now())
+	if err != nil {
+		return models.SqueueResponse{
+			Success:   "no",
+			Message:   "Failed to parse slurmrestd response: " + err.Error(),
+			Command:   command,
+			RawOutput: raw,
+			Err:       err,
+		}
+	}
+	return models.SqueueResponse{
+		Success: "yes",
+		Data:    jobs,
+		Total:   len(jobs),
+		Command: command,
+	}
+}
+
+// unsupported 返回取值非空的不支持参数
+func unsupported(options map[string]bool) error {
+	var names []string
+	for name, set := range options {
+		if set {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return fmt.Errorf("%w: %s", ErrUnsupported, strings.Join(names, ", "))
+}
+
+// ExecuteSinfo 查询节点或预留信息，按 sinfo 的规则在本地过滤和汇总
+func (s *RestSlurmService) ExecuteSinfo(ctx context.Context, req models.SinfoRequest) models.SinfoResponse {
+	if err := unsupported(map[string]bool{"clusters": len(req.Clusters) > 0}); err != nil {
+		return models.SinfoResponse{Success: "no", Message: err.Error(), Err: err}
+	}
+	endpoint, message := s.endpoint("slurm", "nodes"), "Failed to query nodes from slurmrestd: "
+	if req.Reservation {
+		endpoint, message = s.endpoint("slurm", "reservations"), "Failed to query reservations from slurmrestd: "
+	}
+	command, raw, err := s.do(ctx, "sinfo", http.MethodGet, endpoint, nil, nil)
+	if err != nil {
+		return models.SinfoResponse{
+			Success:   "no",
+			Message:   message + err.Error(),
+			Command:   command,
+			RawOutput: raw,
+			Err:       err,
+		}
+	}
+
+	var data interface{}
+	if req.Reservation {
+		data, err = s.parser.ParseReservationsJSON([]byte(raw), s.now())
+	} else {
+		data, err = s.parser.ParseSinfoJSON([]byte(raw), req)
+	}
+	if err != nil {
+		return models.SinfoResponse{
+			Success:   "no",
+			Message:   "Failed to parse slurmrestd response: " + err.Error(),
+			Command:   command,
+			RawOutput: raw,
+			Err:       err,
+		}
+	}
+	return models.SinfoResponse{Success: "yes", Data: data, Total: dataTotal(data), Command: command}
+}
+
+// ExecuteSacct 查询 slurmdbd 中的作业记录。未指定用户且不查询所有用户时只查询登录用户的作业
+func (s *RestSlurmService) ExecuteSacct(ctx context.Context, req *models.SacctRequest) models.SacctResponse {
+	if err := unsupported(map[string]bool{"allclusters": req.AllClusters}); err != nil {
+		return models.SacctResponse{Success: "no", Message: err.Error(), Err: err}
+	}
+	query := url.Values{}
+	users := req.Users
+	if len(users) == 0 && !req.AllUsers {
+		users = []string{s.user.Name}
+	}
+	states := make([]string, 0, len(req.States))
+	for _, state := range req.States {
+		states = append(states, utils.FullJobState(state))
+	}
+	for key, values := range map[string][]string{
+		"users":     users,
+		"account":   req.Accounts,
+		"partition": req.Partitions,
+		"state":     states,
+		"qos":       req.QOS,
+		"cluster":   req.Clusters,
+		"job_name":  req.JobNames,
+		"node":      req.NodeList,
+	} {
+		if len(values) > 0 {
+			query.Set(key, strings.Join(values, ","))
+		}
+	}
+	if req.StartTime != "" {
+		query.Set("start_time", req.StartTime)
+	}
+	if req.EndTime != "" {
+		query.Set("end_time", req.EndTime)
+	}
+	if req.Duplicates {
+		query.Set("show_duplicates", "true")
+	}
+	if req.Allocations {
+		query.Set("skip_steps", "true")
+	}
+
+	command, raw, err := s.do(ctx, "sacct", http.MethodGet, s.endpoint("slurmdb", "jobs"), query, nil)
+	if err != nil {
+		return models.SacctResponse{
+			Success:   "no",
+			Message:   "Failed to query jobs from slurmdbd: " + err.Error(),
+			Command:   command,
+			RawOutput: raw,
+			Err:       err,
+		}
+	}
+
+	jobs, err := s.parser.ParseSacctJSON([]byte(raw), req)
+	if err != nil {
+		return models.SacctResponse{
+			Success:   "no",
+			Message:   "Failed to parse slurmrestd response: " + err.Error(),
+			Command:   command,
+			RawOutput: raw,
+			Err:       err,
+		}
+	}
+	return models.SacctResponse{
+		Success: "yes",
+		Data:    jobs,
+		Total:   len(jobs),
+		Command: command,
+	}
+}
+
+// ExecuteSbatch 提交 wrap 作业，slurmrestd 无法读取登录节点上的脚本文件，script_file 需改为上传脚本
+func (s *RestSlurmService) ExecuteSbatch(ctx context.Context, req *models.SbatchRequest) *models.SbatchResponse {
+	if req.ScriptFile != "" {
+		err := fmt.Errorf("%w: script_file, upload the script instead", ErrUnsupported)
+		return &models.SbatchResponse{Success: "no", Message: err.Error(), Err: err}
+	}
+	if req.Wrap == "" {
+		err := errors.New("no script provided")
+		return &models.SbatchResponse{Success: "no", Message: "Failed to build job description: " + err.Error(), Err: err}
+	}
+	return s.submit(ctx, req, "#!/bin/sh\n"+req.Wrap+"\n")
+}
+
+// ExecuteSbatchWithUpload 将上传的脚本内容随作业描述一起提交
+func (s *RestSlurmService) ExecuteSbatchWithUpload(ctx context.Context, req *models.SbatchRequest, filename string, script []byte) *models.SbatchResponse {
+	return s.submit(ctx, req, string(script))
+}
+
+func (s *RestSlurmService) submit(ctx context.Context, req *models.SbatchRequest, script string) *models.SbatchResponse {
+	job, err := s.jobDesc(req, script)
+	if err != nil {
+		return &models.SbatchResponse{
+			Success: "no",
+			Message: "Failed to build job description: " + err.Error(),
+			Err:     err,
+		}
+	}
+	var resp struct {
+		JobID   int64  `json:"job_id"`
+		Message string `json:"job_submit_user_msg"`
+		Result  struct {
+			JobID int64 `json:"job_id"`
+		} `json:"result"`
+	}
+	command, raw, err := s.do(ctx, "sbatch", http.MethodPost, s.endpoint("slurm", "job/submit"), nil, map[string]interface{}{"job": job})
+	if err == nil {
+		err = json.Unmarshal([]byte(raw), &resp)
+	}
+	if err != nil {
+		return &models.SbatchResponse{
+			Success:   "no",
+			Message:   "Failed to submit job to slurmrestd: " + err.Error(),
+			Command:   command,
+			RawOutput: raw,
+			Err:       err,
+		}
+	}
+	if resp.JobID == 0 {
+		resp.JobID = resp.Result.JobID
+	}
+	if resp.JobID == 0 {
+		err := errors.New("no job id in response")
+		return &models.SbatchResponse{
+			Success:   "no",
+			Message:   "Failed to parse slurmrestd response: " + err.Error(),
+			Command:   command,
+			RawOutput: raw,
+			Err:       err,
+		}
+	}
+	message := "Job submitted successfully"
+	if resp.Message != "" {
+		message = resp.Message
+	}
+	return &models.SbatchResponse{
+		Success: "yes",
+		Message: message,
+		JobID:   strconv.FormatInt(resp.JobID, 10),
+		Command: command,
+	}
+}
+
+// jobDesc 将 sbatch 请求转换为 slurmrestd 的作业描述，无法映射的选项返回 ErrUnsupported
+func (s *RestSlurmService) jobDesc(req *models.SbatchRequest, script string) (*restJobDesc, error) {
+	if err := unsupported(map[string]bool{
+		"begin":           req.Begin != "",
+		"deadline":        req.Deadline != "",
+		"signal":          req.Signal != "",
+		"export_file":     req.ExportFile != "",
+		"clusters":        len(req.Clusters) > 0,
+		"uid":             req.UID != "",
+		"gid":             req.GID != "",
+		"exclusive":       req.Exclusive != "",
+		"distribution":    req.Distribution != "",
+		"cpu_freq":        req.CPUFreq != "",
+		"gres_flags":      req.GRESFlags != "",
+		"node_file":       req.NodeFile != "",
+		"wait":            req.Wait,
+		"tmp_disk":        req.TmpDisk != "",
+		"container_id":    req.ContainerID != "",
+		"mem_per_gpu":     req.MemPerGPU != "",
+		"tres_per_task":   req.TRESPerTask != "",
+		"gpus_per_task":   req.GPUsPerTask > 0,
+		"gpus_per_node":   req.GPUsPerNode > 0,
+		"gpus":            req.GPUs > 0,
+		"cpus_per_gpu":    req.CPUsPerGPU > 0,
+		"ntasks_per_core": req.NTasksPerCore > 0,
+	}); err != nil {
+		return nil, err
+	}
+
+	job := &restJobDesc{
+		Script:                  script,
+		Name:                    req.JobName,
+		Account:                 req.Account,
+		Partition:               req.Partition,
+		QOS:                     req.QOS,
+		Comment:                 req.Comment,
+		Reservation:             req.Reservation,
+		CurrentWorkingDirectory: s.user.Home,
+		Tasks:                   req.NTasks,
+		TasksPerNode:            req.NTasksPerNode,
+		CPUsPerTask:             req.CPUsPerTask,
+		MinimumCPUs:             req.MinCPUs,
+		Nodes:                   req.Nodes,
+		StandardOutput:          req.Output,
+		StandardError:           req.Error,
+		StandardInput:           req.Input,
+		Dependency:              req.Dependency,
+		Array:                   req.Array,
+		Constraints:             req.Constraint,
+		Licenses:                req.Licenses,
+		RequiredNodes:           req.NodeList,
+		ExcludedNodes:           req.ExcludeNodes,
+		MailUser:                req.MailUser,
+		WCKey:                   req.WCKey,
+		Nice:                    req.Nice,
+		Hold:                    req.Hold,
+		Requeue:                 req.Requeue,
+		Container:               req.Container,
+	}
+	if req.Chdir != "" {
+		job.CurrentWorkingDirectory = req.Chdir
+		if !path.IsAbs(req.Chdir) && s.user.Home != "" {
+			job.CurrentWorkingDirectory = path.Join(s.user.Home, req.Chdir)
+		}
+	}
+	if len(req.ScriptArgs) > 0 {
+		job.Argv = append([]string{"script"}, req.ScriptArgs...)
+	}
+	if req.MailType != "" {
+		job.MailType = strings.Split(req.MailType, ",")
+	}
+	if req.Priority > 0 {
+		job.Priority = slurmNumber(int64(req.Priority))
+	}
+	if req.GRES != "" {
+		var tres []string
+		for _, gres := range strings.Split(req.GRES, ",") {
+			if !strings.HasPrefix(gres, "gres/") {
+				gres = "gres/" + gres
+			}
+			tres = append(tres, gres)
+		}
+		job.TRESPerNode = strings.Join(tres, ",")
+	}
+
+	// slurmrestd 不继承登录环境，需要显式传入环境变量
+	job.Environment = []string{"PATH=/usr/local/bin:/usr/bin:/bin"}
+	if s.user.Home != "" {
+		job.Environment = append(job.Environment, "HOME="+s.user.Home)
+	}
+	if s.user.Name != "" {
+		job.Environment = append(job.Environment, "USER="+s.user.Name)
+	}
+	for _, item := range strings.Split(req.Export, ",") {
+		if strings.Contains(item, "=") {
+			job.Environment = append(job.Environment, item)
+		}
+	}
+
+	var err error
+	if job.TimeLimit, err = slurmMinutes(req.Time); err != nil {
+		return nil, fmt.Errorf("invalid time %q", req.Time)
+	}
+	if job.TimeMinimum, err = slurmMinutes(req.TimeMin); err != nil {
+		return nil, fmt.Errorf("invalid time_min %q", req.TimeMin)
+	}
+	if job.MemoryPerNode, err = slurmMegabytes(req.Memory); err != nil {
+		return nil, fmt.Errorf("invalid memory %q", req.Memory)
+	}
+	if job.MemoryPerCPU, err = slurmMegabytes(req.MemPerCPU); err != nil {
+		return nil, fmt.Errorf("invalid mem_per_cpu %q", req.MemPerCPU)
+	}
+	return job, nil
+}
+
+// slurmMinutes 解析 Slurm 时间格式，返回分钟数。支持 M、M:S、H:M:S、D-H、D-H:M、D-H:M:S 和 UNLIMITED
+func slurmMinutes(s string) (*utils.SlurmNumber, error) {
+	switch strings.ToUpper(s) {
+	case "":
+		return nil, nil
+	case "UNLIMITED", "INFINITE":
+		return &utils.SlurmNumber{Set: true, Infinite: true}, nil
+	}
+	var days int64
+	if d, rest, ok := strings.Cut(s, "-"); ok {
+		n, err := strconv.ParseInt(d, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		days, s = n, rest
+	}
+	var parts []int64
+	for _, field := range strings.Split(s, ":") {
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid time %q", s)
+		}
+		parts = append(parts, n)
+	}
+	var seconds int64
+	switch {
+	case days > 0 && len(parts) == 1: // D-H
+		seconds = parts[0] * 3600
+	case days > 0 && len(parts) == 2: // D-H:M
+		seconds = parts[0]*3600 + parts[1]*60
+	case len(parts) == 1: // M
+		seconds = parts[0] * 60
+	case len(parts) == 2: // M:S
+		seconds = parts[0]*60 + parts[1]
+	case len(parts) == 3: // H:M:S
+		seconds = parts[0]*3600 + parts[1]*60 + parts[2]
+	default:
+		return nil, fmt.Errorf("invalid time %q", s)
+	}
+	// 与 Slurm 一致，不足一分钟的部分向上取整
+	minutes := days*24*60 + (seconds+59)/60
+	return slurmNumber(minutes), nil
+}
+
+// slurmMegabytes 解析 Slurm 内存格式，返回 MB 数，无单位时为 MB
+func slurmMegabytes(s string) (*utils.SlurmNumber, error) {
+	if s == "" {
+		return nil, nil
+	}
+	units := map[byte]float64{'K': 1.0 / 1024, 'M': 1, 'G': 1024, 'T': 1024 * 1024}
+	value, multiplier := strings.ToUpper(s), 1.0
+	if m, ok := units[value[len(value)-1]]; ok {
+		value, multiplier = value[:len(value)-1], m
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid memory %q", s)
+	}
+	return slurmNumber(int64(n*multiplier + 0.5)), nil
+}
+
+// restJobDesc POST /slurm/{version}/job/submit 的作业描述
+type restJobDesc struct {
+	Script                  string             `json:"script"`
+	Name                    string             `json:"name,omitempty"`
+	Account                 string             `json:"account,omitempty"`
+	Partition               string             `json:"partition,omitempty"`
+	QOS                     string             `json:"qos,omitempty"`
+	Comment                 string             `json:"comment,omitempty"`
+	Reservation             string             `json:"reservation,omitempty"`
+	CurrentWorkingDirectory string             `json:"current_working_directory"`
+	Environment             []string           `json:"environment"`
+	Argv                    []string           `json:"argv,omitempty"`
+	TimeLimit               *utils.SlurmNumber `json:"time_limit,omitempty"`
+	TimeMinimum             *utils.SlurmNumber `json:"time_minimum,omitempty"`
+	Nodes                   string             `json:"nodes,omitempty"`
+	Tasks                   int                `json:"tasks,omitempty"`
+	TasksPerNode            int                `json:"tasks_per_node,omitempty"`
+	CPUsPerTask             int                `json:"cpus_per_task,omitempty"`
+	MinimumCPUs             int                `json:"minimum_cpus,omitempty"`
+	MemoryPerNode           *utils.SlurmNumber `json:"memory_per_node,omitempty"`
+	MemoryPerCPU            *utils.SlurmNumber `json:"memory_per_cpu,omitempty"`
+	StandardOutput          string             `json:"standard_output,omitempty"`
+	StandardError           string             `json:"standard_error,omitempty"`
+	StandardInput           string             `json:"standard_input,omitempty"`
+	Dependency              string             `json:"dependency,omitempty"`
+	Array                   string             `json:"array,omitempty"`
+	Constraints             string             `json:"constraints,omitempty"`
+	Licenses                string             `json:"licenses,omitempty"`
+	TRESPerNode             string             `json:"tres_per_node,omitempty"`
+	RequiredNodes           []string           `json:"required_nodes,omitempty"`
+	ExcludedNodes           []string           `json:"excluded_nodes,omitempty"`
+	MailUser                string             `json:"mail_user,omitempty"`
+	MailType                []string           `json:"mail_type,omitempty"`
+	WCKey                   string             `json:"wckey,omitempty"`
+	Nice                    int                `json:"nice,omitempty"`
+	Priority                *utils.SlurmNumber `json:"priority,omitempty"`
+	Hold                    bool               `json:"hold,omitempty"`
+	Requeue                 bool               `json:"requeue,omitempty"`
+	Container               string             `json:"container,omitempty"`
+}
+
+// slurmNumber 返回作业描述中的数值
+func slurmNumber(n int64) *utils.SlurmNumber {
+	return &utils.SlurmNumber{Set: true, Number: n}
+}
+
+// ScontrolToken 在登录节点上执行 scontrol token 获取用户的 slurmrestd 令牌，
+// 令牌在有效期的 90% 内复用
+type ScontrolToken struct {
+	// Lifespan 令牌有效期，为 0 时使用 1 小时
+	Lifespan time.Duration
+
+	mu      sync.Mutex
+	token   string
+	renewAt time.Time
+}
+
+// Token 返回缓存的令牌，过期时重新生成
+func (t *ScontrolToken) Token(ctx context.Context, executor RemoteExecutor) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.renewAt) {
+		return t.token, nil
+	}
+	lifespan := t.Lifespan
+	if lifespan <= 0 {
+		lifespan = time.Hour
+	}
+	seconds := int64(lifespan / time.Second)
+	result, err := Exec(ctx, executor, fmt.Sprintf("scontrol token lifespan=%d", seconds))
+	if err != nil {
+		return "", fmt.Errorf("scontrol token: %w", err)
+	}
+	for _, line := range strings.Split(string(result.Stdout), "\n") {
+		if token, ok := strings.CutPrefix(strings.TrimSpace(line), "SLURM_JWT="); ok && token != "" {
+			t.token = token
+			t.renewAt = time.Now().Add(lifespan * 9 / 10)
+			return token, nil
+		}
+	}
+	return "", errors.New("scontrol token: no SLURM_JWT in output")
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+)
+
+// 按 slurmrestd v0.0.40 的响应格式录制
+const (
+	restJobsResponse = `{"jobs":[
+{"job_id":101,"name":"train","user_name":"alice","account":"proj-a","partition":"cpu","qos":"normal",
+ "job_state":["RUNNING"],"state_reason":"None","nodes":"cn[01-02]","node_count":{"set":true,"infinite":false,"number":2},
+ "cpus":{"set":true,"infinite":false,"number":64},"priority":{"set":true,"infinite":false,"number":4294901759},
+ "submit_time":{"set":true,"infinite":false,"number":1700000000},"start_time":{"set":true,"infinite":false,"number":1700000000},
+ "time_limit":{"set":true,"infinite":false,"number":120},"array_job_id":{"set":true,"infinite":false,"number":0},
+ "array_task_id":{"set":false,"infinite":false,"number":0},"memory_per_node":{"set":true,"infinite":false,"number":4096}},
+{"job_id":102,"name":"eval","user_name":"bob","account":"proj-b","partition":"gpu","qos":"normal",
+ "job_state":["PENDING"],"state_reason":"Resources","nodes":"","node_count":{"set":true,"infinite":false,"number":1},
+ "time_limit":{"set":false,"infinite":true,"number":0},"array_job_id":{"set":true,"infinite":false,"number":100},
+ "array_task_id":{"set":true,"infinite":false,"number":3}},
+{"job_id":99,"name":"old","user_name":"alice","partition":"cpu","job_state":["COMPLETED"]}
+],"errors":[],"warnings":[]}`
+
+	restNodesResponse = `{"nodes":[
+{"name":"cn01","state":["MIXED"],"partitions":["cpu","debug"],"cpus":64,"alloc_cpus":32,"alloc_idle_cpus":32,
+ "real_memory":257000,"alloc_memory":128000,"features":["avx512"],"cpu_load":1250,"free_mem":{"set":true,"infinite":false,"number":100000}},
+{"name":"cn02","state":["IDLE","DRAIN"],"partitions":["cpu"],"cpus":64,"reason":"disk","reason_set_by_user":"root",
+ "real_memory":257000},
+{"name":"gpu01","state":["DOWN","NOT_RESPONDING"],"partitions":["gpu"],"cpus":32,"real_memory":512000}
+],"errors":[]}`
+
+	restAccountingResponse = `{"jobs":[
+{"job_id":101,"name":"train","partition":"cpu","account":"proj-a","user":"alice","group":"users","qos":"normal",
+ "cluster":"dev","wckey":{"wckey":"","flags":[]},"nodes":"cn[01-02]","allocation_nodes":2,
+ "state":{"current":["COMPLETED"],"reason":"None"},
+ "exit_code":{"status":["SUCCESS"],"return_code":{"set":true,"infinite":false,"number":0}},
+ "time":{"elapsed":3723,"submission":1700000000,"start":1700000000,"end":1700003723},
+ "required":{"CPUs":64,"memory_per_node":{"set":true,"infinite":false,"number":4096}},
+ "tres":{"allocated":[{"type":"cpu","name":"","count":64},{"type":"node","name":"","count":2}],
+         "requested":[{"type":"cpu","name":"","count":64},{"type":"node","name":"","count":2}]},
+ "steps":[{"step":{"id":"101.batch","name":"batch"},"state":["COMPLETED"],
+   "exit_code":{"return_code":{"set":true,"infinite":false,"number":0}},"time":{"elapsed":3723},
+   "nodes":{"count":1,"range":"cn01"},"tres":{"allocated":[{"type":"cpu","name":"","count":32}]}}]},
+{"job_id":102,"name":"eval","partition":"gpu","user":"alice","state":{"current":["FAILED"]},
+ "exit_code":{"return_code":{"set":true,"infinite":false,"number":1},"signal":{"id":{"set":true,"infinite":false,"number":9}}},
+ "time":{"elapsed":5}}
+],"errors":[]}`
+)
+
+// fakeSlurmrestd 模拟 slurmrestd，校验认证请求头并记录请求
+type fakeSlurmrestd struct {
+	*httptest.Server
+	requests []*http.Request
+	bodies   []string
+}
+
+func newFakeSlurmrestd(t *testing.T) *fakeSlurmrestd {
+	f := &fakeSlurmrestd{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /slurm/v0.0.40/jobs", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, restJobsResponse)
+	})
+	mux.HandleFunc("GET /slurm/v0.0.40/nodes", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, restNodesResponse)
+	})
+	mux.HandleFunc("GET /slurmdb/v0.0.40/jobs", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, restAccountingResponse)
+	})
+	mux.HandleFunc("POST /slurm/v0.0.40/job/submit", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Job restJobDesc `json:"job"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Job.Partition == "nope" {
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `{"errors":[{"description":"Batch job submission failed","error_number":2015,"error":"Invalid partition name specified"}]}`)
+			return
+		}
+		io.WriteString(w, `{"job_id":4242,"step_id":"batch","job_submit_user_msg":"","errors":[],"warnings":[]}`)
+	})
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-SLURM-USER-NAME") != "alice" || r.Header.Get("X-SLURM-USER-TOKEN") != "jwt-alice" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"errors":[{"error":"Authentication failure"}]}`)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.requests = append(f.requests, r)
+		f.bodies = append(f.bodies, string(body))
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func newTestRestService(url string) *RestSlurmService {
+	config := &models.SlurmRestConfig{URL: url}
+	_ = config.Validate()
+	s := NewRestSlurmService(config, RestUser{
+		Name:  "alice",
+		Home:  "/home/alice",
+		Token: func(ctx context.Context) (string, error) { return "jwt-alice", nil },
+	}, CommandTimeouts{Default: time.Minute})
+	s.now = func() time.Time { return time.Unix(1700003723, 0) }
+	return s
+}
+
+func TestRestSlurmServiceSqueue(t *testing.T) {
+	f := newFakeSlurmrestd(t)
+	s := newTestRestService(f.URL)
+
+	// 默认不显示已结束的作业
+	response := s.ExecuteSqueue(context.Background(), models.SqueueRequest{})
+	require.Equal(t, "yes", response.Success, response.Message)
+	require.Len(t, response.Data, 2)
+	assert.Equal(t, "GET /slurm/v0.0.40/jobs", response.Command)
+
+	running := response.Data[0]
+	assert.Equal(t, "101", running.JobID)
+	assert.Equal(t, "R", running.State)
+	assert.Equal(t, "1:02:03", running.Time)
+	assert.Equal(t, "57:57", running.TimeLeft)
+	assert.Equal(t, 2, running.Nodes)
+	assert.Equal(t, "cn[01-02]", running.NodeList)
+	assert.Equal(t, "4096M", running.MinMemory)
+	assert.Equal(t, time.Unix(1700000000, 0), running.StartTime)
+
+	pending := response.Data[1]
+	assert.Equal(t, "100_3", pending.JobID)
+	assert.Equal(t, "PD", pending.State)
+	assert.Equal(t, "0:00", pending.Time)
+	assert.Equal(t, "UNLIMITED", pending.TimeLeft)
+	assert.Equal(t, "Resources", pending.Reason)
+
+	// 按 squeue 的规则过滤
+	response = s.ExecuteSqueue(context.Background(), models.SqueueRequest{States: []string{"all"}, Users: []string{"alice"}})
+	require.Equal(t, "yes", response.Success, response.Message)
+	assert.Len(t, response.Data, 2)
+	response = s.ExecuteSqueue(context.Background(), models.SqueueRequest{Jobs: []string{"100"}})
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, "100_3", response.Data[0].JobID)
+	response = s.ExecuteSqueue(context.Background(), models.SqueueRequest{NodeList: []string{"cn02"}})
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, "101", response.Data[0].JobID)
+}
+
+func TestRestSlurmServiceSinfo(t *testing.T) {
+	f := newFakeSlurmrestd(t)
+	s := newTestRestService(f.URL)
+
+	// 节点属于多个分区时每个分区一条记录
+	response := s.ExecuteSinfo(context.Background(), models.SinfoRequest{})
+	require.Equal(t, "yes", response.Success, response.Message)
+	nodes, ok := response.Data.([]models.NodeInfo)
+	require.True(t, ok)
+	require.Len(t, nodes, 4)
+	assert.Equal(t, "cn01", nodes[0].NodeName)
+	assert.Equal(t, "cpu", nodes[0].Partition)
+	assert.Equal(t, "mix", nodes[0].State)
+	assert.Equal(t, "12.50", nodes[0].Load)
+	assert.Equal(t, "avx512", nodes[0].Features)
+	assert.Equal(t, "debug", nodes[1].Partition)
+	assert.Equal(t, "drain", nodes[2].State)
+	assert.Equal(t, "disk", nodes[2].Reason)
+	assert.Equal(t, "down*", nodes[3].State)
+
+	response = s.ExecuteSinfo(context.Background(), models.SinfoRequest{Partitions: []string{"cpu"}, States: []string{"drain"}})
+	nodes = response.Data.([]models.NodeInfo)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "cn02", nodes[0].NodeName)
+	response = s.ExecuteSinfo(context.Background(), models.SinfoRequest{Nodes: []string{"cn[01-02]"}, Responding: true})
+	assert.Equal(t, 3, response.Total)
+
+	response = s.ExecuteSinfo(context.Background(), models.SinfoRequest{Summarize: true})
+	require.Equal(t, "yes", response.Success, response.Message)
+	assert.Equal(t, []models.NodeSummary{
+		{State: "down*", Count: 1, CPUs: 32},
+		{State: "drain", Count: 1, CPUs: 64},
+		{State: "mix", Count: 1, CPUs: 64},
+	}, response.Data)
+}
+
+func TestRestSlurmServiceSacct(t *testing.T) {
+	f := newFakeSlurmrestd(t)
+	s := newTestRestService(f.URL)
+
+	response := s.ExecuteSacct(context.Background(), &models.SacctRequest{States: []string{"CD", "F"}, StartTime: "2023-11-01"})
+	require.Equal(t, "yes", response.Success, response.Message)
+	query := f.requests[0].URL.Query()
+	assert.Equal(t, "alice", query.Get("users"))
+	assert.Equal(t, "COMPLETED,FAILED", query.Get("state"))
+	assert.Equal(t, "2023-11-01", query.Get("start_time"))
+
+	// 作业步作为单独的记录
+	require.Len(t, response.Data, 3)
+	job := response.Data[0]
+	assert.Equal(t, "101", job.JobID)
+	assert.Equal(t, "COMPLETED", job.State)
+	assert.Equal(t, 64, job.AllocCPUS)
+	assert.Equal(t, "0:0", job.ExitCode)
+	assert.Equal(t, "01:02:03", job.Elapsed)
+	assert.Equal(t, "4096M", job.ReqMem)
+	assert.Equal(t, "cpu=64,node=2", job.AllocTRES)
+	assert.Equal(t, "101.batch", response.Data[1].JobID)
+	assert.Equal(t, 32, response.Data[1].AllocCPUS)
+	assert.Equal(t, "cn01", response.Data[1].NodeList)
+	assert.Equal(t, "1:9", response.Data[2].ExitCode)
+
+	response = s.ExecuteSacct(context.Background(), &models.SacctRequest{JobIDs: []string{"101"}, Allocations: true, AllUsers: true})
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, "true", f.requests[1].URL.Query().Get("skip_steps"))
+	assert.Empty(t, f.requests[1].URL.Query().Get("users"))
+}
+
+func TestRestSlurmServiceSbatch(t *testing.T) {
+	f := newFakeSlurmrestd(t)
+	s := newTestRestService(f.URL)
+
+	response := s.ExecuteSbatch(context.Background(), &models.SbatchRequest{
+		Wrap: "hostname", JobName: "hello", Partition: "cpu", Time: "1-00:30", Memory: "4G", Chdir: "work", GRES: "gpu:2",
+	})
+	require.Equal(t, "yes", response.Success, response.Message)
+	assert.Equal(t, "4242", response.JobID)
+	assert.Equal(t, "Job submitted successfully", response.Message)
+	assert.Equal(t, "POST /slurm/v0.0.40/job/submit", response.Command)
+
+	var body struct {
+		Job map[string]interface{} `json:"job"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(f.bodies[0]), &body))
+	assert.Equal(t, "#!/bin/sh\nhostname\n", body.Job["script"])
+	assert.Equal(t, "/home/alice/work", body.Job["current_working_directory"])
+	assert.Equal(t, map[string]interface{}{"set": true, "infinite": false, "number": float64(1470)}, body.Job["time_limit"])
+	assert.Equal(t, map[string]interface{}{"set": true, "infinite": false, "number": float64(4096)}, body.Job["memory_per_node"])
+	assert.Equal(t, "gres/gpu:2", body.Job["tres_per_node"])
+	assert.Contains(t, body.Job["environment"], "HOME=/home/alice")
+
+	// 上传的脚本原样提交
+	response = s.ExecuteSbatchWithUpload(context.Background(), &models.SbatchRequest{}, "job.sh", []byte("#!/bin/bash\n#SBATCH -N1\nsrun hostname\n"))
+	require.Equal(t, "yes", response.Success, response.Message)
+	require.NoError(t, json.Unmarshal([]byte(f.bodies[1]), &body))
+	assert.Equal(t, "#!/bin/bash\n#SBATCH -N1\nsrun hostname\n", body.Job["script"])
+
+	// slurmrestd 拒绝作业时返回错误信息和原始响应
+	response = s.ExecuteSbatch(context.Background(), &models.SbatchRequest{Wrap: "hostname", Partition: "nope"})
+	assert.Equal(t, "no", response.Success)
+	var restErr *RestError
+	require.True(t, errors.As(response.Err, &restErr))
+	assert.Equal(t, http.StatusInternalServerError, restErr.Status)
+	assert.Equal(t, []string{"Invalid partition name specified: Batch job submission failed"}, restErr.Errors)
+	assert.Contains(t, response.RawOutput, "error_number")
+
+	// 登录节点上的脚本文件无法通过 slurmrestd 提交
+	response = s.ExecuteSbatch(context.Background(), &models.SbatchRequest{ScriptFile: "job.sh"})
+	assert.ErrorIs(t, response.Err, ErrUnsupported)
+	assert.Len(t, f.requests, 3)
+}
+
+func TestRestSlurmServiceErrors(t *testing.T) {
+	f := newFakeSlurmrestd(t)
+
+	// 令牌错误
+	s := newTestRestService(f.URL)
+	s.user.Token = func(ctx context.Context) (string, error) { return "wrong", nil }
+	response := s.ExecuteSqueue(context.Background(), models.SqueueRequest{})
+	var restErr *RestError
+	require.True(t, errors.As(response.Err, &restErr))
+	assert.Equal(t, http.StatusUnauthorized, restErr.Status)
+
+	// 请求按命令名超时
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+	s = newTestRestService(slow.URL)
+	s.timeouts = CommandTimeouts{Default: time.Minute, Commands: map[string]time.Duration{"sacct": 20 * time.Millisecond}}
+	start := time.Now()
+	accounting := s.ExecuteSacct(context.Background(), &models.SacctRequest{})
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.ErrorIs(t, accounting.Err, context.DeadlineExceeded)
+	assert.Contains(t, accounting.Err.Error(), `"sacct" timed out after 20ms`)
+}
+
+func TestScontrolToken(t *testing.T) {
+	fake := NewFakeExecutor().Handle("scontrol", FakeResponse{Stdout: "SLURM_JWT=eyJhbGciOi\n"})
+	token := &ScontrolToken{Lifespan: time.Hour}
+	for i := 0; i < 2; i++ {
+		jwt, err := token.Token(context.Background(), fake)
+		require.NoError(t, err)
+		assert.Equal(t, "eyJhbGciOi", jwt)
+	}
+	// 有效期内复用令牌
+	calls := fake.Calls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "scontrol token lifespan=3600", calls[0].Cmd)
+}
+
+func TestSlurmTimeUnits(t *testing.T) {
+	for in, want := range map[string]int64{"30": 30, "1:30": 2, "2:00:00": 120, "1-2": 1560, "1-00:30": 1470, "0-0:0:1": 1} {
+		n, err := slurmMinutes(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, n.Number, in)
+	}
+	n, err := slurmMinutes("UNLIMITED")
+	require.NoError(t, err)
+	assert.True(t, n.Infinite)
+	_, err = slurmMinutes("1:x")
+	assert.Error(t, err)
+
+	for in, want := range map[string]int64{"512": 512, "4G": 4096, "1.5g": 1536, "2048K": 2} {
+		n, err := slurmMegabytes(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, n.Number, in)
+	}
+}
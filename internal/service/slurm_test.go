@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+	"star-dim/internal/utils"
+)
+
+// 录制自 Slurm 23.02 的命令输出
+const (
+	squeueOutput = `             JOBID PARTITION     NAME     USER ST       TIME  NODES NODELIST(REASON)
+               101       cpu    train    alice  R    1:02:03      2 cn[01-02]
+               102       gpu     eval      bob PD       0:00      1 (Resources)
+`
+	sacctOutput = `JobID|JobName|Partition|Account|AllocCPUS|State|ExitCode
+101|train|cpu|proj-a|64|RUNNING|0:0
+101.batch|batch||proj-a|32|RUNNING|0:0
+`
+)
+
+func TestSlurmServiceExecuteSqueue(t *testing.T) {
+	fake := NewFakeExecutor().Handle("squeue", FakeResponse{Stdout: squeueOutput})
+	s := NewSlurmService(fake, utils.NewSlurmParser(nil))
+
+	response := s.ExecuteSqueue(context.Background(), models.SqueueRequest{Users: []string{"alice", "bob"}})
+	require.Equal(t, "yes", response.Success, response.Message)
+	require.Len(t, response.Data, 2)
+	assert.Equal(t, "101", response.Data[0].JobID)
+	assert.Equal(t, "alice", response.Data[0].User)
+	assert.Equal(t, "PD", response.Data[1].State)
+	assert.Equal(t, fake.Calls()[0].Cmd, response.Command)
+	assert.True(t, strings.HasPrefix(response.Command, "squeue"))
+}
+
+func TestSlurmServiceExecuteSacct(t *testing.T) {
+	fake := NewFakeExecutor().Handle("sacct", FakeResponse{Stdout: sacctOutput})
+	s := NewSlurmService(fake, utils.NewSlurmParser(nil))
+
+	response := s.ExecuteSacct(context.Background(), &models.SacctRequest{Format: "JobID,JobName,Partition,Account,AllocCPUS,State,ExitCode", Parsable: true})
+	assert.Equal(t, "yes", response.Success, response.Message)
+	assert.NoError(t, response.Err)
+	assert.Equal(t, len(response.Data), response.Total)
+}
+
+func TestSlurmServiceExecuteSbatch(t *testing.T) {
+	fake := NewFakeExecutor().Handle("sbatch", FakeResponse{Stdout: "Submitted batch job 4242\n"})
+	s := NewSlurmService(fake, utils.NewSlurmParser(nil))
+
+	response := s.ExecuteSbatch(context.Background(), &models.SbatchRequest{Wrap: "hostname"})
+	require.Equal(t, "yes", response.Success, response.Message)
+	assert.Equal(t, "4242", response.JobID)
+
+	// Slurm 拒绝作业时保留错误输出和退出状态
+	fake.Handle("sbatch", FakeResponse{Stderr: "sbatch: error: invalid partition specified: nope\n", ExitCode: 1})
+	response = s.ExecuteSbatch(context.Background(), &models.SbatchRequest{Wrap: "hostname", Partition: "nope"})
+	assert.Equal(t, "no", response.Success)
+	assert.Contains(t, response.RawOutput, "invalid partition")
+	var exit *ExitError
+	require.True(t, errors.As(response.Err, &exit))
+	assert.Equal(t, 1, exit.ExitCode)
+}
+
+func TestSlurmServiceExecuteSbatchWithUpload(t *testing.T) {
+	fake := NewFakeExecutor().
+		Handle("cat > ", FakeResponse{}).
+		Handle("rm -f ", FakeResponse{}).
+		Handle("sbatch", FakeResponse{Stdout: "Submitted batch job 7\n"})
+	s := NewSlurmService(fake, utils.NewSlurmParser(nil))
+
+	response := s.ExecuteSbatchWithUpload(context.Background(), &models.SbatchRequest{}, "../job.sh", []byte("#!/bin/bash\nhostname\n"))
+	require.Equal(t, "yes", response.Success, response.Message)
+	assert.Equal(t, "7", response.JobID)
+
+	calls := fake.Calls()
+	require.Len(t, calls, 3)
+	assert.Equal(t, "#!/bin/bash\nhostname\n", calls[0].Stdin)
+	assert.Contains(t, calls[0].Cmd, "/tmp/sbatch_script_")
+	assert.NotContains(t, calls[0].Cmd, "..")
+	assert.True(t, strings.HasPrefix(calls[1].Cmd, "sbatch"))
+	assert.True(t, strings.HasPrefix(calls[2].Cmd, "rm -f "))
+}
+
+func TestSlurmServiceJSON(t *testing.T) {
+	support := NewJSONSupport()
+	parser := utils.NewSlurmParser(nil)
+	squeueJSON := `{"jobs":[{"job_id":101,"name":"train","user_name":"alice","partition":"cpu","job_state":["RUNNING"]}],"errors":[]}`
+	fake := NewFakeExecutor().
+		Handle("squeue", FakeResponse{Stdout: squeueOutput}).
+		Handle(parser.BuildSqueueCommand(models.SqueueRequest{JSON: "default"}), FakeResponse{Stdout: squeueJSON})
+	s := NewSlurmService(fake, parser).WithJSON(support, "hpc")
+
+	response := s.ExecuteSqueue(context.Background(), models.SqueueRequest{})
+	require.Equal(t, "yes", response.Success, response.Message)
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, "R", response.Data[0].State)
+	assert.Contains(t, response.Command, "--json")
+
+	// 不支持 --json 的集群回退到文本输出，之后不再尝试 JSON
+	req := &models.SacctRequest{Format: "JobID,JobName,Partition,Account,AllocCPUS,State,ExitCode", Parsable: true}
+	fake = NewFakeExecutor().
+		Handle("sacct", FakeResponse{Stdout: sacctOutput}).
+		Handle(parser.BuildSacctCommand(&models.SacctRequest{Format: req.Format, Parsable: true, JSON: "default"}),
+			FakeResponse{Stderr: "sacct: unrecognized option '--json'\n", ExitCode: 1})
+	s = NewSlurmService(fake, parser).WithJSON(support, "old")
+	sacct := s.ExecuteSacct(context.Background(), req)
+	require.Equal(t, "yes", sacct.Success, sacct.Message)
+	assert.NotContains(t, sacct.Command, "--json")
+	assert.False(t, support.Enabled("old", "sacct"))
+	assert.True(t, support.Enabled("hpc", "sacct"))
+	s.ExecuteSacct(context.Background(), req)
+	assert.Len(t, fake.Calls(), 3)
+
+	// 显式请求 --json 时不回退
+	sacct = s.ExecuteSacct(context.Background(), &models.SacctRequest{Format: req.Format, Parsable: true, JSON: "default"})
+	assert.Equal(t, "no", sacct.Success)
+
+	// 未启用 JSON 检测时只使用文本输出
+	fake = NewFakeExecutor().Handle("squeue", FakeResponse{Stdout: squeueOutput})
+	response = NewSlurmService(fake, parser).ExecuteSqueue(context.Background(), models.SqueueRequest{})
+	require.Equal(t, "yes", response.Success, response.Message)
+	assert.NotContains(t, response.Command, "--json")
+}
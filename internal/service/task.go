@@ -0,0 +1,258 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"star-dim/internal/models"
+)
+
+var ErrTaskNotFound = errors.New("task not found")
+
+// 后台任务的类型
+const (
+	TaskExtract  = "extract"
+	TaskCompress = "compress"
+)
+
+// Task 在登录节点上执行的后台任务，记录状态和进度
+type Task struct {
+	ID    string
+	Owner string // 创建任务的集群用户，格式同 UploadOwner
+	Type  string
+	// Path 任务的输入，Destination 任务的输出
+	Path        string
+	Destination string
+
+	mu       sync.Mutex
+	state    string
+	phase    string
+	total    int
+	done     int
+	current  string
+	err      string
+	started  time.Time
+	finished time.Time
+	cancel   context.CancelFunc
+}
+
+// SetPhase 进入新的阶段并重置进度，total 为该阶段的条目总数，未知时为 0
+func (t *Task) SetPhase(phase string, total int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phase, t.total, t.done, t.current = phase, total, 0, ""
+}
+
+// Step 记录处理完一个条目
+func (t *Task) Step(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done++
+	t.current = name
+}
+
+// Lines 返回按行解析命令输出的 Writer，parse 识别出条目时记录进度
+func (t *Task) Lines(parse func(line string) (string, bool)) io.Writer {
+	return &taskLines{task: t, parse: parse}
+}
+
+// Running 任务是否仍在执行
+func (t *Task) Running() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state == models.TaskRunning
+}
+
+// Status 返回任务的状态和进度
+func (t *Task) Status() models.TaskStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := models.TaskStatus{
+		TaskID:      t.ID,
+		Type:        t.Type,
+		State:       t.state,
+		Phase:       t.phase,
+		Total:       t.total,
+		Done:        t.done,
+		Current:     t.current,
+		Path:        t.Path,
+		Destination: t.Destination,
+		Error:       t.err,
+		StartedAt:   t.started,
+	}
+	switch {
+	case t.state == models.TaskSucceeded:
+		status.Progress = 1
+	case t.total > 0:
+		status.Progress = min(float64(t.done)/float64(t.total), 1)
+	}
+	if !t.finished.IsZero() {
+		finished := t.finished
+		status.FinishedAt = &finished
+	}
+	return status
+}
+
+// finish 根据 run 的返回值结束任务
+func (t *Task) finish(ctx context.Context, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.finished = time.Now()
+	switch {
+	case err == nil:
+		t.state = models.TaskSucceeded
+	case ctx.Err() != nil:
+		t.state = models.TaskCanceled
+	default:
+		t.state, t.err = models.TaskFailed, err.Error()
+	}
+}
+
+// taskLines 将输出切分为行，最后一行不完整时等待后续输出
+type taskLines struct {
+	task  *Task
+	parse func(line string) (string, bool)
+	buf   []byte
+}
+
+func (w *taskLines) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if name, ok := w.parse(strings.TrimSuffix(string(w.buf[:i]), "\r")); ok {
+			w.task.Step(name)
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// TaskStore 保存集群用户的后台任务，会话过期重新登录后仍可查询和取消。任务与请求无关，在后台协程中执行直到完成或取消；
+// 结束的任务保留 ttl 供查询结果
+type TaskStore struct {
+	mu    sync.Mutex
+	tasks map[string]*Task
+	ttl   time.Duration
+}
+
+func NewTaskStore(ttl time.Duration) *TaskStore {
+	return &TaskStore{tasks: make(map[string]*Task), ttl: ttl}
+}
+
+// Start 创建任务并在后台协程中执行 run，取消任务时 run 的 ctx 被取消
+func (s *TaskStore) Start(owner, typ, src, dst string, run func(ctx context.Context, task *Task) error) *Task {
+	ctx, cancel := context.WithCancel(context.Background())
+	task := &Task{
+		ID:          strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Owner:       owner,
+		Type:        typ,
+		Path:        src,
+		Destination: dst,
+		state:       models.TaskRunning,
+		started:     time.Now(),
+		cancel:      cancel,
+	}
+	s.mu.Lock()
+	s.tasks[task.ID] = task
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		err := run(ctx, task)
+		if err != nil {
+			log.Printf("%s task %s (%s -> %s): %v", typ, task.ID, src, dst, err)
+		}
+		task.finish(ctx, err)
+	}()
+	return task
+}
+
+// Get 返回集群用户创建的任务，不存在或属于其他用户时返回 ErrTaskNotFound
+func (s *TaskStore) Get(id, owner string) (*Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[id]
+	if !ok || task.Owner != owner {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+	return task, nil
+}
+
+// List 返回集群用户的任务，按创建时间排序
+func (s *TaskStore) List(owner string) []models.TaskStatus {
+	s.mu.Lock()
+	var tasks []*Task
+	for _, task := range s.tasks {
+		if task.Owner == owner {
+			tasks = append(tasks, task)
+		}
+	}
+	s.mu.Unlock()
+	statuses := make([]models.TaskStatus, 0, len(tasks))
+	for _, task := range tasks {
+		statuses = append(statuses, task.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].StartedAt.Before(statuses[j].StartedAt) })
+	return statuses
+}
+
+// Cancel 取消进行中的任务，已结束的任务从列表中移除
+func (s *TaskStore) Cancel(id, owner string) (*Task, error) {
+	task, err := s.Get(id, owner)
+	if err != nil {
+		return nil, err
+	}
+	if task.Running() {
+		task.cancel()
+		return task, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tasks, id)
+	return task, nil
+}
+
+// Reap 移除结束超过 ttl 的任务，返回移除的数量
+func (s *TaskStore) Reap(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, task := range s.tasks {
+		task.mu.Lock()
+		expired := !task.finished.IsZero() && now.Sub(task.finished) > s.ttl
+		task.mu.Unlock()
+		if expired {
+			delete(s.tasks, id)
+			n++
+		}
+	}
+	return n
+}
+
+// StartReaper 定期清理结束的任务，stop 关闭时退出
+func (s *TaskStore) StartReaper(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.Reap(time.Now())
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+)
+
+// waitTask 等待任务结束
+func waitTask(t *testing.T, task *Task) models.TaskStatus {
+	require.Eventually(t, func() bool { return !task.Running() }, time.Second, time.Millisecond)
+	return task.Status()
+}
+
+func TestTaskStore(t *testing.T) {
+	store := NewTaskStore(time.Hour)
+	executor := NewFakeExecutor().Handle("tar -xvf", FakeResponse{Stdout: "run/\nrun/a.txt\nrun/b.txt\n"})
+
+	task := store.Start(UploadOwner("hpc1", "alice"), TaskExtract, "/data/a.tar", "/data", func(ctx context.Context, task *Task) error {
+		task.SetPhase(TaskExtracting, 4)
+		_, err := executor.Run(ctx, &Command{Cmd: "tar -xvf /data/a.tar", Stdout: task.Lines(func(line string) (string, bool) {
+			return ArchiveProgressLine(ArchiveTar, line)
+		})})
+		return err
+	})
+	status := waitTask(t, task)
+	assert.Equal(t, models.TaskSucceeded, status.State)
+	assert.Equal(t, 3, status.Done)
+	assert.Equal(t, "run/b.txt", status.Current)
+	assert.Equal(t, float64(1), status.Progress)
+	assert.NotNil(t, status.FinishedAt)
+
+	failed := store.Start(UploadOwner("hpc1", "alice"), TaskCompress, "/data/run", "/data/run.zip", func(ctx context.Context, task *Task) error {
+		return errors.New("zip: command not found")
+	})
+	status = waitTask(t, failed)
+	assert.Equal(t, models.TaskFailed, status.State)
+	assert.Equal(t, "zip: command not found", status.Error)
+
+	// 其他用户或其他集群上的同名用户无法访问
+	for _, owner := range []string{UploadOwner("hpc1", "bob"), UploadOwner("hpc2", "alice")} {
+		_, err := store.Get(task.ID, owner)
+		assert.ErrorIs(t, err, ErrTaskNotFound)
+		assert.Empty(t, store.List(owner))
+	}
+	statuses := store.List(UploadOwner("hpc1", "alice"))
+	require.Len(t, statuses, 2)
+	assert.Equal(t, task.ID, statuses[0].TaskID)
+
+	// 结束的任务保留 ttl 后清理
+	assert.Equal(t, 0, store.Reap(time.Now()))
+	assert.Equal(t, 2, store.Reap(time.Now().Add(2*time.Hour)))
+	assert.Empty(t, store.List(UploadOwner("hpc1", "alice")))
+}
+
+func TestTaskCancel(t *testing.T) {
+	store := NewTaskStore(time.Hour)
+	executor := NewFakeExecutor().Handle("tar", FakeResponse{Delay: time.Minute})
+	task := store.Start(UploadOwner("hpc1", "alice"), TaskExtract, "/data/a.tar", "/data", func(ctx context.Context, task *Task) error {
+		task.SetPhase(TaskExtracting, 10)
+		task.Step("run/")
+		_, err := executor.Run(ctx, &Command{Cmd: "tar -xvf /data/a.tar"})
+		return err
+	})
+	require.Eventually(t, func() bool { return task.Status().Done == 1 }, time.Second, time.Millisecond)
+	assert.InDelta(t, 0.1, task.Status().Progress, 1e-9)
+
+	_, err := store.Cancel(task.ID, UploadOwner("hpc1", "alice"))
+	require.NoError(t, err)
+	assert.Equal(t, models.TaskCanceled, waitTask(t, task).State)
+
+	// 再次取消时从列表中移除
+	_, err = store.Cancel(task.ID, UploadOwner("hpc1", "alice"))
+	require.NoError(t, err)
+	_, err = store.Get(task.ID, UploadOwner("hpc1", "alice"))
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// TimeoutError 命令因超时或请求取消被终止
+type TimeoutError struct {
+	Command string
+	Timeout time.Duration
+	// Err 为 context.DeadlineExceeded 或 context.Canceled
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Err == context.Canceled {
+		return fmt.Sprintf("command %q canceled", commandName(e.Command))
+	}
+	if e.Timeout > 0 {
+		return fmt.Sprintf("command %q timed out after %s", commandName(e.Command), e.Timeout)
+	}
+	return fmt.Sprintf("command %q timed out", commandName(e.Command))
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// interrupted 返回 ctx 结束时命令的错误
+func interrupted(ctx context.Context, cmd *Command) error {
+	return &TimeoutError{Command: cmd.Cmd, Timeout: cmd.Timeout, Err: ctx.Err()}
+}
+
+// CommandTimeouts 远程命令的超时时间，0 表示不限制。Default 只作用于 Slurm 查询和管理命令，
+// 复制、删除、执行脚本等耗时取决于数据量的命令默认不限制；Commands 按命令名覆盖，对任意命令生效
+type CommandTimeouts struct {
+	Default  time.Duration
+	Commands map[string]time.Duration
+}
+
+// slurmCommands 使用默认超时时间的 Slurm 命令。srun、salloc、sattach、sbcast 的耗时取决于作业和数据，不在其中
+var slurmCommands = map[string]bool{
+	"sacct": true, "sacctmgr": true, "sbatch": true, "scancel": true, "scontrol": true, "sdiag": true,
+	"sinfo": true, "sprio": true, "squeue": true, "sreport": true, "sshare": true, "sstat": true,
+}
+
+// For 返回命令行对应的超时时间
+func (t CommandTimeouts) For(cmdline string) time.Duration {
+	name := commandName(cmdline)
+	if timeout, ok := t.Commands[name]; ok {
+		return timeout
+	}
+	if slurmCommands[name] {
+		return t.Default
+	}
+	return 0
+}
+
+// ParseCommandTimeouts 解析逗号分隔的 命令=时长 列表，如 sacct=5m,squeue=30s
+func ParseCommandTimeouts(s string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid command timeout %q", item)
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid command timeout %q", item)
+		}
+		timeouts[name] = timeout
+	}
+	return timeouts, nil
+}
+
+// commandName 返回命令行中的命令名
+func commandName(cmdline string) string {
+	fields := strings.Fields(cmdline)
+	if len(fields) == 0 {
+		return ""
+	}
+	return path.Base(strings.Trim(fields[0], `'"`))
+}
+
+// timeoutExecutor 为未设置超时时间的命令按命令名设置超时时间
+type timeoutExecutor struct {
+	executor RemoteExecutor
+	timeouts CommandTimeouts
+}
+
+// WithTimeouts 返回按 timeouts 限制命令执行时间的执行器
+func WithTimeouts(executor RemoteExecutor, timeouts CommandTimeouts) RemoteExecutor {
+	return &timeoutExecutor{executor: executor, timeouts: timeouts}
+}
+
+func (e *timeoutExecutor) Run(ctx context.Context, cmd *Command) (*Result, error) {
+	if cmd.Timeout == 0 {
+		c := *cmd
+		c.Timeout = e.timeouts.For(cmd.Cmd)
+		cmd = &c
+	}
+	return e.executor.Run(ctx, cmd)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+	"star-dim/internal/utils"
+)
+
+func TestCommandTimeouts(t *testing.T) {
+	commands, err := ParseCommandTimeouts(" sacct=5m, squeue=30s ,sbatch=0s")
+	require.NoError(t, err)
+	timeouts := CommandTimeouts{Default: time.Minute, Commands: commands}
+	assert.Equal(t, 5*time.Minute, timeouts.For("sacct -j 1"))
+	assert.Equal(t, 5*time.Minute, timeouts.For("'/usr/bin/sacct' -j 1"))
+	assert.Equal(t, 30*time.Second, timeouts.For("squeue"))
+	assert.Equal(t, time.Duration(0), timeouts.For("sbatch job.sh"))
+	assert.Equal(t, time.Minute, timeouts.For("scontrol show config"))
+
+	// 文件操作和脚本执行默认不限制，可以按命令名单独设置
+	for _, cmdline := range []string{"rm -rf -- /tmp/x", "cp -r -- /a /b", "mv -- /a /b", "bash /home/alice/run.sh", "sha256sum -- /data/x", "srun hostname"} {
+		assert.Equal(t, time.Duration(0), timeouts.For(cmdline), cmdline)
+	}
+	timeouts.Commands["rm"] = time.Hour
+	assert.Equal(t, time.Hour, timeouts.For("rm -rf -- /tmp/x"))
+
+	for _, s := range []string{"sacct", "sacct=", "=5m", "sacct=-1s", "sacct=5x"} {
+		_, err := ParseCommandTimeouts(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestWithTimeouts(t *testing.T) {
+	fake := NewFakeExecutor().
+		Handle("sacct", FakeResponse{Stdout: sacctOutput, Delay: time.Second}).
+		Handle("squeue", FakeResponse{Stdout: squeueOutput})
+	executor := WithTimeouts(fake, CommandTimeouts{
+		Default:  time.Minute,
+		Commands: map[string]time.Duration{"sacct": 20 * time.Millisecond},
+	})
+	s := NewSlurmService(executor, utils.NewSlurmParser(nil))
+
+	// 超时的命令返回 TimeoutError，不会一直阻塞
+	start := time.Now()
+	response := s.ExecuteSacct(context.Background(), &models.SacctRequest{Parsable: true})
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, "no", response.Success)
+	var timeout *TimeoutError
+	require.True(t, errors.As(response.Err, &timeout))
+	assert.ErrorIs(t, response.Err, context.DeadlineExceeded)
+	assert.Equal(t, 20*time.Millisecond, timeout.Timeout)
+	assert.Contains(t, response.Err.Error(), `"sacct" timed out after 20ms`)
+
+	queue := s.ExecuteSqueue(context.Background(), models.SqueueRequest{})
+	assert.Equal(t, "yes", queue.Success, queue.Message)
+
+	// 命令自带的超时时间优先
+	_, err := executor.Run(context.Background(), &Command{Cmd: "squeue", Timeout: time.Second})
+	require.NoError(t, err)
+
+	calls := fake.Calls()
+	require.Len(t, calls, 3)
+	assert.Equal(t, 20*time.Millisecond, calls[0].Timeout)
+	assert.Equal(t, time.Minute, calls[1].Timeout)
+	assert.Equal(t, time.Second, calls[2].Timeout)
+}
+
+func TestExecutorCancel(t *testing.T) {
+	// 请求取消时终止正在执行的命令
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := (&LocalExecutor{}).Run(ctx, &Command{Cmd: "sleep 5"})
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.ErrorIs(t, err, context.Canceled)
+	var timeout *TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	assert.Equal(t, `command "sleep" canceled`, err.Error())
+}
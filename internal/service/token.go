@@ -0,0 +1,282 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 令牌类型
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+)
+
+const tokenIssuer = "star-dim"
+
+// minSecretLength HS256 签名密钥的最短长度
+const minSecretLength = 32
+
+// keyID 密钥列表中 kid 的格式，写入令牌头部用于选择校验密钥
+var keyID = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+var (
+	ErrTokenInvalid = errors.New("invalid or expired token")
+	ErrTokenRevoked = errors.New("token has been revoked")
+)
+
+// TokenClaims 令牌中携带的用户、集群和会话信息，Subject 为集群用户名，Session 为服务端会话密钥
+type TokenClaims struct {
+	Cluster   string `json:"cluster"`
+	LoginNode string `json:"login_node,omitempty"`
+	Session   string `json:"sid"`
+	Type      string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair 登录和刷新时返回给客户端的令牌
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// ExpiresIn 访问令牌的有效期，单位秒
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// RevocationStore 令牌吊销记录，多副本部署时需使用共享存储，
+// 否则刷新令牌轮换和登出只在处理请求的副本上生效
+type RevocationStore interface {
+	// Revoke 记录吊销，ttl 后自动清理，记录已存在时返回 false
+	Revoke(id string, ttl time.Duration) (bool, error)
+	// Revoked 返回 ids 中是否有已吊销的记录
+	Revoked(ids ...string) (bool, error)
+}
+
+// memoryRevocations 进程内吊销记录，单副本部署或未配置 Redis 时使用
+type memoryRevocations struct {
+	mu sync.Mutex
+	// revoked 已吊销的令牌 ID 或会话（前缀 sid:）及其过期时间，过期后清理
+	revoked map[string]time.Time
+}
+
+func NewMemoryRevocations() RevocationStore {
+	return &memoryRevocations{revoked: make(map[string]time.Time)}
+}
+
+func (r *memoryRevocations) Revoke(id string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(now)
+	if _, ok := r.revoked[id]; ok {
+		return false, nil
+	}
+	r.revoked[id] = now.Add(ttl)
+	return true, nil
+}
+
+func (r *memoryRevocations) Revoked(ids ...string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(time.Now())
+	for _, id := range ids {
+		if _, ok := r.revoked[id]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// prune 清理已过期的吊销记录，调用方需持有锁
+func (r *memoryRevocations) prune(now time.Time) {
+	for id, expiresAt := range r.revoked {
+		if now.After(expiresAt) {
+			delete(r.revoked, id)
+		}
+	}
+}
+
+// TokenService 签发和校验 HS256 签名的访问令牌与刷新令牌。
+// 支持配置多个签名密钥以便轮换：第一个密钥用于签名，全部密钥均可用于校验。
+// 吊销记录默认保存在进程内，多副本部署时通过 SetRevocations 使用共享存储。
+type TokenService struct {
+	keys       map[string][]byte
+	activeKid  string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+
+	revocations RevocationStore
+}
+
+// NewTokenService 创建令牌服务。secret 为单个签名密钥，按原样使用；keys 为用于轮换的密钥列表，
+// 逗号分隔，每项格式为 kid=secret，第一个用于签名，全部用于校验。同时配置时 secret 只用于校验，
+// 以便从单个密钥迁移到密钥列表。
+// 未配置密钥时生成随机密钥，服务重启后已签发的令牌全部失效，且无法在多副本间共享。
+func NewTokenService(secret, keys string, accessTTL, refreshTTL time.Duration) (*TokenService, error) {
+	if accessTTL <= 0 || refreshTTL <= 0 {
+		return nil, errors.New("token ttl must be positive")
+	}
+	s := &TokenService{
+		keys:       make(map[string][]byte),
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+
+		revocations: NewMemoryRevocations(),
+	}
+	for _, item := range strings.Split(keys, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kid, key, ok := strings.Cut(item, "=")
+		if !ok || !keyID.MatchString(kid) {
+			return nil, errors.New("jwt keys must be a comma separated list of kid=secret")
+		}
+		if err := s.addKey(kid, key); err != nil {
+			return nil, err
+		}
+	}
+	if secret != "" {
+		sum := sha256.Sum256([]byte(secret))
+		if err := s.addKey(hex.EncodeToString(sum[:4]), secret); err != nil {
+			return nil, err
+		}
+	}
+	if s.activeKid == "" {
+		secret := make([]byte, minSecretLength)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		log.Println("jwt secret is not configured, using a random key; tokens will not survive restarts or be shared between replicas")
+		s.activeKid = "random"
+		s.keys[s.activeKid] = secret
+	}
+	return s, nil
+}
+
+// addKey 添加签名密钥，第一个添加的密钥用于签名
+func (s *TokenService) addKey(kid, secret string) error {
+	if len(secret) < minSecretLength {
+		return fmt.Errorf("jwt secret %s must be at least %d bytes", kid, minSecretLength)
+	}
+	if _, exists := s.keys[kid]; exists {
+		return fmt.Errorf("duplicate jwt key id %s", kid)
+	}
+	s.keys[kid] = []byte(secret)
+	if s.activeKid == "" {
+		s.activeKid = kid
+	}
+	return nil
+}
+
+// SetRevocations 使用共享的吊销记录存储
+func (s *TokenService) SetRevocations(revocations RevocationStore) {
+	s.revocations = revocations
+}
+
+// AccessTTL 访问令牌有效期
+func (s *TokenService) AccessTTL() time.Duration {
+	return s.accessTTL
+}
+
+// Issue 为会话签发一对访问令牌和刷新令牌
+func (s *TokenService) Issue(session, username, cluster, loginNode string) (*TokenPair, error) {
+	now := time.Now()
+	access, err := s.sign(TokenAccess, session, username, cluster, loginNode, now, s.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.sign(TokenRefresh, session, username, cluster, loginNode, now, s.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.accessTTL / time.Second),
+	}, nil
+}
+
+func (s *TokenService) sign(typ, session, username, cluster, loginNode string, now time.Time, ttl time.Duration) (string, error) {
+	claims := &TokenClaims{
+		Cluster:   cluster,
+		LoginNode: loginNode,
+		Session:   session,
+		Type:      typ,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    tokenIssuer,
+			Subject:   username,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = s.activeKid
+	return token.SignedString(s.keys[s.activeKid])
+}
+
+// Parse 校验令牌签名、有效期、类型和吊销状态
+func (s *TokenService) Parse(tokenString, typ string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(tokenIssuer), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenInvalid, err)
+	}
+	if claims.Type != typ || claims.Session == "" {
+		return nil, ErrTokenInvalid
+	}
+	revoked, err := s.revocations.Revoked(claims.ID, "sid:"+claims.Session)
+	if err != nil {
+		return nil, fmt.Errorf("%w: check revocation: %v", ErrTokenInvalid, err)
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// Revoke 吊销单个令牌，用于刷新令牌轮换。令牌已被吊销时返回 ErrTokenRevoked，
+// 同一刷新令牌在多个副本上并发使用时只有一个能成功
+func (s *TokenService) Revoke(claims *TokenClaims) error {
+	ok, err := s.revocations.Revoke(claims.ID, revocationTTL(time.Until(claims.ExpiresAt.Time)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// RevokeSession 吊销会话的全部令牌，用于登出
+func (s *TokenService) RevokeSession(session string) error {
+	_, err := s.revocations.Revoke("sid:"+session, s.refreshTTL)
+	return err
+}
+
+// revocationTTL 吊销记录至少保留一秒，避免令牌临近过期时记录立即失效
+func revocationTTL(ttl time.Duration) time.Duration {
+	if ttl < time.Second {
+		return time.Second
+	}
+	return ttl
+}
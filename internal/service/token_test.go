@@ -0,0 +1,151 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSecret    = "0123456789abcdef0123456789abcdef"
+	testSecretOld = "fedcba9876543210fedcba9876543210"
+)
+
+func TestNewTokenService(t *testing.T) {
+	_, err := NewTokenService("short", "", time.Minute, time.Hour)
+	assert.Error(t, err)
+	_, err = NewTokenService("", "k1="+testSecret+",k1="+testSecretOld, time.Minute, time.Hour)
+	assert.Error(t, err)
+	_, err = NewTokenService("", testSecret, time.Minute, time.Hour)
+	assert.Error(t, err)
+	_, err = NewTokenService(testSecret, "", 0, time.Hour)
+	assert.Error(t, err)
+
+	// 未配置密钥时使用随机密钥
+	s, err := NewTokenService("", "", time.Minute, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, s.AccessTTL())
+
+	// 单个密钥按原样使用，其中的冒号和逗号不会被拆分
+	opaque := "k1:" + testSecret + "," + testSecretOld
+	s, err = NewTokenService(opaque, "", time.Minute, time.Hour)
+	require.NoError(t, err)
+	assert.Len(t, s.keys, 1)
+	assert.Equal(t, []byte(opaque), s.keys[s.activeKid])
+}
+
+func TestTokenIssueParse(t *testing.T) {
+	s, err := NewTokenService("", "k2="+testSecret+",k1="+testSecretOld, time.Minute, time.Hour)
+	require.NoError(t, err)
+	pair, err := s.Issue("tsh_a", "alice", "hpc1", "ln1")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", pair.TokenType)
+	assert.Equal(t, int64(60), pair.ExpiresIn)
+
+	claims, err := s.Parse(pair.AccessToken, TokenAccess)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", claims.Subject)
+	assert.Equal(t, "hpc1", claims.Cluster)
+	assert.Equal(t, "ln1", claims.LoginNode)
+	assert.Equal(t, "tsh_a", claims.Session)
+
+	// 令牌类型必须匹配
+	_, err = s.Parse(pair.RefreshToken, TokenAccess)
+	assert.ErrorIs(t, err, ErrTokenInvalid)
+	_, err = s.Parse(pair.AccessToken[:len(pair.AccessToken)-2], TokenAccess)
+	assert.ErrorIs(t, err, ErrTokenInvalid)
+
+	// 轮换密钥后旧密钥签发的令牌仍然有效，移除旧密钥后失效
+	old, err := NewTokenService("", "k1="+testSecretOld, time.Minute, time.Hour)
+	require.NoError(t, err)
+	oldPair, err := old.Issue("tsh_b", "bob", "hpc1", "ln1")
+	require.NoError(t, err)
+	_, err = s.Parse(oldPair.AccessToken, TokenAccess)
+	require.NoError(t, err)
+	rotated, err := NewTokenService("", "k2="+testSecret, time.Minute, time.Hour)
+	require.NoError(t, err)
+	_, err = rotated.Parse(oldPair.AccessToken, TokenAccess)
+	assert.ErrorIs(t, err, ErrTokenInvalid)
+
+	// 从单个密钥迁移到密钥列表时，单个密钥签发的令牌仍然有效
+	single, err := NewTokenService(testSecretOld, "", time.Minute, time.Hour)
+	require.NoError(t, err)
+	singlePair, err := single.Issue("tsh_c", "carol", "hpc1", "ln1")
+	require.NoError(t, err)
+	migrated, err := NewTokenService(testSecretOld, "k2="+testSecret, time.Minute, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", migrated.activeKid)
+	_, err = migrated.Parse(singlePair.AccessToken, TokenAccess)
+	require.NoError(t, err)
+
+	// 拒绝非 HS256 签名的令牌
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, &TokenClaims{Session: "tsh_a", Type: TokenAccess}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = s.Parse(none, TokenAccess)
+	assert.ErrorIs(t, err, ErrTokenInvalid)
+}
+
+func TestTokenRevoke(t *testing.T) {
+	s, err := NewTokenService(testSecret, "", time.Minute, time.Hour)
+	require.NoError(t, err)
+	pair, err := s.Issue("tsh_a", "alice", "hpc1", "ln1")
+	require.NoError(t, err)
+	claims, err := s.Parse(pair.RefreshToken, TokenRefresh)
+	require.NoError(t, err)
+
+	// 刷新令牌只能轮换一次
+	require.NoError(t, s.Revoke(claims))
+	assert.ErrorIs(t, s.Revoke(claims), ErrTokenRevoked)
+	_, err = s.Parse(pair.RefreshToken, TokenRefresh)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	_, err = s.Parse(pair.AccessToken, TokenAccess)
+	require.NoError(t, err)
+
+	// 登出吊销会话的全部令牌
+	require.NoError(t, s.RevokeSession("tsh_a"))
+	_, err = s.Parse(pair.AccessToken, TokenAccess)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+}
+
+func TestTokenSharedRevocations(t *testing.T) {
+	// 共享吊销记录的两个副本，一个副本上的轮换和登出在另一个副本上同样生效
+	shared := NewMemoryRevocations()
+	a, err := NewTokenService(testSecret, "", time.Minute, time.Hour)
+	require.NoError(t, err)
+	b, err := NewTokenService(testSecret, "", time.Minute, time.Hour)
+	require.NoError(t, err)
+	a.SetRevocations(shared)
+	b.SetRevocations(shared)
+
+	pair, err := a.Issue("tsh_a", "alice", "hpc1", "ln1")
+	require.NoError(t, err)
+	claims, err := a.Parse(pair.RefreshToken, TokenRefresh)
+	require.NoError(t, err)
+	require.NoError(t, a.Revoke(claims))
+	_, err = b.Parse(pair.RefreshToken, TokenRefresh)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	assert.ErrorIs(t, b.Revoke(claims), ErrTokenRevoked)
+
+	require.NoError(t, b.RevokeSession("tsh_a"))
+	_, err = a.Parse(pair.AccessToken, TokenAccess)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+}
+
+func TestMemoryRevocationsExpire(t *testing.T) {
+	r := NewMemoryRevocations()
+	ok, err := r.Revoke("jti", 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	revoked, err := r.Revoked("other", "jti")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// 过期的记录被清理
+	time.Sleep(20 * time.Millisecond)
+	revoked, err = r.Revoked("jti")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+}
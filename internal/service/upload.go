@@ -0,0 +1,318 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"star-dim/internal/models"
+)
+
+var (
+	ErrUploadNotFound   = errors.New("upload not found")
+	ErrUploadIncomplete = errors.New("upload incomplete")
+	ErrInvalidChunk     = errors.New("invalid chunk")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// 分块大小的默认值和取值范围
+const (
+	DefaultChunkSize int64 = 8 << 20
+	MinChunkSize     int64 = 256 << 10
+	MaxChunkSize     int64 = 64 << 20
+)
+
+// UploadOwner 返回上传和后台任务所属的集群用户。二者属于集群用户而不是会话，会话过期重新登录后仍可续传和查询
+func UploadOwner(cluster, user string) string {
+	return cluster + "/" + user
+}
+
+// PartRemover 使用 owner 当前可用的连接删除临时文件。没有可用连接时返回错误，上传保留到下次清理时重试
+type PartRemover func(owner, part string) error
+
+// Upload 分块上传会话。分块写入目标目录下的临时文件，全部收到并校验后重命名为目标文件，
+// 不同分块可以按任意顺序并行上传
+type Upload struct {
+	ID    string
+	Owner string // 创建上传的集群用户，见 UploadOwner
+	// Path 目标文件，PartPath 接收分块的临时文件，二者位于同一目录
+	Path      string
+	PartPath  string
+	Size      int64
+	ChunkSize int64
+	SHA256    string
+	Overwrite bool
+
+	mu        sync.Mutex
+	received  []bool
+	expiresAt time.Time
+}
+
+// Chunks 返回分块数量
+func (u *Upload) Chunks() int {
+	return len(u.received)
+}
+
+// chunkRange 返回分块在文件中的偏移和长度
+func (u *Upload) chunkRange(index int) (int64, int64, error) {
+	if index < 0 || index >= u.Chunks() {
+		return 0, 0, fmt.Errorf("%w: index %d out of range [0, %d)", ErrInvalidChunk, index, u.Chunks())
+	}
+	offset := int64(index) * u.ChunkSize
+	return offset, min(u.ChunkSize, u.Size-offset), nil
+}
+
+// WriteChunk 将分块流式写入临时文件的对应位置，同时计算 SHA-256。长度与分块大小不符或
+// checksum 不为空且与内容不一致时返回错误，分块不计为已接收，客户端可重新上传。
+// 重新上传已接收的分块时先将其标记为未接收，写入失败后不会保留损坏的内容
+func (u *Upload) WriteChunk(w io.WriterAt, index int, body io.Reader, checksum []byte) error {
+	offset, length, err := u.chunkRange(index)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	u.received[index] = false
+	u.mu.Unlock()
+	hash := sha256.New()
+	n, err := io.Copy(io.NewOffsetWriter(w, offset), io.TeeReader(io.LimitReader(body, length), hash))
+	if err != nil {
+		return err
+	}
+	if extra, _ := body.Read(make([]byte, 1)); n != length || extra > 0 {
+		return fmt.Errorf("%w: chunk %d must be %d bytes", ErrInvalidChunk, index, length)
+	}
+	if checksum != nil && !bytes.Equal(hash.Sum(nil), checksum) {
+		return fmt.Errorf("%w: chunk %d", ErrChecksumMismatch, index)
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.received[index] = true
+	return nil
+}
+
+// Missing 返回尚未接收的分块
+func (u *Upload) Missing() []int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	missing := []int{}
+	for i, ok := range u.received {
+		if !ok {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// Status 返回上传进度
+func (u *Upload) Status() models.UploadStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	status := models.UploadStatus{
+		UploadID:  u.ID,
+		Path:      u.Path,
+		Size:      u.Size,
+		ChunkSize: u.ChunkSize,
+		Chunks:    len(u.received),
+		Received:  []int{},
+		Missing:   []int{},
+		ExpiresAt: u.expiresAt,
+	}
+	for i, ok := range u.received {
+		if !ok {
+			status.Missing = append(status.Missing, i)
+			continue
+		}
+		status.Received = append(status.Received, i)
+		status.BytesReceived += min(u.ChunkSize, u.Size-int64(i)*u.ChunkSize)
+	}
+	return status
+}
+
+// VerifySHA256 校验整个文件的 SHA-256，expected 为空时使用创建上传时提供的值，均为空时不校验
+func (u *Upload) VerifySHA256(expected, actual string) error {
+	if expected == "" {
+		expected = u.SHA256
+	}
+	if expected != "" && !strings.EqualFold(expected, actual) {
+		return fmt.Errorf("%w: file sha256 is %s, want %s", ErrChecksumMismatch, actual, expected)
+	}
+	return nil
+}
+
+// ParseUploadChecksum 解析 tus 校验扩展格式的 Upload-Checksum 请求头，如 sha256 <base64>，为空时返回 nil
+func ParseUploadChecksum(header string) ([]byte, error) {
+	if header == "" {
+		return nil, nil
+	}
+	algorithm, value, _ := strings.Cut(strings.TrimSpace(header), " ")
+	if !strings.EqualFold(algorithm, "sha256") {
+		return nil, fmt.Errorf("%w: unsupported checksum algorithm %q", ErrInvalidChunk, algorithm)
+	}
+	checksum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(checksum) != sha256.Size {
+		return nil, fmt.Errorf("%w: invalid sha256 checksum %q", ErrInvalidChunk, value)
+	}
+	return checksum, nil
+}
+
+// UploadStore 保存进行中的分块上传。上传在 ttl 内没有新的分块时过期，过期或取消时删除临时文件。
+// 创建上传的会话此时可能已经关闭，临时文件通过 SetRemover 设置的函数使用用户当前的连接删除，
+// 删除失败的上传保留在 orphans 中，每次清理时重试
+type UploadStore struct {
+	mu      sync.Mutex
+	uploads map[string]*Upload
+	orphans []*Upload
+	remove  PartRemover
+	ttl     time.Duration
+}
+
+func NewUploadStore(ttl time.Duration) *UploadStore {
+	return &UploadStore{uploads: make(map[string]*Upload), ttl: ttl}
+}
+
+// SetRemover 设置删除临时文件的函数，未设置时过期和取消的上传不删除临时文件
+func (s *UploadStore) SetRemover(remove PartRemover) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove = remove
+}
+
+// Create 为目标文件 dst 创建上传，临时文件为同目录下的隐藏文件
+func (s *UploadStore) Create(owner, dst string, req *models.UploadRequest) (*Upload, error) {
+	if req.Size < 0 {
+		return nil, fmt.Errorf("%w: negative size", ErrInvalidChunk)
+	}
+	if req.SHA256 != "" {
+		if sum, err := hex.DecodeString(req.SHA256); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("%w: invalid sha256 %q", ErrInvalidChunk, req.SHA256)
+		}
+	}
+	chunkSize := req.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize < MinChunkSize || chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("%w: chunk size must be between %d and %d bytes", ErrInvalidChunk, MinChunkSize, MaxChunkSize)
+	}
+
+	id := strings.ReplaceAll(uuid.NewString(), "-", "")
+	upload := &Upload{
+		ID:        id,
+		Owner:     owner,
+		Path:      dst,
+		PartPath:  path.Join(path.Dir(dst), ".star-dim-upload-"+id),
+		Size:      req.Size,
+		ChunkSize: chunkSize,
+		SHA256:    strings.ToLower(req.SHA256),
+		Overwrite: req.Overwrite,
+		received:  make([]bool, (req.Size+chunkSize-1)/chunkSize),
+		expiresAt: time.Now().Add(s.ttl),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploads[id] = upload
+	return upload, nil
+}
+
+// Get 返回集群用户创建的上传并延长有效期，不存在、已过期或属于其他用户时返回 ErrUploadNotFound
+func (s *UploadStore) Get(id, owner string) (*Upload, error) {
+	s.mu.Lock()
+	upload, ok := s.uploads[id]
+	s.mu.Unlock()
+	if !ok || upload.Owner != owner {
+		return nil, fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+	}
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+	if time.Now().After(upload.expiresAt) {
+		return nil, fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+	}
+	upload.expiresAt = time.Now().Add(s.ttl)
+	return upload, nil
+}
+
+// Remove 移除已完成的上传，不删除文件
+func (s *UploadStore) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uploads, id)
+}
+
+// Abort 取消上传并删除临时文件
+func (s *UploadStore) Abort(id, owner string) error {
+	upload, err := s.Get(id, owner)
+	if err != nil {
+		return err
+	}
+	s.Remove(id)
+	s.cleanup([]*Upload{upload})
+	return nil
+}
+
+// cleanup 删除上传的临时文件，删除失败的上传留待下次清理时重试
+func (s *UploadStore) cleanup(uploads []*Upload) {
+	s.mu.Lock()
+	remove := s.remove
+	s.mu.Unlock()
+	if remove == nil {
+		return
+	}
+	var failed []*Upload
+	for _, upload := range uploads {
+		if err := remove(upload.Owner, upload.PartPath); err != nil {
+			log.Printf("remove partial upload %s: %v", upload.PartPath, err)
+			failed = append(failed, upload)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orphans = append(s.orphans, failed...)
+}
+
+// Reap 移除过期的上传并删除其临时文件，同时重试此前删除失败的临时文件，返回移除的上传数量
+func (s *UploadStore) Reap(now time.Time) int {
+	var expired []*Upload
+	s.mu.Lock()
+	orphans := s.orphans
+	s.orphans = nil
+	for id, upload := range s.uploads {
+		upload.mu.Lock()
+		if now.After(upload.expiresAt) {
+			expired = append(expired, upload)
+			delete(s.uploads, id)
+		}
+		upload.mu.Unlock()
+	}
+	s.mu.Unlock()
+	for _, upload := range expired {
+		log.Printf("upload %s to %s expired", upload.ID, upload.Path)
+	}
+	s.cleanup(append(orphans, expired...))
+	return len(expired)
+}
+
+// StartReaper 定期清理过期的上传，stop 关闭时退出
+func (s *UploadStore) StartReaper(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.Reap(time.Now())
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+)
+
+// memFile 模拟预分配大小的临时文件
+type memFile []byte
+
+func (f memFile) WriteAt(p []byte, off int64) (int, error) {
+	return copy(f[off:], p), nil
+}
+
+func chunkChecksum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+func TestUploadChunks(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), int(2*MinChunkSize/16+7))[:2*MinChunkSize+100]
+	store := NewUploadStore(time.Hour)
+	upload, err := store.Create("hpc1/alice", "/home/alice/data.bin", &models.UploadRequest{Size: int64(len(content)), ChunkSize: MinChunkSize})
+	require.NoError(t, err)
+	assert.Equal(t, 3, upload.Chunks())
+	assert.Equal(t, "/home/alice/.star-dim-upload-"+upload.ID, upload.PartPath)
+
+	file := make(memFile, len(content))
+	chunk := func(i int) []byte {
+		return content[int64(i)*MinChunkSize : min(int64(i+1)*MinChunkSize, int64(len(content)))]
+	}
+
+	// 分块可以乱序上传
+	require.NoError(t, upload.WriteChunk(file, 2, bytes.NewReader(chunk(2)), chunkChecksum(chunk(2))))
+	require.NoError(t, upload.WriteChunk(file, 0, bytes.NewReader(chunk(0)), nil))
+	status := upload.Status()
+	assert.Equal(t, []int{0, 2}, status.Received)
+	assert.Equal(t, []int{1}, status.Missing)
+	assert.Equal(t, MinChunkSize+100, status.BytesReceived)
+
+	// 长度不符、校验失败、序号越界的分块不计为已接收
+	assert.ErrorIs(t, upload.WriteChunk(file, 1, bytes.NewReader(chunk(1)[1:]), nil), ErrInvalidChunk)
+	assert.ErrorIs(t, upload.WriteChunk(file, 1, bytes.NewReader(append(bytes.Clone(chunk(1)), 'x')), nil), ErrInvalidChunk)
+	assert.ErrorIs(t, upload.WriteChunk(file, 1, bytes.NewReader(chunk(1)), chunkChecksum(chunk(2))), ErrChecksumMismatch)
+	assert.ErrorIs(t, upload.WriteChunk(file, 3, bytes.NewReader(nil), nil), ErrInvalidChunk)
+	assert.Equal(t, []int{1}, upload.Missing())
+
+	require.NoError(t, upload.WriteChunk(file, 1, bytes.NewReader(chunk(1)), chunkChecksum(chunk(1))))
+	assert.Empty(t, upload.Missing())
+	assert.Equal(t, content, []byte(file))
+
+	// 重新上传已接收的分块失败时，该分块重新计为未接收
+	corrupted := bytes.Repeat([]byte("x"), int(MinChunkSize))
+	assert.ErrorIs(t, upload.WriteChunk(file, 0, bytes.NewReader(corrupted), chunkChecksum(chunk(0))), ErrChecksumMismatch)
+	assert.Equal(t, []int{0}, upload.Missing())
+	require.NoError(t, upload.WriteChunk(file, 0, bytes.NewReader(chunk(0)), chunkChecksum(chunk(0))))
+	assert.Empty(t, upload.Missing())
+	assert.Equal(t, content, []byte(file))
+
+	sum := hex.EncodeToString(chunkChecksum(content))
+	assert.NoError(t, upload.VerifySHA256(strings.ToUpper(sum), sum))
+	assert.ErrorIs(t, upload.VerifySHA256(strings.Repeat("0", 64), sum), ErrChecksumMismatch)
+}
+
+func TestUploadStore(t *testing.T) {
+	var removed []string
+	store := NewUploadStore(time.Hour)
+	store.SetRemover(func(owner, part string) error {
+		removed = append(removed, part)
+		return nil
+	})
+	alice := UploadOwner("hpc1", "alice")
+
+	_, err := store.Create(alice, "/a", &models.UploadRequest{Size: 10, ChunkSize: 1})
+	assert.ErrorIs(t, err, ErrInvalidChunk)
+	_, err = store.Create(alice, "/a", &models.UploadRequest{Size: 10, SHA256: "abc"})
+	assert.ErrorIs(t, err, ErrInvalidChunk)
+
+	upload, err := store.Create(alice, "/a", &models.UploadRequest{Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultChunkSize, upload.ChunkSize)
+
+	// 其他用户以及其他集群上的同名用户无法访问
+	_, err = store.Get(upload.ID, UploadOwner("hpc1", "bob"))
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+	_, err = store.Get(upload.ID, UploadOwner("hpc2", "alice"))
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+	assert.ErrorIs(t, store.Abort(upload.ID, UploadOwner("hpc1", "bob")), ErrUploadNotFound)
+
+	require.NoError(t, store.Abort(upload.ID, alice))
+	assert.Equal(t, []string{upload.PartPath}, removed)
+	_, err = store.Get(upload.ID, alice)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+
+	// 过期的上传被清理并删除临时文件
+	expired, err := store.Create(alice, "/b", &models.UploadRequest{Size: 0})
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired.Chunks())
+	assert.Equal(t, 0, store.Reap(time.Now()))
+	assert.Equal(t, 1, store.Reap(time.Now().Add(2*time.Hour)))
+	assert.Equal(t, []string{upload.PartPath, expired.PartPath}, removed)
+	_, err = store.Get(expired.ID, alice)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+}
+
+func TestUploadStoreReapWithoutSession(t *testing.T) {
+	// live 模拟各集群用户当前在线的会话，创建上传的会话在清理前已经关闭
+	live := map[string]bool{}
+	var removed []string
+	store := NewUploadStore(time.Hour)
+	store.SetRemover(func(owner, part string) error {
+		if !live[owner] {
+			return errors.New("no live session")
+		}
+		removed = append(removed, part)
+		return nil
+	})
+	alice := UploadOwner("hpc1", "alice")
+	live[alice] = true
+	upload, err := store.Create(alice, "/home/alice/data.bin", &models.UploadRequest{Size: 10})
+	require.NoError(t, err)
+	live[alice] = false
+
+	// 重新登录后可以继续上传
+	live[alice] = true
+	resumed, err := store.Get(upload.ID, alice)
+	require.NoError(t, err)
+	assert.Same(t, upload, resumed)
+	live[alice] = false
+
+	// 没有在线会话时临时文件无法删除，上传已移除但临时文件保留到下次清理
+	later := time.Now().Add(2 * time.Hour)
+	assert.Equal(t, 1, store.Reap(later))
+	assert.Empty(t, removed)
+	_, err = store.Get(upload.ID, alice)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+	assert.Equal(t, 0, store.Reap(later))
+	assert.Empty(t, removed)
+
+	// 用户重新登录后，下次清理通过新的会话删除临时文件，之后不再重复删除
+	live[alice] = true
+	assert.Equal(t, 0, store.Reap(later))
+	assert.Equal(t, []string{upload.PartPath}, removed)
+	assert.Equal(t, 0, store.Reap(later))
+	assert.Equal(t, []string{upload.PartPath}, removed)
+}
+
+func TestParseUploadChecksum(t *testing.T) {
+	sum := chunkChecksum([]byte("hello"))
+	checksum, err := ParseUploadChecksum("sha256 " + base64.StdEncoding.EncodeToString(sum))
+	require.NoError(t, err)
+	assert.Equal(t, sum, checksum)
+
+	checksum, err = ParseUploadChecksum("")
+	assert.NoError(t, err)
+	assert.Nil(t, checksum)
+
+	for _, header := range []string{"md5 XUFAKrxLKna5cZ2REBfFkg==", "sha256 !!", "sha256 aGVsbG8="} {
+		_, err := ParseUploadChecksum(header)
+		assert.ErrorIs(t, err, ErrInvalidChunk, header)
+	}
+}
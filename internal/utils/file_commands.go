@@ -0,0 +1,34 @@
+package utils
+
+import "fmt"
+
+// 文件接口在登录节点上执行的命令。路径和参数均作为单独的参数转义，路径前加 -- 以免被当作选项
+
+// RemoveCommand 递归删除 path
+func RemoveCommand(path string) string {
+	return NewCommand("rm", "-rf", "--", path).String()
+}
+
+// CopyCommand 将 src 复制为 dst，recursive 为 true 时复制目录
+func CopyCommand(src, dst string, recursive bool) string {
+	return NewCommand("cp").Flag("-r", recursive).Arg("--", src, dst).String()
+}
+
+// MoveCommand 将 src 移动为 dst
+func MoveCommand(src, dst string) string {
+	return NewCommand("mv", "--", src, dst).String()
+}
+
+// ExecuteCommand 使用 bash 执行脚本。params 按 shell 规则拆分后逐个转义，不会展开变量或执行命令替换
+func ExecuteCommand(script, params string) (string, error) {
+	args, err := SplitArgs(params)
+	if err != nil {
+		return "", fmt.Errorf("invalid command params: %v", err)
+	}
+	return NewCommand("bash", "--", script).Arg(args...).String(), nil
+}
+
+// SHA256Command 计算 path 的 SHA-256
+func SHA256Command(path string) string {
+	return NewCommand("sha256sum", "--", path).String()
+}
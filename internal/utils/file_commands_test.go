@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCommands(t *testing.T) {
+	assert.Equal(t, "rm -rf -- '/home/u/my dir'", RemoveCommand("/home/u/my dir"))
+	assert.Equal(t, "cp -r -- /home/u/a /home/u/b", CopyCommand("/home/u/a", "/home/u/b", true))
+	assert.Equal(t, "cp -- /home/u/a /home/u/b", CopyCommand("/home/u/a", "/home/u/b", false))
+	assert.Equal(t, "mv -- /home/u/a '/home/u/$(id)'", MoveCommand("/home/u/a", "/home/u/$(id)"))
+	assert.Equal(t, "sha256sum -- '/home/u/a b'", SHA256Command("/home/u/a b"))
+
+	cmd, err := ExecuteCommand("/home/u/run.sh", `-n 4 "a b" '$HOME'`)
+	require.NoError(t, err)
+	assert.Equal(t, "bash -- /home/u/run.sh -n 4 'a b' '$HOME'", cmd)
+	_, err = ExecuteCommand("/home/u/run.sh", `"a`)
+	assert.Error(t, err)
+}
+
+// FuzzFileCommands 路径和脚本参数都不能改变 shell 拆分得到的参数列表
+func FuzzFileCommands(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed, seed)
+	}
+	f.Fuzz(func(t *testing.T, path, params string) {
+		if strings.IndexByte(path, 0) >= 0 || strings.IndexByte(params, 0) >= 0 {
+			t.Skip("NUL cannot be passed in argv")
+		}
+		assert.Equal(t, []string{"rm", "-rf", "--", path}, shellArgs(t, RemoveCommand(path)))
+		assert.Equal(t, []string{"cp", "-r", "--", path, params}, shellArgs(t, CopyCommand(path, params, true)))
+		assert.Equal(t, []string{"cp", "--", path, params}, shellArgs(t, CopyCommand(path, params, false)))
+		assert.Equal(t, []string{"mv", "--", path, params}, shellArgs(t, MoveCommand(path, params)))
+		assert.Equal(t, []string{"sha256sum", "--", path}, shellArgs(t, SHA256Command(path)))
+
+		split, err := SplitArgs(params)
+		if err != nil {
+			_, err = ExecuteCommand(path, params)
+			assert.Error(t, err)
+			return
+		}
+		cmd, err := ExecuteCommand(path, params)
+		require.NoError(t, err)
+		assert.Equal(t, append([]string{"bash", "--", path}, split...), shellArgs(t, cmd))
+	})
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+)
+
+// 录制自 Slurm 21.08 的 --json 输出：数值为普通数字，状态为字符串
+const (
+	squeueJSON2108 = `{"meta":{"Slurm":{"version":{"major":21,"micro":8,"minor":8}}},"errors":[],"jobs":[
+{"job_id":101,"name":"train","user_name":"alice","partition":"cpu","job_state":"RUNNING","nodes":"cn[01-02]",
+ "node_count":2,"cpus":64,"start_time":1700000000,"end_time":1700086400,"time_limit":4294967295,"array_job_id":0,"array_task_id":4294967294},
+{"job_id":102,"name":"eval","user_name":"bob","partition":"gpu","job_state":"PENDING","state_reason":"Resources",
+ "node_count":1,"cpus":8,"start_time":0,"time_limit":60,"array_job_id":100,"array_task_id":3},
+{"job_id":103,"name":"old","user_name":"alice","partition":"cpu","job_state":"COMPLETED","node_count":1}]}`
+	sinfoJSON2108 = `{"errors":[],"nodes":[
+{"name":"cn01","state":"idle","state_flags":["DRAIN"],"partitions":["cpu","debug"],"cpus":32,"idle_cpus":32,"real_memory":128000,"reason":"maint","free_memory":4294967294},
+{"name":"cn02","state":"allocated","state_flags":[],"partitions":["cpu"],"cpus":32,"alloc_cpus":32,"real_memory":128000,"cpu_load":3150}]}`
+)
+
+// 录制自 Slurm 23.02 和 23.11 的 --json 输出：数值为 {set,infinite,number}，状态为数组
+const (
+	squeueJSON2302 = `{"jobs":[{"job_id":201,"name":"sim","user_name":"alice","partition":"cpu","job_state":["RUNNING"],
+ "node_count":{"set":true,"infinite":false,"number":1},"cpus":{"set":true,"infinite":false,"number":4},
+ "start_time":{"set":true,"infinite":false,"number":1700000000},"time_limit":{"set":true,"infinite":false,"number":120},
+ "array_job_id":{"set":true,"infinite":false,"number":0},"array_task_id":{"set":false,"infinite":false,"number":0}}],"errors":[]}`
+	sinfoJSON2311 = `{"sinfo":[
+{"node":{"state":["MIXED"]},"nodes":{"nodes":["gpu[1-2]"]},"cpus":{"allocated":16,"idle":48,"minimum":32},
+ "memory":{"minimum":256000,"allocated":64000,"free":{"minimum":{"set":true,"infinite":false,"number":100000}}},
+ "partition":{"name":"gpu"}},
+{"node":{"state":["IDLE","DRAIN"]},"nodes":{"nodes":["cn01"]},"cpus":{"minimum":32,"idle":32},
+ "memory":{"minimum":128000},"reason":{"description":"maint"},"partition":{"name":"cpu"}}],"errors":[]}`
+	sacctJSON2302 = `{"jobs":[{"job_id":301,"name":"train","partition":"cpu","account":"proj-a","user":"alice",
+ "state":{"current":["COMPLETED"],"reason":"None"},"exit_code":{"status":["SUCCESS"],"return_code":{"set":true,"infinite":false,"number":0}},
+ "time":{"elapsed":3725,"submission":1700000000,"start":1700000010,"end":1700003735},"allocation_nodes":1,
+ "required":{"CPUs":4,"memory_per_node":{"set":true,"infinite":false,"number":8000}},
+ "tres":{"allocated":[{"type":"cpu","count":4},{"type":"node","count":1}],"requested":[{"type":"cpu","count":4}]},
+ "steps":[{"step":{"id":{"job_id":301,"step_id":"batch"},"name":"batch"},"state":["COMPLETED"],
+   "exit_code":{"return_code":{"set":true,"infinite":false,"number":0}},"time":{"elapsed":3725},
+   "nodes":{"count":1,"range":"cn01"},"tres":{"allocated":[{"type":"cpu","count":4}]}}]}],"errors":[]}`
+)
+
+func TestParseSqueueJSON(t *testing.T) {
+	p := NewSlurmParser(nil)
+	now := time.Unix(1700003723, 0)
+
+	// 21.08：默认不显示已结束的作业，NO_VAL 和 INFINITE 不当作数字
+	jobs, err := p.ParseSqueueJSON([]byte(squeueJSON2108), models.SqueueRequest{}, now)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "101", jobs[0].JobID)
+	assert.Equal(t, "R", jobs[0].State)
+	assert.Equal(t, "1:02:03", jobs[0].Time)
+	assert.Equal(t, "UNLIMITED", jobs[0].TimeLeft)
+	assert.Empty(t, jobs[0].ArrayJobID)
+	assert.Equal(t, "100_3", jobs[1].JobID)
+	assert.Equal(t, "PD", jobs[1].State)
+	assert.Equal(t, "0:00", jobs[1].Time)
+	assert.Equal(t, "1:00:00", jobs[1].TimeLeft)
+
+	jobs, err = p.ParseSqueueJSON([]byte(squeueJSON2108), models.SqueueRequest{Users: []string{"bob"}}, now)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "bob", jobs[0].User)
+
+	// 23.02
+	jobs, err = p.ParseSqueueJSON([]byte(squeueJSON2302), models.SqueueRequest{}, now)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "201", jobs[0].JobID)
+	assert.Equal(t, 4, jobs[0].CPUS)
+	assert.Equal(t, "57:57", jobs[0].TimeLeft)
+}
+
+func TestParseSinfoJSON(t *testing.T) {
+	p := NewSlurmParser(nil)
+
+	// 21.08：状态和状态标志分开，节点属于多个分区时每个分区一条记录
+	data, err := p.ParseSinfoJSON([]byte(sinfoJSON2108), models.SinfoRequest{})
+	require.NoError(t, err)
+	nodes := data.([]models.NodeInfo)
+	require.Len(t, nodes, 3)
+	assert.Equal(t, "cn01", nodes[0].NodeName)
+	assert.Equal(t, "drain", nodes[0].State)
+	assert.Equal(t, 32, nodes[0].IdleCPUs)
+	assert.Equal(t, 0, nodes[0].FreeMem)
+	assert.Equal(t, "debug", nodes[1].Partition)
+	assert.Equal(t, "alloc", nodes[2].State)
+	assert.Equal(t, "31.50", nodes[2].Load)
+
+	data, err = p.ParseSinfoJSON([]byte(sinfoJSON2108), models.SinfoRequest{Partitions: []string{"debug"}})
+	require.NoError(t, err)
+	assert.Len(t, data.([]models.NodeInfo), 1)
+
+	// 汇总时多个分区的节点只计一次
+	data, err = p.ParseSinfoJSON([]byte(sinfoJSON2108), models.SinfoRequest{Summarize: true})
+	require.NoError(t, err)
+	assert.Equal(t, []models.NodeSummary{{State: "alloc", Count: 1, CPUs: 32}, {State: "drain", Count: 1, CPUs: 32}}, data)
+
+	// 23.11：分组记录展开为节点
+	data, err = p.ParseSinfoJSON([]byte(sinfoJSON2311), models.SinfoRequest{Nodes: []string{"gpu2", "cn01"}})
+	require.NoError(t, err)
+	nodes = data.([]models.NodeInfo)
+	require.Len(t, nodes, 2)
+	assert.Equal(t, "gpu2", nodes[0].NodeName)
+	assert.Equal(t, "mix", nodes[0].State)
+	assert.Equal(t, 8, nodes[0].AllocCPUs)
+	assert.Equal(t, 100000, nodes[0].FreeMem)
+	assert.Equal(t, "drain", nodes[1].State)
+	assert.Equal(t, "maint", nodes[1].Reason)
+
+	data, err = p.ParseSinfoJSON([]byte(sinfoJSON2311), models.SinfoRequest{States: []string{"drain"}})
+	require.NoError(t, err)
+	assert.Len(t, data.([]models.NodeInfo), 1)
+}
+
+func TestParseSacctJSON(t *testing.T) {
+	p := NewSlurmParser(nil)
+
+	jobs, err := p.ParseSacctJSON([]byte(sacctJSON2302), &models.SacctRequest{})
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "301", jobs[0].JobID)
+	assert.Equal(t, "COMPLETED", jobs[0].State)
+	assert.Equal(t, "0:0", jobs[0].ExitCode)
+	assert.Equal(t, "01:02:05", jobs[0].Elapsed)
+	assert.Equal(t, 4, jobs[0].AllocCPUS)
+	assert.Equal(t, "8000M", jobs[0].ReqMem)
+	assert.Equal(t, "301.batch", jobs[1].JobID)
+	assert.Equal(t, "cn01", jobs[1].NodeList)
+
+	jobs, err = p.ParseSacctJSON([]byte(sacctJSON2302), &models.SacctRequest{Allocations: true})
+	require.NoError(t, err)
+	assert.Len(t, jobs, 1)
+
+	jobs, err = p.ParseSacctJSON([]byte(sacctJSON2302), &models.SacctRequest{JobIDs: []string{"999"}})
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestParseJSONErrors(t *testing.T) {
+	p := NewSlurmParser(nil)
+	output := []byte(`{"jobs":[],"errors":[{"error":"Invalid user","error_number":2002,"description":"unknown user nobody"}]}`)
+
+	assert.NotEmpty(t, JSONErrors(output))
+	_, err := p.ParseSqueueJSON(output, models.SqueueRequest{}, time.Now())
+	assert.Error(t, err)
+	assert.False(t, IsJSONOutput([]byte("JOBID PARTITION NAME\n")))
+	assert.True(t, IsJSONOutput([]byte(" \n{\"jobs\":[]}")))
+}
+
+func TestExpandHostList(t *testing.T) {
+	assert.Equal(t, []string{"cn01", "cn02", "cn05", "gpu1"}, ExpandHostList("cn[01-02,05],gpu1"))
+	assert.Equal(t, []string{"r1n1", "r1n2", "r2n1", "r2n2"}, ExpandHostList("r[1-2]n[1-2]"))
+	assert.Nil(t, ExpandHostList(""))
+}
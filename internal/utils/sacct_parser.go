@@ -8,8 +8,13 @@ import (
 	"strings"
 )
 
-// BuildCommand 根据请求参数构建 sacct 命令
+// BuildSacctCommand 根据请求参数构建转义后的 sacct 命令行
 func (p *SlurmParser) BuildSacctCommand(req *models.SacctRequest) string {
+	return QuoteArgs(p.SacctArgs(req))
+}
+
+// SacctArgs 根据请求参数构建 sacct 命令的参数列表
+func (p *SlurmParser) SacctArgs(req *models.SacctRequest) []string {
 	var args []string
 	args = append(args, "sacct")
 
@@ -135,7 +140,43 @@ func (p *SlurmParser) BuildSacctCommand(req *models.SacctRequest) string {
 		args = append(args, "-X")
 	}
 
-	return strings.Join(args, " ")
+	// JSON 输出，sacct 忽略输出格式参数
+	if req.JSON == "default" {
+		args = append(args, "--json")
+	} else if req.JSON != "" {
+		args = append(args, "--json="+req.JSON)
+	}
+
+	return args
+}
+
+// ValidateSacctRequest 验证 sacct 请求参数
+func (p *SlurmParser) ValidateSacctRequest(req *models.SacctRequest) error {
+	if err := validateJobIDs(req.JobIDs); err != nil {
+		return err
+	}
+	if err := validateNames("users", req.Users...); err != nil {
+		return err
+	}
+	if err := validateNames("accounts", req.Accounts...); err != nil {
+		return err
+	}
+	if err := validateNames("partitions", req.Partitions...); err != nil {
+		return err
+	}
+	if err := validateNames("states", req.States...); err != nil {
+		return err
+	}
+	if err := validateNames("qos", req.QOS...); err != nil {
+		return err
+	}
+	if err := validateNames("clusters", req.Clusters...); err != nil {
+		return err
+	}
+	if err := validateHostList("nodelist", req.NodeList...); err != nil {
+		return err
+	}
+	return validateText("jobnames", append([]string{req.StartTime, req.EndTime, req.Format}, req.JobNames...)...)
 }
 
 // ParseOutput 解析 sacct 命令输出
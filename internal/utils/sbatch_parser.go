@@ -9,8 +9,17 @@ import (
 	"strings"
 )
 
-// BuildCommand 根据请求参数构建 sbatch 命令
+// BuildSbatchCommand 根据请求参数构建转义后的 sbatch 命令行
 func (p *SlurmParser) BuildSbatchCommand(req *models.SbatchRequest) (string, error) {
+	args, err := p.SbatchArgs(req)
+	if err != nil {
+		return "", err
+	}
+	return QuoteArgs(args), nil
+}
+
+// SbatchArgs 根据请求参数构建 sbatch 命令的参数列表
+func (p *SlurmParser) SbatchArgs(req *models.SbatchRequest) ([]string, error) {
 	var args []string
 	log.Println("req:", req)
 	args = append(args, "sbatch")
@@ -403,10 +412,10 @@ func (p *SlurmParser) BuildSbatchCommand(req *models.SbatchRequest) (string, err
 			args = append(args, req.ScriptArgs...)
 		}
 	} else {
-		return "", fmt.Errorf("no script provided (script_file, or wrap required)")
+		return nil, fmt.Errorf("no script provided (script_file, or wrap required)")
 	}
 
-	return strings.Join(args, " "), nil
+	return args, nil
 }
 
 // ParseOutput 解析 sbatch 命令输出
@@ -519,6 +528,42 @@ func (p *SlurmParser) ValidateSbatchRequest(req *models.SbatchRequest) error {
 		}
 	}
 
+	// 检查名称类参数，这些参数只允许 Slurm 实体名称中的字符
+	if err := validateNames("account", req.Account); err != nil {
+		return err
+	}
+	if err := validateNames("partition", req.Partition); err != nil {
+		return err
+	}
+	if err := validateNames("qos", req.QOS); err != nil {
+		return err
+	}
+	if err := validateNames("reservation", req.Reservation); err != nil {
+		return err
+	}
+	if err := validateNames("wckey", req.WCKey); err != nil {
+		return err
+	}
+	if err := validateNames("clusters", req.Clusters...); err != nil {
+		return err
+	}
+	if err := validateHostList("node_list", req.NodeList...); err != nil {
+		return err
+	}
+	if err := validateHostList("exclude_nodes", req.ExcludeNodes...); err != nil {
+		return err
+	}
+	// 脚本路径作为位置参数传递，不能被当作选项
+	if err := validatePath("script_file", req.ScriptFile); err != nil {
+		return err
+	}
+	if err := validateText("script_args", req.ScriptArgs...); err != nil {
+		return err
+	}
+	if err := validateText("job_name", req.JobName, req.Comment, req.Wrap, req.Output, req.Error, req.Input, req.Chdir); err != nil {
+		return err
+	}
+
 	return nil
 }
 
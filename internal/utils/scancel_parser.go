@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"fmt"
+	"star-dim/internal/models"
+	"strings"
+)
+
+// BuildScancelCommand 根据请求参数构建转义后的 scancel 命令行
+func (p *SlurmParser) BuildScancelCommand(req *models.ScancelRequest) string {
+	return QuoteArgs(p.ScancelArgs(req))
+}
+
+// ScancelArgs 根据请求参数构建 scancel 命令的参数列表
+func (p *SlurmParser) ScancelArgs(req *models.ScancelRequest) []string {
+	cmd := NewCommand("scancel")
+	cmd.Opt("--account=", req.Account)
+	cmd.Flag("--batch", req.Batch)
+	cmd.Flag("--ctld", req.Ctld)
+	cmd.Flag("--cron", req.Cron)
+	cmd.Flag("--full", req.Full)
+	cmd.Flag("--hurry", req.Hurry)
+	cmd.Flag("--interactive", req.Interactive)
+	cmd.Opt("--clusters=", req.Clusters)
+	cmd.Opt("--name=", req.Name)
+	cmd.Opt("--partition=", req.Partition)
+	cmd.Flag("--quiet", req.Quiet)
+	cmd.Opt("--qos=", req.QOS)
+	cmd.Opt("--reservation=", req.Reservation)
+	cmd.Opt("--sibling=", req.Sibling)
+	cmd.Opt("--signal=", req.Signal)
+	cmd.Opt("--state=", req.State)
+	cmd.Opt("--user=", req.User)
+	cmd.Flag("--verbose", req.Verbose)
+	cmd.Opt("--nodelist=", req.NodeList)
+	cmd.Opt("--wckey=", req.WCKey)
+	// 添加作业ID（可选）
+	cmd.Arg(req.JobIDs...)
+	return cmd.Args()
+}
+
+// ValidateScancelRequest 验证 scancel 请求参数
+func (p *SlurmParser) ValidateScancelRequest(req *models.ScancelRequest) error {
+	if err := validateJobIDs(req.JobIDs); err != nil {
+		return err
+	}
+	if err := validateNames("account", req.Account); err != nil {
+		return err
+	}
+	if err := validateNames("clusters", req.Clusters); err != nil {
+		return err
+	}
+	if err := validateNames("partition", req.Partition); err != nil {
+		return err
+	}
+	if err := validateNames("qos", req.QOS); err != nil {
+		return err
+	}
+	if err := validateNames("reservation", req.Reservation); err != nil {
+		return err
+	}
+	if err := validateNames("sibling", req.Sibling); err != nil {
+		return err
+	}
+	if err := validateNames("user", req.User); err != nil {
+		return err
+	}
+	if err := validateNames("wckey", req.WCKey); err != nil {
+		return err
+	}
+	if err := validateHostList("nodelist", req.NodeList); err != nil {
+		return err
+	}
+	if err := validateText("name", req.Name); err != nil {
+		return err
+	}
+	if req.Signal != "" && !signalPattern.MatchString(req.Signal) {
+		return fmt.Errorf("invalid signal: %q", req.Signal)
+	}
+	// scancel 只支持按这三种状态过滤
+	validStates := map[string]bool{
+		"PENDING": true, "RUNNING": true, "SUSPENDED": true,
+		"PD": true, "R": true, "S": true,
+	}
+	if req.State != "" && !validStates[strings.ToUpper(req.State)] {
+		return fmt.Errorf("invalid state: %q", req.State)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CommandBuilder 按参数列表构建命令行，生成命令行时每个参数都经过 shell 转义，
+// 请求中的任何字段都只会成为命令的一个参数
+type CommandBuilder struct {
+	args []string
+}
+
+func NewCommand(name string, args ...string) *CommandBuilder {
+	return &CommandBuilder{args: append([]string{name}, args...)}
+}
+
+// Arg 添加参数
+func (b *CommandBuilder) Arg(args ...string) *CommandBuilder {
+	b.args = append(b.args, args...)
+	return b
+}
+
+// Opt 值不为空时添加选项。flag 以 = 结尾时与值合并为一个参数，如 --name=value，
+// 否则作为两个参数，如 -A value
+func (b *CommandBuilder) Opt(flag, value string) *CommandBuilder {
+	if value == "" {
+		return b
+	}
+	if strings.HasSuffix(flag, "=") {
+		return b.Arg(flag + value)
+	}
+	return b.Arg(flag, value)
+}
+
+// OptList 列表不为空时添加以逗号连接的选项值
+func (b *CommandBuilder) OptList(flag string, values []string) *CommandBuilder {
+	return b.Opt(flag, strings.Join(values, ","))
+}
+
+// Flag on 为 true 时添加开关选项
+func (b *CommandBuilder) Flag(flag string, on bool) *CommandBuilder {
+	if on {
+		b.args = append(b.args, flag)
+	}
+	return b
+}
+
+// Args 返回参数列表，第一个元素为命令名
+func (b *CommandBuilder) Args() []string {
+	return b.args
+}
+
+// String 返回转义后的命令行
+func (b *CommandBuilder) String() string {
+	return QuoteArgs(b.args)
+}
+
+// safeArg 无需转义的参数
+var safeArg = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Quote 按 POSIX shell 规则转义参数：仅含安全字符的参数原样返回，
+// 其余使用单引号包裹，参数中的单引号先结束引用再以反斜杠转义
+func Quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if safeArg.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// QuoteArgs 转义每个参数后以空格连接
+func QuoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// SplitArgs 按 shell 规则将参数字符串拆分为参数列表，支持单引号、双引号和反斜杠转义，
+// 不展开变量、命令替换和通配符
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		case ch == '\\':
+			inArg = true
+			if i+1 < len(s) {
+				i++
+				cur.WriteByte(s[i])
+			}
+		case ch == '\'':
+			inArg = true
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in %q", s)
+			}
+			cur.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case ch == '"':
+			inArg = true
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '"' {
+					closed = true
+					break
+				}
+				// 双引号中反斜杠只转义 $ ` " \
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\", s[i+1]) >= 0 {
+					i++
+				}
+				cur.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated double quote in %q", s)
+			}
+		default:
+			inArg = true
+			cur.WriteByte(ch)
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+var (
+	// jobIDPattern 作业 ID，支持数组作业（123_4、123_[1-3]）、异构作业（123+1）和作业步（123.batch）
+	jobIDPattern = regexp.MustCompile(`^\d+(_(\d+|\[[\d,\-%]+\]))?(\+\d+)?(\.(\d+|batch|extern|interactive))?$`)
+	// namePattern 用户、账户、分区、QOS、集群、预留等 Slurm 实体名称
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.@+-]*$`)
+	// hostListPattern 节点名或主机列表表达式，如 cn[01-04,08]
+	hostListPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.\-\[\],]*$`)
+	// signalPattern 信号名或编号
+	signalPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+)
+
+// validateJobIDs 校验作业 ID 列表
+func validateJobIDs(ids []string) error {
+	for _, id := range ids {
+		if !jobIDPattern.MatchString(id) {
+			return fmt.Errorf("invalid job id: %q", id)
+		}
+	}
+	return nil
+}
+
+// validateNames 校验 Slurm 实体名称，多个值可以逗号分隔
+func validateNames(field string, values ...string) error {
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		for _, name := range strings.Split(value, ",") {
+			if !namePattern.MatchString(name) {
+				return fmt.Errorf("invalid %s: %q", field, value)
+			}
+		}
+	}
+	return nil
+}
+
+// validateHostList 校验节点列表
+func validateHostList(field string, values ...string) error {
+	for _, value := range values {
+		if value != "" && !hostListPattern.MatchString(value) {
+			return fmt.Errorf("invalid %s: %q", field, value)
+		}
+	}
+	return nil
+}
+
+// validateText 校验自由文本参数（作业名、格式串等），经过转义后可以包含任意可见字符，
+// 但不能包含 NUL 等无法通过命令行传递的控制字符
+func validateText(field string, values ...string) error {
+	for _, value := range values {
+		for _, r := range value {
+			if r < 0x20 && r != '\t' && r != '\n' || r == 0x7f {
+				return fmt.Errorf("invalid %s: contains control characters", field)
+			}
+		}
+	}
+	return nil
+}
+
+// validatePath 校验作为位置参数传递的路径，不能以 - 开头以免被当作选项
+func validatePath(field, path string) error {
+	if strings.HasPrefix(path, "-") {
+		return fmt.Errorf("invalid %s: %q", field, path)
+	}
+	return validateText(field, path)
+}
@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"star-dim/internal/models"
+)
+
+// injectionSeeds 常见的 shell 注入载荷
+var injectionSeeds = []string{
+	"; rm -rf ~", "$(id)", "`id`", "a'b", "\n", "-rf", "*", "~",
+	"a b", `"$HOME"`, "x && id", "|id", "'; id; '", `\`, "",
+}
+
+// shellArgs 交给 /bin/sh 执行，用 printf 输出 shell 实际拆分得到的参数
+func shellArgs(t testing.TB, cmdline string) []string {
+	t.Helper()
+	name, rest, _ := strings.Cut(cmdline, " ")
+	out, err := exec.Command("/bin/sh", "-c", `printf '%s\0' `+Quote(name)+" "+rest).Output()
+	require.NoError(t, err, cmdline)
+	args := strings.Split(string(out), "\x00")
+	return args[:len(args)-1]
+}
+
+func TestQuote(t *testing.T) {
+	tests := map[string]string{
+		"":           "''",
+		"squeue":     "squeue",
+		"--name=job": "--name=job",
+		"cn[01-02]":  "'cn[01-02]'",
+		"a b":        "'a b'",
+		"it's":       `'it'\''s'`,
+		"$(id)":      "'$(id)'",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, Quote(in), in)
+	}
+	assert.Equal(t, "rm -rf -- '/home/u/my dir'", NewCommand("rm", "-rf", "--", "/home/u/my dir").String())
+}
+
+func TestCommandBuilder(t *testing.T) {
+	args := NewCommand("squeue").
+		Opt("-A", "proj").
+		Opt("--name=", "train").
+		Opt("-p", "").
+		OptList("-u", []string{"alice", "bob"}).
+		Flag("-l", true).
+		Flag("-h", false).
+		Arg("101").
+		Args()
+	assert.Equal(t, []string{"squeue", "-A", "proj", "--name=train", "-u", "alice,bob", "-l", "101"}, args)
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  a  b\tc ", []string{"a", "b", "c"}},
+		{`'a b' "c d" e\ f`, []string{"a b", "c d", "e f"}},
+		{`"a \"b\" \$x \n"`, []string{`a "b" $x \n`}},
+		{`'' ""`, []string{"", ""}},
+		{"$(id) `id` ;", []string{"$(id)", "`id`", ";"}},
+	}
+	for _, tt := range tests {
+		got, err := SplitArgs(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	_, err := SplitArgs(`'abc`)
+	assert.Error(t, err)
+	_, err = SplitArgs(`"abc`)
+	assert.Error(t, err)
+}
+
+func TestValidateRequests(t *testing.T) {
+	p := NewSlurmParser(nil)
+
+	// 合法的作业 ID 和名称
+	assert.NoError(t, p.ValidateScancelRequest(&models.ScancelRequest{
+		JobIDs: []string{"101", "102_3", "103_[1-5]", "104+1", "105.batch"},
+		User:   "alice", Partition: "gpu,cpu", NodeList: "cn[01-04]", Signal: "USR1", State: "pending",
+	}))
+	assert.NoError(t, p.ValidateSqueueRequest(models.SqueueRequest{States: []string{"all"}}))
+	assert.NoError(t, p.ValidateSacctRequest(&models.SacctRequest{Users: []string{"alice"}, JobNames: []string{"my job"}}))
+
+	// 非法字符在校验阶段被拒绝
+	assert.Error(t, p.ValidateScancelRequest(&models.ScancelRequest{JobIDs: []string{"1;id"}}))
+	assert.Error(t, p.ValidateScancelRequest(&models.ScancelRequest{JobIDs: []string{"-u"}}))
+	assert.Error(t, p.ValidateScancelRequest(&models.ScancelRequest{User: "$(id)"}))
+	assert.Error(t, p.ValidateScancelRequest(&models.ScancelRequest{Signal: "KILL;id"}))
+	assert.Error(t, p.ValidateSqueueRequest(models.SqueueRequest{Users: []string{"-a"}}))
+	assert.Error(t, p.ValidateSinfoRequest(models.SinfoRequest{Partitions: []string{"a b"}}))
+	assert.Error(t, p.ValidateSacctRequest(&models.SacctRequest{Accounts: []string{"`id`"}}))
+	assert.Error(t, p.ValidateSbatchRequest(&models.SbatchRequest{ScriptFile: "--wrap=id"}))
+	assert.Error(t, p.ValidateSbatchRequest(&models.SbatchRequest{Wrap: "a\x00b"}))
+}
+
+// FuzzQuote 任意字符串转义后经过 shell 解析得到原值
+func FuzzQuote(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if strings.IndexByte(s, 0) >= 0 {
+			t.Skip("NUL cannot be passed in argv")
+		}
+		assert.Equal(t, []string{"printf", s}, shellArgs(t, "printf "+Quote(s)))
+	})
+}
+
+// fill 将字符串和字符串列表字段全部设置为 s
+func fill(v interface{}, s string) {
+	rv := reflect.ValueOf(v).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(s)
+		case reflect.Slice:
+			if field.Type().Elem().Kind() == reflect.String {
+				field.Set(reflect.ValueOf([]string{s, s}))
+			}
+		}
+	}
+}
+
+// FuzzBuildCommands 请求的任意字段都不能改变 shell 拆分得到的参数列表
+func FuzzBuildCommands(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	p := NewSlurmParser(nil)
+	f.Fuzz(func(t *testing.T, s string) {
+		if strings.IndexByte(s, 0) >= 0 {
+			t.Skip("NUL cannot be passed in argv")
+		}
+
+		sacct := &models.SacctRequest{}
+		fill(sacct, s)
+		assert.Equal(t, p.SacctArgs(sacct), shellArgs(t, p.BuildSacctCommand(sacct)))
+
+		squeue := &models.SqueueRequest{}
+		fill(squeue, s)
+		assert.Equal(t, p.SqueueArgs(*squeue), shellArgs(t, p.BuildSqueueCommand(*squeue)))
+
+		sinfo := &models.SinfoRequest{}
+		fill(sinfo, s)
+		assert.Equal(t, p.SinfoArgs(*sinfo), shellArgs(t, p.BuildSinfoCommand(*sinfo)))
+
+		sbatch := &models.SbatchRequest{}
+		fill(sbatch, s)
+		if sbatch.Wrap == "" {
+			sbatch.Wrap = "hostname"
+		}
+		args, err := p.SbatchArgs(sbatch)
+		require.NoError(t, err)
+		cmdline, err := p.BuildSbatchCommand(sbatch)
+		require.NoError(t, err)
+		assert.Equal(t, args, shellArgs(t, cmdline))
+
+		scancel := &models.ScancelRequest{}
+		fill(scancel, s)
+		assert.Equal(t, p.ScancelArgs(scancel), shellArgs(t, p.BuildScancelCommand(scancel)))
+	})
+}
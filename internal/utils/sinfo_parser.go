@@ -9,118 +9,77 @@ import (
 	"strings"
 )
 
-// BuildCommand 根据请求参数构建 sinfo 命令
+// BuildSinfoCommand 根据请求参数构建转义后的 sinfo 命令行
 func (p *SlurmParser) BuildSinfoCommand(req models.SinfoRequest) string {
-	cmd := "sinfo"
-	log.Println("req:", req)
+	cmd := QuoteArgs(p.SinfoArgs(req))
+	log.Println("sinfo command:", cmd)
+	return cmd
+}
+
+// SinfoArgs 根据请求参数构建 sinfo 命令的参数列表
+func (p *SlurmParser) SinfoArgs(req models.SinfoRequest) []string {
+	cmd := NewCommand("sinfo")
 	// 基本过滤参数
-	if len(req.Nodes) > 0 {
-		cmd += fmt.Sprintf(" -n %s", strings.Join(req.Nodes, ","))
-	}
-	if len(req.Partitions) > 0 {
-		cmd += fmt.Sprintf(" -p %s", strings.Join(req.Partitions, ","))
-	}
-	if len(req.States) > 0 {
-		cmd += fmt.Sprintf(" -t %s", strings.Join(req.States, ","))
-	}
-	if len(req.Clusters) > 0 {
-		cmd += fmt.Sprintf(" -M %s", strings.Join(req.Clusters, ","))
-	}
+	cmd.OptList("-n", req.Nodes)
+	cmd.OptList("-p", req.Partitions)
+	cmd.OptList("-t", req.States)
+	cmd.OptList("-M", req.Clusters)
 
 	// 显示选项
-	if req.All {
-		cmd += " -a"
-	}
-	if req.Dead {
-		cmd += " -d"
-	}
-	if req.Exact {
-		cmd += " -e"
-	}
-	if req.Future {
-		cmd += " -F"
-	}
-	if req.Hide {
-		cmd += " --hide"
-	}
-	if req.Long {
-		cmd += " -l"
-	}
-	if req.NodeCentric {
-		cmd += " -N"
-	}
-	if req.Responding {
-		cmd += " -r"
-	}
-	if req.ListReasons {
-		cmd += " -R"
-	}
-	if req.Summarize {
-		cmd += " -s"
-	}
-	if req.Reservation {
-		cmd += " -T"
-	}
-	if req.Verbose {
-		cmd += " -v"
-	}
-	if req.Federation {
-		cmd += " --federation"
-	}
-	if req.Local {
-		cmd += " --local"
-	}
-	if req.NoConvert {
-		cmd += " --noconvert"
-	}
-	if req.NoHeader {
-		cmd += " -h"
-	}
+	cmd.Flag("-a", req.All)
+	cmd.Flag("-d", req.Dead)
+	cmd.Flag("-e", req.Exact)
+	cmd.Flag("-F", req.Future)
+	cmd.Flag("--hide", req.Hide)
+	cmd.Flag("-l", req.Long)
+	cmd.Flag("-N", req.NodeCentric)
+	cmd.Flag("-r", req.Responding)
+	cmd.Flag("-R", req.ListReasons)
+	cmd.Flag("-s", req.Summarize)
+	cmd.Flag("-T", req.Reservation)
+	cmd.Flag("-v", req.Verbose)
+	cmd.Flag("--federation", req.Federation)
+	cmd.Flag("--local", req.Local)
+	cmd.Flag("--noconvert", req.NoConvert)
+	cmd.Flag("-h", req.NoHeader)
 
 	// 输出格式控制
 	if req.Format != "" {
-		cmd += fmt.Sprintf(" -o %s", req.Format)
+		cmd.Opt("-o", req.Format)
 	} else if req.FormatLong != "" {
-		cmd += fmt.Sprintf(" -O %s", req.FormatLong)
+		cmd.Opt("-O", req.FormatLong)
 	} else if req.NodeCentric {
 		// 节点中心格式的默认字段
-		cmd += " -o \"%.15N %.9P %.6t %.4c %.8z %.6m %.8d %.6w %.8f %.20E\""
+		cmd.Opt("-o", "%.15N %.9P %.6t %.4c %.8z %.6m %.8d %.6w %.8f %.20E")
 	} else if req.Summarize {
 		// 摘要模式的默认字段
-		cmd += " -o \"%.9P %.5a %.6t %.4c %.8z %.6m %.8d %.6w %.8f %.20E\""
+		cmd.Opt("-o", "%.9P %.5a %.6t %.4c %.8z %.6m %.8d %.6w %.8f %.20E")
 	} else {
 		// 默认格式
-		cmd += " -o \"%.15N %.9P %.6t %.4c %.8z %.6m %.8d %.6w %.8f %.20E\""
+		cmd.Opt("-o", "%.15N %.9P %.6t %.4c %.8z %.6m %.8d %.6w %.8f %.20E")
 	}
 
 	// 输出格式
-	if req.JSON != "" {
-		if req.JSON == "default" {
-			cmd += " --json"
-		} else {
-			cmd += fmt.Sprintf(" --json=%s", req.JSON)
-		}
+	if req.JSON == "default" {
+		cmd.Arg("--json")
+	} else {
+		cmd.Opt("--json=", req.JSON)
 	}
-	if req.YAML != "" {
-		if req.YAML == "default" {
-			cmd += " --yaml"
-		} else {
-			cmd += fmt.Sprintf(" --yaml=%s", req.YAML)
-		}
+	if req.YAML == "default" {
+		cmd.Arg("--yaml")
+	} else {
+		cmd.Opt("--yaml=", req.YAML)
 	}
 
 	// 排序
-	if len(req.Sort) > 0 {
-		cmd += fmt.Sprintf(" -S %s", strings.Join(req.Sort, ","))
-	}
+	cmd.OptList("-S", req.Sort)
 
 	// 迭代
 	if req.Iterate > 0 {
-		cmd += fmt.Sprintf(" -i %d", req.Iterate)
+		cmd.Opt("-i", strconv.Itoa(req.Iterate))
 	}
 
-	log.Println("sinfo command:", cmd)
-	return cmd
+	return cmd.Args()
 }
 
 // ParseOutput 解析 sinfo 命令输出
@@ -539,5 +498,18 @@ func (p *SlurmParser) ValidateSinfoRequest(req models.SinfoRequest) error {
 		}
 	}
 
+	if err := validateHostList("nodes", req.Nodes...); err != nil {
+		return err
+	}
+	if err := validateNames("partitions", req.Partitions...); err != nil {
+		return err
+	}
+	if err := validateNames("clusters", req.Clusters...); err != nil {
+		return err
+	}
+	if err := validateText("format", req.Format, req.FormatLong); err != nil {
+		return err
+	}
+
 	return nil
 }
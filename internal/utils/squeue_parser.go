@@ -9,122 +9,73 @@ import (
 	"time"
 )
 
-// BuildCommand 根据请求参数构建 squeue 命令
+// BuildSqueueCommand 根据请求参数构建转义后的 squeue 命令行
 func (p *SlurmParser) BuildSqueueCommand(req models.SqueueRequest) string {
-	cmd := "squeue"
+	return QuoteArgs(p.SqueueArgs(req))
+}
+
+// SqueueArgs 根据请求参数构建 squeue 命令的参数列表
+func (p *SlurmParser) SqueueArgs(req models.SqueueRequest) []string {
+	cmd := NewCommand("squeue")
 
 	// 基本过滤参数
-	if len(req.Accounts) > 0 {
-		cmd += fmt.Sprintf(" -A %s", strings.Join(req.Accounts, ","))
-	}
-	if len(req.Jobs) > 0 {
-		cmd += fmt.Sprintf(" -j %s", strings.Join(req.Jobs, ","))
-	}
-	if len(req.Partitions) > 0 {
-		cmd += fmt.Sprintf(" -p %s", strings.Join(req.Partitions, ","))
-	}
-	if len(req.QOS) > 0 {
-		cmd += fmt.Sprintf(" -q %s", strings.Join(req.QOS, ","))
-	}
-	if len(req.States) > 0 {
-		cmd += fmt.Sprintf(" -t %s", strings.Join(req.States, ","))
-	}
-	if len(req.Users) > 0 {
-		cmd += fmt.Sprintf(" -u %s", strings.Join(req.Users, ","))
-	}
-	if len(req.Names) > 0 {
-		cmd += fmt.Sprintf(" -n %s", strings.Join(req.Names, ","))
-	}
-	if len(req.Clusters) > 0 {
-		cmd += fmt.Sprintf(" -M %s", strings.Join(req.Clusters, ","))
-	}
-	if len(req.Licenses) > 0 {
-		cmd += fmt.Sprintf(" -L %s", strings.Join(req.Licenses, ","))
-	}
-	if len(req.NodeList) > 0 {
-		cmd += fmt.Sprintf(" -w %s", strings.Join(req.NodeList, ","))
-	}
-	if len(req.Steps) > 0 {
-		cmd += fmt.Sprintf(" -s %s", strings.Join(req.Steps, ","))
-	}
-	if req.Reservation != "" {
-		cmd += fmt.Sprintf(" -R %s", req.Reservation)
-	}
+	cmd.OptList("-A", req.Accounts)
+	cmd.OptList("-j", req.Jobs)
+	cmd.OptList("-p", req.Partitions)
+	cmd.OptList("-q", req.QOS)
+	cmd.OptList("-t", req.States)
+	cmd.OptList("-u", req.Users)
+	cmd.OptList("-n", req.Names)
+	cmd.OptList("-M", req.Clusters)
+	cmd.OptList("-L", req.Licenses)
+	cmd.OptList("-w", req.NodeList)
+	cmd.OptList("-s", req.Steps)
+	cmd.Opt("-R", req.Reservation)
 
 	// 输出格式控制
 	if req.Format != "" {
-		cmd += fmt.Sprintf(" -o %s", req.Format)
+		cmd.Opt("-o", req.Format)
 	} else if req.FormatLong != "" {
-		cmd += fmt.Sprintf(" -O %s", req.FormatLong)
+		cmd.Opt("-O", req.FormatLong)
 	} else {
 		// 默认格式，包含常用字段
-		cmd += " -o \"%.10i %.9P %.20j %.8u %.2t %.10M %.10l %.6D %R\""
-	}
-
-	if req.NoHeader {
-		cmd += " -h"
-	}
-	if req.Long {
-		cmd += " -l"
-	}
-	if req.NoConvert {
-		cmd += " --noconvert"
-	}
-	if req.Array {
-		cmd += " -r"
-	}
-	if req.Start {
-		cmd += " --start"
-	}
-	if req.Verbose {
-		cmd += " -v"
-	}
-	if req.All {
-		cmd += " -a"
-	}
-	if req.Hide {
-		cmd += " --hide"
-	}
-	if req.Federation {
-		cmd += " --federation"
-	}
-	if req.Local {
-		cmd += " --local"
-	}
-	if req.Sibling {
-		cmd += " --sibling"
-	}
-	if req.OnlyJobState {
-		cmd += " --only-job-state"
-	}
+		cmd.Opt("-o", "%.10i %.9P %.20j %.8u %.2t %.10M %.10l %.6D %R")
+	}
+
+	cmd.Flag("-h", req.NoHeader)
+	cmd.Flag("-l", req.Long)
+	cmd.Flag("--noconvert", req.NoConvert)
+	cmd.Flag("-r", req.Array)
+	cmd.Flag("--start", req.Start)
+	cmd.Flag("-v", req.Verbose)
+	cmd.Flag("-a", req.All)
+	cmd.Flag("--hide", req.Hide)
+	cmd.Flag("--federation", req.Federation)
+	cmd.Flag("--local", req.Local)
+	cmd.Flag("--sibling", req.Sibling)
+	cmd.Flag("--only-job-state", req.OnlyJobState)
 
 	// 输出格式
-	if req.JSON != "" {
-		if req.JSON == "default" {
-			cmd += " --json"
-		} else {
-			cmd += fmt.Sprintf(" --json=%s", req.JSON)
-		}
+	if req.JSON == "default" {
+		cmd.Arg("--json")
+	} else {
+		cmd.Opt("--json=", req.JSON)
 	}
-	if req.YAML != "" {
-		if req.YAML == "default" {
-			cmd += " --yaml"
-		} else {
-			cmd += fmt.Sprintf(" --yaml=%s", req.YAML)
-		}
+	if req.YAML == "default" {
+		cmd.Arg("--yaml")
+	} else {
+		cmd.Opt("--yaml=", req.YAML)
 	}
 
 	// 排序
-	if len(req.Sort) > 0 {
-		cmd += fmt.Sprintf(" -S %s", strings.Join(req.Sort, ","))
-	}
+	cmd.OptList("-S", req.Sort)
 
 	// 迭代
 	if req.Iterate > 0 {
-		cmd += fmt.Sprintf(" -i %d", req.Iterate)
+		cmd.Opt("-i", strconv.Itoa(req.Iterate))
 	}
 
-	return cmd
+	return cmd.Args()
 }
 
 // ParseOutput 解析 squeue 命令输出
@@ -409,7 +360,7 @@ func (p *SlurmParser) ValidateSqueueRequest(req models.SqueueRequest) error {
 		"PREEMPTED": true, "NODE_FAIL": true, "REVOKED": true, "SPECIAL_EXIT": true,
 		"PD": true, "R": true, "S": true, "CG": true, "CD": true, "CA": true,
 		"F": true, "TO": true, "PR": true, "NF": true, "RV": true, "SE": true,
-		"all": true,
+		"ALL": true,
 	}
 
 	for _, state := range req.States {
@@ -418,5 +369,43 @@ func (p *SlurmParser) ValidateSqueueRequest(req models.SqueueRequest) error {
 		}
 	}
 
+	// 名称、作业和节点参数只允许对应的字符集
+	if err := validateJobIDs(req.Jobs); err != nil {
+		return err
+	}
+	if err := validateJobIDs(req.Steps); err != nil {
+		return err
+	}
+	if err := validateNames("accounts", req.Accounts...); err != nil {
+		return err
+	}
+	if err := validateNames("partitions", req.Partitions...); err != nil {
+		return err
+	}
+	if err := validateNames("qos", req.QOS...); err != nil {
+		return err
+	}
+	if err := validateNames("users", req.Users...); err != nil {
+		return err
+	}
+	if err := validateNames("clusters", req.Clusters...); err != nil {
+		return err
+	}
+	if err := validateNames("licenses", req.Licenses...); err != nil {
+		return err
+	}
+	if err := validateNames("reservation", req.Reservation); err != nil {
+		return err
+	}
+	if err := validateHostList("nodelist", req.NodeList...); err != nil {
+		return err
+	}
+	if err := validateText("names", req.Names...); err != nil {
+		return err
+	}
+	if err := validateText("format", req.Format, req.FormatLong); err != nil {
+		return err
+	}
+
 	return nil
 }
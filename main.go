@@ -8,14 +8,30 @@ import (
 	"star-dim/api"
 	"star-dim/configs"
 	_ "star-dim/docs" // 导入 docs 包以注册 Swagger 信息
+	"strconv"
+	"time"
 )
 
 func main() {
 	// 定义命令行参数
 	var (
-		host = flag.String("host", getEnvOrDefault("STAR_DIM_HOST", "0.0.0.0"), "服务器监听地址")
-		port = flag.String("port", getEnvOrDefault("STAR_DIM_PORT", "8080"), "服务器监听端口")
-		help = flag.Bool("help", false, "显示帮助信息")
+		host       = flag.String("host", getEnvOrDefault("STAR_DIM_HOST", "0.0.0.0"), "服务器监听地址")
+		port       = flag.String("port", getEnvOrDefault("STAR_DIM_PORT", "8080"), "服务器监听端口")
+		clusters   = flag.String("clusters", getEnvOrDefault("STAR_DIM_CLUSTERS", "configs/clusters.yaml"), "集群配置文件（YAML 或 JSON）")
+		hostKeys   = flag.String("host-keys", getEnvOrDefault("STAR_DIM_HOST_KEYS", "configs/host_keys.json"), "登录节点主机密钥记录文件")
+		adminToken = flag.String("admin-token", getEnvOrDefault("STAR_DIM_ADMIN_TOKEN", ""), "管理接口引导令牌，可管理所有集群，为空时只允许集群管理员会话调用管理接口")
+		idleTTL    = flag.Duration("session-idle-ttl", getEnvDurationOrDefault("STAR_DIM_SESSION_IDLE_TTL", 30*time.Minute), "会话空闲超时，0 表示不限制")
+		maxTTL     = flag.Duration("session-max-ttl", getEnvDurationOrDefault("STAR_DIM_SESSION_MAX_TTL", 12*time.Hour), "会话最长存活时间，0 表示不限制")
+		redisURL   = flag.String("redis", getEnvOrDefault("STAR_DIM_REDIS", ""), "会话注册表 Redis 地址，多副本部署时使用")
+		advertise  = flag.String("advertise", getEnvOrDefault("STAR_DIM_ADVERTISE_ADDR", ""), "本副本对其他副本可访问的地址，默认 http://主机名:端口")
+		jwtSecret  = flag.String("jwt-secret", getEnvOrDefault("STAR_DIM_JWT_SECRET", ""), "令牌签名密钥，按原样使用，为空时使用随机密钥")
+		jwtKeys    = flag.String("jwt-keys", getEnvOrDefault("STAR_DIM_JWT_KEYS", ""), "用于轮换的令牌签名密钥，逗号分隔的 kid=secret 列表，第一个用于签名；同时配置 -jwt-secret 时其只用于校验")
+		accessTTL  = flag.Duration("access-token-ttl", getEnvDurationOrDefault("STAR_DIM_ACCESS_TOKEN_TTL", 15*time.Minute), "访问令牌有效期")
+		refreshTTL = flag.Duration("refresh-token-ttl", getEnvDurationOrDefault("STAR_DIM_REFRESH_TOKEN_TTL", 12*time.Hour), "刷新令牌有效期")
+		cmdTimeout = flag.Duration("command-timeout", getEnvDurationOrDefault("STAR_DIM_COMMAND_TIMEOUT", time.Minute), "Slurm 命令默认超时时间，0 表示不限制；文件操作和脚本执行默认不限制")
+		timeouts   = flag.String("command-timeouts", getEnvOrDefault("STAR_DIM_COMMAND_TIMEOUTS", ""), "按命令名设置的超时时间，逗号分隔的 命令=时长 列表，如 sacct=5m,squeue=30s")
+		archiveMax = flag.Int64("archive-max-size", getEnvInt64OrDefault("STAR_DIM_ARCHIVE_MAX_SIZE", 0), "目录打包下载的文件总大小上限（字节），0 表示不限制")
+		help       = flag.Bool("help", false, "显示帮助信息")
 	)
 
 	flag.Parse()
@@ -29,14 +45,42 @@ func main() {
 		fmt.Println("\n环境变量:")
 		fmt.Println("  STAR-DIM_HOST    服务器监听地址 (默认: 0.0.0.0)")
 		fmt.Println("  STAR-DIM_PORT    服务器监听端口 (默认: 8080)")
+		fmt.Println("  STAR_DIM_CLUSTERS 集群配置文件 (默认: configs/clusters.yaml)")
+		fmt.Println("  STAR_DIM_HOST_KEYS 主机密钥记录文件 (默认: configs/host_keys.json)")
+		fmt.Println("  STAR_DIM_ADMIN_TOKEN 管理接口引导令牌 (默认: 空，只允许集群管理员会话)")
+		fmt.Println("  STAR_DIM_SESSION_IDLE_TTL 会话空闲超时 (默认: 30m)")
+		fmt.Println("  STAR_DIM_SESSION_MAX_TTL 会话最长存活时间 (默认: 12h)")
+		fmt.Println("  STAR_DIM_REDIS 会话注册表 Redis 地址 (默认: 空，仅单副本)")
+		fmt.Println("  STAR_DIM_ADVERTISE_ADDR 本副本对其他副本可访问的地址 (默认: http://主机名:端口)")
+		fmt.Println("  STAR_DIM_JWT_SECRET 令牌签名密钥 (默认: 空，使用随机密钥，多副本部署时必须配置)")
+		fmt.Println("  STAR_DIM_JWT_KEYS 用于轮换的令牌签名密钥，kid=secret 列表 (默认: 空)")
+		fmt.Println("  STAR_DIM_ACCESS_TOKEN_TTL 访问令牌有效期 (默认: 15m)")
+		fmt.Println("  STAR_DIM_REFRESH_TOKEN_TTL 刷新令牌有效期 (默认: 12h)")
+		fmt.Println("  STAR_DIM_COMMAND_TIMEOUT Slurm 命令默认超时时间 (默认: 1m)")
+		fmt.Println("  STAR_DIM_COMMAND_TIMEOUTS 按命令名设置的超时时间 (默认: 空)")
+		fmt.Println("  STAR_DIM_ARCHIVE_MAX_SIZE 目录打包下载的文件总大小上限，单位字节 (默认: 0，不限制)")
 		fmt.Println("\n示例:")
 		fmt.Printf("  %s -host 127.0.0.1 -port 9090\n", os.Args[0])
 		fmt.Printf("  STAR-DIM_HOST=192.168.1.100 STAR-DIM_PORT=8888 %s\n", os.Args[0])
 		return
 	}
 	conf := configs.Config{
-		Host: *host,
-		Port: *port,
+		Host:            *host,
+		Port:            *port,
+		ClusterFile:     *clusters,
+		AdminToken:      *adminToken,
+		HostKeyFile:     *hostKeys,
+		SessionIdleTTL:  *idleTTL,
+		SessionMaxTTL:   *maxTTL,
+		RedisURL:        *redisURL,
+		AdvertiseAddr:   *advertise,
+		JWTSecret:       *jwtSecret,
+		JWTKeys:         *jwtKeys,
+		AccessTokenTTL:  *accessTTL,
+		RefreshTokenTTL: *refreshTTL,
+		CommandTimeout:  *cmdTimeout,
+		CommandTimeouts: *timeouts,
+		ArchiveMaxSize:  *archiveMax,
 	}
 
 	// 构建监听地址
@@ -52,3 +96,25 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvDurationOrDefault 获取时长类型的环境变量（如 30m、12h），不存在或格式错误时返回默认值
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		log.Printf("invalid duration %s=%s, using default %s", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvInt64OrDefault 获取整数类型的环境变量，不存在或格式错误时返回默认值
+func getEnvInt64OrDefault(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+		log.Printf("invalid integer %s=%s, using default %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
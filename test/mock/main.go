@@ -2,17 +2,24 @@ package main
 
 import (
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 	"log"
 	"os"
+	"path/filepath"
 )
 
 func main() {
+	home, _ := os.UserHomeDir()
+	hostKeyCallback, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		log.Fatalf("Failed to load known_hosts: %v", err)
+	}
 	config := &ssh.ClientConfig{
 		User: "your-username",
 		Auth: []ssh.AuthMethod{
 			ssh.Password("your-password"),
 		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 仅用于测试
+		HostKeyCallback: hostKeyCallback,
 	}
 
 	client, err := ssh.Dial("tcp", "your-ssh-server:22", config)